@@ -6,10 +6,17 @@ import (
 	"os"
 	"runtime/debug"
 
+	"github.com/JarcauCristian/ztp-mcp/internal/cli"
 	"github.com/JarcauCristian/ztp-mcp/internal/server/middleware"
+	"github.com/JarcauCristian/ztp-mcp/internal/server/prompts"
 	"github.com/JarcauCristian/ztp-mcp/internal/server/registry"
+	"github.com/JarcauCristian/ztp-mcp/internal/server/resources"
 	"github.com/JarcauCristian/ztp-mcp/internal/server/tools"
+	"github.com/JarcauCristian/ztp-mcp/internal/server/tools/dns"
 	"github.com/JarcauCristian/ztp-mcp/internal/server/tools/fabrics"
+	"github.com/JarcauCristian/ztp-mcp/internal/server/tools/interfaces"
+	"github.com/JarcauCristian/ztp-mcp/internal/server/tools/spaces"
+	"github.com/JarcauCristian/ztp-mcp/internal/server/tools/storage"
 	"github.com/JarcauCristian/ztp-mcp/internal/server/tools/subnets"
 	"github.com/JarcauCristian/ztp-mcp/internal/server/tools/tags"
 	"github.com/JarcauCristian/ztp-mcp/internal/server/tools/vlans"
@@ -39,14 +46,31 @@ func registerTools(mcpServer *server.MCPServer) {
 		tools.Machines{},
 		tools.Power{},
 		tools.Templates{},
+		tools.Subscriptions{},
+		tools.Events{},
+		tools.Diagnostics{},
+		tools.Hardware{},
+		tools.Bulk{},
+		tools.Jobs{},
 		tags.Tags{},
 		tags.Tag{},
 		subnets.Subnets{},
 		subnets.Subnet{},
+		subnets.IPRanges{},
+		subnets.IPAddresses{},
+		spaces.Spaces{},
+		spaces.Space{},
+		dns.Domains{},
+		dns.Domain{},
+		dns.Records{},
 		fabrics.Fabrics{},
 		fabrics.Fabric{},
 		vlans.Vlans{},
 		vlans.Vlan{},
+		storage.Storage{},
+		interfaces.Interfaces{},
+		resources.Resources{},
+		prompts.Prompts{},
 	}
 
 	for _, reg := range registries {
@@ -55,6 +79,10 @@ func registerTools(mcpServer *server.MCPServer) {
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		os.Exit(cli.Run(os.Args[1:]))
+	}
+
 	var version string
 	info, ok := debug.ReadBuildInfo()
 
@@ -70,6 +98,7 @@ func main() {
 		server.WithInstructions("This server is used to communicate with the ZTP agent in order to deploy, interact and retrieve the status of machines inside an Ubuntu MAAS instance."),
 		server.WithToolCapabilities(true),
 		server.WithResourceCapabilities(true, true),
+		server.WithPromptCapabilities(true),
 	)
 
 	registerTools(mcpServer)
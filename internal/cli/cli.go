@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/JarcauCristian/ztp-mcp/internal/server/templates"
+)
+
+const usage = `Usage:
+  ztp-mcp                                      Start the MCP server
+  ztp-mcp template export [-o file] <id>       Export a template as a tar.gz bundle
+  ztp-mcp template import [-force] <file|->    Import a template bundle
+`
+
+// Run executes the CLI subcommand described by args and returns the process
+// exit code.
+func Run(args []string) int {
+	if len(args) < 2 || args[0] != "template" {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	var err error
+
+	switch args[1] {
+	case "export":
+		err = exportTemplate(args[2:])
+	case "import":
+		err = importTemplate(args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+func exportTemplate(args []string) error {
+	flags := flag.NewFlagSet("template export", flag.ContinueOnError)
+	output := flags.String("o", "", "Write the bundle to this file instead of <id>.tar.gz.")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("expected exactly one template id")
+	}
+
+	templateId := flags.Arg(0)
+
+	bundle, err := templates.ExportTemplate(templateId)
+	if err != nil {
+		return err
+	}
+
+	outputPath := *output
+	if outputPath == "" {
+		outputPath = templateId + ".tar.gz"
+	}
+
+	if outputPath == "-" {
+		_, err = os.Stdout.Write(bundle)
+		return err
+	}
+
+	if err := os.WriteFile(outputPath, bundle, 0644); err != nil {
+		return err
+	}
+
+	fmt.Printf("Exported template %s to %s\n", templateId, outputPath)
+	return nil
+}
+
+func importTemplate(args []string) error {
+	flags := flag.NewFlagSet("template import", flag.ContinueOnError)
+	force := flags.Bool("force", false, "Overwrite an existing template with the same id.")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("expected exactly one bundle file")
+	}
+
+	var bundle []byte
+	var err error
+
+	if flags.Arg(0) == "-" {
+		bundle, err = io.ReadAll(os.Stdin)
+	} else {
+		bundle, err = os.ReadFile(flags.Arg(0))
+	}
+	if err != nil {
+		return err
+	}
+
+	description, err := templates.ImportTemplate(bundle, *force)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Imported template %s\n", description.ID)
+	return nil
+}
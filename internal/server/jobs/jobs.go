@@ -0,0 +1,488 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	StateRunning   = "running"
+	StateSucceeded = "succeeded"
+	StateFailed    = "failed"
+	StateCancelled = "cancelled"
+)
+
+// Results of a single machine of a job. A machine is pending until its
+// operation is sent to MAAS.
+const (
+	ResultPending   = "pending"
+	ResultRunning   = "running"
+	ResultSucceeded = "succeeded"
+	ResultFailed    = "failed"
+	ResultCancelled = "cancelled"
+)
+
+const (
+	maxLogEntries = 200
+	retention     = 7 * 24 * time.Hour
+)
+
+var ErrNotFound = errors.New("job not found")
+
+// Operation is a MAAS machine operation a job can run, with the statuses that
+// tell the machine finished it.
+type Operation struct {
+	Success []string
+	Failure []string
+	Timeout time.Duration
+}
+
+var operations = map[string]Operation{
+	"commission": {
+		Success: []string{"Ready"},
+		Failure: []string{"Failed commissioning", "Failed testing", "Broken"},
+		Timeout: 30 * time.Minute,
+	},
+	"deploy": {
+		Success: []string{"Deployed"},
+		Failure: []string{"Failed deployment", "Broken"},
+		Timeout: time.Hour,
+	},
+	"release": {
+		Success: []string{"Ready"},
+		Failure: []string{"Failed releasing", "Failed disk erasing", "Broken"},
+		Timeout: time.Hour,
+	},
+}
+
+// waitFailures are the statuses after which a machine will not reach the
+// status a job waits for before sending its operation.
+var waitFailures = []string{"Failed commissioning", "Failed testing", "Broken"}
+
+type Target struct {
+	SystemID  string `json:"system_id"`
+	Hostname  string `json:"hostname,omitempty"`
+	Submitted bool   `json:"submitted"`
+	Sending   bool   `json:"sending,omitempty"`
+	Status    string `json:"status,omitempty"`
+	Result    string `json:"result"`
+	Detail    string `json:"detail,omitempty"`
+}
+
+func (t Target) finished() bool {
+	return t.Result != ResultPending && t.Result != ResultRunning
+}
+
+type Entry struct {
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+}
+
+// Job sends one operation to a set of machines and follows them until each
+// reaches a final status. When SendWhen is set the operation is only sent to
+// a machine once it reaches that status. The form is kept so that machines
+// which were not sent the operation yet can still get it after a restart.
+// Abort remembers whether a cancel asked to abort the machines whose
+// operation was still being sent.
+type Job struct {
+	ID        string     `json:"id"`
+	Tool      string     `json:"tool"`
+	Operation string     `json:"operation"`
+	SendWhen  string     `json:"send_when,omitempty"`
+	Form      url.Values `json:"form,omitempty"`
+	State     string     `json:"state"`
+	Error     string     `json:"error,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Deadline  time.Time  `json:"deadline"`
+	Abort     bool       `json:"abort,omitempty"`
+	Targets   []Target   `json:"targets"`
+	Log       []Entry    `json:"log"`
+}
+
+// Progress counts the machines of the job by result.
+func (j Job) Progress() map[string]int {
+	progress := map[string]int{"total": len(j.Targets)}
+	for _, target := range j.Targets {
+		progress[target.Result]++
+	}
+	return progress
+}
+
+func (j *Job) logf(format string, args ...any) {
+	j.Log = append(j.Log, Entry{Time: time.Now().UTC(), Message: fmt.Sprintf(format, args...)})
+	if len(j.Log) > maxLogEntries {
+		j.Log = j.Log[len(j.Log)-maxLogEntries:]
+	}
+}
+
+func (j *Job) clone() Job {
+	job := *j
+	job.Form = maps.Clone(j.Form)
+	job.Targets = slices.Clone(j.Targets)
+	job.Log = slices.Clone(j.Log)
+	return job
+}
+
+// Manager runs the jobs and keeps them in a JSON file, so that the jobs that
+// were running when the server stopped are resumed when it starts again.
+type Manager struct {
+	mu      sync.Mutex
+	path    string
+	jobs    map[string]*Job
+	cancels map[string]context.CancelFunc
+}
+
+var (
+	manager *Manager
+	once    sync.Once
+)
+
+// Default returns the job manager of the server. The first call loads the
+// job store and resumes the jobs that were still running.
+func Default() *Manager {
+	once.Do(func() {
+		manager = &Manager{
+			path:    storePath(),
+			jobs:    make(map[string]*Job),
+			cancels: make(map[string]context.CancelFunc),
+		}
+		manager.load()
+		manager.resume()
+	})
+	return manager
+}
+
+// storePath returns ZTP_JOBS_FILE, or ztp-mcp/jobs.json in the user
+// configuration directory. It returns an empty path, and jobs are only kept in
+// memory, when neither is available.
+func storePath() string {
+	if path := os.Getenv("ZTP_JOBS_FILE"); path != "" {
+		return path
+	}
+
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		zap.L().Warn(fmt.Sprintf("[Jobs] No configuration directory and ZTP_JOBS_FILE is not set, jobs will not survive a restart err=%v", err))
+		return ""
+	}
+
+	return filepath.Join(configDir, "ztp-mcp", "jobs.json")
+}
+
+func (m *Manager) load() {
+	if m.path == "" {
+		return
+	}
+
+	content, err := os.ReadFile(m.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[Jobs] Failed to read the job store %s err=%v", m.path, err))
+		return
+	}
+
+	var stored []*Job
+	if err := json.Unmarshal(content, &stored); err != nil {
+		zap.L().Error(fmt.Sprintf("[Jobs] Failed to parse the job store %s err=%v", m.path, err))
+		return
+	}
+
+	for _, job := range stored {
+		if job.State != StateRunning && time.Since(job.UpdatedAt) > retention {
+			continue
+		}
+		interrupted(job)
+		m.jobs[job.ID] = job
+	}
+
+	zap.L().Info(fmt.Sprintf("[Jobs] Loaded %d jobs from %s", len(m.jobs), m.path))
+}
+
+// interrupted settles the machines whose operation was being sent when the
+// server stopped. MAAS may or may not have received it: a running job sends
+// it again, a cancelled one marks the machine cancelled.
+func interrupted(job *Job) {
+	for i := range job.Targets {
+		target := &job.Targets[i]
+		if !target.Sending {
+			continue
+		}
+
+		target.Sending = false
+		if job.State == StateRunning {
+			job.logf("%s: the server stopped while sending %s, sending it again", target.SystemID, job.Operation)
+			continue
+		}
+
+		target.Result = ResultCancelled
+		target.Detail = fmt.Sprintf("the server stopped while sending %s, check the machine", job.Operation)
+	}
+}
+
+// save writes every job to the store, readable by the owner only. The caller
+// holds the lock.
+func (m *Manager) save() {
+	if m.path == "" {
+		return
+	}
+
+	stored := make([]*Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		stored = append(stored, job)
+	}
+	slices.SortFunc(stored, func(a, b *Job) int { return a.CreatedAt.Compare(b.CreatedAt) })
+
+	content, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[Jobs] Failed to marshal the jobs err=%v", err))
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(m.path), 0700); err != nil {
+		zap.L().Error(fmt.Sprintf("[Jobs] Failed to create the job store directory %s err=%v", filepath.Dir(m.path), err))
+		return
+	}
+
+	staging := m.path + ".tmp"
+	if err := os.WriteFile(staging, content, 0600); err != nil {
+		zap.L().Error(fmt.Sprintf("[Jobs] Failed to write the job store %s err=%v", staging, err))
+		return
+	}
+
+	if err := os.Rename(staging, m.path); err != nil {
+		zap.L().Error(fmt.Sprintf("[Jobs] Failed to replace the job store %s err=%v", m.path, err))
+	}
+}
+
+func (m *Manager) resume() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, job := range m.jobs {
+		if job.State != StateRunning {
+			continue
+		}
+
+		job.logf("server restarted, resuming the job")
+		zap.L().Info(fmt.Sprintf("[Jobs] Resuming job %s (%s)", id, job.Operation))
+		m.start(id)
+	}
+
+	m.save()
+}
+
+// start runs the job in the background. The caller holds the lock.
+func (m *Manager) start(id string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancels[id] = cancel
+	go m.run(ctx, id)
+}
+
+func newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Submit creates a job that sends op with form to every target and follows
+// the machines until they finish or timeout passes. A zero timeout uses the
+// default of the operation.
+func (m *Manager) Submit(tool, op string, form url.Values, targets []Target, timeout time.Duration) (Job, error) {
+	return m.SubmitWhen(tool, op, "", form, targets, timeout)
+}
+
+// SubmitWhen is Submit for machines that are still on their way to the
+// status sendWhen, such as a VM that commissions before it can be deployed.
+// The timeout covers the wait as well.
+func (m *Manager) SubmitWhen(tool, op, sendWhen string, form url.Values, targets []Target, timeout time.Duration) (Job, error) {
+	operation, ok := operations[op]
+	if !ok {
+		return Job{}, fmt.Errorf("unsupported job operation %s", op)
+	}
+
+	if len(targets) == 0 {
+		return Job{}, fmt.Errorf("the job has no machines")
+	}
+
+	if timeout <= 0 {
+		timeout = operation.Timeout
+	}
+
+	id, err := newID()
+	if err != nil {
+		return Job{}, fmt.Errorf("failed to generate a job id: %w", err)
+	}
+
+	now := time.Now().UTC()
+	job := &Job{
+		ID:        id,
+		Tool:      tool,
+		Operation: op,
+		SendWhen:  sendWhen,
+		Form:      form,
+		State:     StateRunning,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Deadline:  now.Add(timeout),
+		Targets:   make([]Target, len(targets)),
+	}
+
+	for i, target := range targets {
+		job.Targets[i] = Target{SystemID: target.SystemID, Hostname: target.Hostname, Result: ResultPending}
+	}
+
+	if sendWhen != "" {
+		job.logf("job created to %s %d machines once they are %s", op, len(targets), sendWhen)
+	} else {
+		job.logf("job created to %s %d machines", op, len(targets))
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.jobs[id] = job
+	m.start(id)
+	m.save()
+
+	zap.L().Info(fmt.Sprintf("[Jobs] Started job %s to %s %d machines", id, op, len(targets)))
+
+	return job.clone(), nil
+}
+
+func (m *Manager) Get(id string) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+
+	return job.clone(), nil
+}
+
+// List returns the jobs in the given state, or every job when state is
+// empty, newest first.
+func (m *Manager) List(state string) []Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	jobs := make([]Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		if state == "" || job.State == state {
+			jobs = append(jobs, job.clone())
+		}
+	}
+
+	slices.SortFunc(jobs, func(a, b Job) int { return b.CreatedAt.Compare(a.CreatedAt) })
+
+	return jobs
+}
+
+// Cancel stops following a running job. The machines that did not finish
+// are marked cancelled and, when abort is set, the ones MAAS is still
+// working on are sent an abort. The machines whose operation is being sent
+// stay pending until MAAS answers; they are then marked cancelled and aborted
+// like the others.
+func (m *Manager) Cancel(ctx context.Context, id string, abort bool) (Job, error) {
+	m.mu.Lock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		m.mu.Unlock()
+		return Job{}, ErrNotFound
+	}
+
+	if job.State != StateRunning {
+		m.mu.Unlock()
+		return Job{}, fmt.Errorf("job %s is already %s", id, job.State)
+	}
+
+	if cancel, ok := m.cancels[id]; ok {
+		cancel()
+		delete(m.cancels, id)
+	}
+
+	var inProgress []string
+	sending := 0
+	for i := range job.Targets {
+		target := &job.Targets[i]
+		if target.finished() {
+			continue
+		}
+		if target.Sending {
+			target.Detail = fmt.Sprintf("cancel pending, %s is being sent", job.Operation)
+			sending++
+			continue
+		}
+		if target.Submitted {
+			inProgress = append(inProgress, target.SystemID)
+		}
+		target.Result = ResultCancelled
+	}
+
+	job.State = StateCancelled
+	job.Abort = abort
+	job.UpdatedAt = time.Now().UTC()
+	if sending > 0 {
+		job.logf("job cancelled, waiting for MAAS to answer for %d machines", sending)
+	} else {
+		job.logf("job cancelled")
+	}
+	m.save()
+	m.mu.Unlock()
+
+	if abort {
+		for _, systemID := range inProgress {
+			m.abort(ctx, id, systemID)
+		}
+	}
+
+	return m.Get(id)
+}
+
+// abort sends an abort to a machine of the job and logs the outcome.
+func (m *Manager) abort(ctx context.Context, id, systemID string) {
+	err := abortMachine(ctx, systemID)
+
+	m.update(id, func(job *Job) {
+		if err != nil {
+			job.logf("%s: failed to abort err=%v", systemID, err)
+		} else {
+			job.logf("%s: aborted", systemID)
+		}
+	})
+}
+
+// update applies change to the job under the lock and saves the store.
+func (m *Manager) update(id string, change func(job *Job)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return
+	}
+
+	change(job)
+	job.UpdatedAt = time.Now().UTC()
+	m.save()
+}
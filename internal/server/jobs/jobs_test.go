@@ -0,0 +1,179 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testManager(t *testing.T) *Manager {
+	t.Helper()
+
+	return &Manager{
+		path:    filepath.Join(t.TempDir(), "ztp-jobs.json"),
+		jobs:    make(map[string]*Job),
+		cancels: make(map[string]context.CancelFunc),
+	}
+}
+
+func testJob(id, state string, updated time.Time, targets ...Target) *Job {
+	return &Job{ID: id, Operation: "commission", State: state, CreatedAt: updated, UpdatedAt: updated, Deadline: updated.Add(time.Hour), Targets: targets}
+}
+
+func TestStoreRoundTrip(t *testing.T) {
+	m := testManager(t)
+	now := time.Now().UTC()
+
+	m.jobs["running"] = testJob("running", StateRunning, now.Add(-30*24*time.Hour),
+		Target{SystemID: "aaa111", Result: ResultPending, Sending: true},
+		Target{SystemID: "bbb222", Result: ResultRunning, Submitted: true},
+	)
+	m.jobs["cancelled"] = testJob("cancelled", StateCancelled, now,
+		Target{SystemID: "ccc333", Result: ResultPending, Sending: true},
+	)
+	m.jobs["expired"] = testJob("expired", StateSucceeded, now.Add(-30*24*time.Hour),
+		Target{SystemID: "ddd444", Result: ResultSucceeded},
+	)
+	m.save()
+
+	loaded := testManager(t)
+	loaded.path = m.path
+	loaded.load()
+
+	if _, err := loaded.Get("expired"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expired job was loaded, err = %v", err)
+	}
+
+	running, err := loaded.Get("running")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target := running.Targets[0]; target.Sending || target.Result != ResultPending {
+		t.Errorf("interrupted target of a running job = %+v, want it pending to be sent again", target)
+	}
+	if target := running.Targets[1]; !target.Submitted || target.Result != ResultRunning {
+		t.Errorf("submitted target = %+v, want it unchanged", target)
+	}
+
+	cancelled, err := loaded.Get("cancelled")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target := cancelled.Targets[0]; target.Sending || target.Result != ResultCancelled || target.Detail == "" {
+		t.Errorf("interrupted target of a cancelled job = %+v, want it cancelled with a detail", target)
+	}
+}
+
+func TestList(t *testing.T) {
+	m := testManager(t)
+	now := time.Now().UTC()
+
+	m.jobs["old"] = testJob("old", StateRunning, now.Add(-time.Hour))
+	m.jobs["new"] = testJob("new", StateRunning, now)
+	m.jobs["done"] = testJob("done", StateFailed, now.Add(-time.Minute))
+
+	var ids []string
+	for _, job := range m.List(StateRunning) {
+		ids = append(ids, job.ID)
+	}
+	if len(ids) != 2 || ids[0] != "new" || ids[1] != "old" {
+		t.Errorf("running jobs = %v, want [new old]", ids)
+	}
+
+	if jobs := m.List(""); len(jobs) != 3 {
+		t.Errorf("listed %d jobs, want 3", len(jobs))
+	}
+}
+
+func TestCancelWhileSending(t *testing.T) {
+	m := testManager(t)
+	now := time.Now().UTC()
+
+	m.jobs["job"] = testJob("job", StateRunning, now,
+		Target{SystemID: "aaa111", Result: ResultSucceeded, Submitted: true},
+		Target{SystemID: "bbb222", Result: ResultPending},
+		Target{SystemID: "ccc333", Result: ResultPending, Sending: true},
+		Target{SystemID: "ddd444", Result: ResultPending, Sending: true},
+	)
+
+	job, err := m.Cancel(context.Background(), "job", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{ResultSucceeded, ResultCancelled, ResultPending, ResultPending}
+	for i, target := range job.Targets {
+		if target.Result != want[i] {
+			t.Errorf("after the cancel %s = %s, want %s", target.SystemID, target.Result, want[i])
+		}
+	}
+	if job.State != StateCancelled {
+		t.Errorf("state = %s, want %s", job.State, StateCancelled)
+	}
+
+	m.sent("job", 2, `{"status_name": "Commissioning"}`, nil)
+	m.sent("job", 3, "", errors.New("MAAS API returned status 503"))
+
+	job, err = m.Get("job")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if target := job.Targets[2]; target.Sending || !target.Submitted || target.Result != ResultCancelled || target.Status != "Commissioning" {
+		t.Errorf("target sent before the cancel = %+v, want it submitted and cancelled", target)
+	}
+	if target := job.Targets[3]; target.Sending || target.Submitted || target.Result != ResultCancelled {
+		t.Errorf("target that failed to send = %+v, want it cancelled", target)
+	}
+	if _, err := m.Cancel(context.Background(), "job", false); err == nil {
+		t.Error("cancelling a cancelled job succeeded")
+	}
+}
+
+func TestStorePath(t *testing.T) {
+	configDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configDir)
+
+	t.Setenv("ZTP_JOBS_FILE", "/var/lib/ztp-mcp/jobs.json")
+	if got := storePath(); got != "/var/lib/ztp-mcp/jobs.json" {
+		t.Errorf("storePath with ZTP_JOBS_FILE = %s, want /var/lib/ztp-mcp/jobs.json", got)
+	}
+
+	t.Setenv("ZTP_JOBS_FILE", "")
+	if got, want := storePath(), filepath.Join(configDir, "ztp-mcp", "jobs.json"); got != want {
+		t.Errorf("storePath = %s, want %s", got, want)
+	}
+}
+
+func TestSavePermissions(t *testing.T) {
+	m := testManager(t)
+	m.path = filepath.Join(t.TempDir(), "ztp-mcp", "jobs.json")
+	m.jobs["job"] = testJob("job", StateSucceeded, time.Now().UTC())
+	m.save()
+
+	for path, want := range map[string]os.FileMode{filepath.Dir(m.path): 0700, m.path: 0600} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode().Perm(); got != want {
+			t.Errorf("%s mode = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestWithoutStore(t *testing.T) {
+	m := testManager(t)
+	m.path = ""
+	m.jobs["job"] = testJob("job", StateSucceeded, time.Now().UTC())
+
+	m.save()
+	m.load()
+
+	if _, err := m.Get("job"); err != nil {
+		t.Errorf("job kept in memory: %v", err)
+	}
+}
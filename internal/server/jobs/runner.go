@@ -0,0 +1,298 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/JarcauCristian/ztp-mcp/internal/server/maas_client"
+	"go.uber.org/zap"
+)
+
+const (
+	pollInterval   = 15 * time.Second
+	requestTimeout = 30 * time.Second
+)
+
+// run sends the operation to the machines that did not get it yet and polls
+// MAAS until every machine finished, the deadline passes or the job is
+// cancelled.
+func (m *Manager) run(ctx context.Context, id string) {
+	client, err := maas_client.GetClient()
+	if err != nil {
+		m.progress(id, func(job *Job) {
+			m.finish(job, fmt.Sprintf("failed to create the MAAS client: %v", err))
+		})
+		return
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		m.submitPending(ctx, client, id)
+
+		if m.poll(ctx, client, id) {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// progress applies change to the job only while it is still running, so that
+// a request finishing after a cancel does not overwrite it.
+func (m *Manager) progress(id string, change func(job *Job)) {
+	m.update(id, func(job *Job) {
+		if job.State == StateRunning {
+			change(job)
+		}
+	})
+}
+
+func (m *Manager) submitPending(ctx context.Context, client *maas_client.MAASClient, id string) {
+	job, err := m.Get(id)
+	if err != nil {
+		return
+	}
+
+	for i, target := range job.Targets {
+		if target.Submitted || target.finished() {
+			continue
+		}
+
+		if job.SendWhen != "" && !m.reached(ctx, client, id, i, job.SendWhen) {
+			continue
+		}
+
+		sending := false
+		m.progress(id, func(job *Job) {
+			job.Targets[i].Sending = true
+			sending = true
+		})
+		if !sending {
+			return
+		}
+
+		// A cancel does not interrupt the request: MAAS may act on it
+		// anyway, so its answer is recorded either way.
+		requestCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), requestTimeout)
+		path := fmt.Sprintf("/MAAS/api/2.0/machines/%s/op-%s", target.SystemID, job.Operation)
+		resultData, err := client.Do(requestCtx, maas_client.RequestTypePost, path, strings.NewReader(job.Form.Encode()))
+		cancel()
+
+		m.sent(id, i, resultData, err)
+
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// sent records the answer of MAAS to the operation sent to a machine. When
+// the job was cancelled in the meantime the machine ends cancelled and, if
+// the cancel asked for it, is aborted.
+func (m *Manager) sent(id string, i int, resultData string, err error) {
+	abort := ""
+
+	m.update(id, func(job *Job) {
+		target := &job.Targets[i]
+		target.Sending = false
+		cancelled := job.State != StateRunning
+
+		if err != nil {
+			zap.L().Error(fmt.Sprintf("[Jobs] Failed to %s machine %s in job %s err=%v", job.Operation, target.SystemID, id, err))
+			job.logf("%s: failed to %s: %v", target.SystemID, job.Operation, err)
+			target.Result = ResultFailed
+			target.Detail = err.Error()
+			if cancelled {
+				target.Result = ResultCancelled
+			}
+			return
+		}
+
+		target.Submitted = true
+		target.Status = statusOf(resultData)
+		job.logf("%s: %s sent, status %s", target.SystemID, job.Operation, target.Status)
+
+		if !cancelled {
+			target.Result = ResultRunning
+			return
+		}
+
+		target.Result = ResultCancelled
+		target.Detail = fmt.Sprintf("cancelled after %s was sent", job.Operation)
+		if job.Abort {
+			abort = target.SystemID
+		}
+	})
+
+	if abort != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		defer cancel()
+
+		m.abort(ctx, id, abort)
+	}
+}
+
+// reached reports whether a pending machine of the job has the status the
+// job waits for, and fails the machine when it will not get there.
+func (m *Manager) reached(ctx context.Context, client *maas_client.MAASClient, id string, i int, want string) bool {
+	job, err := m.Get(id)
+	if err != nil {
+		return false
+	}
+
+	target := job.Targets[i]
+
+	requestCtx, cancel := context.WithTimeout(ctx, requestTimeout)
+	resultData, err := client.Do(requestCtx, maas_client.RequestTypeGet, fmt.Sprintf("/MAAS/api/2.0/machines/%s/", target.SystemID), nil)
+	cancel()
+
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[Jobs] Failed to poll machine %s in job %s err=%v", target.SystemID, id, err))
+		return false
+	}
+
+	status := statusOf(resultData)
+
+	m.progress(id, func(job *Job) {
+		target := &job.Targets[i]
+
+		if status != target.Status {
+			job.logf("%s: waiting for %s, %s -> %s", target.SystemID, want, target.Status, status)
+			target.Status = status
+		}
+
+		if slices.Contains(waitFailures, status) {
+			target.Result = ResultFailed
+			target.Detail = fmt.Sprintf("machine is %s instead of %s", status, want)
+		}
+	})
+
+	return status == want
+}
+
+// poll refreshes the status of the running machines of the job and reports
+// whether the job is over.
+func (m *Manager) poll(ctx context.Context, client *maas_client.MAASClient, id string) bool {
+	job, err := m.Get(id)
+	if err != nil || job.State != StateRunning {
+		return true
+	}
+
+	operation := operations[job.Operation]
+
+	for i, target := range job.Targets {
+		if target.Result != ResultRunning {
+			continue
+		}
+
+		requestCtx, cancel := context.WithTimeout(ctx, requestTimeout)
+		resultData, err := client.Do(requestCtx, maas_client.RequestTypeGet, fmt.Sprintf("/MAAS/api/2.0/machines/%s/", target.SystemID), nil)
+		cancel()
+
+		if ctx.Err() != nil {
+			return true
+		}
+
+		if err != nil {
+			zap.L().Error(fmt.Sprintf("[Jobs] Failed to poll machine %s in job %s err=%v", target.SystemID, id, err))
+			continue
+		}
+
+		status := statusOf(resultData)
+
+		m.progress(id, func(job *Job) {
+			target := &job.Targets[i]
+
+			if status != target.Status {
+				job.logf("%s: %s -> %s", target.SystemID, target.Status, status)
+				target.Status = status
+			}
+
+			switch {
+			case slices.Contains(operation.Success, status):
+				target.Result = ResultSucceeded
+				target.Detail = ""
+			case slices.Contains(operation.Failure, status):
+				target.Result = ResultFailed
+				target.Detail = fmt.Sprintf("machine is %s", status)
+			}
+		})
+	}
+
+	done := true
+	m.progress(id, func(job *Job) {
+		expired := time.Now().After(job.Deadline)
+
+		for i := range job.Targets {
+			target := &job.Targets[i]
+			if target.finished() {
+				continue
+			}
+
+			if !expired {
+				done = false
+				return
+			}
+
+			target.Result = ResultFailed
+			target.Detail = fmt.Sprintf("timed out while %s", target.Status)
+		}
+
+		if expired {
+			m.finish(job, "the job timed out")
+		} else {
+			m.finish(job, "")
+		}
+	})
+
+	return done
+}
+
+// finish ends the job. The caller holds the lock.
+func (m *Manager) finish(job *Job, reason string) {
+	progress := job.Progress()
+
+	job.State = StateSucceeded
+	if reason != "" || progress[ResultFailed] > 0 {
+		job.State = StateFailed
+	}
+	job.Error = reason
+
+	if cancel, ok := m.cancels[job.ID]; ok {
+		cancel()
+		delete(m.cancels, job.ID)
+	}
+
+	job.logf("job %s: %d succeeded, %d failed", job.State, progress[ResultSucceeded], progress[ResultFailed])
+	zap.L().Info(fmt.Sprintf("[Jobs] Job %s %s", job.ID, job.State))
+}
+
+func abortMachine(ctx context.Context, systemID string) error {
+	client, err := maas_client.GetClient()
+	if err != nil {
+		return err
+	}
+
+	_, err = client.Do(ctx, maas_client.RequestTypePost, fmt.Sprintf("/MAAS/api/2.0/machines/%s/op-abort", systemID), strings.NewReader(""))
+	return err
+}
+
+func statusOf(resultData string) string {
+	var machine map[string]any
+	if err := json.Unmarshal([]byte(resultData), &machine); err != nil {
+		return ""
+	}
+
+	status, _ := machine["status_name"].(string)
+	return status
+}
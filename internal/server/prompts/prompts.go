@@ -0,0 +1,187 @@
+package prompts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+)
+
+// Playbook is a prompt definition loaded from a JSON file. The content of
+// every message is a Go text/template rendered with the prompt arguments.
+type Playbook struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Arguments   []Argument `json:"arguments"`
+	Messages    []Message  `json:"messages"`
+}
+
+type Argument struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Required    bool   `json:"required"`
+}
+
+type Message struct {
+	Role    string   `json:"role"`
+	Content []string `json:"content"`
+}
+
+type Prompts struct{}
+
+func (Prompts) Register(mcpServer *server.MCPServer) {
+	playbooks, err := LoadPlaybooks()
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[Prompts] Failed to load playbooks err=%v", err))
+		return
+	}
+
+	for _, playbook := range playbooks {
+		mcpServer.AddPrompt(playbook.Prompt(), playbook.Handle)
+	}
+
+	zap.L().Info(fmt.Sprintf("[Prompts] Registered %d prompts", len(playbooks)))
+}
+
+// LoadPlaybooks reads the built-in playbooks followed by the ones found in
+// ZTP_PROMPTS_DIR. A site playbook replaces a built-in one with the same name.
+func LoadPlaybooks() ([]Playbook, error) {
+	currentDir, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("error getting working directory")
+	}
+
+	directories := []string{filepath.Join(currentDir, "internal/server/prompts/playbooks")}
+	if promptsDir := os.Getenv("ZTP_PROMPTS_DIR"); promptsDir != "" {
+		directories = append(directories, promptsDir)
+	}
+
+	playbooks := make(map[string]Playbook)
+
+	for _, directory := range directories {
+		entries, err := os.ReadDir(directory)
+		if err != nil {
+			zap.L().Error(fmt.Sprintf("error reading directory %s: %v", directory, err))
+			continue
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+				continue
+			}
+
+			playbookPath := filepath.Join(directory, entry.Name())
+
+			playbook, err := readPlaybook(playbookPath)
+			if err != nil {
+				zap.L().Error(fmt.Sprintf("error loading playbook %s: %v", playbookPath, err))
+				continue
+			}
+
+			playbooks[playbook.Name] = playbook
+		}
+	}
+
+	names := make([]string, 0, len(playbooks))
+	for name := range playbooks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := make([]Playbook, 0, len(names))
+	for _, name := range names {
+		result = append(result, playbooks[name])
+	}
+
+	return result, nil
+}
+
+func readPlaybook(playbookPath string) (Playbook, error) {
+	var playbook Playbook
+
+	fileData, err := os.ReadFile(playbookPath)
+	if err != nil {
+		return playbook, err
+	}
+
+	if err := json.Unmarshal(fileData, &playbook); err != nil {
+		return playbook, fmt.Errorf("error parsing JSON: %w", err)
+	}
+
+	if playbook.Name == "" {
+		return playbook, fmt.Errorf("playbook has no name")
+	}
+
+	if len(playbook.Messages) == 0 {
+		return playbook, fmt.Errorf("playbook %s has no messages", playbook.Name)
+	}
+
+	for _, message := range playbook.Messages {
+		if message.Role != string(mcp.RoleUser) && message.Role != string(mcp.RoleAssistant) {
+			return playbook, fmt.Errorf("playbook %s has a message with invalid role %q", playbook.Name, message.Role)
+		}
+
+		if _, err := template.New(playbook.Name).Parse(strings.Join(message.Content, "\n")); err != nil {
+			return playbook, fmt.Errorf("playbook %s has an invalid message template: %w", playbook.Name, err)
+		}
+	}
+
+	return playbook, nil
+}
+
+func (p Playbook) Prompt() mcp.Prompt {
+	options := []mcp.PromptOption{mcp.WithPromptDescription(p.Description)}
+
+	for _, argument := range p.Arguments {
+		argumentOptions := []mcp.ArgumentOption{mcp.ArgumentDescription(argument.Description)}
+		if argument.Required {
+			argumentOptions = append(argumentOptions, mcp.RequiredArgument())
+		}
+
+		options = append(options, mcp.WithArgument(argument.Name, argumentOptions...))
+	}
+
+	return mcp.NewPrompt(p.Name, options...)
+}
+
+func (p Playbook) Handle(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	arguments := make(map[string]string, len(p.Arguments))
+
+	for _, argument := range p.Arguments {
+		value := strings.TrimSpace(request.Params.Arguments[argument.Name])
+		if value == "" && argument.Required {
+			return nil, fmt.Errorf("required argument %s not present", argument.Name)
+		}
+
+		arguments[argument.Name] = value
+	}
+
+	messages := make([]mcp.PromptMessage, 0, len(p.Messages))
+
+	for _, message := range p.Messages {
+		tmpl, err := template.New(p.Name).Option("missingkey=zero").Parse(strings.Join(message.Content, "\n"))
+		if err != nil {
+			zap.L().Error(fmt.Sprintf("[Prompts] Failed to parse playbook %s err=%v", p.Name, err))
+			return nil, err
+		}
+
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, arguments); err != nil {
+			zap.L().Error(fmt.Sprintf("[Prompts] Failed to render playbook %s err=%v", p.Name, err))
+			return nil, err
+		}
+
+		messages = append(messages, mcp.NewPromptMessage(mcp.Role(message.Role), mcp.NewTextContent(buf.String())))
+	}
+
+	return mcp.NewGetPromptResult(p.Description, messages), nil
+}
@@ -0,0 +1,216 @@
+package prompts
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// inPlaybooksDir runs the test from a temporary working directory holding an
+// empty internal/server/prompts/playbooks tree and returns that tree.
+func inPlaybooksDir(t *testing.T) string {
+	t.Helper()
+
+	root := t.TempDir()
+	playbooksDir := filepath.Join(root, "internal/server/prompts/playbooks")
+	if err := os.MkdirAll(playbooksDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	previous, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(previous) })
+
+	return playbooksDir
+}
+
+func writePlaybook(t *testing.T, dir, name, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBuiltinPlaybooks(t *testing.T) {
+	previous, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir("../../.."); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(previous) })
+	t.Setenv("ZTP_PROMPTS_DIR", "")
+
+	entries, err := os.ReadDir("internal/server/prompts/playbooks")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	playbooks, err := LoadPlaybooks()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(playbooks) != len(entries) {
+		t.Fatalf("loaded %d playbooks from %d files, every built-in playbook must be valid", len(playbooks), len(entries))
+	}
+}
+
+func TestLoadPlaybooks(t *testing.T) {
+	playbooksDir := inPlaybooksDir(t)
+	siteDir := t.TempDir()
+	t.Setenv("ZTP_PROMPTS_DIR", siteDir)
+
+	writePlaybook(t, playbooksDir, "b.json", `{"name": "b", "description": "Built-in b.", "messages": [{"role": "user", "content": ["b"]}]}`)
+	writePlaybook(t, playbooksDir, "a.json", `{"name": "a", "description": "Built-in a.", "messages": [{"role": "user", "content": ["a"]}]}`)
+	writePlaybook(t, playbooksDir, "notes.txt", `not a playbook`)
+	writePlaybook(t, siteDir, "a.json", `{"name": "a", "description": "Site a.", "messages": [{"role": "user", "content": ["site a"]}]}`)
+	writePlaybook(t, siteDir, "broken.json", `{"name": "broken", "messages": []}`)
+
+	playbooks, err := LoadPlaybooks()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, playbook := range playbooks {
+		names = append(names, playbook.Name)
+	}
+	if strings.Join(names, ",") != "a,b" {
+		t.Fatalf("playbooks = %v, want [a b]", names)
+	}
+
+	if playbooks[0].Description != "Site a." {
+		t.Errorf("playbook a = %q, want the site playbook to replace the built-in one", playbooks[0].Description)
+	}
+}
+
+func TestReadPlaybook(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{"valid", `{"name": "p", "messages": [{"role": "assistant", "content": ["{{ .x }}"]}]}`, ""},
+		{"invalid json", `{"name": `, "error parsing JSON"},
+		{"no name", `{"messages": [{"role": "user", "content": ["hi"]}]}`, "has no name"},
+		{"no messages", `{"name": "p"}`, "has no messages"},
+		{"invalid role", `{"name": "p", "messages": [{"role": "system", "content": ["hi"]}]}`, "invalid role"},
+		{"invalid template", `{"name": "p", "messages": [{"role": "user", "content": ["{{ .x "]}]}`, "invalid message template"},
+	}
+
+	dir := t.TempDir()
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(dir, strings.ReplaceAll(test.name, " ", "_")+".json")
+			writePlaybook(t, dir, filepath.Base(path), test.content)
+
+			_, err := readPlaybook(path)
+			if test.err == "" {
+				if err != nil {
+					t.Fatalf("readPlaybook = %v, want nil", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("readPlaybook = %v, want an error containing %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestPlaybookPrompt(t *testing.T) {
+	playbook := Playbook{
+		Name:        "p",
+		Description: "A playbook.",
+		Arguments: []Argument{
+			{Name: "cidr", Description: "The CIDR.", Required: true},
+			{Name: "vid", Description: "The VLAN ID."},
+		},
+	}
+
+	prompt := playbook.Prompt()
+
+	if prompt.Name != "p" || prompt.Description != "A playbook." {
+		t.Errorf("prompt = %q %q, want p and its description", prompt.Name, prompt.Description)
+	}
+	if len(prompt.Arguments) != 2 || !prompt.Arguments[0].Required || prompt.Arguments[1].Required {
+		t.Errorf("arguments = %+v, want cidr required and vid optional", prompt.Arguments)
+	}
+}
+
+func TestPlaybookHandle(t *testing.T) {
+	playbook := Playbook{
+		Name: "p",
+		Arguments: []Argument{
+			{Name: "cidr", Required: true},
+			{Name: "vid"},
+		},
+		Messages: []Message{
+			{Role: "user", Content: []string{"Plan {{ .cidr }}{{ if .vid }} in VLAN {{ .vid }}{{ end }}.", "Then report."}},
+			{Role: "assistant", Content: []string{"Planning {{ .cidr }}."}},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		arguments map[string]string
+		want      []string
+		err       bool
+	}{
+		{"all arguments", map[string]string{"cidr": "10.0.0.0/24", "vid": "20"}, []string{"Plan 10.0.0.0/24 in VLAN 20.\nThen report.", "Planning 10.0.0.0/24."}, false},
+		{"optional argument omitted", map[string]string{"cidr": "10.0.0.0/24"}, []string{"Plan 10.0.0.0/24.\nThen report.", "Planning 10.0.0.0/24."}, false},
+		{"values are trimmed", map[string]string{"cidr": " 10.0.0.0/24 ", "vid": "  "}, []string{"Plan 10.0.0.0/24.\nThen report.", "Planning 10.0.0.0/24."}, false},
+		{"unknown arguments are ignored", map[string]string{"cidr": "10.0.0.0/24", "other": "x"}, []string{"Plan 10.0.0.0/24.\nThen report.", "Planning 10.0.0.0/24."}, false},
+		{"required argument missing", map[string]string{"vid": "20"}, nil, true},
+		{"required argument blank", map[string]string{"cidr": " "}, nil, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var request mcp.GetPromptRequest
+			request.Params.Arguments = test.arguments
+
+			result, err := playbook.Handle(context.Background(), request)
+			if test.err {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(result.Messages) != len(test.want) {
+				t.Fatalf("got %d messages, want %d", len(result.Messages), len(test.want))
+			}
+
+			for i, message := range result.Messages {
+				text, ok := message.Content.(mcp.TextContent)
+				if !ok {
+					t.Fatalf("message %d content is %T, want text", i, message.Content)
+				}
+				if text.Text != test.want[i] {
+					t.Errorf("message %d = %q, want %q", i, text.Text, test.want[i])
+				}
+				if message.Role != mcp.Role(playbook.Messages[i].Role) {
+					t.Errorf("message %d role = %s, want %s", i, message.Role, playbook.Messages[i].Role)
+				}
+			}
+		})
+	}
+}
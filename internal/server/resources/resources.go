@@ -0,0 +1,240 @@
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/JarcauCristian/ztp-mcp/internal/server/maas_client"
+	"github.com/JarcauCristian/ztp-mcp/internal/server/parser"
+	"github.com/JarcauCristian/ztp-mcp/internal/server/templates"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+)
+
+const (
+	TemplatesURI       = "ztp://templates"
+	TemplateURI        = "ztp://templates/%s"
+	TemplateContentURI = "ztp://templates/%s/content"
+	MachineURI         = "ztp://machines/%s"
+)
+
+const (
+	jsonMIMEType        = "application/json"
+	templateContentMIME = "text/yaml"
+)
+
+type Resources struct{}
+
+func (Resources) Register(mcpServer *server.MCPServer) {
+	mcpServer.AddResource(
+		mcp.NewResource(
+			TemplatesURI,
+			"Templates",
+			mcp.WithResourceDescription("The descriptions of all deployment templates available on the server."),
+			mcp.WithMIMEType(jsonMIMEType),
+		),
+		handleTemplates,
+	)
+
+	resourceTemplates := []server.ServerResourceTemplate{
+		{
+			Template: mcp.NewResourceTemplate(
+				"ztp://templates/{id}",
+				"Template",
+				mcp.WithTemplateDescription("The description, parameters and metadata of a deployment template."),
+				mcp.WithTemplateMIMEType(jsonMIMEType),
+			),
+			Handler: handleTemplate,
+		},
+		{
+			Template: mcp.NewResourceTemplate(
+				"ztp://templates/{id}/content",
+				"Template Content",
+				mcp.WithTemplateDescription("The raw cloud-init content of a deployment template."),
+				mcp.WithTemplateMIMEType(templateContentMIME),
+			),
+			Handler: handleTemplateContent,
+		},
+		{
+			Template: mcp.NewResourceTemplate(
+				"ztp://machines/{system_id}",
+				"Machine",
+				mcp.WithTemplateDescription("The MAAS machine object for a system id. Use subscribe_machine to be notified when its status changes."),
+				mcp.WithTemplateMIMEType(jsonMIMEType),
+			),
+			Handler: handleMachine,
+		},
+		{
+			Template: mcp.NewResourceTemplate(
+				"ztp://subnets/{id}",
+				"Subnet",
+				mcp.WithTemplateDescription("The MAAS subnet object for a subnet id."),
+				mcp.WithTemplateMIMEType(jsonMIMEType),
+			),
+			Handler: handleSubnet,
+		},
+		{
+			Template: mcp.NewResourceTemplate(
+				"ztp://fabrics/{id}/vlans",
+				"Fabric VLANs",
+				mcp.WithTemplateDescription("The VLANs defined on a MAAS fabric."),
+				mcp.WithTemplateMIMEType(jsonMIMEType),
+			),
+			Handler: handleFabricVlans,
+		},
+	}
+
+	mcpServer.AddResourceTemplates(resourceTemplates...)
+
+	watcher.start(mcpServer)
+}
+
+// argument returns a URI template variable from a resource request. The
+// server stores matched variables as string slices.
+func argument(request mcp.ReadResourceRequest, name string) (string, error) {
+	switch value := request.Params.Arguments[name].(type) {
+	case string:
+		if value != "" {
+			return value, nil
+		}
+	case []string:
+		if len(value) > 0 && value[0] != "" {
+			return value[0], nil
+		}
+	}
+
+	return "", fmt.Errorf("missing %s in resource uri %s", name, request.Params.URI)
+}
+
+func textContents(uri, mimeType, text string) []mcp.ResourceContents {
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{
+			URI:      uri,
+			MIMEType: mimeType,
+			Text:     text,
+		},
+	}
+}
+
+func handleTemplates(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	descriptions, err := templates.Templates()
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[Resources] Failed to retrieve the templates err=%v", err))
+		return nil, err
+	}
+
+	jsonData, err := json.Marshal(descriptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal result: %w", err)
+	}
+
+	return textContents(request.Params.URI, jsonMIMEType, string(jsonData)), nil
+}
+
+func handleTemplate(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	templateId, err := argument(request, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	description, err := templates.Template(templateId)
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[Resources] Failed to retrieve template %s err=%v", templateId, err))
+		return nil, fmt.Errorf("template %s not found", templateId)
+	}
+
+	jsonData, err := json.Marshal(description)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal result: %w", err)
+	}
+
+	return textContents(request.Params.URI, jsonMIMEType, string(jsonData)), nil
+}
+
+func handleTemplateContent(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	templateId, err := argument(request, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := templates.TemplateContent(templateId)
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[Resources] Failed to retrieve content of template %s err=%v", templateId, err))
+		return nil, fmt.Errorf("template %s not found", templateId)
+	}
+
+	return textContents(request.Params.URI, templateContentMIME, content), nil
+}
+
+func handleMachine(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	systemID, err := argument(request, "system_id")
+	if err != nil {
+		return nil, err
+	}
+
+	resultData, err := fetchMachine(ctx, systemID)
+	if err != nil {
+		return nil, err
+	}
+
+	return textContents(request.Params.URI, jsonMIMEType, resultData), nil
+}
+
+func handleSubnet(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	subnetID, err := argument(request, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	client := maas_client.MustClient()
+
+	resultData, err := client.Do(ctx, maas_client.RequestTypeGet, fmt.Sprintf("/MAAS/api/2.0/subnets/%s/", subnetID), nil)
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[Resources] Failed to read subnet %s err=%v", subnetID, err))
+		return nil, err
+	}
+
+	return textContents(request.Params.URI, jsonMIMEType, resultData), nil
+}
+
+func handleFabricVlans(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	fabricID, err := argument(request, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	client := maas_client.MustClient()
+
+	resultData, err := client.Do(ctx, maas_client.RequestTypeGet, fmt.Sprintf("/MAAS/api/2.0/fabrics/%s/vlans/", fabricID), nil)
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[Resources] Failed to list VLANs of fabric %s err=%v", fabricID, err))
+		return nil, err
+	}
+
+	return textContents(request.Params.URI, jsonMIMEType, resultData), nil
+}
+
+// fetchMachine returns the raw MAAS machine object, refusing machines that
+// carry the protected tag.
+func fetchMachine(ctx context.Context, systemID string) (string, error) {
+	client := maas_client.MustClient()
+
+	resultData, err := client.Do(ctx, maas_client.RequestTypeGet, fmt.Sprintf("/MAAS/api/2.0/machines/%s/", systemID), nil)
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[Resources] Failed to retrieve machine %s err=%v", systemID, err))
+		return "", err
+	}
+
+	var machine map[string]any
+	if err := json.Unmarshal([]byte(resultData), &machine); err != nil {
+		return "", fmt.Errorf("failed to unmarshal the machine: %w", err)
+	}
+
+	if parser.CheckForProtectedTag(machine) {
+		return "", fmt.Errorf("machine %s not found", systemID)
+	}
+
+	return resultData, nil
+}
@@ -0,0 +1,173 @@
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+)
+
+const watchInterval = 30 * time.Second
+
+var watcher = &machineWatcher{
+	subscriptions: make(map[string]map[string]struct{}),
+	statuses:      make(map[string]string),
+}
+
+// machineWatcher polls MAAS for the machines clients subscribed to and sends a
+// resources/updated notification to those clients when a status changes.
+type machineWatcher struct {
+	mu            sync.Mutex
+	once          sync.Once
+	mcpServer     *server.MCPServer
+	subscriptions map[string]map[string]struct{}
+	statuses      map[string]string
+}
+
+func (w *machineWatcher) start(mcpServer *server.MCPServer) {
+	w.once.Do(func() {
+		w.mcpServer = mcpServer
+		go w.run()
+	})
+}
+
+func (w *machineWatcher) run() {
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		w.poll()
+	}
+}
+
+func (w *machineWatcher) poll() {
+	w.mu.Lock()
+	systemIDs := make([]string, 0, len(w.subscriptions))
+	for systemID := range w.subscriptions {
+		systemIDs = append(systemIDs, systemID)
+	}
+	w.mu.Unlock()
+
+	for _, systemID := range systemIDs {
+		ctx, cancel := context.WithTimeout(context.Background(), watchInterval)
+		status, err := machineStatus(ctx, systemID)
+		cancel()
+		if err != nil {
+			zap.L().Error(fmt.Sprintf("[MachineWatcher] Failed to poll machine %s err=%v", systemID, err))
+			continue
+		}
+
+		w.mu.Lock()
+		previous, seen := w.statuses[systemID]
+		w.statuses[systemID] = status
+		sessions := make([]string, 0, len(w.subscriptions[systemID]))
+		for sessionID := range w.subscriptions[systemID] {
+			sessions = append(sessions, sessionID)
+		}
+		w.mu.Unlock()
+
+		if !seen || previous == status {
+			continue
+		}
+
+		zap.L().Info(fmt.Sprintf("[MachineWatcher] Machine %s changed status from %s to %s", systemID, previous, status))
+
+		uri := fmt.Sprintf(MachineURI, systemID)
+		for _, sessionID := range sessions {
+			err := w.mcpServer.SendNotificationToSpecificClient(sessionID, mcp.MethodNotificationResourceUpdated, map[string]any{"uri": uri})
+			if errors.Is(err, server.ErrSessionNotFound) {
+				w.Unsubscribe(sessionID, systemID)
+			} else if err != nil {
+				zap.L().Error(fmt.Sprintf("[MachineWatcher] Failed to notify session %s err=%v", sessionID, err))
+			}
+		}
+	}
+}
+
+func machineStatus(ctx context.Context, systemID string) (string, error) {
+	resultData, err := fetchMachine(ctx, systemID)
+	if err != nil {
+		return "", err
+	}
+
+	var machine map[string]any
+	if err := json.Unmarshal([]byte(resultData), &machine); err != nil {
+		return "", err
+	}
+
+	status, _ := machine["status_name"].(string)
+	return status, nil
+}
+
+// Subscribe registers the session for status change notifications of the
+// machine and returns its current status.
+func (w *machineWatcher) Subscribe(ctx context.Context, sessionID, systemID string) (string, error) {
+	status, err := machineStatus(ctx, systemID)
+	if err != nil {
+		return "", err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.subscriptions[systemID] == nil {
+		w.subscriptions[systemID] = make(map[string]struct{})
+	}
+	w.subscriptions[systemID][sessionID] = struct{}{}
+	w.statuses[systemID] = status
+
+	return status, nil
+}
+
+func (w *machineWatcher) Unsubscribe(sessionID, systemID string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	sessions, ok := w.subscriptions[systemID]
+	if !ok {
+		return false
+	}
+
+	if _, ok := sessions[sessionID]; !ok {
+		return false
+	}
+
+	delete(sessions, sessionID)
+	if len(sessions) == 0 {
+		delete(w.subscriptions, systemID)
+		delete(w.statuses, systemID)
+	}
+
+	return true
+}
+
+func SubscribeMachine(ctx context.Context, sessionID, systemID string) (string, error) {
+	return watcher.Subscribe(ctx, sessionID, systemID)
+}
+
+func UnsubscribeMachine(sessionID, systemID string) bool {
+	return watcher.Unsubscribe(sessionID, systemID)
+}
+
+// NotifyTemplatesChanged tells every client that the template list changed
+// and that the resources of the given template were updated.
+func NotifyTemplatesChanged(ctx context.Context, templateId string) {
+	mcpServer := server.ServerFromContext(ctx)
+	if mcpServer == nil {
+		return
+	}
+
+	mcpServer.SendNotificationToAllClients(mcp.MethodNotificationResourcesListChanged, nil)
+	mcpServer.SendNotificationToAllClients(mcp.MethodNotificationResourceUpdated, map[string]any{"uri": TemplatesURI})
+
+	if templateId != "" {
+		mcpServer.SendNotificationToAllClients(mcp.MethodNotificationResourceUpdated, map[string]any{"uri": fmt.Sprintf(TemplateURI, templateId)})
+		mcpServer.SendNotificationToAllClients(mcp.MethodNotificationResourceUpdated, map[string]any{"uri": fmt.Sprintf(TemplateContentURI, templateId)})
+	}
+}
@@ -0,0 +1,385 @@
+package templates
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	bundleManifestName = "manifest.json"
+	bundleFormat       = 1
+	maxBundleFileSize  = 10 << 20
+)
+
+// BundleManifest is stored at the root of every exported bundle. It records a
+// SHA-256 checksum for each file and, when ZTP_BUNDLE_KEY is set on the
+// exporting side, an HMAC-SHA256 signature over the checksums.
+type BundleManifest struct {
+	Format     int               `json:"format"`
+	TemplateID string            `json:"template_id"`
+	ExportedAt time.Time         `json:"exported_at"`
+	Files      map[string]string `json:"files"`
+	Signature  string            `json:"signature,omitempty"`
+}
+
+func (m BundleManifest) sign(key []byte) string {
+	names := make([]string, 0, len(m.Files))
+	for name := range m.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "%d\n%s\n", m.Format, m.TemplateID)
+	for _, name := range names {
+		fmt.Fprintf(mac, "%s %s\n", m.Files[name], name)
+	}
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func bundleKey() []byte {
+	return []byte(os.Getenv("ZTP_BUNDLE_KEY"))
+}
+
+// ExportTemplate packs the template directory into a tar.gz bundle holding
+// the description, the template files, any partials and a manifest.
+func ExportTemplate(templateId string) ([]byte, error) {
+	currentDir, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("error getting working directory")
+	}
+
+	templateDir := filepath.Join(currentDir, "internal/server/templates", templateId)
+
+	if _, err := Template(templateId); err != nil {
+		return nil, fmt.Errorf("template id %v does not exist or has no valid description: %w", templateId, err)
+	}
+
+	files := make(map[string][]byte)
+	err = filepath.WalkDir(templateDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() {
+			return nil
+		}
+
+		if !entry.Type().IsRegular() {
+			return fmt.Errorf("refusing to export non regular file %s", path)
+		}
+
+		relPath, err := filepath.Rel(templateDir, path)
+		if err != nil {
+			return err
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		files[filepath.ToSlash(relPath)] = content
+		return nil
+	})
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("Failed to read template directory %s err=%v", templateDir, err))
+		return nil, err
+	}
+
+	manifest := BundleManifest{
+		Format:     bundleFormat,
+		TemplateID: templateId,
+		ExportedAt: time.Now().UTC(),
+		Files:      make(map[string]string, len(files)),
+	}
+
+	for name, content := range files {
+		sum := sha256.Sum256(content)
+		manifest.Files[name] = hex.EncodeToString(sum[:])
+	}
+
+	if key := bundleKey(); len(key) > 0 {
+		manifest.Signature = manifest.sign(key)
+	}
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buf)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	writeEntry := func(name string, content []byte) error {
+		header := &tar.Header{
+			Name:    name,
+			Mode:    0644,
+			Size:    int64(len(content)),
+			ModTime: manifest.ExportedAt,
+		}
+
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+
+		_, err := tarWriter.Write(content)
+		return err
+	}
+
+	if err := writeEntry(bundleManifestName, manifestData); err != nil {
+		return nil, err
+	}
+
+	for _, name := range names {
+		if err := writeEntry(name, files[name]); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := gzipWriter.Close(); err != nil {
+		return nil, err
+	}
+
+	zap.L().Info(fmt.Sprintf("Exported template %s with %d files", templateId, len(files)))
+	return buf.Bytes(), nil
+}
+
+// ImportTemplate validates a bundle produced by ExportTemplate and unpacks it
+// into the templates directory. An existing template with the same id is only
+// replaced when force is true.
+func ImportTemplate(bundle []byte, force bool) (Description, error) {
+	manifest, files, err := readBundle(bundle)
+	if err != nil {
+		return Description{}, err
+	}
+
+	descriptionData, ok := files["description.json"]
+	if !ok {
+		return Description{}, fmt.Errorf("bundle does not contain description.json")
+	}
+
+	var description Description
+	if err := json.Unmarshal(descriptionData, &description); err != nil {
+		return Description{}, fmt.Errorf("bundle contains an invalid description.json: %w", err)
+	}
+
+	if description.ID == "" || description.ID != manifest.TemplateID {
+		return Description{}, fmt.Errorf("description id %q does not match manifest template id %q", description.ID, manifest.TemplateID)
+	}
+
+	if !filepath.IsLocal(description.ID) || filepath.Base(description.ID) != description.ID || description.ID == "template" {
+		return Description{}, fmt.Errorf("invalid template id %q", description.ID)
+	}
+
+	if len(description.Parts) == 0 {
+		if _, ok := files["template.yaml"]; !ok {
+			return Description{}, fmt.Errorf("bundle does not contain template.yaml")
+		}
+	} else {
+		for _, part := range description.Parts {
+			if _, ok := files[filepath.ToSlash(part.File)]; !ok {
+				return Description{}, fmt.Errorf("bundle does not contain part file %s", part.File)
+			}
+		}
+	}
+
+	currentDir, err := os.Getwd()
+	if err != nil {
+		return Description{}, fmt.Errorf("error getting working directory")
+	}
+
+	templatesDir := filepath.Join(currentDir, "internal/server/templates")
+	outputDir := filepath.Join(templatesDir, description.ID)
+
+	if _, err := os.Stat(outputDir); err == nil {
+		if !force {
+			return Description{}, fmt.Errorf("template id %s already exists, use force to overwrite it", description.ID)
+		}
+	}
+
+	// Unpack into a work directory next to the templates directory, not inside
+	// it, so a failed import never leaves a half written template behind and
+	// the template listing never sees the staged or previous copy. Keeping it
+	// on the same filesystem makes the final swap a rename.
+	workDir, err := os.MkdirTemp(filepath.Dir(templatesDir), ".template-import-")
+	if err != nil {
+		return Description{}, err
+	}
+
+	keepWorkDir := false
+	defer func() {
+		if !keepWorkDir {
+			if err := os.RemoveAll(workDir); err != nil {
+				zap.L().Warn(fmt.Sprintf("Failed to remove the import directory %s err=%v", workDir, err))
+			}
+		}
+	}()
+
+	stagingDir := filepath.Join(workDir, "staged")
+	if err := os.Mkdir(stagingDir, 0755); err != nil {
+		return Description{}, err
+	}
+
+	for name, content := range files {
+		outputPath := filepath.Join(stagingDir, filepath.FromSlash(name))
+
+		if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+			return Description{}, err
+		}
+
+		if err := os.WriteFile(outputPath, content, 0644); err != nil {
+			zap.L().Error(fmt.Sprintf("Failed to write file %s err=%v", outputPath, err))
+			return Description{}, err
+		}
+	}
+
+	if len(description.Parts) > 0 {
+		if err := validateParts(stagingDir, description.Parts); err != nil {
+			return Description{}, err
+		}
+	}
+
+	// Move the existing template aside rather than deleting it, so it can be
+	// put back when the swap fails.
+	var backupDir string
+	if _, err := os.Stat(outputDir); err == nil {
+		backupDir = filepath.Join(workDir, "previous")
+		if err := os.Rename(outputDir, backupDir); err != nil {
+			return Description{}, err
+		}
+	}
+
+	if err := os.Rename(stagingDir, outputDir); err != nil {
+		if backupDir != "" {
+			if restoreErr := os.Rename(backupDir, outputDir); restoreErr != nil {
+				keepWorkDir = true
+				zap.L().Error(fmt.Sprintf("Failed to restore template %s from %s err=%v", description.ID, backupDir, restoreErr))
+				return Description{}, fmt.Errorf("%w (restoring the previous template from %s also failed: %v)", err, backupDir, restoreErr)
+			}
+		}
+		return Description{}, err
+	}
+
+	zap.L().Info(fmt.Sprintf("Imported template %s with %d files", description.ID, len(files)))
+	return description, nil
+}
+
+func readBundle(bundle []byte) (BundleManifest, map[string][]byte, error) {
+	var manifest BundleManifest
+
+	gzipReader, err := gzip.NewReader(bytes.NewReader(bundle))
+	if err != nil {
+		return manifest, nil, fmt.Errorf("bundle is not a valid gzip archive: %w", err)
+	}
+	defer gzipReader.Close()
+
+	tarReader := tar.NewReader(gzipReader)
+	files := make(map[string][]byte)
+	var manifestData []byte
+
+	for {
+		header, err := tarReader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return manifest, nil, fmt.Errorf("bundle is not a valid tar archive: %w", err)
+		}
+
+		if header.Typeflag == tar.TypeDir {
+			continue
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			return manifest, nil, fmt.Errorf("bundle entry %s is not a regular file", header.Name)
+		}
+
+		if !filepath.IsLocal(filepath.FromSlash(header.Name)) {
+			return manifest, nil, fmt.Errorf("bundle entry %s has an invalid path", header.Name)
+		}
+
+		if header.Size > maxBundleFileSize {
+			return manifest, nil, fmt.Errorf("bundle entry %s exceeds the maximum size", header.Name)
+		}
+
+		content, err := io.ReadAll(io.LimitReader(tarReader, maxBundleFileSize))
+		if err != nil {
+			return manifest, nil, err
+		}
+
+		if header.Name == bundleManifestName {
+			manifestData = content
+			continue
+		}
+
+		files[header.Name] = content
+	}
+
+	if manifestData == nil {
+		return manifest, nil, fmt.Errorf("bundle does not contain %s", bundleManifestName)
+	}
+
+	if err := json.Unmarshal(manifestData, &manifest); err != nil {
+		return manifest, nil, fmt.Errorf("bundle contains an invalid manifest: %w", err)
+	}
+
+	if manifest.Format != bundleFormat {
+		return manifest, nil, fmt.Errorf("unsupported bundle format %d", manifest.Format)
+	}
+
+	if len(manifest.Files) != len(files) {
+		return manifest, nil, fmt.Errorf("bundle holds %d files but the manifest lists %d", len(files), len(manifest.Files))
+	}
+
+	for name, content := range files {
+		expected, ok := manifest.Files[name]
+		if !ok {
+			return manifest, nil, fmt.Errorf("bundle entry %s is not listed in the manifest", name)
+		}
+
+		sum := sha256.Sum256(content)
+		if hex.EncodeToString(sum[:]) != expected {
+			return manifest, nil, fmt.Errorf("checksum mismatch for bundle entry %s", name)
+		}
+	}
+
+	if key := bundleKey(); len(key) > 0 {
+		if manifest.Signature == "" {
+			return manifest, nil, fmt.Errorf("bundle is not signed but ZTP_BUNDLE_KEY is set")
+		}
+
+		if !hmac.Equal([]byte(manifest.sign(key)), []byte(manifest.Signature)) {
+			return manifest, nil, fmt.Errorf("bundle signature is invalid")
+		}
+	}
+
+	return manifest, files, nil
+}
@@ -0,0 +1,171 @@
+package templates
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// inTemplatesDir runs the test from a temporary working directory holding an
+// empty internal/server/templates tree and returns that tree.
+func inTemplatesDir(t *testing.T) string {
+	t.Helper()
+
+	root := t.TempDir()
+	templatesDir := filepath.Join(root, "internal/server/templates")
+	if err := os.MkdirAll(templatesDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	previous, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(previous) })
+
+	return templatesDir
+}
+
+func writeTemplate(t *testing.T, dir, yaml string) {
+	t.Helper()
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	description := `{"id": "demo", "name": "Demo", "description": "Demo template.", "parameters": {}}`
+	if err := os.WriteFile(filepath.Join(dir, "description.json"), []byte(description), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "template.yaml"), []byte(yaml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImportTemplateOverwrite(t *testing.T) {
+	templatesDir := inTemplatesDir(t)
+	templateDir := filepath.Join(templatesDir, "demo")
+
+	writeTemplate(t, templateDir, "#cloud-config\n# v2\n")
+	bundle, err := ExportTemplate("demo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeTemplate(t, templateDir, "#cloud-config\n# v1\n")
+
+	if _, err := ImportTemplate(bundle, false); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("import without force: err = %v, want already exists", err)
+	}
+
+	if _, err := ImportTemplate(bundle, true); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(templateDir, "template.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "v2") {
+		t.Errorf("template.yaml = %q, want the imported version", content)
+	}
+
+	entries, err := os.ReadDir(templatesDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if entry.Name() != "demo" {
+			t.Errorf("leftover %s in the templates directory", entry.Name())
+		}
+	}
+
+	entries, err = os.ReadDir(filepath.Dir(templatesDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if entry.Name() != "templates" {
+			t.Errorf("leftover %s next to the templates directory", entry.Name())
+		}
+	}
+}
+
+// repack rewrites every entry of a bundle through edit and packs it again.
+func repack(t *testing.T, bundle []byte, edit func(name string, content []byte) []byte) []byte {
+	t.Helper()
+
+	gzipReader, err := gzip.NewReader(bytes.NewReader(bundle))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tarReader := tar.NewReader(gzipReader)
+
+	var buf bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buf)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	for {
+		header, err := tarReader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		content, err := io.ReadAll(tarReader)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		content = edit(header.Name, content)
+		header.Size = int64(len(content))
+
+		if err := tarWriter.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tarWriter.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestImportTemplateRejectsTampering(t *testing.T) {
+	templatesDir := inTemplatesDir(t)
+	writeTemplate(t, filepath.Join(templatesDir, "demo"), "#cloud-config\n")
+
+	bundle, err := ExportTemplate("demo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := repack(t, bundle, func(name string, content []byte) []byte {
+		if name == "template.yaml" {
+			return append(content, "runcmd: [reboot]\n"...)
+		}
+		return content
+	})
+
+	if _, err := ImportTemplate(tampered, true); err == nil || !strings.Contains(err.Error(), "checksum mismatch for bundle entry template.yaml") {
+		t.Fatalf("import of a tampered bundle: err = %v, want a checksum mismatch", err)
+	}
+}
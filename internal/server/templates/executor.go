@@ -2,19 +2,55 @@ package templates
 
 import (
 	"bytes"
+	"compress/gzip"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"html/template"
+	"io"
+	"mime/multipart"
+	"net/textproto"
 	"os"
 	"path/filepath"
+	texttemplate "text/template"
+	"unicode/utf8"
 
 	"go.uber.org/zap"
 )
 
+// partContentTypes are the MIME types cloud-init knows how to handle inside a
+// multi-part user_data message.
+var partContentTypes = map[string]bool{
+	"text/cloud-config":               true,
+	"text/cloud-config-archive":       true,
+	"text/x-shellscript":              true,
+	"text/x-shellscript-per-boot":     true,
+	"text/x-shellscript-per-instance": true,
+	"text/x-shellscript-per-once":     true,
+	"text/cloud-boothook":             true,
+	"text/part-handler":               true,
+	"text/x-include-url":              true,
+	"text/x-include-once-url":         true,
+	"text/jinja2":                     true,
+}
+
+// markupContentTypes are the part types rendered with html/template, like
+// template.yaml. Scripts and the other parts are rendered with text/template
+// so parameter values are not HTML-escaped.
+var markupContentTypes = map[string]bool{
+	"text/cloud-config":         true,
+	"text/cloud-config-archive": true,
+}
+
+type executable interface {
+	Execute(wr io.Writer, data any) error
+}
+
 type TemplateExecutor struct {
 	TemplateId string
 	Parameters map[string]any
+	Parts      []Part
+	Compress   bool
 }
 
 func (t *TemplateExecutor) Execute() (string, error) {
@@ -24,28 +60,149 @@ func (t *TemplateExecutor) Execute() (string, error) {
 		return "", err
 	}
 
-	templatePath := filepath.Join(currentDir, "internal/server/templates", t.TemplateId, "template.yaml")
+	templateDir := filepath.Join(currentDir, "internal/server/templates", t.TemplateId)
+
+	var userData []byte
+
+	if len(t.Parts) == 0 {
+		userData, err = t.render(filepath.Join(templateDir, "template.yaml"), true)
+		if err != nil {
+			return "", err
+		}
+	} else {
+		userData, err = t.renderMultipart(templateDir)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if t.Compress {
+		var buf bytes.Buffer
+		gzipWriter := gzip.NewWriter(&buf)
+
+		if _, err := gzipWriter.Write(userData); err != nil {
+			zap.L().Error(fmt.Sprintf("Failed to compress user data for template %s err=%v", t.TemplateId, err))
+			return "", err
+		}
 
+		if err := gzipWriter.Close(); err != nil {
+			zap.L().Error(fmt.Sprintf("Failed to compress user data for template %s err=%v", t.TemplateId, err))
+			return "", err
+		}
+
+		userData = buf.Bytes()
+	}
+
+	encodedStr := base64.StdEncoding.EncodeToString(userData)
+	return encodedStr, nil
+}
+
+func (t *TemplateExecutor) render(templatePath string, markup bool) ([]byte, error) {
 	if _, err := os.Stat(templatePath); os.IsNotExist(err) {
 		zap.L().Error(fmt.Sprintf("Template file not found: %s", templatePath))
-		return "", fmt.Errorf("template file not found: %s", templatePath)
+		return nil, fmt.Errorf("template file not found: %s", templatePath)
 	}
 
-	tmpl, err := template.ParseFiles(templatePath)
+	var tmpl executable
+	var err error
+	if markup {
+		tmpl, err = template.ParseFiles(templatePath)
+	} else {
+		tmpl, err = texttemplate.ParseFiles(templatePath)
+	}
 	if err != nil {
 		zap.L().Error(fmt.Sprintf("Failed to parse template file %s err=%v", templatePath, err))
-		return "", err
+		return nil, err
 	}
 
 	var buf bytes.Buffer
 	err = tmpl.Execute(&buf, t.Parameters)
 	if err != nil {
 		zap.L().Error(fmt.Sprintf("Failed to execute template %s err=%v", templatePath, err))
-		return "", err
+		return nil, err
 	}
 
-	encodedStr := base64.StdEncoding.EncodeToString(buf.Bytes())
-	return encodedStr, nil
+	return buf.Bytes(), nil
+}
+
+// renderMultipart renders every declared part and assembles them into a
+// multipart/mixed message in the format expected by cloud-init.
+func (t *TemplateExecutor) renderMultipart(templateDir string) ([]byte, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	for _, part := range t.Parts {
+		content, err := t.render(filepath.Join(templateDir, part.File), markupContentTypes[part.ContentType])
+		if err != nil {
+			return nil, err
+		}
+
+		filename := part.Filename
+		if filename == "" {
+			filename = filepath.Base(part.File)
+		}
+
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Type", fmt.Sprintf("%s; charset=\"utf-8\"", part.ContentType))
+		header.Set("MIME-Version", "1.0")
+		header.Set("Content-Transfer-Encoding", transferEncoding(content))
+		header.Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+		if part.MergeType != "" {
+			header.Set("Merge-Type", part.MergeType)
+		}
+
+		partWriter, err := writer.CreatePart(header)
+		if err != nil {
+			zap.L().Error(fmt.Sprintf("Failed to create MIME part for %s err=%v", part.File, err))
+			return nil, err
+		}
+
+		if _, err := partWriter.Write(content); err != nil {
+			zap.L().Error(fmt.Sprintf("Failed to write MIME part for %s err=%v", part.File, err))
+			return nil, err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		zap.L().Error(fmt.Sprintf("Failed to close MIME writer for template %s err=%v", t.TemplateId, err))
+		return nil, err
+	}
+
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "Content-Type: multipart/mixed; boundary=\"%s\"\r\n", writer.Boundary())
+	message.WriteString("MIME-Version: 1.0\r\n\r\n")
+	message.Write(body.Bytes())
+
+	return message.Bytes(), nil
+}
+
+// transferEncoding returns 7bit for ASCII content and 8bit otherwise, as the
+// parts are written unencoded.
+func transferEncoding(content []byte) string {
+	for _, b := range content {
+		if b >= utf8.RuneSelf {
+			return "8bit"
+		}
+	}
+	return "7bit"
+}
+
+func validateParts(templateDir string, parts []Part) error {
+	for _, part := range parts {
+		if part.File == "" || !filepath.IsLocal(part.File) {
+			return fmt.Errorf("invalid part file %q", part.File)
+		}
+
+		if !partContentTypes[part.ContentType] {
+			return fmt.Errorf("unsupported content type %q for part %s", part.ContentType, part.File)
+		}
+
+		if _, err := os.Stat(filepath.Join(templateDir, part.File)); os.IsNotExist(err) {
+			return fmt.Errorf("part file %s not found", part.File)
+		}
+	}
+
+	return nil
 }
 
 func RetrieveExecutor(templateId string, parameters string) (*TemplateExecutor, error) {
@@ -65,9 +222,18 @@ func RetrieveExecutor(templateId string, parameters string) (*TemplateExecutor,
 		return nil, fmt.Errorf("template description not found for id %v", templateId)
 	}
 
-	templatePath := filepath.Join(templateDir, "template.yaml")
-	if _, err := os.Stat(templatePath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("template file not found for id %v", templateId)
+	description, err := Template(templateId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template description for id %v: %w", templateId, err)
+	}
+
+	if len(description.Parts) == 0 {
+		templatePath := filepath.Join(templateDir, "template.yaml")
+		if _, err := os.Stat(templatePath); os.IsNotExist(err) {
+			return nil, fmt.Errorf("template file not found for id %v", templateId)
+		}
+	} else if err := validateParts(templateDir, description.Parts); err != nil {
+		return nil, fmt.Errorf("invalid parts for template id %v: %w", templateId, err)
 	}
 
 	var params map[string]any
@@ -80,5 +246,7 @@ func RetrieveExecutor(templateId string, parameters string) (*TemplateExecutor,
 	return &TemplateExecutor{
 		TemplateId: templateId,
 		Parameters: params,
+		Parts:      description.Parts,
+		Compress:   description.Compress,
 	}, nil
 }
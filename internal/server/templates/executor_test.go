@@ -0,0 +1,72 @@
+package templates
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderMultipart(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"setup.sh":   "#!/bin/sh\necho '{{ .Message }}' && {{ .Command }}\n",
+		"config.yml": "#cloud-config\nhostname: {{ .Hostname }}\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	executor := TemplateExecutor{
+		TemplateId: "test",
+		Parameters: map[string]any{
+			"Message":  "héllo <world>",
+			"Command":  "test -f /a & wait",
+			"Hostname": "node-1",
+		},
+		Parts: []Part{
+			{File: "config.yml", ContentType: "text/cloud-config"},
+			{File: "setup.sh", ContentType: "text/x-shellscript"},
+		},
+	}
+
+	message, err := executor.renderMultipart(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := string(message)
+	for _, want := range []string{
+		"echo 'héllo <world>' && test -f /a & wait",
+		"hostname: node-1",
+		"Content-Type: text/x-shellscript; charset=\"utf-8\"",
+		"Content-Disposition: attachment; filename=\"setup.sh\"",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("message does not contain %q:\n%s", want, out)
+		}
+	}
+
+	if strings.Count(out, "Content-Transfer-Encoding: 8bit") != 1 || strings.Count(out, "Content-Transfer-Encoding: 7bit") != 1 {
+		t.Errorf("expected one 7bit and one 8bit part:\n%s", out)
+	}
+}
+
+func TestTransferEncoding(t *testing.T) {
+	tests := map[string]string{
+		"":             "7bit",
+		"echo hi\n":    "7bit",
+		"echo héllo\n": "8bit",
+		"# 日本語\n":      "8bit",
+		"tab\tand\r\n": "7bit",
+	}
+
+	for content, want := range tests {
+		if got := transferEncoding([]byte(content)); got != want {
+			t.Errorf("transferEncoding(%q) = %s, want %s", content, got, want)
+		}
+	}
+}
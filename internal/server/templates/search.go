@@ -0,0 +1,133 @@
+package templates
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// SearchFilter narrows down the templates returned by SearchTemplates. Empty
+// fields are ignored. CPUCount, MemoryMB and StorageGB describe the hardware
+// available, so only templates whose minimums fit are returned.
+type SearchFilter struct {
+	Query         string
+	Tags          []string
+	DistroSeries  string
+	Architecture  string
+	NetworkAccess string
+	CPUCount      int
+	MemoryMB      int
+	StorageGB     int
+}
+
+func (f SearchFilter) Matches(description Description) bool {
+	if f.Query != "" {
+		query := strings.ToLower(f.Query)
+		if !strings.Contains(strings.ToLower(description.ID), query) &&
+			!strings.Contains(strings.ToLower(description.Name), query) &&
+			!strings.Contains(strings.ToLower(description.Description), query) {
+			return false
+		}
+	}
+
+	for _, tag := range f.Tags {
+		if !containsFold(description.Tags, tag) {
+			return false
+		}
+	}
+
+	if f.DistroSeries != "" && len(description.DistroSeries) > 0 && !containsFold(description.DistroSeries, f.DistroSeries) {
+		return false
+	}
+
+	if f.Architecture != "" && len(description.Architectures) > 0 && !supportsArchitecture(description.Architectures, f.Architecture) {
+		return false
+	}
+
+	if f.NetworkAccess != "" && !containsFold(description.NetworkAccess, f.NetworkAccess) {
+		return false
+	}
+
+	requirements := description.Requirements
+
+	if f.CPUCount > 0 && requirements.MinCPUCount > f.CPUCount {
+		return false
+	}
+
+	if f.MemoryMB > 0 && requirements.MinMemoryMB > f.MemoryMB {
+		return false
+	}
+
+	if f.StorageGB > 0 && requirements.MinStorageGB > f.StorageGB {
+		return false
+	}
+
+	return true
+}
+
+func SearchTemplates(filter SearchFilter) ([]Description, error) {
+	descriptions, err := Templates()
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make([]Description, 0, len(descriptions))
+	for _, description := range descriptions {
+		if filter.Matches(description) {
+			matches = append(matches, description)
+		}
+	}
+
+	return matches, nil
+}
+
+// CheckMachine compares a MAAS machine object against the template metadata
+// and returns a warning for every requirement the machine does not meet.
+func (d Description) CheckMachine(machine map[string]any, distroSeries string) []string {
+	var warnings []string
+
+	requirements := d.Requirements
+
+	if cpuCount, ok := machine["cpu_count"].(float64); ok && requirements.MinCPUCount > 0 && int(cpuCount) < requirements.MinCPUCount {
+		warnings = append(warnings, fmt.Sprintf("machine has %d CPUs but template %s requires at least %d", int(cpuCount), d.ID, requirements.MinCPUCount))
+	}
+
+	if memory, ok := machine["memory"].(float64); ok && requirements.MinMemoryMB > 0 && int(memory) < requirements.MinMemoryMB {
+		warnings = append(warnings, fmt.Sprintf("machine has %d MiB of memory but template %s requires at least %d MiB", int(memory), d.ID, requirements.MinMemoryMB))
+	}
+
+	// MAAS reports storage in MB.
+	if storage, ok := machine["storage"].(float64); ok && requirements.MinStorageGB > 0 && int(storage/1000) < requirements.MinStorageGB {
+		warnings = append(warnings, fmt.Sprintf("machine has %d GB of storage but template %s requires at least %d GB", int(storage/1000), d.ID, requirements.MinStorageGB))
+	}
+
+	if architecture, ok := machine["architecture"].(string); ok && len(d.Architectures) > 0 && !supportsArchitecture(d.Architectures, architecture) {
+		warnings = append(warnings, fmt.Sprintf("machine architecture %s is not supported by template %s (supported: %s)", architecture, d.ID, strings.Join(d.Architectures, ", ")))
+	}
+
+	if distroSeries != "" && len(d.DistroSeries) > 0 && !containsFold(d.DistroSeries, distroSeries) {
+		warnings = append(warnings, fmt.Sprintf("distro series %s is not supported by template %s (supported: %s)", distroSeries, d.ID, strings.Join(d.DistroSeries, ", ")))
+	}
+
+	return warnings
+}
+
+// supportsArchitecture matches both bare architectures (amd64) and MAAS
+// architecture/subarchitecture pairs (amd64/generic).
+func supportsArchitecture(supported []string, architecture string) bool {
+	base, _, _ := strings.Cut(architecture, "/")
+
+	return slices.ContainsFunc(supported, func(value string) bool {
+		valueBase, _, hasSub := strings.Cut(value, "/")
+		if hasSub {
+			return strings.EqualFold(value, architecture)
+		}
+		return strings.EqualFold(valueBase, base)
+	})
+}
+
+func containsFold(values []string, value string) bool {
+	return slices.ContainsFunc(values, func(candidate string) bool {
+		return strings.EqualFold(candidate, value)
+	})
+}
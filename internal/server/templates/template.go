@@ -11,15 +11,20 @@ import (
 )
 
 type GenericTemplate struct {
-	Id              string      `json:"id" jsonschema_description:"The id of the template, should be lowercased and separated by underscores."`
-	Name            string      `json:"name" jsonschema_description:"The name of the template, the same as the id, but with each word capitalized and replace the underscores with spaces."`
-	Parameters      []Parameter `json:"parameters" jsonschema_description:"The parameters that will be placed inside the template.yaml to customize each deployment."`
-	Description     string      `json:"description" jsonschema_description:"The description of the template."`
-	UpdatePackages  bool        `json:"update_packages" jsonschema_description:"If true will update all the packages."`
-	UpgradePackages bool        `json:"upgrade_packages" jsonschema_description:"If true will upgrade all the packages."`
-	Packages        []string    `json:"packages" jsonschema_description:"The packages to install on the system."`
-	Commands        []string    `json:"commands" jsonschema_description:"The commands to run when the system is installed."`
-	Files           []File      `json:"files" jsonschema_description:"Specify the files that needs to be available on the system, such as config files and other files needed by the installed packages and applications."`
+	Id              string        `json:"id" jsonschema_description:"The id of the template, should be lowercased and separated by underscores."`
+	Name            string        `json:"name" jsonschema_description:"The name of the template, the same as the id, but with each word capitalized and replace the underscores with spaces."`
+	Parameters      []Parameter   `json:"parameters" jsonschema_description:"The parameters that will be placed inside the template.yaml to customize each deployment."`
+	Description     string        `json:"description" jsonschema_description:"The description of the template."`
+	UpdatePackages  bool          `json:"update_packages" jsonschema_description:"If true will update all the packages."`
+	UpgradePackages bool          `json:"upgrade_packages" jsonschema_description:"If true will upgrade all the packages."`
+	Packages        []string      `json:"packages" jsonschema_description:"The packages to install on the system."`
+	Commands        []string      `json:"commands" jsonschema_description:"The commands to run when the system is installed."`
+	Files           []File        `json:"files" jsonschema_description:"Specify the files that needs to be available on the system, such as config files and other files needed by the installed packages and applications."`
+	Tags            []string      `json:"tags,omitempty" jsonschema_description:"Category tags used to search for the template, such as kubernetes or web."`
+	DistroSeries    []string      `json:"distro_series,omitempty" jsonschema_description:"The Ubuntu releases the template supports, such as jammy or noble. Leave empty if any release works."`
+	Architectures   []string      `json:"architectures,omitempty" jsonschema_description:"The architectures the template supports, such as amd64 or arm64. Leave empty if any architecture works."`
+	Requirements    *Requirements `json:"requirements,omitempty" jsonschema_description:"The minimum hardware a machine needs to be deployed with the template."`
+	NetworkAccess   []string      `json:"network_access,omitempty" jsonschema_description:"The network access the deployment needs, such as internet or the hosts it downloads from."`
 }
 
 type Parameter struct {
@@ -0,0 +1,58 @@
+package templates
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDescriptionTemplateRequirements(t *testing.T) {
+	entries, err := os.ReadDir("template")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var file os.DirEntry
+	for _, entry := range entries {
+		if entry.Name() == "description.json.templ" {
+			file = entry
+		}
+	}
+	if file == nil {
+		t.Fatal("template/description.json.templ not found")
+	}
+
+	tests := []struct {
+		name         string
+		requirements *Requirements
+		want         Requirements
+	}{
+		{"omitted", nil, Requirements{}},
+		{"set", &Requirements{MinCPUCount: 4, MinMemoryMB: 8192, MinStorageGB: 40}, Requirements{MinCPUCount: 4, MinMemoryMB: 8192, MinStorageGB: 40}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			outputDir := t.TempDir()
+			templ := GenericTemplate{Id: "demo", Name: "Demo", Description: "Demo template.", Requirements: test.requirements}
+
+			if err := executeTemplateFile("template", outputDir, file, templ); err != nil {
+				t.Fatal(err)
+			}
+
+			content, err := os.ReadFile(filepath.Join(outputDir, "description.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var description Description
+			if err := json.Unmarshal(content, &description); err != nil {
+				t.Fatalf("description.json is not valid JSON: %v\n%s", err, content)
+			}
+			if description.Requirements != test.want {
+				t.Errorf("requirements = %+v, want %+v", description.Requirements, test.want)
+			}
+		})
+	}
+}
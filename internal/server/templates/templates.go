@@ -10,10 +10,34 @@ import (
 )
 
 type Description struct {
-	ID          string            `json:"id"`
-	Name        string            `json:"name"`
-	Description string            `json:"description"`
-	Parameters  map[string]string `json:"parameters"`
+	ID            string            `json:"id"`
+	Name          string            `json:"name"`
+	Description   string            `json:"description"`
+	Parameters    map[string]string `json:"parameters"`
+	Parts         []Part            `json:"parts,omitempty"`
+	Compress      bool              `json:"compress,omitempty"`
+	Tags          []string          `json:"tags,omitempty"`
+	DistroSeries  []string          `json:"distro_series,omitempty"`
+	Architectures []string          `json:"architectures,omitempty"`
+	Requirements  Requirements      `json:"requirements"`
+	NetworkAccess []string          `json:"network_access,omitempty"`
+}
+
+// Requirements holds the minimum hardware a machine needs for the template to
+// deploy successfully. Zero values mean no minimum.
+type Requirements struct {
+	MinCPUCount  int `json:"min_cpu_count,omitempty" jsonschema_description:"The minimum number of CPU cores the machine needs."`
+	MinMemoryMB  int `json:"min_memory_mb,omitempty" jsonschema_description:"The minimum amount of memory the machine needs in MiB."`
+	MinStorageGB int `json:"min_storage_gb,omitempty" jsonschema_description:"The minimum amount of storage the machine needs in GB."`
+}
+
+// Part describes one document of a multi-part MIME user_data message. When a
+// template declares no parts, template.yaml is used as a single cloud-config.
+type Part struct {
+	File        string `json:"file"`
+	ContentType string `json:"content_type"`
+	Filename    string `json:"filename,omitempty"`
+	MergeType   string `json:"merge_type,omitempty"`
 }
 
 func Templates() ([]Description, error) {
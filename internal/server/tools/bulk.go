@@ -0,0 +1,641 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/JarcauCristian/ztp-mcp/internal/server/jobs"
+	"github.com/JarcauCristian/ztp-mcp/internal/server/maas_client"
+	"github.com/JarcauCristian/ztp-mcp/internal/server/parser"
+	"github.com/JarcauCristian/ztp-mcp/internal/server/templates"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+)
+
+const maxParallelism = 50
+
+type Bulk struct{}
+
+func (Bulk) Register(mcpServer *server.MCPServer) {
+	mcpTools := []MCPTool{BulkCommission{}, BulkPower{}, BulkDeploy{}, BulkRelease{}, BulkTag{}}
+
+	for _, tool := range mcpTools {
+		mcpServer.AddTool(tool.Create(), tool.Handle)
+	}
+}
+
+// withSelection adds the parameters that select the machines of a bulk
+// operation and control how it runs.
+func withSelection() []mcp.ToolOption {
+	return []mcp.ToolOption{
+		mcp.WithString(
+			"system_ids",
+			mcp.Description("Comma separated system IDs of the machines. Combined with the other selectors when both are given."),
+		),
+		mcp.WithString(
+			"tag",
+			mcp.Description("Select the machines with this tag."),
+		),
+		mcp.WithString(
+			"status",
+			mcp.Description("Select the machines with this status, e.g. new, ready, deployed, broken."),
+		),
+		mcp.WithString(
+			"pool",
+			mcp.Description("Select the machines in this resource pool."),
+		),
+		mcp.WithString(
+			"zone",
+			mcp.Description("Select the machines in this availability zone."),
+		),
+		mcp.WithNumber(
+			"parallelism",
+			mcp.Description(fmt.Sprintf("How many machines are processed at the same time. Defaults to 5, at most %d.", maxParallelism)),
+		),
+		mcp.WithNumber(
+			"timeout",
+			mcp.Min(1),
+			mcp.Description("Seconds allowed for each machine before it is reported as failed. Defaults to 120, at least 1."),
+		),
+	}
+}
+
+type bulkResult struct {
+	SystemID string `json:"system_id"`
+	Hostname string `json:"hostname,omitempty"`
+	Result   string `json:"result"`
+	Detail   string `json:"detail,omitempty"`
+}
+
+// bulkRun is a bulk operation over the selected machines. check may reject a
+// machine before anything is sent for it, in which case it is skipped. When
+// op is set the operation is the MAAS machine op sent with form, apply
+// defaults to sending it and the request may run it as a background job.
+type bulkRun struct {
+	toolName string
+	action   string
+	op       string
+	form     url.Values
+	check    func(machine map[string]any) error
+	apply    func(ctx context.Context, client *maas_client.MAASClient, machine map[string]any) (string, error)
+}
+
+// selectMachines returns the machines matching the selectors of the request,
+// and a result for every requested system ID that does not exist.
+func selectMachines(ctx context.Context, client *maas_client.MAASClient, request mcp.CallToolRequest) ([]map[string]any, []bulkResult, error) {
+	query := make(url.Values)
+
+	var systemIDs []string
+	for _, systemID := range strings.Split(request.GetString("system_ids", ""), ",") {
+		if systemID = strings.TrimSpace(systemID); systemID != "" {
+			systemIDs = append(systemIDs, systemID)
+			query.Add("id", systemID)
+		}
+	}
+
+	selectors := map[string]string{"tag": "tags", "status": "status", "pool": "pool", "zone": "zone"}
+	for param, filter := range selectors {
+		if value := request.GetString(param, ""); value != "" {
+			query.Add(filter, value)
+		}
+	}
+
+	if len(query) == 0 {
+		return nil, nil, fmt.Errorf("select the machines with system_ids, tag, status, pool or zone")
+	}
+
+	resultData, err := client.Do(ctx, maas_client.RequestTypeGet, "/MAAS/api/2.0/machines/?"+query.Encode(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var machines []map[string]any
+	if err := json.Unmarshal([]byte(resultData), &machines); err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal the machines: %w", err)
+	}
+
+	var missing []bulkResult
+	for _, systemID := range systemIDs {
+		found := slices.ContainsFunc(machines, func(machine map[string]any) bool { return machine["system_id"] == systemID })
+		if !found {
+			missing = append(missing, bulkResult{SystemID: systemID, Result: "failed", Detail: "machine not found or not matching the other selectors"})
+		}
+	}
+
+	return machines, missing, nil
+}
+
+// bulkTimeout returns the time allowed for each machine, at least a second.
+func bulkTimeout(request mcp.CallToolRequest) time.Duration {
+	return time.Duration(max(request.GetInt("timeout", 120), 1)) * time.Second
+}
+
+func (b bulkRun) handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	parallelism := min(max(request.GetInt("parallelism", 5), 1), maxParallelism)
+	timeout := bulkTimeout(request)
+
+	client := maas_client.MustClient()
+
+	machines, results, err := selectMachines(ctx, client, request)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to select the machines err=%v", err)
+		zap.L().Error(fmt.Sprintf("[%s] %s", b.toolName, errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	zap.L().Info(fmt.Sprintf("[%s] Trying to %s on %d machines with parallelism %d...", b.toolName, b.action, len(machines), parallelism))
+
+	processed, eligible := b.screen(machines)
+
+	if b.op != "" && request.GetBool("async", false) {
+		return b.submit(machines, eligible, append(processed, results...))
+	}
+
+	b.run(ctx, client, machines, eligible, processed, parallelism, timeout)
+	results = append(processed, results...)
+
+	summary := map[string]int{"total": len(results), "ok": 0, "failed": 0, "skipped": 0}
+	for _, result := range results {
+		summary[result.Result]++
+	}
+
+	jsonData, err := json.Marshal(map[string]any{"action": b.action, "summary": summary, "results": results})
+	if err != nil {
+		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[%s] %s", b.toolName, errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	var table strings.Builder
+	writer := tabwriter.NewWriter(&table, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "SYSTEM ID\tHOSTNAME\tRESULT\tDETAIL")
+	for _, result := range results {
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", result.SystemID, result.Hostname, result.Result, result.Detail)
+	}
+	writer.Flush()
+
+	result := mcp.NewToolResultText(string(jsonData))
+	result.Content = append(result.Content, mcp.NewTextContent(table.String()))
+
+	return result, nil
+}
+
+// screen returns a result per machine, with the protected machines and the
+// ones rejected by check already skipped, and the indexes of the others.
+func (b bulkRun) screen(machines []map[string]any) ([]bulkResult, []int) {
+	processed := make([]bulkResult, len(machines))
+	var eligible []int
+
+	for i, machine := range machines {
+		systemID, _ := machine["system_id"].(string)
+		hostname, _ := machine["hostname"].(string)
+		processed[i] = bulkResult{SystemID: systemID, Hostname: hostname}
+
+		if parser.CheckForProtectedTag(machine) {
+			processed[i].Result = "skipped"
+			processed[i].Detail = "machine is protected"
+			continue
+		}
+
+		if b.check != nil {
+			if err := b.check(machine); err != nil {
+				processed[i].Result = "skipped"
+				processed[i].Detail = err.Error()
+				continue
+			}
+		}
+
+		eligible = append(eligible, i)
+	}
+
+	return processed, eligible
+}
+
+// run applies the operation to the eligible machines, at most parallelism at
+// a time and each within timeout, and records the outcome in processed.
+func (b bulkRun) run(ctx context.Context, client *maas_client.MAASClient, machines []map[string]any, eligible []int, processed []bulkResult, parallelism int, timeout time.Duration) {
+	apply := b.apply
+	if apply == nil {
+		apply = func(ctx context.Context, client *maas_client.MAASClient, machine map[string]any) (string, error) {
+			return postMachineOp(ctx, client, machine, b.op, b.form)
+		}
+	}
+
+	semaphore := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+
+	for _, i := range eligible {
+		wg.Add(1)
+		go func(result *bulkResult, machine map[string]any) {
+			defer wg.Done()
+
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			itemCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			detail, err := apply(itemCtx, client, machine)
+			if err != nil {
+				zap.L().Error(fmt.Sprintf("[%s] Failed to %s on machine %s err=%v", b.toolName, b.action, result.SystemID, err))
+				result.Result = "failed"
+				result.Detail = err.Error()
+				return
+			}
+
+			result.Result = "ok"
+			result.Detail = detail
+		}(&processed[i], machines[i])
+	}
+
+	wg.Wait()
+}
+
+// submit starts a background job for the eligible machines and returns it
+// with the machines that were left out of it.
+func (b bulkRun) submit(machines []map[string]any, eligible []int, results []bulkResult) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	selected := make([]map[string]any, 0, len(eligible))
+	for _, i := range eligible {
+		selected = append(selected, machines[i])
+	}
+
+	excluded := make([]bulkResult, 0, len(results))
+	for _, result := range results {
+		if result.Result != "" {
+			excluded = append(excluded, result)
+		}
+	}
+
+	output := map[string]any{"action": b.action, "excluded": excluded}
+
+	if len(selected) > 0 {
+		job, err := jobs.Default().Submit(b.toolName, b.op, b.form, jobTargets(selected), 0)
+		if err != nil {
+			errMsg = fmt.Sprintf("Failed to start the %s job err=%v", b.op, err)
+			zap.L().Error(fmt.Sprintf("[%s] %s", b.toolName, errMsg))
+			return mcp.NewToolResultError(errMsg), nil
+		}
+
+		output["job"] = newJobView(job, false)
+	}
+
+	jsonData, err := json.Marshal(output)
+	if err != nil {
+		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[%s] %s", b.toolName, errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+// postMachineOp sends a machine operation and returns the new status.
+func postMachineOp(ctx context.Context, client *maas_client.MAASClient, machine map[string]any, op string, form url.Values) (string, error) {
+	systemID, _ := machine["system_id"].(string)
+
+	resultData, err := client.Do(ctx, maas_client.RequestTypePost, fmt.Sprintf("/MAAS/api/2.0/machines/%s/op-%s", systemID, op), strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+
+	var updated map[string]any
+	if err := json.Unmarshal([]byte(resultData), &updated); err != nil {
+		return "", fmt.Errorf("failed to unmarshal the machine: %w", err)
+	}
+
+	status, _ := updated["status_name"].(string)
+	return status, nil
+}
+
+type BulkCommission struct{}
+
+func (BulkCommission) Create() mcp.Tool {
+	options := append(withSelection(),
+		mcp.WithBoolean(
+			"enable_ssh",
+			mcp.Description("Keep the machines reachable over SSH after commissioning. Defaults to true."),
+		),
+		withAsync(),
+		mcp.WithToolAnnotation(CreateToolAnnotation("Bulk Commission", false, true, false, true)),
+		mcp.WithDescription("Starts commissioning on many machines at once. Machines that are protected or not in a state that can be commissioned are skipped. Returns a result per machine."),
+	)
+
+	return mcp.NewTool("bulk_commission", options...)
+}
+
+func (BulkCommission) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	form := make(url.Values)
+	if request.GetBool("enable_ssh", true) {
+		form.Add("enable_ssh", "1")
+	}
+
+	return bulkRun{
+		toolName: "BulkCommission",
+		action:   "commission",
+		op:       "commission",
+		form:     form,
+		check:    statusIn("New", "Ready", "Broken", "Failed commissioning", "Failed testing"),
+	}.handle(ctx, request)
+}
+
+type BulkPower struct{}
+
+func (BulkPower) Create() mcp.Tool {
+	options := append(withSelection(),
+		mcp.WithString(
+			"action",
+			mcp.Required(),
+			mcp.Enum("on", "off", "cycle"),
+			mcp.Description("Power the machines on, off, or off and back on."),
+		),
+		mcp.WithString(
+			"stop_mode",
+			mcp.Enum("soft", "hard"),
+			mcp.Description("soft asks the OS to shut down, hard cuts the power. Defaults to hard."),
+		),
+		mcp.WithToolAnnotation(CreateToolAnnotation("Bulk Power", false, true, true, true)),
+		mcp.WithDescription("Powers many machines on, off or cycles them at once and waits, within the per-machine timeout, for each BMC to confirm the new state. Protected machines are skipped. Returns the resulting power state per machine."),
+	)
+
+	return mcp.NewTool("bulk_power", options...)
+}
+
+func (BulkPower) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	action, err := request.RequireString("action")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[BulkPower] Required parameter action not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if !slices.Contains([]string{"on", "off", "cycle"}, action) {
+		errMsg := fmt.Sprintf("unknown action %s, expected on, off or cycle", action)
+		zap.L().Error(fmt.Sprintf("[BulkPower] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	stopMode := request.GetString("stop_mode", "")
+	timeout := bulkTimeout(request)
+
+	return bulkRun{
+		toolName: "BulkPower",
+		action:   "power " + action,
+		check: func(machine map[string]any) error {
+			if powerType, _ := machine["power_type"].(string); powerType == "manual" && action == "cycle" {
+				return fmt.Errorf("manual power control cannot be power cycled")
+			}
+			return nil
+		},
+		apply: func(ctx context.Context, client *maas_client.MAASClient, machine map[string]any) (string, error) {
+			offForm := make(url.Values)
+			if stopMode != "" {
+				offForm.Add("stop_mode", stopMode)
+			}
+
+			var result powerResult
+			var err error
+
+			switch action {
+			case "on":
+				result, err = changePower(ctx, client, machine, true, url.Values{}, timeout)
+			case "off":
+				result, err = changePower(ctx, client, machine, false, offForm, timeout)
+			case "cycle":
+				result, err = changePower(ctx, client, machine, false, offForm, timeout/2)
+				if err == nil && result.Reached {
+					result, err = changePower(ctx, client, machine, true, url.Values{}, timeout/2)
+				}
+			}
+
+			if err != nil {
+				return "", err
+			}
+
+			if result.Message != "" && result.PowerState != "unknown" {
+				return "", fmt.Errorf("%s (power state %s)", result.Message, result.PowerState)
+			}
+
+			return "power state " + result.PowerState, nil
+		},
+	}.handle(ctx, request)
+}
+
+type BulkDeploy struct{}
+
+func (BulkDeploy) Create() mcp.Tool {
+	options := append(withSelection(),
+		mcp.WithString(
+			"templateId",
+			mcp.Required(),
+			mcp.Pattern("^[0-9a-z-_]*$"),
+			mcp.Description("The id of the template to deploy the machines with."),
+		),
+		mcp.WithString(
+			"templateParameters",
+			mcp.Required(),
+			mcp.Description("The parameters of the template as a JSON object, {} when it takes none. The same user data is sent to every machine."),
+		),
+		mcp.WithString(
+			"distro_series",
+			mcp.Description("The OS release to deploy (e.g. jammy, noble). Defaults to the MAAS default distro series."),
+		),
+		withAsync(),
+		mcp.WithToolAnnotation(CreateToolAnnotation("Bulk Deploy", false, true, false, true)),
+		mcp.WithDescription("Deploys many Ready or Allocated machines with the same template at once. Other machines, protected ones and those that do not meet the template requirements are skipped. Returns a result per machine."),
+	)
+
+	return mcp.NewTool("bulk_deploy", options...)
+}
+
+func (BulkDeploy) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	templateId, err := request.RequireString("templateId")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[BulkDeploy] Required parameter templateId not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	parameters, err := request.RequireString("templateParameters")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[BulkDeploy] Required parameter templateParameters not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	templateExecutor, err := templates.RetrieveExecutor(templateId, parameters)
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[BulkDeploy] Failed to retrieve the template executor for parameters %s.", parameters))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	userData, err := templateExecutor.Execute()
+	if err != nil {
+		errMsg = "Failed to execute the template to retrieve the userData."
+		zap.L().Error(fmt.Sprintf("[BulkDeploy] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	description, err := templates.Template(templateId)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to retrieve description for template with id %s: %v", templateId, err)
+		zap.L().Error(fmt.Sprintf("[BulkDeploy] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	distroSeries := request.GetString("distro_series", "")
+
+	form := make(url.Values)
+	form.Add("user_data", userData)
+	if distroSeries != "" {
+		form.Add("distro_series", distroSeries)
+	}
+
+	deployable := statusIn("Ready", "Allocated")
+
+	return bulkRun{
+		toolName: "BulkDeploy",
+		action:   "deploy " + templateId,
+		op:       "deploy",
+		form:     form,
+		check: func(machine map[string]any) error {
+			if err := deployable(machine); err != nil {
+				return err
+			}
+
+			if warnings := description.CheckMachine(machine, distroSeries); len(warnings) > 0 {
+				return fmt.Errorf("machine does not meet the template requirements: %s", strings.Join(warnings, "; "))
+			}
+
+			return nil
+		},
+	}.handle(ctx, request)
+}
+
+type BulkRelease struct{}
+
+func (BulkRelease) Create() mcp.Tool {
+	options := append(withSelection(),
+		mcp.WithBoolean(
+			"erase",
+			mcp.Description("Erase the disks of the machines while releasing them."),
+		),
+		mcp.WithBoolean(
+			"quick_erase",
+			mcp.Description("With erase, only wipe the start and end of each disk."),
+		),
+		mcp.WithString(
+			"comment",
+			mcp.Description("Reason for releasing the machines, recorded in the machine events."),
+		),
+		withAsync(),
+		mcp.WithToolAnnotation(CreateToolAnnotation("Bulk Release", false, true, false, true)),
+		mcp.WithDescription("Releases many allocated or deployed machines at once, returning them to Ready. Protected and locked machines are skipped. Returns a result per machine."),
+	)
+
+	return mcp.NewTool("bulk_release", options...)
+}
+
+func (BulkRelease) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	form := make(url.Values)
+	if request.GetBool("erase", false) {
+		form.Add("erase", "true")
+		if request.GetBool("quick_erase", false) {
+			form.Add("quick_erase", "true")
+		}
+	}
+	if comment := request.GetString("comment", ""); comment != "" {
+		form.Add("comment", comment)
+	}
+
+	releasable := statusIn("Allocated", "Deployed", "Failed deployment", "Failed releasing", "Failed disk erasing", "Broken")
+
+	return bulkRun{
+		toolName: "BulkRelease",
+		action:   "release",
+		op:       "release",
+		form:     form,
+		check: func(machine map[string]any) error {
+			if err := releasable(machine); err != nil {
+				return err
+			}
+			return locked(false)(machine)
+		},
+	}.handle(ctx, request)
+}
+
+type BulkTag struct{}
+
+func (BulkTag) Create() mcp.Tool {
+	options := append(withSelection(),
+		mcp.WithString(
+			"name",
+			mcp.Required(),
+			mcp.Description("The tag to add or remove. It must already exist."),
+		),
+		mcp.WithString(
+			"action",
+			mcp.Enum("add", "remove"),
+			mcp.Description("Add the tag to the machines or remove it. Defaults to add."),
+		),
+		mcp.WithToolAnnotation(CreateToolAnnotation("Bulk Tag", false, false, true, true)),
+		mcp.WithDescription("Adds a tag to, or removes it from, many machines at once. Protected machines are skipped. Returns a result per machine."),
+	)
+
+	return mcp.NewTool("bulk_tag", options...)
+}
+
+func (BulkTag) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	name, err := request.RequireString("name")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[BulkTag] Required parameter name not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if name == "protected" {
+		return mcp.NewToolResultError("the protected tag cannot be changed in bulk"), nil
+	}
+
+	action := request.GetString("action", "add")
+	if action != "add" && action != "remove" {
+		errMsg := fmt.Sprintf("unknown action %s, expected add or remove", action)
+		zap.L().Error(fmt.Sprintf("[BulkTag] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	return bulkRun{
+		toolName: "BulkTag",
+		action:   fmt.Sprintf("%s tag %s", action, name),
+		apply: func(ctx context.Context, client *maas_client.MAASClient, machine map[string]any) (string, error) {
+			systemID, _ := machine["system_id"].(string)
+
+			form := make(url.Values)
+			form.Add(action, systemID)
+
+			resultData, err := client.Do(ctx, maas_client.RequestTypePost, fmt.Sprintf("/MAAS/api/2.0/tags/%s/op-update_nodes", url.PathEscape(name)), strings.NewReader(form.Encode()))
+			if err != nil {
+				return "", err
+			}
+
+			var counts struct {
+				Added   int `json:"added"`
+				Removed int `json:"removed"`
+			}
+			if err := json.Unmarshal([]byte(resultData), &counts); err != nil {
+				return "", fmt.Errorf("failed to unmarshal the tag update: %w", err)
+			}
+
+			return fmt.Sprintf("added %d, removed %d", counts.Added, counts.Removed), nil
+		},
+	}.handle(ctx, request)
+}
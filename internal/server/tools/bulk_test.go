@@ -0,0 +1,166 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/JarcauCristian/ztp-mcp/internal/server/maas_client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func bulkRequest(arguments map[string]any) mcp.CallToolRequest {
+	var request mcp.CallToolRequest
+	request.Params.Arguments = arguments
+	return request
+}
+
+func TestSelectMachines(t *testing.T) {
+	var query url.Values
+	client := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		w.Write([]byte(`[{"system_id": "abc123", "hostname": "node1"}, {"system_id": "def456", "hostname": "node2"}]`))
+	})
+
+	request := bulkRequest(map[string]any{"system_ids": " abc123, def456 ,,ghi789", "tag": "rack1", "status": "ready"})
+
+	machines, missing, err := selectMachines(context.Background(), client, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Join(query["id"], ","); got != "abc123,def456,ghi789" {
+		t.Errorf("id query = %s, want abc123,def456,ghi789", got)
+	}
+	if query.Get("tags") != "rack1" || query.Get("status") != "ready" || query.Has("pool") || query.Has("zone") {
+		t.Errorf("query = %v, want tags=rack1 and status=ready only", query)
+	}
+
+	if len(machines) != 2 {
+		t.Errorf("got %d machines, want 2", len(machines))
+	}
+	if len(missing) != 1 || missing[0].SystemID != "ghi789" || missing[0].Result != "failed" {
+		t.Errorf("missing = %+v, want ghi789 failed", missing)
+	}
+}
+
+func TestSelectMachinesWithoutSelectors(t *testing.T) {
+	client := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s", r.URL)
+	})
+
+	if _, _, err := selectMachines(context.Background(), client, bulkRequest(map[string]any{"system_ids": " , "})); err == nil {
+		t.Fatal("expected an error without selectors")
+	}
+}
+
+func TestBulkRunScreen(t *testing.T) {
+	machines := []map[string]any{
+		{"system_id": "abc123", "hostname": "node1", "status_name": "Ready"},
+		{"system_id": "def456", "hostname": "node2", "status_name": "Ready", "tag_names": []any{"protected"}},
+		{"system_id": "ghi789", "hostname": "node3", "status_name": "Deployed"},
+	}
+
+	processed, eligible := bulkRun{check: statusIn("Ready")}.screen(machines)
+
+	if len(eligible) != 1 || eligible[0] != 0 {
+		t.Errorf("eligible = %v, want [0]", eligible)
+	}
+
+	want := []bulkResult{
+		{SystemID: "abc123", Hostname: "node1"},
+		{SystemID: "def456", Hostname: "node2", Result: "skipped", Detail: "machine is protected"},
+		{SystemID: "ghi789", Hostname: "node3", Result: "skipped", Detail: "the machine is Deployed, expected Ready"},
+	}
+	for i := range want {
+		if processed[i] != want[i] {
+			t.Errorf("result %d = %+v, want %+v", i, processed[i], want[i])
+		}
+	}
+}
+
+func TestBulkRunRun(t *testing.T) {
+	machines := []map[string]any{
+		{"system_id": "ok0001"},
+		{"system_id": "ok0002"},
+		{"system_id": "fail01"},
+		{"system_id": "slow01"},
+		{"system_id": "skip01"},
+	}
+
+	var running, peak atomic.Int32
+
+	b := bulkRun{
+		toolName: "BulkTest",
+		action:   "test",
+		apply: func(ctx context.Context, client *maas_client.MAASClient, machine map[string]any) (string, error) {
+			current := running.Add(1)
+			defer running.Add(-1)
+			for {
+				previous := peak.Load()
+				if current <= previous || peak.CompareAndSwap(previous, current) {
+					break
+				}
+			}
+
+			switch systemID := machine["system_id"].(string); systemID {
+			case "fail01":
+				return "", errors.New("boom")
+			case "slow01":
+				<-ctx.Done()
+				return "", ctx.Err()
+			default:
+				time.Sleep(10 * time.Millisecond)
+				return "done " + systemID, nil
+			}
+		},
+	}
+
+	processed := make([]bulkResult, len(machines))
+	for i, machine := range machines {
+		processed[i].SystemID = machine["system_id"].(string)
+	}
+	processed[4].Result = "skipped"
+
+	b.run(context.Background(), nil, machines, []int{0, 1, 2, 3}, processed, 2, 50*time.Millisecond)
+
+	want := []struct{ result, detail string }{
+		{"ok", "done ok0001"},
+		{"ok", "done ok0002"},
+		{"failed", "boom"},
+		{"failed", context.DeadlineExceeded.Error()},
+		{"skipped", ""},
+	}
+	for i, w := range want {
+		if processed[i].Result != w.result || processed[i].Detail != w.detail {
+			t.Errorf("%s = %s %q, want %s %q", processed[i].SystemID, processed[i].Result, processed[i].Detail, w.result, w.detail)
+		}
+	}
+
+	if peak.Load() > 2 {
+		t.Errorf("%d machines ran at the same time, want at most 2", peak.Load())
+	}
+}
+
+func TestBulkTimeout(t *testing.T) {
+	tests := []struct {
+		arguments map[string]any
+		want      time.Duration
+	}{
+		{map[string]any{}, 120 * time.Second},
+		{map[string]any{"timeout": 30}, 30 * time.Second},
+		{map[string]any{"timeout": 0}, time.Second},
+		{map[string]any{"timeout": -5}, time.Second},
+	}
+
+	for _, test := range tests {
+		if got := bulkTimeout(bulkRequest(test.arguments)); got != test.want {
+			t.Errorf("bulkTimeout(%v) = %s, want %s", test.arguments, got, test.want)
+		}
+	}
+}
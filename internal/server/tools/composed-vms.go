@@ -0,0 +1,372 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/JarcauCristian/ztp-mcp/internal/server/maas_client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.uber.org/zap"
+)
+
+type DeleteComposedVM struct{}
+
+func (DeleteComposedVM) Create() mcp.Tool {
+	return mcp.NewTool(
+		"delete_composed_vm",
+		mcp.WithString(
+			"system_id",
+			mcp.Required(),
+			mcp.Pattern("^[0-9a-z]{6}$"),
+			mcp.Description("The system ID of the composed VM."),
+		),
+		mcp.WithBoolean(
+			"force",
+			mcp.Description("Also delete the VM when it is Deployed."),
+		),
+		mcp.WithToolAnnotation(CreateToolAnnotation("Delete Composed VM", false, true, true, true)),
+		mcp.WithDescription("Decomposes a VM composed on a VM host, freeing its cores, memory and disks on the host, and removes its machine from MAAS. Protected and locked VMs are refused, and Deployed VMs need force."),
+	)
+}
+
+func (DeleteComposedVM) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	systemID, err := request.RequireString("system_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[DeleteComposedVM] Required parameter system_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	client := maas_client.MustClient()
+
+	machine, err := RetrieveMachine(ctx, client, systemID)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to retrieve the machine with id %s err=%v", systemID, err)
+		zap.L().Error(fmt.Sprintf("[DeleteComposedVM] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	pod, _ := machine["pod"].(map[string]any)
+	if pod == nil {
+		errMsg = fmt.Sprintf("machine %s was not composed on a VM host", systemID)
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	if err := locked(false)(machine); err != nil {
+		errMsg = fmt.Sprintf("Cannot delete VM %s: %v", systemID, err)
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	if status, _ := machine["status_name"].(string); status == "Deployed" && !request.GetBool("force", false) {
+		errMsg = fmt.Sprintf("VM %s is Deployed, set force to delete it anyway", systemID)
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	path := fmt.Sprintf("/MAAS/api/2.0/machines/%s/", systemID)
+
+	return performRequest(ctx, client, "DeleteComposedVM", systemID, maas_client.RequestTypeDelete, path, nil, fmt.Sprintf("decompose the VM from VM host %v", pod["name"]))
+}
+
+type orphanFinding struct {
+	Kind     string `json:"kind"`
+	Host     string `json:"host,omitempty"`
+	SystemID string `json:"system_id,omitempty"`
+	Hostname string `json:"hostname,omitempty"`
+	Detail   string `json:"detail"`
+}
+
+// hostInventory compares the VMs a VM host reports with the machines MAAS
+// has composed on it.
+// hostInventory summarises a VM host. ComparedBy tells whether its VMs were
+// matched to machines by system ID or, when the host does not report its VMs,
+// by the cores and memory in use.
+type hostInventory struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	Project       string `json:"project,omitempty"`
+	ComparedBy    string `json:"compared_by"`
+	VMs           int    `json:"vms,omitempty"`
+	Machines      int    `json:"machines"`
+	UsedCores     int    `json:"used_cores,omitempty"`
+	MachineCores  int    `json:"machine_cores,omitempty"`
+	UsedMemory    int    `json:"used_memory,omitempty"`
+	MachineMemory int    `json:"machine_memory,omitempty"`
+}
+
+// hostVM is a VM as a VM host reports it. SystemID is the MAAS machine of
+// the VM, empty when it has none.
+type hostVM struct {
+	Name     string `json:"name"`
+	Project  string `json:"project,omitempty"`
+	SystemID string `json:"system_id,omitempty"`
+}
+
+// hostVMs returns the VMs a VM host reports in its resources, and false when
+// the host does not report them.
+func hostVMs(vmHost map[string]any) ([]hostVM, bool) {
+	resources, _ := vmHost["resources"].(map[string]any)
+	entries, ok := resources["vms"].([]any)
+	if !ok {
+		return nil, false
+	}
+
+	vms := make([]hostVM, 0, len(entries))
+	for _, entry := range entries {
+		vm, _ := entry.(map[string]any)
+
+		var parsed hostVM
+		parsed.Name, _ = vm["name"].(string)
+		parsed.Project, _ = vm["project"].(string)
+		parsed.SystemID, _ = vm["system_id"].(string)
+		vms = append(vms, parsed)
+	}
+
+	return vms, true
+}
+
+// reconcileHost matches the VMs of a host with the machines MAAS composed on
+// it by system ID. VMs of other projects than the one MAAS uses are left out.
+func reconcileHost(inventory *hostInventory, vms []hostVM, machines []map[string]any) []orphanFinding {
+	var findings []orphanFinding
+
+	inventory.ComparedBy = "system_id"
+	project := inventory.projectSuffix()
+
+	onHost := make(map[string]bool)
+	for _, vm := range vms {
+		if inventory.Project != "" && vm.Project != "" && vm.Project != inventory.Project {
+			continue
+		}
+
+		inventory.VMs++
+
+		if vm.SystemID == "" {
+			findings = append(findings, orphanFinding{
+				Kind:     "vm_not_in_maas",
+				Host:     inventory.Name,
+				Hostname: vm.Name,
+				Detail:   fmt.Sprintf("VM %s runs on the host%s without a MAAS machine", vm.Name, project),
+			})
+			continue
+		}
+
+		onHost[vm.SystemID] = true
+	}
+
+	for _, machine := range machines {
+		systemID, _ := machine["system_id"].(string)
+		hostname, _ := machine["hostname"].(string)
+
+		inventory.Machines++
+
+		if !onHost[systemID] {
+			findings = append(findings, orphanFinding{
+				Kind:     "machine_not_on_host",
+				Host:     inventory.Name,
+				SystemID: systemID,
+				Hostname: hostname,
+				Detail:   fmt.Sprintf("the machine was composed on the host%s but its VM is not there", project),
+			})
+		}
+	}
+
+	return findings
+}
+
+// reconcileUsage compares the cores and memory a host has in use with those
+// of the machines MAAS composed on it. It is used for hosts that do not report
+// their VMs, and can only tell that some VM is unaccounted for, not which.
+func reconcileUsage(inventory *hostInventory, vmHost map[string]any, machines []map[string]any) []orphanFinding {
+	inventory.ComparedBy = "usage"
+
+	used, _ := vmHost["used"].(map[string]any)
+	inventory.UsedCores = int(number(used["cores"]))
+	inventory.UsedMemory = int(number(used["memory"]))
+
+	for _, machine := range machines {
+		inventory.Machines++
+		inventory.MachineCores += int(number(machine["cpu_count"]))
+		inventory.MachineMemory += int(number(machine["memory"]))
+	}
+
+	project := inventory.projectSuffix()
+	untrackedCores := inventory.UsedCores - inventory.MachineCores
+	untrackedMemory := inventory.UsedMemory - inventory.MachineMemory
+
+	switch {
+	case untrackedCores > 0 || untrackedMemory > 0:
+		return []orphanFinding{{
+			Kind:   "vm_not_in_maas",
+			Host:   inventory.Name,
+			Detail: fmt.Sprintf("the host%s has %d cores and %d MiB of memory in use beyond its %d MAAS machines", project, max(untrackedCores, 0), max(untrackedMemory, 0), inventory.Machines),
+		}}
+	case untrackedCores < 0 || untrackedMemory < 0:
+		return []orphanFinding{{
+			Kind:   "machine_not_on_host",
+			Host:   inventory.Name,
+			Detail: fmt.Sprintf("the %d MAAS machines of the host%s account for %d cores and %d MiB of memory more than it has in use, some of their VMs are gone", inventory.Machines, project, max(-untrackedCores, 0), max(-untrackedMemory, 0)),
+		}}
+	}
+
+	return nil
+}
+
+func (inventory hostInventory) projectSuffix() string {
+	if inventory.Project == "" {
+		return ""
+	}
+
+	return fmt.Sprintf(" project %s", inventory.Project)
+}
+
+type FindOrphanedVMs struct{}
+
+func (FindOrphanedVMs) Create() mcp.Tool {
+	return mcp.NewTool(
+		"find_orphaned_vms",
+		mcp.WithBoolean(
+			"refresh",
+			mcp.Description("Refresh every VM host first so the report reflects what is on the hosts right now rather than at their last refresh. Defaults to false."),
+		),
+		mcp.WithToolAnnotation(CreateToolAnnotation("Find Orphaned VMs", false, false, true, true)),
+		mcp.WithDescription("Cross-references the VMs of each VM host with the machines MAAS composed on it. VMs are matched by system ID when the host reports them, otherwise the cores and memory the host has in use are compared with those of its machines. Reports VMs that have no MAAS machine, MAAS machines whose VM is not on the host and MAAS machines whose VM host no longer exists."),
+	)
+}
+
+func (FindOrphanedVMs) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	client := maas_client.MustClient()
+
+	resultData, err := client.Do(ctx, maas_client.RequestTypeGet, "/MAAS/api/2.0/vm-hosts/", nil)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to retrieve the VM hosts err=%v", err)
+		zap.L().Error(fmt.Sprintf("[FindOrphanedVMs] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	var vmHosts []map[string]any
+	if err := json.Unmarshal([]byte(resultData), &vmHosts); err != nil {
+		errMsg = fmt.Sprintf("Failed to unmarshal the VM hosts err=%v", err)
+		zap.L().Error(fmt.Sprintf("[FindOrphanedVMs] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	var findings []orphanFinding
+
+	if request.GetBool("refresh", false) {
+		for i, vmHost := range vmHosts {
+			vmHostID := fmt.Sprint(vmHost["id"])
+
+			zap.L().Info(fmt.Sprintf("[FindOrphanedVMs] Refreshing VM host with ID %s...", vmHostID))
+			if _, err := client.Do(ctx, maas_client.RequestTypePost, fmt.Sprintf("/MAAS/api/2.0/vm-hosts/%s/op-refresh", vmHostID), nil); err != nil {
+				findings = append(findings, orphanFinding{Kind: "refresh_failed", Host: fmt.Sprint(vmHost["name"]), Detail: err.Error()})
+				continue
+			}
+
+			// Read the host again so the usage reflects the refresh.
+			if refreshed, err := retrieveVMHost(ctx, client, vmHostID); err == nil {
+				vmHosts[i] = refreshed
+			}
+		}
+	}
+
+	resultData, err = client.Do(ctx, maas_client.RequestTypeGet, "/MAAS/api/2.0/machines/", nil)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to retrieve the machines err=%v", err)
+		zap.L().Error(fmt.Sprintf("[FindOrphanedVMs] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	var machines []map[string]any
+	if err := json.Unmarshal([]byte(resultData), &machines); err != nil {
+		errMsg = fmt.Sprintf("Failed to unmarshal the machines err=%v", err)
+		zap.L().Error(fmt.Sprintf("[FindOrphanedVMs] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	composed := make(map[string][]map[string]any)
+	known := make(map[string]bool)
+	for _, vmHost := range vmHosts {
+		known[fmt.Sprint(vmHost["id"])] = true
+	}
+
+	for _, machine := range machines {
+		pod, _ := machine["pod"].(map[string]any)
+		if pod == nil {
+			continue
+		}
+
+		podID := fmt.Sprint(pod["id"])
+		if !known[podID] {
+			systemID, _ := machine["system_id"].(string)
+			hostname, _ := machine["hostname"].(string)
+
+			findings = append(findings, orphanFinding{
+				Kind:     "machine_without_host",
+				Host:     fmt.Sprint(pod["name"]),
+				SystemID: systemID,
+				Hostname: hostname,
+				Detail:   "the machine was composed on a VM host that is no longer in MAAS",
+			})
+			continue
+		}
+
+		composed[podID] = append(composed[podID], machine)
+	}
+
+	hosts := make([]hostInventory, 0, len(vmHosts))
+	for _, vmHost := range vmHosts {
+		inventory := hostInventory{ID: fmt.Sprint(vmHost["id"])}
+		inventory.Name, _ = vmHost["name"].(string)
+
+		if vmHostType, _ := vmHost["type"].(string); vmHostType == "lxd" {
+			parameters, err := client.Do(ctx, maas_client.RequestTypeGet, fmt.Sprintf("/MAAS/api/2.0/vm-hosts/%s/op-parameters", inventory.ID), nil)
+			if err == nil {
+				var values map[string]any
+				if json.Unmarshal([]byte(parameters), &values) == nil {
+					inventory.Project, _ = values["project"].(string)
+				}
+			}
+		}
+
+		if vms, ok := hostVMs(vmHost); ok {
+			findings = append(findings, reconcileHost(&inventory, vms, composed[inventory.ID])...)
+		} else {
+			findings = append(findings, reconcileUsage(&inventory, vmHost, composed[inventory.ID])...)
+		}
+		hosts = append(hosts, inventory)
+	}
+
+	jsonData, err := json.Marshal(map[string]any{"findings": findings, "hosts": hosts})
+	if err != nil {
+		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[FindOrphanedVMs] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	var table strings.Builder
+	writer := tabwriter.NewWriter(&table, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "KIND\tHOST\tMACHINE\tDETAIL")
+	for _, finding := range findings {
+		machine := finding.SystemID
+		if finding.Hostname != "" {
+			machine = fmt.Sprintf("%s (%s)", finding.SystemID, finding.Hostname)
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", finding.Kind, finding.Host, machine, finding.Detail)
+	}
+	writer.Flush()
+
+	result := mcp.NewToolResultText(string(jsonData))
+	if len(findings) == 0 {
+		result.Content = append(result.Content, mcp.NewTextContent("No orphaned VMs found."))
+	} else {
+		result.Content = append(result.Content, mcp.NewTextContent(table.String()))
+	}
+
+	return result, nil
+}
@@ -0,0 +1,93 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestReconcileHost(t *testing.T) {
+	var vmHost map[string]any
+	if err := json.Unmarshal([]byte(`{"resources": {"vms": [
+		{"name": "web-1", "project": "maas", "system_id": "aaa111"},
+		{"name": "stray", "project": "maas", "system_id": null},
+		{"name": "dev", "project": "default"}
+	]}}`), &vmHost); err != nil {
+		t.Fatal(err)
+	}
+
+	vms, ok := hostVMs(vmHost)
+	if !ok {
+		t.Fatal("the VMs of the host were not found")
+	}
+
+	machines := []map[string]any{
+		{"system_id": "aaa111", "hostname": "web-1"},
+		{"system_id": "bbb222", "hostname": "web-2"},
+	}
+
+	inventory := hostInventory{Name: "kvm-1", Project: "maas"}
+	findings := reconcileHost(&inventory, vms, machines)
+
+	var got []string
+	for _, finding := range findings {
+		got = append(got, fmt.Sprintf("%s %s %s", finding.Kind, finding.SystemID, finding.Hostname))
+	}
+
+	want := []string{"vm_not_in_maas  stray", "machine_not_on_host bbb222 web-2"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("findings = %q, want %q", got, want)
+	}
+	if inventory.VMs != 2 || inventory.Machines != 2 {
+		t.Errorf("inventory = %+v, want 2 VMs in the project and 2 machines", inventory)
+	}
+
+	if _, ok := hostVMs(map[string]any{"name": "kvm-2"}); ok {
+		t.Error("a host without resources reported its VMs")
+	}
+}
+
+func TestReconcileUsage(t *testing.T) {
+	machines := []map[string]any{
+		{"system_id": "aaa111", "cpu_count": 2.0, "memory": 4096.0},
+		{"system_id": "bbb222", "cpu_count": 4.0, "memory": 8192.0},
+	}
+
+	tests := []struct {
+		name string
+		used string
+		want string
+	}{
+		{"matching", `{"cores": 6, "memory": 12288}`, ""},
+		{"more in use", `{"cores": 8, "memory": 12288}`, "vm_not_in_maas the host project maas has 2 cores and 0 MiB of memory in use beyond its 2 MAAS machines"},
+		{"less in use", `{"cores": 2, "memory": 4096}`, "machine_not_on_host the 2 MAAS machines of the host project maas account for 4 cores and 8192 MiB of memory more than it has in use, some of their VMs are gone"},
+		{"no usage reported", `null`, "machine_not_on_host the 2 MAAS machines of the host project maas account for 6 cores and 12288 MiB of memory more than it has in use, some of their VMs are gone"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var vmHost map[string]any
+			if err := json.Unmarshal([]byte(`{"name": "kvm-1", "used": `+test.used+`}`), &vmHost); err != nil {
+				t.Fatal(err)
+			}
+
+			inventory := hostInventory{Name: "kvm-1", Project: "maas"}
+			findings := reconcileUsage(&inventory, vmHost, machines)
+
+			got := ""
+			if len(findings) > 1 {
+				t.Fatalf("findings = %+v, want at most one", findings)
+			}
+			if len(findings) == 1 {
+				got = findings[0].Kind + " " + findings[0].Detail
+			}
+			if got != test.want {
+				t.Errorf("finding = %q, want %q", got, test.want)
+			}
+
+			if inventory.ComparedBy != "usage" || inventory.Machines != 2 || inventory.MachineCores != 6 || inventory.MachineMemory != 12288 {
+				t.Errorf("inventory = %+v, want usage compared over 2 machines with 6 cores and 12288 MiB", inventory)
+			}
+		})
+	}
+}
@@ -0,0 +1,622 @@
+package tools
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JarcauCristian/ztp-mcp/internal/server/maas_client"
+	"github.com/JarcauCristian/ztp-mcp/internal/server/templates"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+type Diagnostics struct{}
+
+func (Diagnostics) Register(mcpServer *server.MCPServer) {
+	mcpTools := []MCPTool{DiagnoseMachine{}}
+
+	for _, tool := range mcpTools {
+		mcpServer.AddTool(tool.Create(), tool.Handle)
+	}
+}
+
+// diagnosis holds everything collected about a machine. Collection errors
+// are kept instead of aborting so the rules can work with partial data.
+type diagnosis struct {
+	Machine        map[string]any   `json:"-"`
+	Status         string           `json:"status"`
+	StatusMessage  string           `json:"status_message,omitempty"`
+	PowerState     string           `json:"power_state,omitempty"`
+	PowerError     string           `json:"power_error,omitempty"`
+	BootInterface  map[string]any   `json:"boot_interface,omitempty"`
+	BootVlan       map[string]any   `json:"boot_vlan,omitempty"`
+	BootSubnets    []map[string]any `json:"boot_subnets,omitempty"`
+	Events         []map[string]any `json:"events"`
+	FailedScripts  []scriptResult   `json:"failed_scripts"`
+	UserDataIssues []string         `json:"user_data_issues,omitempty"`
+	UserData       string           `json:"user_data,omitempty"`
+	Errors         []string         `json:"collection_errors,omitempty"`
+}
+
+type likelyCause struct {
+	Cause       string   `json:"cause"`
+	Confidence  float64  `json:"confidence"`
+	Evidence    []string `json:"evidence"`
+	NextActions []string `json:"next_actions"`
+}
+
+// diagnosisRule is a known failure signature. Check returns a confidence
+// between 0 and 1 and the evidence that supports it.
+type diagnosisRule struct {
+	Cause       string
+	NextActions []string
+	Check       func(d *diagnosis) (float64, []string)
+}
+
+// Failure signatures, anchored on the messages MAAS and curtin log rather than
+// on single words such as "disk" that also show up in healthy output.
+var (
+	powerFailurePattern = regexp.MustCompile(`(?i)(failed to (power (on|off|cycle)|query node'?s? (bmc|power))|power (on|off|cycle|query) failed|\b(ipmi|redfish|bmc)\b.*\b(error|failed|timed out|unreachable)\b)`)
+	timeoutPattern      = regexp.MustCompile(`(?i)(timed out after \d+ minutes?|node operation '[^']+' timed out)`)
+	pxeRequestPattern   = regexp.MustCompile(`(?i)\b(pxe request|performing pxe boot|tftp request)\b`)
+	ephemeralPattern    = regexp.MustCompile(`(?i)\bloading ephemeral\b`)
+	storageErrorPattern = regexp.MustCompile(`(?i)(failed to (find|exclusively open) (device|path)|no space left on device|wipefs: error|mdadm: (error|cannot|fail)|\b(sgdisk|parted|partprobe)\b.*\b(error|failed|invalid)\b|unable to (find|locate) (disk|device)|device or resource busy|no such file or directory: '/dev/|an error occur+ed handling '[^']+')`)
+	userDataPattern     = regexp.MustCompile(`(?i)(yaml\.\w+\.\w*error|failed loading yaml|invalid (cloud-config|user-data)|cloud-config.*schema (error|failed))`)
+	imageFailurePattern = regexp.MustCompile(`(?i)(failed to download|unable to fetch|no boot images?|boot image .*not (found|available)|image not found)`)
+)
+
+var diagnosisRules = []diagnosisRule{
+	{
+		Cause: "BMC unreachable or wrong power parameters",
+		NextActions: []string{
+			"Check the BMC address and credentials of the machine in MAAS.",
+			"Verify the rack controller can reach the BMC network.",
+			"Retry power_state once the BMC responds.",
+		},
+		Check: func(d *diagnosis) (float64, []string) {
+			var evidence []string
+			score := 0.0
+
+			if d.PowerError != "" {
+				score += 0.6
+				evidence = append(evidence, fmt.Sprintf("power query failed: %s", d.PowerError))
+			}
+
+			if d.PowerState == "error" || d.PowerState == "unknown" {
+				score += 0.3
+				evidence = append(evidence, fmt.Sprintf("power state is %s", d.PowerState))
+			}
+
+			if event := d.findEvent(powerFailurePattern); event != "" {
+				score += 0.3
+				evidence = append(evidence, fmt.Sprintf("event: %s", event))
+			}
+
+			return score, evidence
+		},
+	},
+	{
+		Cause: "PXE boot timeout",
+		NextActions: []string{
+			"Confirm the machine is set to network boot first in its firmware.",
+			"Check that the boot interface is cabled to the VLAN MAAS provides DHCP on.",
+			"Look at the rack controller logs for TFTP or HTTP boot requests from the machine.",
+		},
+		Check: func(d *diagnosis) (float64, []string) {
+			var evidence []string
+			score := 0.0
+
+			// Without a PXE event the machine never reached the boot stage, so a
+			// timeout is left to the other rules.
+			pxeEvent := d.findEvent(pxeRequestPattern)
+			if pxeEvent == "" {
+				return 0, nil
+			}
+
+			if event := d.findEvent(timeoutPattern); event != "" {
+				score += 0.5
+				evidence = append(evidence, fmt.Sprintf("event: %s", event))
+			}
+
+			if d.findEvent(ephemeralPattern) == "" {
+				score += 0.3
+				evidence = append(evidence, fmt.Sprintf("event: %s, but the ephemeral environment never loaded", pxeEvent))
+			}
+
+			if timeoutPattern.MatchString(d.StatusMessage) {
+				score += 0.2
+				evidence = append(evidence, fmt.Sprintf("status message: %s", d.StatusMessage))
+			}
+
+			return score, evidence
+		},
+	},
+	{
+		Cause: "No DHCP on the boot VLAN",
+		NextActions: []string{
+			"Enable DHCP on the boot VLAN with update_vlan (dhcp_on, primary_rack) or configure a relay_vlan.",
+			"Make sure the subnet on the VLAN has a dynamic IP range.",
+		},
+		Check: func(d *diagnosis) (float64, []string) {
+			if d.BootVlan == nil {
+				return 0, nil
+			}
+
+			dhcpOn, _ := d.BootVlan["dhcp_on"].(bool)
+			relayVlan := d.BootVlan["relay_vlan"]
+
+			if dhcpOn || relayVlan != nil {
+				return 0, nil
+			}
+
+			return 0.9, []string{fmt.Sprintf("DHCP is disabled on VLAN %v of fabric %v and no relay is set", d.BootVlan["vid"], d.BootVlan["fabric"])}
+		},
+	},
+	{
+		Cause: "Boot subnet has no free addresses",
+		NextActions: []string{
+			"Release unused addresses or extend the dynamic range of the subnet.",
+			"Check subnet_unreserved_ip_ranges for space to grow the range.",
+		},
+		Check: func(d *diagnosis) (float64, []string) {
+			var evidence []string
+			score := 0.0
+
+			for _, subnet := range d.BootSubnets {
+				statistics, _ := subnet["statistics"].(map[string]any)
+				if statistics == nil {
+					continue
+				}
+
+				available, _ := statistics["num_available"].(float64)
+				usage, _ := statistics["usage"].(float64)
+
+				if available == 0 || usage >= 0.95 {
+					score = 0.7
+					evidence = append(evidence, fmt.Sprintf("subnet %v has %d available addresses (%.0f%% used)", subnet["cidr"], int(available), usage*100))
+				}
+			}
+
+			return score, evidence
+		},
+	},
+	{
+		Cause: "Curtin storage or disk error",
+		NextActions: []string{
+			"Review the storage layout of the machine and reset it to a default layout.",
+			"Run test_machine with storage tests to check the disks.",
+			"Run machine_installation_log to read the full curtin output.",
+		},
+		Check: func(d *diagnosis) (float64, []string) {
+			var evidence []string
+			score := 0.0
+
+			for _, script := range d.FailedScripts {
+				if script.ResultSet != "Installation" {
+					continue
+				}
+
+				if line := matchingLine(script.Output+"\n"+script.Stderr, storageErrorPattern); line != "" {
+					score = 0.8
+					evidence = append(evidence, fmt.Sprintf("installation log: %s", line))
+				}
+			}
+
+			if event := d.findEvent(storageErrorPattern); event != "" {
+				score += 0.1
+				evidence = append(evidence, fmt.Sprintf("event: %s", event))
+			}
+
+			return score, evidence
+		},
+	},
+	{
+		Cause: "Invalid cloud-init user data",
+		NextActions: []string{
+			"Fix the template with retrieve_template_content and redeploy.",
+			"Validate the rendered cloud-config with `cloud-init schema --config-file`.",
+		},
+		Check: func(d *diagnosis) (float64, []string) {
+			var evidence []string
+			score := 0.0
+
+			if len(d.UserDataIssues) > 0 {
+				score = 0.9
+				evidence = append(evidence, d.UserDataIssues...)
+			}
+
+			for _, script := range d.FailedScripts {
+				if line := matchingLine(script.Output+"\n"+script.Stderr, userDataPattern); line != "" {
+					score += 0.2
+					evidence = append(evidence, fmt.Sprintf("%s: %s", script.Name, line))
+				}
+			}
+
+			return score, evidence
+		},
+	},
+	{
+		Cause: "OS image could not be downloaded",
+		NextActions: []string{
+			"Check that the requested distro series is imported and synced on the rack controllers.",
+			"Check the proxy and network access of the boot subnet.",
+		},
+		Check: func(d *diagnosis) (float64, []string) {
+			if event := d.findEvent(imageFailurePattern); event != "" {
+				return 0.7, []string{fmt.Sprintf("event: %s", event)}
+			}
+
+			return 0, nil
+		},
+	},
+	{
+		Cause: "Hardware failed commissioning or testing",
+		NextActions: []string{
+			"Read the failed script output with machine_script_results.",
+			"Replace or reseat the failing component, then run commission_machine again.",
+		},
+		Check: func(d *diagnosis) (float64, []string) {
+			var evidence []string
+
+			for _, script := range d.FailedScripts {
+				if script.ResultSet == "Commissioning" || script.ResultSet == "Testing" {
+					evidence = append(evidence, fmt.Sprintf("%s script %s is %s", strings.ToLower(script.ResultSet), script.Name, script.Status))
+				}
+			}
+
+			if len(evidence) == 0 {
+				return 0, nil
+			}
+
+			return 0.6, evidence
+		},
+	},
+	{
+		Cause: "Boot interface is not connected to a subnet",
+		NextActions: []string{
+			"Link the boot interface to a subnet or set it to DHCP.",
+			"Recommission the machine so MAAS rediscovers its network links.",
+		},
+		Check: func(d *diagnosis) (float64, []string) {
+			if d.Machine == nil {
+				return 0, nil
+			}
+
+			if d.BootInterface == nil {
+				return 0.5, []string{"the machine has no boot interface"}
+			}
+
+			if d.BootVlan == nil {
+				return 0.5, []string{fmt.Sprintf("boot interface %v is not on a VLAN", d.BootInterface["name"])}
+			}
+
+			return 0, nil
+		},
+	},
+}
+
+// findEvent returns the first event whose type or description matches the
+// pattern.
+func (d *diagnosis) findEvent(pattern *regexp.Regexp) string {
+	for _, event := range d.Events {
+		eventType, _ := event["type"].(string)
+		description, _ := event["description"].(string)
+		text := strings.TrimSpace(eventType + " " + description)
+
+		if pattern.MatchString(text) {
+			return text
+		}
+	}
+
+	return ""
+}
+
+// matchingLine returns the first line of text matching the pattern.
+func matchingLine(text string, pattern *regexp.Regexp) string {
+	for _, line := range strings.Split(text, "\n") {
+		if pattern.MatchString(line) {
+			return strings.TrimSpace(line)
+		}
+	}
+
+	return ""
+}
+
+func (d *diagnosis) causes() []likelyCause {
+	var causes []likelyCause
+
+	for _, rule := range diagnosisRules {
+		score, evidence := rule.Check(d)
+		if score <= 0 {
+			continue
+		}
+
+		causes = append(causes, likelyCause{
+			Cause:       rule.Cause,
+			Confidence:  math.Round(min(score, 1)*100) / 100,
+			Evidence:    evidence,
+			NextActions: rule.NextActions,
+		})
+	}
+
+	sort.SliceStable(causes, func(i, j int) bool {
+		return causes[i].Confidence > causes[j].Confidence
+	})
+
+	return causes
+}
+
+// collectNetwork reads the VLAN and subnets of the boot interface.
+func (d *diagnosis) collectNetwork(ctx context.Context, client *maas_client.MAASClient) {
+	bootInterface, _ := d.Machine["boot_interface"].(map[string]any)
+	if bootInterface == nil {
+		return
+	}
+
+	d.BootInterface = map[string]any{
+		"name":           bootInterface["name"],
+		"mac_address":    bootInterface["mac_address"],
+		"link_connected": bootInterface["link_connected"],
+		"links":          bootInterface["links"],
+	}
+
+	vlan, _ := bootInterface["vlan"].(map[string]any)
+	if vlan != nil {
+		fabricID, _ := vlan["fabric_id"].(float64)
+		vid, _ := vlan["vid"].(float64)
+
+		path := fmt.Sprintf("/MAAS/api/2.0/fabrics/%d/vlans/%d/", int(fabricID), int(vid))
+
+		resultData, err := client.Do(ctx, maas_client.RequestTypeGet, path, nil)
+		if err == nil {
+			err = json.Unmarshal([]byte(resultData), &d.BootVlan)
+		}
+		if err != nil {
+			d.BootVlan = vlan
+			d.Errors = append(d.Errors, fmt.Sprintf("boot vlan: %v", err))
+		}
+	}
+
+	links, _ := bootInterface["links"].([]any)
+	for _, link := range links {
+		linkData, _ := link.(map[string]any)
+		subnet, _ := linkData["subnet"].(map[string]any)
+		if subnet == nil {
+			continue
+		}
+
+		subnetID, _ := subnet["id"].(float64)
+		summary := map[string]any{"id": int(subnetID), "cidr": subnet["cidr"], "mode": linkData["mode"]}
+
+		path := fmt.Sprintf("/MAAS/api/2.0/subnets/%d/op-statistics", int(subnetID))
+
+		var statistics map[string]any
+		resultData, err := client.Do(ctx, maas_client.RequestTypeGet, path, nil)
+		if err == nil {
+			err = json.Unmarshal([]byte(resultData), &statistics)
+		}
+		if err != nil {
+			d.Errors = append(d.Errors, fmt.Sprintf("subnet %d statistics: %v", int(subnetID), err))
+		} else {
+			summary["statistics"] = statistics
+		}
+
+		d.BootSubnets = append(d.BootSubnets, summary)
+	}
+}
+
+// checkUserData decodes user data as produced by TemplateExecutor and reports
+// every cloud-config document that is not valid YAML.
+func checkUserData(encoded string) (string, []string, error) {
+	userData, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if bytes.HasPrefix(userData, []byte{0x1f, 0x8b}) {
+		gzipReader, err := gzip.NewReader(bytes.NewReader(userData))
+		if err != nil {
+			return "", nil, err
+		}
+
+		userData, err = io.ReadAll(gzipReader)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	if !bytes.HasPrefix(userData, []byte("Content-Type: multipart/")) {
+		return string(userData), cloudConfigIssues("user_data", userData), nil
+	}
+
+	message, err := mail.ReadMessage(bytes.NewReader(userData))
+	if err != nil {
+		return string(userData), nil, err
+	}
+
+	_, params, err := mime.ParseMediaType(message.Header.Get("Content-Type"))
+	if err != nil {
+		return string(userData), nil, err
+	}
+
+	var issues []string
+	reader := multipart.NewReader(message.Body, params["boundary"])
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return string(userData), issues, err
+		}
+
+		content, err := io.ReadAll(part)
+		if err != nil {
+			return string(userData), issues, err
+		}
+
+		if strings.HasPrefix(part.Header.Get("Content-Type"), "text/cloud-config") {
+			issues = append(issues, cloudConfigIssues(part.FileName(), content)...)
+		}
+	}
+
+	return string(userData), issues, nil
+}
+
+func cloudConfigIssues(name string, content []byte) []string {
+	if !bytes.HasPrefix(content, []byte("#cloud-config")) {
+		return []string{fmt.Sprintf("%s does not start with #cloud-config", name)}
+	}
+
+	var document map[string]any
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return []string{fmt.Sprintf("%s is not valid YAML: %v", name, err)}
+	}
+
+	return nil
+}
+
+type DiagnoseMachine struct{}
+
+func (DiagnoseMachine) Create() mcp.Tool {
+	return mcp.NewTool(
+		"diagnose_machine",
+		mcp.WithString(
+			"system_id",
+			mcp.Required(),
+			mcp.Pattern("^[0-9a-z]{6}$"),
+			mcp.Description("The system ID of the machine to diagnose."),
+		),
+		mcp.WithNumber(
+			"events",
+			mcp.Min(1),
+			mcp.Max(500),
+			mcp.Description("The number of recent events to inspect. Defaults to 50."),
+		),
+		mcp.WithString(
+			"templateId",
+			mcp.Pattern("^[0-9a-z-_]*$"),
+			mcp.Description("The template the machine was deployed with, used to render and validate the user data."),
+		),
+		mcp.WithString(
+			"templateParameters",
+			mcp.Description("The JSON object of parameters the machine was deployed with. Required when templateId is set."),
+		),
+		mcp.WithToolAnnotation(CreateToolAnnotation("Diagnose Machine", true, false, false, true)),
+		mcp.WithDescription("Collects the status, recent events, failed script results, power state, boot interface and boot VLAN/subnet configuration of a machine and matches them against known failure signatures. Returns a ranked list of likely causes with suggested next actions."),
+	)
+}
+
+func (DiagnoseMachine) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	systemID, err := request.RequireString("system_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[DiagnoseMachine] Required parameter system_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	eventCount := request.GetInt("events", 50)
+	templateId := request.GetString("templateId", "")
+	templateParameters := request.GetString("templateParameters", "{}")
+
+	client := maas_client.MustClient()
+
+	zap.L().Info(fmt.Sprintf("[DiagnoseMachine] Diagnosing machine %s...", systemID))
+	machine, err := RetrieveMachine(ctx, client, systemID)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to retrieve the machine with id %s err=%v", systemID, err)
+		zap.L().Error(fmt.Sprintf("[DiagnoseMachine] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	d := &diagnosis{Machine: machine}
+	d.Status, _ = machine["status_name"].(string)
+	d.StatusMessage, _ = machine["status_message"].(string)
+
+	powerData, err := client.Do(ctx, maas_client.RequestTypeGet, fmt.Sprintf("/MAAS/api/2.0/machines/%s/op-query_power_state", systemID), nil)
+	if err != nil {
+		d.PowerError = err.Error()
+		d.PowerState, _ = machine["power_state"].(string)
+	} else {
+		var power map[string]any
+		if err := json.Unmarshal([]byte(powerData), &power); err == nil {
+			d.PowerState, _ = power["state"].(string)
+		}
+	}
+
+	events, err := queryEvents(ctx, client, url.Values{"id": {systemID}, "level": {"DEBUG"}, "limit": {strconv.Itoa(eventCount)}}, time.Time{})
+	if err != nil {
+		d.Errors = append(d.Errors, fmt.Sprintf("events: %v", err))
+	}
+	d.Events = events
+
+	results, err := queryScriptResults(ctx, client, systemID, "", "", true)
+	if err != nil {
+		d.Errors = append(d.Errors, fmt.Sprintf("script results: %v", err))
+	}
+	for _, result := range results {
+		if !isFailedStatus(result.Status) {
+			continue
+		}
+
+		result.Output = pageLines(result.Output, 0, 50, true)
+		result.Stderr = pageLines(result.Stderr, 0, 50, true)
+		d.FailedScripts = append(d.FailedScripts, result)
+	}
+
+	d.collectNetwork(ctx, client)
+
+	if templateId != "" {
+		executor, err := templates.RetrieveExecutor(templateId, templateParameters)
+		if err == nil {
+			var userData string
+			userData, err = executor.Execute()
+			if err == nil {
+				d.UserData, d.UserDataIssues, err = checkUserData(userData)
+			}
+		}
+		if err != nil {
+			d.UserDataIssues = append(d.UserDataIssues, fmt.Sprintf("failed to render template %s: %v", templateId, err))
+		}
+	}
+
+	report := struct {
+		SystemID     string        `json:"system_id"`
+		Hostname     any           `json:"hostname"`
+		LikelyCauses []likelyCause `json:"likely_causes"`
+		Details      *diagnosis    `json:"details"`
+	}{
+		SystemID:     systemID,
+		Hostname:     machine["hostname"],
+		LikelyCauses: d.causes(),
+		Details:      d,
+	}
+
+	jsonData, err := json.Marshal(report)
+	if err != nil {
+		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[DiagnoseMachine] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
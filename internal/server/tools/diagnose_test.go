@@ -0,0 +1,95 @@
+package tools
+
+import (
+	"testing"
+)
+
+func testEvent(eventType, description string) map[string]any {
+	return map[string]any{"type": eventType, "description": description}
+}
+
+func TestDiagnosisCauses(t *testing.T) {
+	tests := []struct {
+		name      string
+		diagnosis diagnosis
+		want      string
+		notWant   []string
+	}{
+		{
+			name: "healthy curtin output mentioning disks",
+			diagnosis: diagnosis{
+				Events: []map[string]any{testEvent("Deploying", "curtin command install"), testEvent("Loading ephemeral", "")},
+				FailedScripts: []scriptResult{{
+					ResultSet: "Installation",
+					Name:      "/tmp/install.log",
+					Output:    "start: cmd-install/stage-partitioning/builtin/cmd-block-meta\nformatting disk sda\ncreating partition sda-part1",
+				}},
+			},
+			notWant: []string{"Curtin storage or disk error", "PXE boot timeout"},
+		},
+		{
+			name: "curtin storage failure",
+			diagnosis: diagnosis{
+				FailedScripts: []scriptResult{{
+					ResultSet: "Installation",
+					Name:      "/tmp/install.log",
+					Stderr:    "An error occured handling 'sda-part1': OSError - [Errno 28] No space left on device",
+				}},
+			},
+			want: "Curtin storage or disk error",
+		},
+		{
+			name: "timeout without PXE events",
+			diagnosis: diagnosis{
+				StatusMessage: "Node operation 'Deploying' timed out after 30 minutes.",
+				Events:        []map[string]any{testEvent("Node changed status", "From 'Deploying' to 'Failed deployment'")},
+			},
+			notWant: []string{"PXE boot timeout"},
+		},
+		{
+			name: "PXE request without ephemeral boot",
+			diagnosis: diagnosis{
+				StatusMessage: "Node operation 'Commissioning' timed out after 30 minutes.",
+				Events:        []map[string]any{testEvent("PXE Request", "Machine powering on"), testEvent("Failed commissioning", "Node operation 'Commissioning' timed out after 30 minutes.")},
+			},
+			want: "PXE boot timeout",
+		},
+		{
+			name: "BMC failure",
+			diagnosis: diagnosis{
+				PowerError: "connection refused",
+				Events:     []map[string]any{testEvent("Failed to power on node", "Power on for the node failed: ipmipower error")},
+			},
+			want: "BMC unreachable or wrong power parameters",
+		},
+		{
+			name: "invalid user data",
+			diagnosis: diagnosis{
+				FailedScripts: []scriptResult{{
+					ResultSet: "Installation",
+					Name:      "/tmp/install.log",
+					Output:    "yaml.scanner.ScannerError: mapping values are not allowed here",
+				}},
+			},
+			want: "Invalid cloud-init user data",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			causes := test.diagnosis.causes()
+
+			if test.want != "" && (len(causes) == 0 || causes[0].Cause != test.want) {
+				t.Errorf("top cause = %v, want %s", causes, test.want)
+			}
+
+			for _, cause := range causes {
+				for _, notWant := range test.notWant {
+					if cause.Cause == notWant {
+						t.Errorf("unexpected cause %s with evidence %v", cause.Cause, cause.Evidence)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package dns
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/JarcauCristian/ztp-mcp/internal/server/maas_client"
+	"github.com/JarcauCristian/ztp-mcp/internal/server/tools"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+)
+
+type Domain struct{}
+
+func (Domain) Register(mcpServer *server.MCPServer) {
+	mcpTools := []tools.MCPTool{ReadDomain{}, UpdateDomain{}, DeleteDomain{}, SetDefaultDomain{}}
+
+	for _, tool := range mcpTools {
+		mcpServer.AddTool(tool.Create(), tool.Handle)
+	}
+}
+
+func withDomainID(description string) mcp.ToolOption {
+	return mcp.WithString(
+		"id",
+		mcp.Required(),
+		mcp.Pattern("^[0-9]+$"),
+		mcp.Description(description),
+	)
+}
+
+type ReadDomain struct{}
+
+func (ReadDomain) Create() mcp.Tool {
+	return mcp.NewTool(
+		"read_domain",
+		withDomainID("The ID of the domain to retrieve."),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Read Domain", true, false, false, true)),
+		mcp.WithDescription("Read a DNS domain with the given ID."),
+	)
+}
+
+func (ReadDomain) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	domainID, err := request.RequireString("id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[ReadDomain] Required parameter id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	path := fmt.Sprintf("/MAAS/api/2.0/domains/%s/", domainID)
+
+	client := maas_client.MustClient()
+
+	zap.L().Info(fmt.Sprintf("[ReadDomain] Retrieving domain with ID: %s", domainID))
+	resultData, err := client.Do(ctx, maas_client.RequestTypeGet, path, nil)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to read domain %s err=%v", domainID, err)
+		zap.L().Error(fmt.Sprintf("[ReadDomain] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	jsonData, err := json.Marshal(resultData)
+	if err != nil {
+		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[ReadDomain] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+type UpdateDomain struct{}
+
+func (UpdateDomain) Create() mcp.Tool {
+	return mcp.NewTool(
+		"update_domain",
+		withDomainID("The ID of the domain to update."),
+		mcp.WithString(
+			"name",
+			mcp.Description("Name of the domain."),
+		),
+		mcp.WithBoolean(
+			"authoritative",
+			mcp.Description("Whether MAAS is authoritative for the domain."),
+		),
+		mcp.WithNumber(
+			"ttl",
+			mcp.Min(1),
+			mcp.Description("Default TTL in seconds of the records in the domain."),
+		),
+		mcp.WithString(
+			"forward_dns_servers",
+			mcp.Description("Space separated DNS servers to forward queries for a non-authoritative domain to."),
+		),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Update Domain", false, false, true, true)),
+		mcp.WithDescription("Update a DNS domain with the given ID. Use set_default_domain to make it the default."),
+	)
+}
+
+func (UpdateDomain) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	domainID, err := request.RequireString("id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[UpdateDomain] Required parameter id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	form, err := domainForm(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if len(form) == 0 {
+		return mcp.NewToolResultError("nothing to update, set name, authoritative, ttl or forward_dns_servers"), nil
+	}
+
+	path := fmt.Sprintf("/MAAS/api/2.0/domains/%s/", domainID)
+
+	client := maas_client.MustClient()
+
+	zap.L().Info(fmt.Sprintf("[UpdateDomain] Updating domain with ID: %s", domainID))
+	resultData, err := client.Do(ctx, maas_client.RequestTypePut, path, strings.NewReader(form.Encode()))
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to update domain %s err=%v", domainID, err)
+		zap.L().Error(fmt.Sprintf("[UpdateDomain] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	jsonData, err := json.Marshal(resultData)
+	if err != nil {
+		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[UpdateDomain] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+type DeleteDomain struct{}
+
+func (DeleteDomain) Create() mcp.Tool {
+	return mcp.NewTool(
+		"delete_domain",
+		withDomainID("The ID of the domain to delete."),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Delete Domain", false, true, false, true)),
+		mcp.WithDescription("Delete a DNS domain with the given ID. MAAS refuses to delete the default domain and domains that still have records."),
+	)
+}
+
+func (DeleteDomain) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	domainID, err := request.RequireString("id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[DeleteDomain] Required parameter id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	path := fmt.Sprintf("/MAAS/api/2.0/domains/%s/", domainID)
+
+	client := maas_client.MustClient()
+
+	zap.L().Info(fmt.Sprintf("[DeleteDomain] Deleting domain with ID: %s", domainID))
+	resultData, err := client.Do(ctx, maas_client.RequestTypeDelete, path, nil)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to delete domain %s err=%v", domainID, err)
+		zap.L().Error(fmt.Sprintf("[DeleteDomain] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	jsonData, err := json.Marshal(resultData)
+	if err != nil {
+		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[DeleteDomain] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+type SetDefaultDomain struct{}
+
+func (SetDefaultDomain) Create() mcp.Tool {
+	return mcp.NewTool(
+		"set_default_domain",
+		withDomainID("The ID of the domain to make the default."),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Set Default Domain", false, false, true, true)),
+		mcp.WithDescription("Makes the DNS domain with the given ID the default, used for machines and records created without a domain."),
+	)
+}
+
+func (SetDefaultDomain) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	domainID, err := request.RequireString("id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[SetDefaultDomain] Required parameter id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	path := fmt.Sprintf("/MAAS/api/2.0/domains/%s/op-set_default", domainID)
+
+	client := maas_client.MustClient()
+
+	zap.L().Info(fmt.Sprintf("[SetDefaultDomain] Setting domain %s as the default", domainID))
+	resultData, err := client.Do(ctx, maas_client.RequestTypePost, path, strings.NewReader(""))
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to set domain %s as the default err=%v", domainID, err)
+		zap.L().Error(fmt.Sprintf("[SetDefaultDomain] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	jsonData, err := json.Marshal(resultData)
+	if err != nil {
+		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[SetDefaultDomain] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
@@ -0,0 +1,152 @@
+package dns
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/JarcauCristian/ztp-mcp/internal/server/maas_client"
+	"github.com/JarcauCristian/ztp-mcp/internal/server/tools"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+)
+
+type Domains struct{}
+
+func (Domains) Register(mcpServer *server.MCPServer) {
+	mcpTools := []tools.MCPTool{ListDomains{}, CreateDomain{}}
+
+	for _, tool := range mcpTools {
+		mcpServer.AddTool(tool.Create(), tool.Handle)
+	}
+}
+
+// domainForm builds the form shared by create_domain and update_domain.
+func domainForm(request mcp.CallToolRequest) (url.Values, error) {
+	form := make(url.Values)
+
+	if name := request.GetString("name", ""); name != "" {
+		if err := tools.ValidHostname(name); err != nil {
+			return nil, err
+		}
+		form.Add("name", strings.TrimSuffix(name, "."))
+	}
+
+	if args := request.GetArguments(); args["authoritative"] != nil {
+		if request.GetBool("authoritative", true) {
+			form.Add("authoritative", "true")
+		} else {
+			form.Add("authoritative", "false")
+		}
+	}
+
+	if ttl := request.GetInt("ttl", 0); ttl > 0 {
+		form.Add("ttl", fmt.Sprint(ttl))
+	}
+
+	if servers := request.GetString("forward_dns_servers", ""); servers != "" {
+		form.Add("forward_dns_servers", servers)
+	}
+
+	return form, nil
+}
+
+type ListDomains struct{}
+
+func (ListDomains) Create() mcp.Tool {
+	return mcp.NewTool(
+		"list_domains",
+		mcp.WithInputSchema[struct{}](),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("List Domains", true, false, false, true)),
+		mcp.WithDescription("Returns all DNS domains that are currently defined on the running instance of MAAS, with whether they are authoritative and the default."),
+	)
+}
+
+func (ListDomains) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+	path := "/MAAS/api/2.0/domains/"
+
+	client := maas_client.MustClient()
+
+	zap.L().Info("[ListDomains] Retrieving all domains...")
+	resultData, err := client.Do(ctx, maas_client.RequestTypeGet, path, nil)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to retrieve all the domains: %v", err)
+		zap.L().Error(fmt.Sprintf("[ListDomains] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	jsonData, err := json.Marshal(resultData)
+	if err != nil {
+		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[ListDomains] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+type CreateDomain struct{}
+
+func (CreateDomain) Create() mcp.Tool {
+	return mcp.NewTool(
+		"create_domain",
+		mcp.WithString(
+			"name",
+			mcp.Required(),
+			mcp.Description("Name of the domain, for example lab.example.com."),
+		),
+		mcp.WithBoolean(
+			"authoritative",
+			mcp.Description("Whether MAAS is authoritative for the domain. Defaults to true."),
+		),
+		mcp.WithNumber(
+			"ttl",
+			mcp.Min(1),
+			mcp.Description("Default TTL in seconds of the records in the domain."),
+		),
+		mcp.WithString(
+			"forward_dns_servers",
+			mcp.Description("Space separated DNS servers to forward queries for a non-authoritative domain to."),
+		),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Create Domain", false, false, false, true)),
+		mcp.WithDescription("Create a new DNS domain on the running instance of MAAS."),
+	)
+}
+
+func (CreateDomain) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+	path := "/MAAS/api/2.0/domains/"
+
+	if _, err := request.RequireString("name"); err != nil {
+		zap.L().Error(fmt.Sprintf("[CreateDomain] Required parameter name not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	form, err := domainForm(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	client := maas_client.MustClient()
+
+	zap.L().Info(fmt.Sprintf("[CreateDomain] Creating domain %s", form.Get("name")))
+	resultData, err := client.Do(ctx, maas_client.RequestTypePost, path, strings.NewReader(form.Encode()))
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to create domain err=%v", err)
+		zap.L().Error(fmt.Sprintf("[CreateDomain] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	jsonData, err := json.Marshal(resultData)
+	if err != nil {
+		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[CreateDomain] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
@@ -0,0 +1,173 @@
+package dns
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/JarcauCristian/ztp-mcp/internal/server/maas_client"
+	"github.com/JarcauCristian/ztp-mcp/internal/server/tools"
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.uber.org/zap"
+)
+
+type LookupDNS struct{}
+
+func (LookupDNS) Create() mcp.Tool {
+	return mcp.NewTool(
+		"lookup_dns",
+		mcp.WithString(
+			"system_id",
+			mcp.Pattern("^[0-9a-z]{6}$"),
+			mcp.Description("The system ID of a machine."),
+		),
+		mcp.WithString(
+			"ip",
+			mcp.Description("An IP address."),
+		),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Lookup DNS", true, false, false, true)),
+		mcp.WithDescription("Answers which DNS records point at a machine or an IP address: the A and AAAA records of its addresses, including those MAAS creates for machines, and the CNAME, MX and SRV records pointing at those names."),
+	)
+}
+
+func (LookupDNS) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	systemID := request.GetString("system_id", "")
+	ip := request.GetString("ip", "")
+
+	if (systemID == "") == (ip == "") {
+		return mcp.NewToolResultError("set either system_id or ip"), nil
+	}
+
+	client := maas_client.MustClient()
+
+	var addresses []netip.Addr
+	names := []string{}
+
+	if ip != "" {
+		addr, err := netip.ParseAddr(ip)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("invalid IP address %s", ip)), nil
+		}
+		addresses = append(addresses, addr)
+	} else {
+		machine, err := tools.RetrieveMachine(ctx, client, systemID)
+		if err != nil {
+			errMsg = fmt.Sprintf("Failed to retrieve the machine with id %s err=%v", systemID, err)
+			zap.L().Error(fmt.Sprintf("[LookupDNS] %s", errMsg))
+			return mcp.NewToolResultError(errMsg), nil
+		}
+
+		if fqdn, _ := machine["fqdn"].(string); fqdn != "" {
+			names = append(names, strings.ToLower(fqdn))
+		}
+
+		machineAddresses, _ := machine["ip_addresses"].([]any)
+		for _, value := range machineAddresses {
+			if addr, err := netip.ParseAddr(fmt.Sprint(value)); err == nil {
+				addresses = append(addresses, addr)
+			}
+		}
+	}
+
+	records, err := loadRecords(ctx, client, true)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to list the DNS records err=%v", err)
+		zap.L().Error(fmt.Sprintf("[LookupDNS] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	resultData, err := client.Do(ctx, maas_client.RequestTypeGet, "/MAAS/api/2.0/domains/", nil)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to list the domains err=%v", err)
+		zap.L().Error(fmt.Sprintf("[LookupDNS] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	var domains []struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal([]byte(resultData), &domains); err != nil {
+		errMsg = fmt.Sprintf("Failed to unmarshal the domains err=%v", err)
+		zap.L().Error(fmt.Sprintf("[LookupDNS] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	// zone returns the longest domain holding fqdn, against which relative
+	// targets are resolved.
+	zone := func(fqdn string) string {
+		var longest string
+		for _, domain := range domains {
+			if inDomain(fqdn, domain.Name) && len(domain.Name) > len(longest) {
+				longest = domain.Name
+			}
+		}
+		return longest
+	}
+
+	matches := []dnsRecord{}
+	targets := slices.Clone(names)
+	for _, record := range records {
+		if !slices.Contains(addressTypes, record.Type) {
+			continue
+		}
+
+		addr, err := netip.ParseAddr(record.Value)
+		if err != nil {
+			continue
+		}
+
+		fqdn := strings.ToLower(record.FQDN)
+		if !slices.Contains(addresses, addr) && !slices.Contains(targets, fqdn) {
+			continue
+		}
+
+		matches = append(matches, record)
+		if !slices.Contains(names, fqdn) {
+			names = append(names, fqdn)
+		}
+	}
+
+	// Follow CNAMEs pointing at CNAMEs until no new name turns up.
+	for found := true; found; {
+		found = false
+		for _, record := range records {
+			if slices.Contains(addressTypes, record.Type) || slices.ContainsFunc(matches, func(match dnsRecord) bool {
+				return match.ID == record.ID && match.Type == record.Type
+			}) {
+				continue
+			}
+
+			if !slices.Contains(names, target(record.Type, record.Value, zone(record.FQDN))) {
+				continue
+			}
+
+			matches = append(matches, record)
+			if fqdn := strings.ToLower(record.FQDN); record.Type == "CNAME" && !slices.Contains(names, fqdn) {
+				names = append(names, fqdn)
+				found = true
+			}
+		}
+	}
+
+	lookup := map[string]any{
+		"names":   names,
+		"records": matches,
+	}
+	if systemID != "" {
+		lookup["system_id"] = systemID
+	}
+	if len(addresses) > 0 {
+		lookup["addresses"] = addresses
+	}
+
+	result, err := recordsResult("LookupDNS", matches, lookup)
+	if err == nil && len(matches) == 0 {
+		result.Content = append(result.Content, mcp.NewTextContent("No DNS records point at the target."))
+	}
+	return result, err
+}
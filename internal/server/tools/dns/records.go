@@ -0,0 +1,614 @@
+package dns
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/JarcauCristian/ztp-mcp/internal/server/maas_client"
+	"github.com/JarcauCristian/ztp-mcp/internal/server/tools"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+)
+
+type Records struct{}
+
+func (Records) Register(mcpServer *server.MCPServer) {
+	mcpTools := []tools.MCPTool{
+		ListDNSRecords{}, CreateDNSRecord{}, UpdateDNSRecord{}, DeleteDNSRecord{},
+		LookupDNS{},
+	}
+
+	for _, tool := range mcpTools {
+		mcpServer.AddTool(tool.Create(), tool.Handle)
+	}
+}
+
+// dnsRecord is one record of either MAAS API: A and AAAA records come from
+// dnsresources, where one ID holds all addresses of a name, and the other
+// types from dnsresourcerecords.
+type dnsRecord struct {
+	ID    int    `json:"id"`
+	Type  string `json:"type"`
+	FQDN  string `json:"fqdn"`
+	Value string `json:"value"`
+	TTL   *int   `json:"ttl,omitempty"`
+}
+
+// inDomain reports whether fqdn is domain or one of its names.
+func inDomain(fqdn, domain string) bool {
+	fqdn, domain = strings.ToLower(fqdn), strings.ToLower(domain)
+	return fqdn == domain || strings.HasSuffix(fqdn, "."+domain)
+}
+
+// recordPath returns the API path of the records of the given type.
+func recordPath(rrtype string) string {
+	if slices.Contains(addressTypes, rrtype) {
+		return "/MAAS/api/2.0/dnsresources/"
+	}
+	return "/MAAS/api/2.0/dnsresourcerecords/"
+}
+
+// loadRecords returns the records of both APIs. With implicit, the A and AAAA
+// records MAAS creates for machines are included.
+func loadRecords(ctx context.Context, client *maas_client.MAASClient, implicit bool) ([]dnsRecord, error) {
+	path := "/MAAS/api/2.0/dnsresources/"
+	if implicit {
+		path += "?all=true"
+	}
+
+	resultData, err := client.Do(ctx, maas_client.RequestTypeGet, path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the DNS resources: %w", err)
+	}
+
+	var resources []struct {
+		ID          int    `json:"id"`
+		FQDN        string `json:"fqdn"`
+		AddressTTL  *int   `json:"address_ttl"`
+		IPAddresses []struct {
+			IP string `json:"ip"`
+		} `json:"ip_addresses"`
+	}
+	if err := json.Unmarshal([]byte(resultData), &resources); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the DNS resources: %w", err)
+	}
+
+	var records []dnsRecord
+	for _, resource := range resources {
+		for _, address := range resource.IPAddresses {
+			addr, err := netip.ParseAddr(address.IP)
+			if err != nil {
+				continue
+			}
+
+			rrtype := "A"
+			if !addr.Is4() {
+				rrtype = "AAAA"
+			}
+
+			records = append(records, dnsRecord{ID: resource.ID, Type: rrtype, FQDN: resource.FQDN, Value: address.IP, TTL: resource.AddressTTL})
+		}
+	}
+
+	resultData, err = client.Do(ctx, maas_client.RequestTypeGet, "/MAAS/api/2.0/dnsresourcerecords/", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the DNS resource records: %w", err)
+	}
+
+	var resourceRecords []struct {
+		ID     int    `json:"id"`
+		FQDN   string `json:"fqdn"`
+		RRType string `json:"rrtype"`
+		RRData string `json:"rrdata"`
+		TTL    *int   `json:"ttl"`
+	}
+	if err := json.Unmarshal([]byte(resultData), &resourceRecords); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the DNS resource records: %w", err)
+	}
+
+	for _, record := range resourceRecords {
+		records = append(records, dnsRecord{ID: record.ID, Type: record.RRType, FQDN: record.FQDN, Value: record.RRData, TTL: record.TTL})
+	}
+
+	return records, nil
+}
+
+func recordsResult(toolName string, records []dnsRecord, extra any) (*mcp.CallToolResult, error) {
+	var data any = records
+	if extra != nil {
+		data = extra
+	}
+
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		errMsg := fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[%s] %s", toolName, errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	result := mcp.NewToolResultText(string(jsonData))
+	if len(records) == 0 {
+		return result, nil
+	}
+
+	var table strings.Builder
+	writer := tabwriter.NewWriter(&table, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "ID\tFQDN\tTYPE\tVALUE\tTTL")
+	for _, record := range records {
+		ttl := "-"
+		if record.TTL != nil {
+			ttl = fmt.Sprint(*record.TTL)
+		}
+		fmt.Fprintf(writer, "%d\t%s\t%s\t%s\t%s\n", record.ID, record.FQDN, record.Type, record.Value, ttl)
+	}
+	writer.Flush()
+
+	result.Content = append(result.Content, mcp.NewTextContent(table.String()))
+	return result, nil
+}
+
+func withRecordType(description string) mcp.ToolOption {
+	return mcp.WithString(
+		"type",
+		mcp.Required(),
+		mcp.Enum(recordTypes...),
+		mcp.Description(description),
+	)
+}
+
+type ListDNSRecords struct{}
+
+func (ListDNSRecords) Create() mcp.Tool {
+	return mcp.NewTool(
+		"list_dns_records",
+		mcp.WithString(
+			"domain",
+			mcp.Description("Only list the records of this domain."),
+		),
+		mcp.WithString(
+			"type",
+			mcp.Enum(recordTypes...),
+			mcp.Description("Only list the records of this type."),
+		),
+		mcp.WithBoolean(
+			"implicit",
+			mcp.Description("Also list the A and AAAA records MAAS creates for machines."),
+		),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("List DNS Records", true, false, false, true)),
+		mcp.WithDescription("Lists the DNS records of MAAS, one per address for A and AAAA records. The ID of an A or AAAA record is the ID of its name, shared by all its addresses."),
+	)
+}
+
+func (ListDNSRecords) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	domain := strings.ToLower(strings.TrimSuffix(request.GetString("domain", ""), "."))
+	rrtype := request.GetString("type", "")
+
+	client := maas_client.MustClient()
+
+	records, err := loadRecords(ctx, client, request.GetBool("implicit", false))
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to list the DNS records err=%v", err)
+		zap.L().Error(fmt.Sprintf("[ListDNSRecords] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	filtered := make([]dnsRecord, 0, len(records))
+	for _, record := range records {
+		if domain != "" && !inDomain(record.FQDN, domain) {
+			continue
+		}
+		if rrtype != "" && record.Type != rrtype {
+			continue
+		}
+		filtered = append(filtered, record)
+	}
+
+	return recordsResult("ListDNSRecords", filtered, nil)
+}
+
+type CreateDNSRecord struct{}
+
+func (CreateDNSRecord) Create() mcp.Tool {
+	return mcp.NewTool(
+		"create_dns_record",
+		mcp.WithString(
+			"domain",
+			mcp.Required(),
+			mcp.Description("Name or ID of the domain of the record."),
+		),
+		mcp.WithString(
+			"name",
+			mcp.Required(),
+			mcp.Description("Name of the record inside the domain, @ for the domain itself, or _service._proto for SRV records."),
+		),
+		withRecordType("Type of the record."),
+		mcp.WithString(
+			"value",
+			mcp.Required(),
+			mcp.Description(`A and AAAA: one or more IP addresses separated by commas. CNAME: the target name. TXT: the text. MX: "<preference> <exchange>". SRV: "<priority> <weight> <port> <target>". End absolute names with a dot.`),
+		),
+		mcp.WithNumber(
+			"ttl",
+			mcp.Min(1),
+			mcp.Description("TTL of the record in seconds. Defaults to the TTL of the domain."),
+		),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Create DNS Record", false, false, false, true)),
+		mcp.WithDescription("Creates an A, AAAA, CNAME, TXT, SRV or MX record. The name and the value are checked against the record type before anything is sent to MAAS."),
+	)
+}
+
+func (CreateDNSRecord) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	domain, err := request.RequireString("domain")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[CreateDNSRecord] Required parameter domain not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	name, err := request.RequireString("name")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[CreateDNSRecord] Required parameter name not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	rrtype, err := request.RequireString("type")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[CreateDNSRecord] Required parameter type not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	value, err := request.RequireString("value")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[CreateDNSRecord] Required parameter value not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if err := validRecordName(name); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if err := validRecord(rrtype, name, value); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	ttl := request.GetInt("ttl", 0)
+
+	form := make(url.Values)
+	form.Add("name", name)
+	form.Add("domain", domain)
+
+	if slices.Contains(addressTypes, rrtype) {
+		addresses, _ := parseAddresses(rrtype, value)
+		form.Add("ip_addresses", strings.Join(addresses, " "))
+		if ttl > 0 {
+			form.Add("address_ttl", fmt.Sprint(ttl))
+		}
+	} else {
+		form.Add("rrtype", rrtype)
+		form.Add("rrdata", value)
+		if ttl > 0 {
+			form.Add("ttl", fmt.Sprint(ttl))
+		}
+	}
+
+	client := maas_client.MustClient()
+
+	zap.L().Info(fmt.Sprintf("[CreateDNSRecord] Creating %s record %s in domain %s", rrtype, name, domain))
+	resultData, err := client.Do(ctx, maas_client.RequestTypePost, recordPath(rrtype), strings.NewReader(form.Encode()))
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to create the %s record %s err=%v", rrtype, name, err)
+		zap.L().Error(fmt.Sprintf("[CreateDNSRecord] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	jsonData, err := json.Marshal(resultData)
+	if err != nil {
+		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[CreateDNSRecord] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+type UpdateDNSRecord struct{}
+
+func (UpdateDNSRecord) Create() mcp.Tool {
+	return mcp.NewTool(
+		"update_dns_record",
+		mcp.WithString(
+			"id",
+			mcp.Required(),
+			mcp.Pattern("^[0-9]+$"),
+			mcp.Description("The ID of the record, as shown by list_dns_records."),
+		),
+		withRecordType("Type of the record."),
+		mcp.WithString(
+			"value",
+			mcp.Description("The new value, in the format of create_dns_record. For A and AAAA records it replaces the addresses of that family and the name keeps those of the other."),
+		),
+		mcp.WithNumber(
+			"ttl",
+			mcp.Min(1),
+			mcp.Description("The new TTL of the record in seconds."),
+		),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Update DNS Record", false, false, true, true)),
+		mcp.WithDescription("Changes the value or TTL of a DNS record. The new value is checked against the record type."),
+	)
+}
+
+func (UpdateDNSRecord) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	recordID, err := request.RequireString("id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[UpdateDNSRecord] Required parameter id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	rrtype, err := request.RequireString("type")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[UpdateDNSRecord] Required parameter type not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if !slices.Contains(recordTypes, rrtype) {
+		return mcp.NewToolResultError(fmt.Sprintf("unknown record type %s, expected one of %s", rrtype, strings.Join(recordTypes, ", "))), nil
+	}
+
+	value := request.GetString("value", "")
+	ttl := request.GetInt("ttl", 0)
+
+	if value == "" && ttl == 0 {
+		return mcp.NewToolResultError("nothing to update, set value or ttl"), nil
+	}
+
+	client := maas_client.MustClient()
+	path := recordPath(rrtype) + recordID + "/"
+
+	resultData, err := client.Do(ctx, maas_client.RequestTypeGet, path, nil)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to read the %s record %s err=%v", rrtype, recordID, err)
+		zap.L().Error(fmt.Sprintf("[UpdateDNSRecord] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	var current struct {
+		addressResource
+		RRType string `json:"rrtype"`
+	}
+	if err := json.Unmarshal([]byte(resultData), &current); err != nil {
+		errMsg = fmt.Sprintf("Failed to unmarshal the %s record %s err=%v", rrtype, recordID, err)
+		zap.L().Error(fmt.Sprintf("[UpdateDNSRecord] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	if current.RRType != "" && current.RRType != rrtype {
+		return mcp.NewToolResultError(fmt.Sprintf("record %s is a %s record, not %s", recordID, current.RRType, rrtype)), nil
+	}
+
+	form := make(url.Values)
+
+	if value != "" {
+		if err := validRecord(rrtype, current.FQDN, value); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		if slices.Contains(addressTypes, rrtype) {
+			addresses, err := current.replaceAddresses(rrtype, value)
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			form.Add("ip_addresses", strings.Join(addresses, " "))
+		} else {
+			form.Add("rrdata", value)
+		}
+	}
+
+	if ttl > 0 {
+		if slices.Contains(addressTypes, rrtype) {
+			form.Add("address_ttl", fmt.Sprint(ttl))
+		} else {
+			form.Add("ttl", fmt.Sprint(ttl))
+		}
+	}
+
+	zap.L().Info(fmt.Sprintf("[UpdateDNSRecord] Updating %s record %s (%s)", rrtype, recordID, current.FQDN))
+	resultData, err = client.Do(ctx, maas_client.RequestTypePut, path, strings.NewReader(form.Encode()))
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to update the %s record %s err=%v", rrtype, recordID, err)
+		zap.L().Error(fmt.Sprintf("[UpdateDNSRecord] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	jsonData, err := json.Marshal(resultData)
+	if err != nil {
+		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[UpdateDNSRecord] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+// addressResource is a dnsresource as read for a change of its addresses.
+type addressResource struct {
+	FQDN        string `json:"fqdn"`
+	IPAddresses []struct {
+		IP string `json:"ip"`
+	} `json:"ip_addresses"`
+	ResourceRecords []struct {
+		RRType string `json:"rrtype"`
+	} `json:"resource_records"`
+}
+
+// replaceAddresses returns the addresses of the name once those of the family
+// of rrtype are replaced by value. The addresses of the other family are kept,
+// since the name holds both in one list.
+func (r addressResource) replaceAddresses(rrtype, value string) ([]string, error) {
+	replacing, err := parseAddresses(rrtype, value)
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := make([]string, 0, len(r.IPAddresses)+len(replacing))
+	for _, address := range r.IPAddresses {
+		addr, err := netip.ParseAddr(address.IP)
+		if err != nil {
+			continue
+		}
+		if addr.Unmap().Is4() != (rrtype == "A") {
+			addresses = append(addresses, address.IP)
+		}
+	}
+
+	return append(addresses, replacing...), nil
+}
+
+// removeAddress returns the addresses the name keeps once value is removed.
+// A name keeping no address is only deleted when it has no other records,
+// which the error reports otherwise.
+func (r addressResource) removeAddress(rrtype, value string) ([]string, error) {
+	removed, err := parseAddresses(rrtype, value)
+	if err != nil {
+		return nil, err
+	}
+	if len(removed) != 1 {
+		return nil, fmt.Errorf("remove one address at a time")
+	}
+
+	found := false
+	remaining := make([]string, 0, len(r.IPAddresses))
+	for _, address := range r.IPAddresses {
+		addr, err := netip.ParseAddr(address.IP)
+		if err == nil && addr.String() == removed[0] {
+			found = true
+			continue
+		}
+		remaining = append(remaining, address.IP)
+	}
+
+	if !found {
+		return nil, fmt.Errorf("%s has no address %s", r.FQDN, removed[0])
+	}
+
+	if len(remaining) == 0 && len(r.ResourceRecords) > 0 {
+		types := make([]string, 0, len(r.ResourceRecords))
+		for _, record := range r.ResourceRecords {
+			if !slices.Contains(types, record.RRType) {
+				types = append(types, record.RRType)
+			}
+		}
+		return nil, fmt.Errorf("%s is its last address and the name also has %s records, delete them first", removed[0], strings.Join(types, ", "))
+	}
+
+	return remaining, nil
+}
+
+type DeleteDNSRecord struct{}
+
+func (DeleteDNSRecord) Create() mcp.Tool {
+	return mcp.NewTool(
+		"delete_dns_record",
+		mcp.WithString(
+			"id",
+			mcp.Required(),
+			mcp.Pattern("^[0-9]+$"),
+			mcp.Description("The ID of the record, as shown by list_dns_records."),
+		),
+		withRecordType("Type of the record."),
+		mcp.WithString(
+			"value",
+			mcp.Description("For A and AAAA records, the address to remove from the name. Required for those types."),
+		),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Delete DNS Record", false, true, false, true)),
+		mcp.WithDescription("Deletes a DNS record. For an A or AAAA record only the given address is removed and the name keeps its other addresses; the name itself is deleted with its last address, which is refused while it still has CNAME, TXT, SRV or MX records."),
+	)
+}
+
+func (DeleteDNSRecord) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	recordID, err := request.RequireString("id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[DeleteDNSRecord] Required parameter id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	rrtype, err := request.RequireString("type")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[DeleteDNSRecord] Required parameter type not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if !slices.Contains(recordTypes, rrtype) {
+		return mcp.NewToolResultError(fmt.Sprintf("unknown record type %s, expected one of %s", rrtype, strings.Join(recordTypes, ", "))), nil
+	}
+
+	client := maas_client.MustClient()
+	path := recordPath(rrtype) + recordID + "/"
+
+	requestType := maas_client.RequestTypeDelete
+	var body io.Reader
+	action := "Deleting"
+
+	if slices.Contains(addressTypes, rrtype) {
+		value := request.GetString("value", "")
+		if value == "" {
+			return mcp.NewToolResultError(fmt.Sprintf("set value to the address to remove from the %s record", rrtype)), nil
+		}
+
+		resultData, err := client.Do(ctx, maas_client.RequestTypeGet, path, nil)
+		if err != nil {
+			errMsg = fmt.Sprintf("Failed to read the %s record %s err=%v", rrtype, recordID, err)
+			zap.L().Error(fmt.Sprintf("[DeleteDNSRecord] %s", errMsg))
+			return mcp.NewToolResultError(errMsg), nil
+		}
+
+		var resource addressResource
+		if err := json.Unmarshal([]byte(resultData), &resource); err != nil {
+			errMsg = fmt.Sprintf("Failed to unmarshal the %s record %s err=%v", rrtype, recordID, err)
+			zap.L().Error(fmt.Sprintf("[DeleteDNSRecord] %s", errMsg))
+			return mcp.NewToolResultError(errMsg), nil
+		}
+
+		remaining, err := resource.removeAddress(rrtype, value)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Cannot delete the %s record %s: %v", rrtype, recordID, err)), nil
+		}
+
+		if len(remaining) > 0 {
+			requestType = maas_client.RequestTypePut
+			body = strings.NewReader(url.Values{"ip_addresses": {strings.Join(remaining, " ")}}.Encode())
+			action = fmt.Sprintf("Removing %s from", value)
+		}
+	}
+
+	zap.L().Info(fmt.Sprintf("[DeleteDNSRecord] %s %s record %s", action, rrtype, recordID))
+
+	resultData, err := client.Do(ctx, requestType, path, body)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to delete the %s record %s err=%v", rrtype, recordID, err)
+		zap.L().Error(fmt.Sprintf("[DeleteDNSRecord] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	jsonData, err := json.Marshal(resultData)
+	if err != nil {
+		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[DeleteDNSRecord] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
@@ -0,0 +1,157 @@
+package dns
+
+import (
+	"fmt"
+	"net/netip"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/JarcauCristian/ztp-mcp/internal/server/tools"
+)
+
+var (
+	addressTypes = []string{"A", "AAAA"}
+	recordTypes  = []string{"A", "AAAA", "CNAME", "TXT", "SRV", "MX"}
+
+	// labelPattern matches one label of a DNS name. Underscores are allowed
+	// for SRV and TXT owner names such as _sip._tcp or _dmarc.
+	labelPattern = regexp.MustCompile(`^[A-Za-z0-9_]([A-Za-z0-9_-]{0,61}[A-Za-z0-9])?$`)
+)
+
+// validRecordName checks the name of a record inside its domain: @ for the
+// domain itself, a leading * for a wildcard, or labels that may start with an
+// underscore.
+func validRecordName(name string) error {
+	if name == "@" {
+		return nil
+	}
+
+	name = strings.TrimPrefix(name, "*.")
+	if name == "*" {
+		return nil
+	}
+
+	return tools.ValidName(name, labelPattern, "letters, digits, underscores or dashes")
+}
+
+func validPort(field, value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 || n > 65535 {
+		return fmt.Errorf("invalid %s %q, expected 0 to 65535", field, value)
+	}
+	return nil
+}
+
+// parseAddresses splits a list of addresses separated by commas or spaces and
+// checks they all belong to the family of the record type.
+func parseAddresses(rrtype, value string) ([]string, error) {
+	fields := strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' })
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("an %s record needs at least one IP address", rrtype)
+	}
+
+	addresses := make([]string, 0, len(fields))
+	for _, field := range fields {
+		addr, err := netip.ParseAddr(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP address %s", field)
+		}
+
+		if rrtype == "A" && !addr.Is4() {
+			return nil, fmt.Errorf("%s is not an IPv4 address, use an AAAA record", field)
+		}
+		if rrtype == "AAAA" && (!addr.Is6() || addr.Is4In6()) {
+			return nil, fmt.Errorf("%s is not an IPv6 address, use an A record", field)
+		}
+
+		addresses = append(addresses, addr.String())
+	}
+
+	return addresses, nil
+}
+
+// validRecord checks the value of a record against its type.
+func validRecord(rrtype, name, value string) error {
+	if !slices.Contains(recordTypes, rrtype) {
+		return fmt.Errorf("unknown record type %s, expected one of %s", rrtype, strings.Join(recordTypes, ", "))
+	}
+
+	if slices.Contains(addressTypes, rrtype) {
+		_, err := parseAddresses(rrtype, value)
+		return err
+	}
+
+	fields := strings.Fields(value)
+
+	switch rrtype {
+	case "CNAME":
+		if name == "@" {
+			return fmt.Errorf("a CNAME record can not be at the domain itself")
+		}
+		if len(fields) != 1 {
+			return fmt.Errorf("a CNAME record points at exactly one name")
+		}
+		return tools.ValidHostname(fields[0])
+	case "TXT":
+		if strings.TrimSpace(value) == "" {
+			return fmt.Errorf("a TXT record needs a value")
+		}
+	case "MX":
+		if len(fields) != 2 {
+			return fmt.Errorf("an MX record is \"<preference> <exchange>\", for example \"10 mail.example.com.\"")
+		}
+		if err := validPort("preference", fields[0]); err != nil {
+			return err
+		}
+		return tools.ValidHostname(fields[1])
+	case "SRV":
+		if len(fields) != 4 {
+			return fmt.Errorf("an SRV record is \"<priority> <weight> <port> <target>\", for example \"10 5 5060 sip.example.com.\"")
+		}
+		labels := strings.Split(name, ".")
+		if len(labels) < 2 || !strings.HasPrefix(labels[0], "_") || !strings.HasPrefix(labels[1], "_") {
+			return fmt.Errorf("the name of an SRV record is _service._proto, for example _sip._tcp")
+		}
+		for i, field := range []string{"priority", "weight", "port"} {
+			if err := validPort(field, fields[i]); err != nil {
+				return err
+			}
+		}
+		if fields[3] == "." {
+			return nil
+		}
+		return tools.ValidHostname(fields[3])
+	}
+
+	return nil
+}
+
+// target returns the name a CNAME, MX or SRV record points at, made absolute
+// within zone when it is relative.
+func target(rrtype, rrdata, zone string) string {
+	fields := strings.Fields(rrdata)
+
+	var name string
+	switch {
+	case rrtype == "CNAME" && len(fields) == 1:
+		name = fields[0]
+	case rrtype == "MX" && len(fields) == 2:
+		name = fields[1]
+	case rrtype == "SRV" && len(fields) == 4:
+		name = fields[3]
+	default:
+		return ""
+	}
+
+	if strings.HasSuffix(name, ".") {
+		return strings.ToLower(strings.TrimSuffix(name, "."))
+	}
+
+	if zone != "" && !strings.HasSuffix(name, "."+zone) {
+		name += "." + zone
+	}
+
+	return strings.ToLower(name)
+}
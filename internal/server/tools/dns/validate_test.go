@@ -0,0 +1,250 @@
+package dns
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestValidRecord(t *testing.T) {
+	tests := []struct {
+		rrtype string
+		name   string
+		value  string
+		err    string
+	}{
+		{"A", "web", "10.0.0.5, 10.0.0.6", ""},
+		{"A", "web", "fd00::1", "not an IPv4 address"},
+		{"A", "web", "10.0.0.300", "invalid IP address"},
+		{"A", "web", " , ", "at least one IP address"},
+		{"AAAA", "web", "fd00::1 fd00::2", ""},
+		{"AAAA", "web", "::ffff:10.0.0.5", "not an IPv6 address"},
+		{"CNAME", "www", "web.lab.example.com.", ""},
+		{"CNAME", "@", "web.lab.example.com.", "domain itself"},
+		{"CNAME", "www", "a b", "exactly one name"},
+		{"CNAME", "www", "web_1.example.com", "invalid name"},
+		{"TXT", "_dmarc", "v=DMARC1; p=none", ""},
+		{"TXT", "@", "  ", "needs a value"},
+		{"MX", "@", "10 mail.lab.example.com.", ""},
+		{"MX", "@", "mail.lab.example.com.", "<preference> <exchange>"},
+		{"MX", "@", "70000 mail.lab.example.com.", "invalid preference"},
+		{"SRV", "_sip._tcp", "10 5 5060 sip.lab.example.com.", ""},
+		{"SRV", "_sip._tcp", "0 0 0 .", ""},
+		{"SRV", "sip", "10 5 5060 sip.lab.example.com.", "_service._proto"},
+		{"SRV", "_sip._tcp", "10 5 port sip.lab.example.com.", "invalid port"},
+		{"PTR", "web", "web.example.com.", "unknown record type"},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s %s", test.rrtype, test.value), func(t *testing.T) {
+			err := validRecord(test.rrtype, test.name, test.value)
+			switch {
+			case test.err == "" && err != nil:
+				t.Errorf("validRecord = %v, want nil", err)
+			case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+				t.Errorf("validRecord = %v, want an error about %s", err, test.err)
+			}
+		})
+	}
+}
+
+func TestValidRecordName(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"@", true},
+		{"*", true},
+		{"*.apps", true},
+		{"_sip._tcp", true},
+		{"web-1", true},
+		{"apps.*", false},
+		{"-web", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		if err := validRecordName(test.name); (err == nil) != test.valid {
+			t.Errorf("validRecordName(%q) = %v, want valid %t", test.name, err, test.valid)
+		}
+	}
+}
+
+func TestTarget(t *testing.T) {
+	tests := []struct {
+		rrtype string
+		rrdata string
+		want   string
+	}{
+		{"CNAME", "Web.Lab.Example.com.", "web.lab.example.com"},
+		{"CNAME", "web", "web.lab.example.com"},
+		{"CNAME", "web.lab.example.com", "web.lab.example.com"},
+		{"MX", "10 mail", "mail.lab.example.com"},
+		{"SRV", "10 5 5060 sip.other.org.", "sip.other.org"},
+		{"TXT", "v=spf1 -all", ""},
+	}
+
+	for _, test := range tests {
+		if got := target(test.rrtype, test.rrdata, "lab.example.com"); got != test.want {
+			t.Errorf("target(%s, %q) = %q, want %q", test.rrtype, test.rrdata, got, test.want)
+		}
+	}
+}
+
+func TestRemoveAddress(t *testing.T) {
+	resource := func(data string) addressResource {
+		var r addressResource
+		if err := json.Unmarshal([]byte(data), &r); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	tests := []struct {
+		name     string
+		resource addressResource
+		rrtype   string
+		value    string
+		want     []string
+		err      string
+	}{
+		{
+			name:     "keeps the other addresses",
+			resource: resource(`{"fqdn": "web.lab", "ip_addresses": [{"ip": "10.0.0.5"}, {"ip": "10.0.0.6"}, {"ip": "fd00::5"}]}`),
+			rrtype:   "A", value: "10.0.0.5",
+			want: []string{"10.0.0.6", "fd00::5"},
+		},
+		{
+			name:     "normalizes the address",
+			resource: resource(`{"fqdn": "web.lab", "ip_addresses": [{"ip": "10.0.0.5"}, {"ip": "fd00::5"}]}`),
+			rrtype:   "AAAA", value: "fd00:0::5",
+			want: []string{"10.0.0.5"},
+		},
+		{
+			name:     "last address of a bare name",
+			resource: resource(`{"fqdn": "web.lab", "ip_addresses": [{"ip": "10.0.0.5"}], "resource_records": []}`),
+			rrtype:   "A", value: "10.0.0.5",
+			want: []string{},
+		},
+		{
+			name:     "last address of a name with other records",
+			resource: resource(`{"fqdn": "web.lab", "ip_addresses": [{"ip": "10.0.0.5"}], "resource_records": [{"rrtype": "TXT"}, {"rrtype": "MX"}, {"rrtype": "TXT"}]}`),
+			rrtype:   "A", value: "10.0.0.5",
+			err: "TXT, MX records",
+		},
+		{
+			name:     "unknown address",
+			resource: resource(`{"fqdn": "web.lab", "ip_addresses": [{"ip": "10.0.0.5"}]}`),
+			rrtype:   "A", value: "10.0.0.7",
+			err: "no address 10.0.0.7",
+		},
+		{
+			name:     "several addresses",
+			resource: resource(`{"fqdn": "web.lab", "ip_addresses": [{"ip": "10.0.0.5"}, {"ip": "10.0.0.6"}]}`),
+			rrtype:   "A", value: "10.0.0.5,10.0.0.6",
+			err: "one address at a time",
+		},
+		{
+			name:     "wrong family",
+			resource: resource(`{"fqdn": "web.lab", "ip_addresses": [{"ip": "fd00::5"}]}`),
+			rrtype:   "A", value: "fd00::5",
+			err: "not an IPv4 address",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			remaining, err := test.resource.removeAddress(test.rrtype, test.value)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("removeAddress = %v, want an error about %s", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(remaining) != fmt.Sprint(test.want) {
+				t.Errorf("remaining = %v, want %v", remaining, test.want)
+			}
+		})
+	}
+}
+
+func TestReplaceAddresses(t *testing.T) {
+	resource := func(data string) addressResource {
+		var r addressResource
+		if err := json.Unmarshal([]byte(data), &r); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	dualStack := resource(`{"fqdn": "web.lab", "ip_addresses": [{"ip": "10.0.0.5"}, {"ip": "fd00::5"}, {"ip": "10.0.0.6"}, {"ip": "fd00::6"}]}`)
+
+	tests := []struct {
+		name     string
+		resource addressResource
+		rrtype   string
+		value    string
+		want     []string
+		err      string
+	}{
+		{
+			name:     "A keeps the IPv6 addresses",
+			resource: dualStack,
+			rrtype:   "A", value: "10.0.0.7, 10.0.0.8",
+			want: []string{"fd00::5", "fd00::6", "10.0.0.7", "10.0.0.8"},
+		},
+		{
+			name:     "AAAA keeps the IPv4 addresses",
+			resource: dualStack,
+			rrtype:   "AAAA", value: "fd00:0::7",
+			want: []string{"10.0.0.5", "10.0.0.6", "fd00::7"},
+		},
+		{
+			name:     "single family",
+			resource: resource(`{"fqdn": "web.lab", "ip_addresses": [{"ip": "10.0.0.5"}]}`),
+			rrtype:   "A", value: "10.0.0.9",
+			want: []string{"10.0.0.9"},
+		},
+		{
+			name:     "adds the first address of a family",
+			resource: resource(`{"fqdn": "web.lab", "ip_addresses": [{"ip": "10.0.0.5"}]}`),
+			rrtype:   "AAAA", value: "fd00::5",
+			want: []string{"10.0.0.5", "fd00::5"},
+		},
+		{
+			name:     "wrong family",
+			resource: dualStack,
+			rrtype:   "AAAA", value: "10.0.0.7",
+			err: "not an IPv6 address",
+		},
+		{
+			name:     "no address",
+			resource: dualStack,
+			rrtype:   "A", value: " , ",
+			err: "at least one IP address",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			addresses, err := test.resource.replaceAddresses(test.rrtype, test.value)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("replaceAddresses = %v, want an error containing %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if strings.Join(addresses, " ") != strings.Join(test.want, " ") {
+				t.Errorf("addresses = %q, want %q", addresses, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/JarcauCristian/ztp-mcp/internal/server/maas_client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.uber.org/zap"
+)
+
+type CreateMachine struct{}
+
+func (CreateMachine) Create() mcp.Tool {
+	return mcp.NewTool(
+		"create_machine",
+		mcp.WithString(
+			"architecture",
+			mcp.Required(),
+			mcp.Pattern("^[0-9a-z]+/[0-9a-z-]+$"),
+			mcp.Description("The architecture of the machine, e.g. amd64/generic or arm64/generic."),
+		),
+		mcp.WithString(
+			"mac_addresses",
+			mcp.Required(),
+			mcp.Description("Comma separated MAC addresses of the machine's network interfaces. The first one is used to PXE boot."),
+		),
+		mcp.WithString(
+			"power_type",
+			mcp.Required(),
+			mcp.Enum(powerTypes()...),
+			mcp.Description("How MAAS controls the power of the machine."),
+		),
+		mcp.WithString(
+			"power_parameters",
+			mcp.Description(`JSON object of power parameters for the power type, see update_power_parameters. Example for IPMI: {"power_address": "10.0.0.21", "power_user": "admin", "power_pass": "secret"}`),
+		),
+		mcp.WithString(
+			"hostname",
+			mcp.Pattern("^[0-9a-z-]*$"),
+			mcp.Description("The hostname of the machine. MAAS picks a random one when empty."),
+		),
+		mcp.WithString(
+			"domain",
+			mcp.Description("The DNS domain of the machine."),
+		),
+		mcp.WithString(
+			"description",
+			mcp.Description("A description of the machine."),
+		),
+		mcp.WithString(
+			"zone",
+			mcp.Description("The availability zone to place the machine in."),
+		),
+		mcp.WithString(
+			"pool",
+			mcp.Description("The resource pool to place the machine in."),
+		),
+		mcp.WithBoolean(
+			"commission",
+			mcp.Description("Commission the machine right after it is created. Defaults to true."),
+		),
+		mcp.WithToolAnnotation(CreateToolAnnotation("Create Machine", false, false, false, true)),
+		mcp.WithDescription("Adds a new machine to MAAS from its architecture, MAC addresses and power settings, and by default starts commissioning it."),
+	)
+}
+
+func (CreateMachine) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	architecture, err := request.RequireString("architecture")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[CreateMachine] Required parameter architecture not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	macAddresses, err := request.RequireString("mac_addresses")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[CreateMachine] Required parameter mac_addresses not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	powerType, err := request.RequireString("power_type")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[CreateMachine] Required parameter power_type not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	parameters, err := parsePowerParameters(request.GetString("power_parameters", ""))
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[CreateMachine] Invalid power parameters err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if err := validatePowerParameters(powerType, parameters); err != nil {
+		zap.L().Error(fmt.Sprintf("[CreateMachine] Invalid power parameters err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	form := make(url.Values)
+	form.Add("architecture", architecture)
+	form.Add("power_type", powerType)
+	addPowerParameters(form, parameters)
+
+	for _, mac := range strings.Split(macAddresses, ",") {
+		mac = strings.TrimSpace(mac)
+		if mac == "" {
+			continue
+		}
+
+		hardwareAddr, err := net.ParseMAC(mac)
+		if err != nil {
+			errMsg = fmt.Sprintf("invalid MAC address %s", mac)
+			zap.L().Error(fmt.Sprintf("[CreateMachine] %s", errMsg))
+			return mcp.NewToolResultError(errMsg), nil
+		}
+
+		form.Add("mac_addresses", hardwareAddr.String())
+	}
+
+	if len(form["mac_addresses"]) == 0 {
+		return mcp.NewToolResultError("at least one MAC address is required"), nil
+	}
+
+	for _, option := range []string{"hostname", "domain", "description", "zone", "pool"} {
+		if value := request.GetString(option, ""); value != "" {
+			form.Add(option, value)
+		}
+	}
+
+	if request.GetBool("commission", true) {
+		form.Add("commission", "true")
+	} else {
+		form.Add("commission", "false")
+	}
+
+	client := maas_client.MustClient()
+
+	zap.L().Info(fmt.Sprintf("[CreateMachine] Creating %s machine with MAC addresses %s...", architecture, strings.Join(form["mac_addresses"], ", ")))
+	resultData, err := client.Do(ctx, maas_client.RequestTypePost, "/MAAS/api/2.0/machines/", strings.NewReader(form.Encode()))
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to create the machine err=%v", err)
+		zap.L().Error(fmt.Sprintf("[CreateMachine] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	jsonData, err := json.Marshal(resultData)
+	if err != nil {
+		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[CreateMachine] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
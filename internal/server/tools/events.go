@@ -0,0 +1,500 @@
+package tools
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JarcauCristian/ztp-mcp/internal/server/maas_client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+)
+
+const defaultLogLines = 200
+
+type Events struct{}
+
+func (Events) Register(mcpServer *server.MCPServer) {
+	mcpTools := []MCPTool{MachineEvents{}, MachineInstallationLog{}, MachineScriptResults{}}
+
+	for _, tool := range mcpTools {
+		mcpServer.AddTool(tool.Create(), tool.Handle)
+	}
+}
+
+// eventTimeLayouts are the formats MAAS uses for the created field of events.
+var eventTimeLayouts = []string{
+	"Mon, 02 Jan. 2006 15:04:05",
+	"Mon, 2 Jan. 2006 15:04:05",
+	"Mon, 02 Jan 2006 15:04:05",
+	time.RFC3339,
+}
+
+func parseEventTime(value string) (time.Time, bool) {
+	for _, layout := range eventTimeLayouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// maxEventPages bounds how far back queryEvents pages to find the events
+// created after since.
+const maxEventPages = 20
+
+// queryEvents returns up to the limit of query events of a machine, newest
+// first. With since, the filter is applied before the limit: older pages are
+// fetched until limit events created at or after since are found or a page
+// holds none of them.
+func queryEvents(ctx context.Context, client *maas_client.MAASClient, query url.Values, since time.Time) ([]map[string]any, error) {
+	query.Set("op", "query")
+
+	limit, _ := strconv.Atoi(query.Get("limit"))
+
+	var events []map[string]any
+	for page := 0; page < maxEventPages; page++ {
+		resultData, err := client.Do(ctx, maas_client.RequestTypeGet, "/MAAS/api/2.0/events/?"+query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var response struct {
+			Events []map[string]any `json:"events"`
+		}
+
+		if err := json.Unmarshal([]byte(resultData), &response); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal the events: %w", err)
+		}
+
+		if since.IsZero() {
+			return response.Events, nil
+		}
+
+		matched := false
+		for _, event := range response.Events {
+			created, _ := event["created"].(string)
+			if createdAt, ok := parseEventTime(created); ok && createdAt.Before(since) {
+				continue
+			}
+
+			matched = true
+			events = append(events, event)
+			if limit > 0 && len(events) == limit {
+				return events, nil
+			}
+		}
+
+		if !matched || limit <= 0 || len(response.Events) < limit {
+			return events, nil
+		}
+
+		oldest, ok := response.Events[len(response.Events)-1]["id"].(float64)
+		if !ok {
+			return events, nil
+		}
+		query.Set("before", strconv.FormatInt(int64(oldest), 10))
+	}
+
+	return events, nil
+}
+
+// scriptResult is a single script of a MAAS result set with its output
+// decoded from base64.
+type scriptResult struct {
+	ResultSet  string `json:"result_set"`
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	ExitStatus any    `json:"exit_status,omitempty"`
+	Started    string `json:"started,omitempty"`
+	Ended      string `json:"ended,omitempty"`
+	Output     string `json:"output,omitempty"`
+	Stderr     string `json:"stderr,omitempty"`
+}
+
+func decodeOutput(value any) string {
+	encoded, ok := value.(string)
+	if !ok || encoded == "" {
+		return ""
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return encoded
+	}
+
+	return string(decoded)
+}
+
+// queryScriptResults returns the scripts run on a node for the given result
+// type (commissioning, testing or installation).
+func queryScriptResults(ctx context.Context, client *maas_client.MAASClient, systemID, resultType, filters string, includeOutput bool) ([]scriptResult, error) {
+	query := url.Values{}
+	if resultType != "" {
+		query.Set("type", resultType)
+	}
+	if filters != "" {
+		query.Set("filters", filters)
+	}
+	if includeOutput {
+		query.Set("include_output", "1")
+	}
+
+	path := fmt.Sprintf("/MAAS/api/2.0/nodes/%s/results/?%s", systemID, query.Encode())
+
+	resultData, err := client.Do(ctx, maas_client.RequestTypeGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var resultSets []map[string]any
+	if err := json.Unmarshal([]byte(resultData), &resultSets); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the script results: %w", err)
+	}
+
+	var results []scriptResult
+	for _, resultSet := range resultSets {
+		setName, _ := resultSet["type_name"].(string)
+		scripts, _ := resultSet["results"].([]any)
+
+		for _, script := range scripts {
+			scriptData, ok := script.(map[string]any)
+			if !ok {
+				continue
+			}
+
+			result := scriptResult{ResultSet: setName, ExitStatus: scriptData["exit_status"]}
+			result.Name, _ = scriptData["name"].(string)
+			result.Status, _ = scriptData["status_name"].(string)
+			result.Started, _ = scriptData["started"].(string)
+			result.Ended, _ = scriptData["ended"].(string)
+
+			if includeOutput {
+				result.Output = decodeOutput(scriptData["output"])
+				result.Stderr = decodeOutput(scriptData["stderr"])
+			}
+
+			results = append(results, result)
+		}
+	}
+
+	return results, nil
+}
+
+// pageLines returns limit lines of text starting at offset, or the last limit
+// lines when tail is true, together with a header describing the window.
+func pageLines(text string, offset, limit int, tail bool) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	total := len(lines)
+
+	if limit <= 0 {
+		limit = defaultLogLines
+	}
+
+	if tail {
+		offset = max(total-limit, 0)
+	}
+
+	if offset >= total {
+		return fmt.Sprintf("[lines %d-%d of %d]\n", total, total, total)
+	}
+
+	end := min(offset+limit, total)
+
+	header := fmt.Sprintf("[lines %d-%d of %d]", offset+1, end, total)
+	if end < total {
+		header += fmt.Sprintf(" use offset=%d for more", end)
+	}
+
+	return header + "\n" + strings.Join(lines[offset:end], "\n")
+}
+
+type MachineEvents struct{}
+
+func (MachineEvents) Create() mcp.Tool {
+	return mcp.NewTool(
+		"machine_events",
+		mcp.WithString(
+			"system_id",
+			mcp.Pattern("^[0-9a-z]{6}$"),
+			mcp.Description("The system ID of the machine to retrieve events for. Either system_id or hostname is required."),
+		),
+		mcp.WithString(
+			"hostname",
+			mcp.Description("The hostname of the machine to retrieve events for."),
+		),
+		mcp.WithString(
+			"level",
+			mcp.Enum("AUDIT", "DEBUG", "INFO", "WARNING", "ERROR", "CRITICAL"),
+			mcp.Description("The minimum level of the events to return. Defaults to INFO."),
+		),
+		mcp.WithNumber(
+			"limit",
+			mcp.Min(1),
+			mcp.Max(1000),
+			mcp.Description("The maximum number of events to return. Defaults to 50."),
+		),
+		mcp.WithString(
+			"since",
+			mcp.Description("Only return events created at or after this time, in RFC3339 format (e.g. 2024-05-01T10:00:00Z)."),
+		),
+		mcp.WithToolAnnotation(CreateToolAnnotation("Machine Events", true, false, false, true)),
+		mcp.WithDescription("Returns the MAAS event log of a machine, newest first. Useful to find out why a commission or deployment failed."),
+	)
+}
+
+func (MachineEvents) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	systemID := request.GetString("system_id", "")
+	hostname := request.GetString("hostname", "")
+
+	if systemID == "" && hostname == "" {
+		errMsg = "Either system_id or hostname is required"
+		zap.L().Error(fmt.Sprintf("[MachineEvents] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	var since time.Time
+	if sinceValue := request.GetString("since", ""); sinceValue != "" {
+		parsed, err := time.Parse(time.RFC3339, sinceValue)
+		if err != nil {
+			errMsg = fmt.Sprintf("Invalid since value %s: %v", sinceValue, err)
+			zap.L().Error(fmt.Sprintf("[MachineEvents] %s", errMsg))
+			return mcp.NewToolResultError(errMsg), nil
+		}
+		since = parsed
+	}
+
+	client := maas_client.MustClient()
+
+	if systemID != "" {
+		if _, err := RetrieveMachine(ctx, client, systemID); err != nil {
+			errMsg = fmt.Sprintf("Failed to retrieve the machine with id %s err=%v", systemID, err)
+			zap.L().Error(fmt.Sprintf("[MachineEvents] %s", errMsg))
+			return mcp.NewToolResultError(errMsg), nil
+		}
+	} else {
+		machine, err := RetrieveMachineByHostname(ctx, client, hostname)
+		if err != nil {
+			errMsg = fmt.Sprintf("Failed to retrieve the machine with hostname %s err=%v", hostname, err)
+			zap.L().Error(fmt.Sprintf("[MachineEvents] %s", errMsg))
+			return mcp.NewToolResultError(errMsg), nil
+		}
+		systemID, _ = machine["system_id"].(string)
+	}
+
+	query := url.Values{}
+	query.Set("id", systemID)
+	query.Set("level", request.GetString("level", "INFO"))
+	query.Set("limit", strconv.Itoa(request.GetInt("limit", 50)))
+
+	zap.L().Info(fmt.Sprintf("[MachineEvents] Retrieving events for machine %s...", systemID))
+	events, err := queryEvents(ctx, client, query, since)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to retrieve the events err=%v", err)
+		zap.L().Error(fmt.Sprintf("[MachineEvents] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	jsonData, err := json.Marshal(events)
+	if err != nil {
+		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[MachineEvents] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+type MachineInstallationLog struct{}
+
+func (MachineInstallationLog) Create() mcp.Tool {
+	return mcp.NewTool(
+		"machine_installation_log",
+		mcp.WithString(
+			"system_id",
+			mcp.Required(),
+			mcp.Pattern("^[0-9a-z]{6}$"),
+			mcp.Description("The system ID of the machine."),
+		),
+		mcp.WithNumber(
+			"offset",
+			mcp.Min(0),
+			mcp.Description("The line to start reading from. Ignored when tail is true."),
+		),
+		mcp.WithNumber(
+			"limit",
+			mcp.Min(1),
+			mcp.Description("The number of lines to return. Defaults to 200."),
+		),
+		mcp.WithBoolean(
+			"tail",
+			mcp.DefaultBool(true),
+			mcp.Description("If true return the last lines of the log, which is where installation errors usually are."),
+		),
+		mcp.WithToolAnnotation(CreateToolAnnotation("Machine Installation Log", true, false, true, true)),
+		mcp.WithDescription("Returns the curtin installation log of the last deployment of a machine, decoded and paged by lines."),
+	)
+}
+
+func (MachineInstallationLog) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	systemID, err := request.RequireString("system_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[MachineInstallationLog] Required parameter system_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	client := maas_client.MustClient()
+
+	if _, err := RetrieveMachine(ctx, client, systemID); err != nil {
+		errMsg = fmt.Sprintf("Failed to retrieve the machine with id %s err=%v", systemID, err)
+		zap.L().Error(fmt.Sprintf("[MachineInstallationLog] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	zap.L().Info(fmt.Sprintf("[MachineInstallationLog] Retrieving installation log for machine %s...", systemID))
+	results, err := queryScriptResults(ctx, client, systemID, "installation", "", true)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to retrieve the installation results for machine %s err=%v", systemID, err)
+		zap.L().Error(fmt.Sprintf("[MachineInstallationLog] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	if len(results) == 0 {
+		return mcp.NewToolResultText(fmt.Sprintf("No installation log found for machine %s", systemID)), nil
+	}
+
+	// The latest installation is the last result MAAS returns.
+	result := results[len(results)-1]
+
+	log := result.Output
+	if log == "" {
+		log = result.Stderr
+	}
+
+	page := pageLines(log, request.GetInt("offset", 0), request.GetInt("limit", defaultLogLines), request.GetBool("tail", true))
+
+	return mcp.NewToolResultText(fmt.Sprintf("%s (%s)\n%s", result.Name, result.Status, page)), nil
+}
+
+type MachineScriptResults struct{}
+
+func (MachineScriptResults) Create() mcp.Tool {
+	return mcp.NewTool(
+		"machine_script_results",
+		mcp.WithString(
+			"system_id",
+			mcp.Required(),
+			mcp.Pattern("^[0-9a-z]{6}$"),
+			mcp.Description("The system ID of the machine."),
+		),
+		mcp.WithString(
+			"type",
+			mcp.Enum("commissioning", "testing", "installation"),
+			mcp.Description("Only return results of this type. Returns all types if not provided."),
+		),
+		mcp.WithString(
+			"filters",
+			mcp.Pattern("^[^,]+(?:,[^,]+)*$"),
+			mcp.Description("A comma-separated list of script names or tags to return results for."),
+		),
+		mcp.WithBoolean(
+			"only_failed",
+			mcp.DefaultBool(false),
+			mcp.Description("If true only return scripts that failed, timed out or failed to install."),
+		),
+		mcp.WithBoolean(
+			"include_output",
+			mcp.DefaultBool(false),
+			mcp.Description("If true include the decoded output of each script, truncated to the last output_lines lines."),
+		),
+		mcp.WithNumber(
+			"output_lines",
+			mcp.Min(1),
+			mcp.Description("The number of output lines to keep per script when include_output is true. Defaults to 50."),
+		),
+		mcp.WithToolAnnotation(CreateToolAnnotation("Machine Script Results", true, false, true, true)),
+		mcp.WithDescription("Returns the commissioning, testing and installation script results of a machine."),
+	)
+}
+
+func (MachineScriptResults) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	systemID, err := request.RequireString("system_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[MachineScriptResults] Required parameter system_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	resultType := request.GetString("type", "")
+	filters := request.GetString("filters", "")
+	onlyFailed := request.GetBool("only_failed", false)
+	includeOutput := request.GetBool("include_output", false)
+	outputLines := request.GetInt("output_lines", 50)
+
+	client := maas_client.MustClient()
+
+	if _, err := RetrieveMachine(ctx, client, systemID); err != nil {
+		errMsg = fmt.Sprintf("Failed to retrieve the machine with id %s err=%v", systemID, err)
+		zap.L().Error(fmt.Sprintf("[MachineScriptResults] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	zap.L().Info(fmt.Sprintf("[MachineScriptResults] Retrieving script results for machine %s...", systemID))
+	results, err := queryScriptResults(ctx, client, systemID, resultType, filters, includeOutput)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to retrieve the script results for machine %s err=%v", systemID, err)
+		zap.L().Error(fmt.Sprintf("[MachineScriptResults] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	filtered := make([]scriptResult, 0, len(results))
+	for _, result := range results {
+		if onlyFailed && !isFailedStatus(result.Status) {
+			continue
+		}
+
+		if includeOutput {
+			if result.Output != "" {
+				result.Output = pageLines(result.Output, 0, outputLines, true)
+			}
+			if result.Stderr != "" {
+				result.Stderr = pageLines(result.Stderr, 0, outputLines, true)
+			}
+		}
+
+		filtered = append(filtered, result)
+	}
+
+	jsonData, err := json.Marshal(filtered)
+	if err != nil {
+		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[MachineScriptResults] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+// isFailedStatus reports whether a script result status is one of the MAAS
+// failure statuses. Transitional statuses such as Applying netconf are not
+// failures.
+func isFailedStatus(status string) bool {
+	switch strings.ToLower(status) {
+	case "failed", "timed out", "failed installing", "failed applying netconf":
+		return true
+	default:
+		return false
+	}
+}
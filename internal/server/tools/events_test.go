@@ -0,0 +1,131 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/JarcauCristian/ztp-mcp/internal/server/maas_client"
+)
+
+// testClient returns a MAAS client talking to handler.
+func testClient(t *testing.T, handler http.HandlerFunc) *maas_client.MAASClient {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	t.Setenv("MAAS_BASE_URL", server.URL)
+	t.Setenv("MAAS_API_KEY", "consumer:token:secret")
+
+	client, err := maas_client.NewMAASClientFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestQueryEventsSince(t *testing.T) {
+	start := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	// Events 100 down to 1, one minute apart, except every third event which
+	// is an hour older, so a page mixes events before and after since.
+	created := func(id int) time.Time {
+		if id%3 == 0 {
+			return start.Add(time.Duration(id)*time.Minute - time.Hour)
+		}
+		return start.Add(time.Duration(id) * time.Minute)
+	}
+
+	requests := 0
+	client := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		before := 101
+		if value := r.URL.Query().Get("before"); value != "" {
+			before, _ = strconv.Atoi(value)
+		}
+
+		var events []map[string]any
+		for id := before - 1; id >= 1 && len(events) < limit; id-- {
+			events = append(events, map[string]any{"id": id, "created": created(id).Format(time.RFC3339)})
+		}
+		json.NewEncoder(w).Encode(map[string]any{"events": events})
+	})
+
+	tests := []struct {
+		limit int
+		since time.Time
+	}{
+		{limit: 3},
+		{limit: 3, since: start.Add(97 * time.Minute)},
+		{limit: 10, since: start.Add(80 * time.Minute)},
+		{limit: 10, since: start.Add(95 * time.Minute)},
+		{limit: 50, since: start},
+		{limit: 50, since: start.Add(2 * time.Hour)},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("limit=%d,since=%s", test.limit, test.since.Format(time.Kitchen)), func(t *testing.T) {
+			var want []int
+			for id := 100; id >= 1 && len(want) < test.limit; id-- {
+				if test.since.IsZero() || !created(id).Before(test.since) {
+					want = append(want, id)
+				}
+			}
+
+			requests = 0
+			query := url.Values{"id": {"abc123"}, "limit": {strconv.Itoa(test.limit)}}
+
+			events, err := queryEvents(context.Background(), client, query, test.since)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var ids []int
+			for _, event := range events {
+				ids = append(ids, int(event["id"].(float64)))
+			}
+
+			if fmt.Sprint(ids) != fmt.Sprint(want) {
+				t.Errorf("ids = %v, want %v", ids, want)
+			}
+			if requests > maxEventPages {
+				t.Errorf("made %d requests, at most %d expected", requests, maxEventPages)
+			}
+		})
+	}
+}
+
+func TestIsFailedStatus(t *testing.T) {
+	tests := []struct {
+		status string
+		want   bool
+	}{
+		{"Failed", true},
+		{"Timed out", true},
+		{"Failed installing", true},
+		{"Failed applying netconf", true},
+		{"Passed", false},
+		{"Pending", false},
+		{"Running", false},
+		{"Installing", false},
+		{"Applying netconf", false},
+		{"Skipped", false},
+		{"Aborted", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		if got := isFailedStatus(test.status); got != test.want {
+			t.Errorf("isFailedStatus(%q) = %t, want %t", test.status, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,541 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/JarcauCristian/ztp-mcp/internal/server/maas_client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+)
+
+// commissioningResourcesScript is the commissioning script whose output holds
+// the LXD machine resources (CPU topology, GPUs and PCI devices).
+const commissioningResourcesScript = "50-maas-01-commissioning"
+
+type Hardware struct{}
+
+func (Hardware) Register(mcpServer *server.MCPServer) {
+	mcpTools := []MCPTool{MachineHardware{}}
+
+	for _, tool := range mcpTools {
+		mcpServer.AddTool(tool.Create(), tool.Handle)
+	}
+}
+
+type hardwareSummary struct {
+	SystemID     string         `json:"system_id"`
+	Hostname     any            `json:"hostname"`
+	Architecture any            `json:"architecture"`
+	System       map[string]any `json:"system,omitempty"`
+	CPU          cpuSummary     `json:"cpu"`
+	Memory       memorySummary  `json:"memory"`
+	NUMANodes    []numaNode     `json:"numa_nodes"`
+	BlockDevices []blockDevice  `json:"block_devices"`
+	NICs         []networkCard  `json:"nics"`
+	GPUs         []pciDevice    `json:"gpus"`
+	PCIDevices   []pciDevice    `json:"pci_devices"`
+	Errors       []string       `json:"collection_errors,omitempty"`
+}
+
+type cpuSummary struct {
+	Model    string  `json:"model,omitempty"`
+	SpeedMHz float64 `json:"speed_mhz,omitempty"`
+	Sockets  int     `json:"sockets,omitempty"`
+	Cores    int     `json:"cores,omitempty"`
+	Threads  int     `json:"threads"`
+}
+
+type memorySummary struct {
+	TotalMB float64 `json:"total_mb"`
+	DIMMs   []dimm  `json:"dimms"`
+}
+
+type dimm struct {
+	Slot        string  `json:"slot,omitempty"`
+	SizeMB      float64 `json:"size_mb"`
+	Description string  `json:"description,omitempty"`
+	Vendor      string  `json:"vendor,omitempty"`
+	Product     string  `json:"product,omitempty"`
+	Serial      string  `json:"serial,omitempty"`
+	ClockMHz    float64 `json:"clock_mhz,omitempty"`
+}
+
+type numaNode struct {
+	Index    any     `json:"index"`
+	Cores    any     `json:"cores"`
+	MemoryMB float64 `json:"memory_mb"`
+}
+
+type blockDevice struct {
+	Name   string   `json:"name"`
+	Model  string   `json:"model,omitempty"`
+	Serial string   `json:"serial,omitempty"`
+	SizeGB float64  `json:"size_gb"`
+	Type   string   `json:"type"`
+	IDPath string   `json:"id_path,omitempty"`
+	Tags   []string `json:"tags,omitempty"`
+}
+
+type networkCard struct {
+	Name           string  `json:"name"`
+	MACAddress     string  `json:"mac_address"`
+	Vendor         string  `json:"vendor,omitempty"`
+	Product        string  `json:"product,omitempty"`
+	LinkSpeed      float64 `json:"link_speed_mbps"`
+	InterfaceSpeed float64 `json:"interface_speed_mbps"`
+	LinkConnected  bool    `json:"link_connected"`
+	Type           string  `json:"type"`
+	Neighbour      string  `json:"lldp_neighbour,omitempty"`
+}
+
+type pciDevice struct {
+	Address string `json:"pci_address"`
+	Vendor  string `json:"vendor,omitempty"`
+	Product string `json:"product,omitempty"`
+	Driver  string `json:"driver,omitempty"`
+	NUMA    any    `json:"numa_node,omitempty"`
+}
+
+// lshwNode is an element of the XML produced by `lshw -xml`.
+type lshwNode struct {
+	ID          string     `xml:"id,attr"`
+	Class       string     `xml:"class,attr"`
+	Description string     `xml:"description"`
+	Product     string     `xml:"product"`
+	Vendor      string     `xml:"vendor"`
+	Serial      string     `xml:"serial"`
+	Slot        string     `xml:"slot"`
+	BusInfo     string     `xml:"businfo"`
+	Size        float64    `xml:"size"`
+	Clock       float64    `xml:"clock"`
+	Nodes       []lshwNode `xml:"node"`
+}
+
+func (n lshwNode) walk(visit func(lshwNode)) {
+	visit(n)
+	for _, child := range n.Nodes {
+		child.walk(visit)
+	}
+}
+
+// lldpDocument is the XML produced by `lldpctl -f xml`.
+type lldpDocument struct {
+	Interfaces []struct {
+		Name    string `xml:"name,attr"`
+		Chassis struct {
+			ID   string `xml:"id"`
+			Name string `xml:"name"`
+		} `xml:"chassis"`
+		Port struct {
+			ID    string `xml:"id"`
+			Descr string `xml:"descr"`
+		} `xml:"port"`
+	} `xml:"interface"`
+}
+
+// bsonBinaryFields extracts the binary and string fields of the top level BSON
+// document returned by op-details. Null fields are skipped and any other
+// element type is rejected.
+func bsonBinaryFields(data []byte) (map[string][]byte, error) {
+	if len(data) < 5 {
+		return nil, fmt.Errorf("bson document too short")
+	}
+
+	length := int(binary.LittleEndian.Uint32(data))
+	if length > len(data) {
+		return nil, fmt.Errorf("bson document truncated")
+	}
+
+	fields := make(map[string][]byte)
+	position := 4
+
+	for position < length-1 {
+		elementType := data[position]
+		position++
+
+		nameEnd := bytes.IndexByte(data[position:], 0)
+		if nameEnd < 0 {
+			return nil, fmt.Errorf("bson element name not terminated")
+		}
+		name := string(data[position : position+nameEnd])
+		position += nameEnd + 1
+
+		switch elementType {
+		case 0x05, 0x02:
+			if position+4 > length {
+				return nil, fmt.Errorf("bson element %s truncated", name)
+			}
+			size := int(binary.LittleEndian.Uint32(data[position:]))
+			position += 4
+
+			if elementType == 0x05 {
+				position++ // binary subtype
+			} else {
+				size-- // trailing NUL of strings
+			}
+
+			if size < 0 || position+size > length {
+				return nil, fmt.Errorf("bson element %s truncated", name)
+			}
+
+			fields[name] = data[position : position+size]
+			position += size
+
+			if elementType == 0x02 {
+				position++
+			}
+		case 0x0A:
+		default:
+			return nil, fmt.Errorf("unsupported bson element type 0x%02x for %s", elementType, name)
+		}
+	}
+
+	return fields, nil
+}
+
+func stringValue(data map[string]any, key string) string {
+	value, _ := data[key].(string)
+	return value
+}
+
+func floatValue(data map[string]any, key string) float64 {
+	switch value := data[key].(type) {
+	case float64:
+		return value
+	case string:
+		parsed, _ := strconv.ParseFloat(value, 64)
+		return parsed
+	}
+
+	return 0
+}
+
+// fromMachine fills the summary from the machine object.
+func (h *hardwareSummary) fromMachine(machine map[string]any) {
+	h.Hostname = machine["hostname"]
+	h.Architecture = machine["architecture"]
+
+	hardwareInfo, _ := machine["hardware_info"].(map[string]any)
+	if hardwareInfo != nil {
+		h.System = make(map[string]any)
+		for key, value := range hardwareInfo {
+			if key != "cpu_model" && value != "Unknown" {
+				h.System[key] = value
+			}
+		}
+		h.CPU.Model = stringValue(hardwareInfo, "cpu_model")
+	}
+
+	h.CPU.Threads = int(floatValue(machine, "cpu_count"))
+	h.CPU.SpeedMHz = floatValue(machine, "cpu_speed")
+	h.Memory.TotalMB = floatValue(machine, "memory")
+
+	numaNodes, _ := machine["numanode_set"].([]any)
+	for _, node := range numaNodes {
+		nodeData, ok := node.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		h.NUMANodes = append(h.NUMANodes, numaNode{
+			Index:    nodeData["index"],
+			Cores:    nodeData["cores"],
+			MemoryMB: floatValue(nodeData, "memory"),
+		})
+	}
+
+	devices, _ := machine["physicalblockdevice_set"].([]any)
+	for _, device := range devices {
+		deviceData, ok := device.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		var tags []string
+		deviceTags, _ := deviceData["tags"].([]any)
+		for _, tag := range deviceTags {
+			if tagName, ok := tag.(string); ok {
+				tags = append(tags, tagName)
+			}
+		}
+
+		h.BlockDevices = append(h.BlockDevices, blockDevice{
+			Name:   stringValue(deviceData, "name"),
+			Model:  stringValue(deviceData, "model"),
+			Serial: stringValue(deviceData, "serial"),
+			SizeGB: math.Round(floatValue(deviceData, "size")/1000/1000/10) / 100,
+			Type:   diskType(stringValue(deviceData, "name"), tags),
+			IDPath: stringValue(deviceData, "id_path"),
+			Tags:   tags,
+		})
+	}
+
+	interfaces, _ := machine["interface_set"].([]any)
+	for _, iface := range interfaces {
+		ifaceData, ok := iface.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		linkConnected, _ := ifaceData["link_connected"].(bool)
+
+		h.NICs = append(h.NICs, networkCard{
+			Name:           stringValue(ifaceData, "name"),
+			MACAddress:     stringValue(ifaceData, "mac_address"),
+			Vendor:         stringValue(ifaceData, "vendor"),
+			Product:        stringValue(ifaceData, "product"),
+			LinkSpeed:      floatValue(ifaceData, "link_speed"),
+			InterfaceSpeed: floatValue(ifaceData, "interface_speed"),
+			LinkConnected:  linkConnected,
+			Type:           stringValue(ifaceData, "type"),
+		})
+	}
+}
+
+func diskType(name string, tags []string) string {
+	switch {
+	case strings.HasPrefix(name, "nvme"):
+		return "nvme"
+	case hasTag(tags, "ssd"):
+		return "ssd"
+	case hasTag(tags, "rotary"):
+		return "hdd"
+	case hasTag(tags, "virtual"):
+		return "virtual"
+	}
+
+	return "unknown"
+}
+
+// hasTag reports whether tags holds tag, ignoring case.
+func hasTag(tags []string, tag string) bool {
+	for _, candidate := range tags {
+		if strings.EqualFold(candidate, tag) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// fromDetails adds the DIMMs found by lshw and the LLDP neighbours of the
+// NICs from the op-details document.
+func (h *hardwareSummary) fromDetails(details map[string][]byte) error {
+	if lshwData := details["lshw"]; len(lshwData) > 0 {
+		var root lshwNode
+		if err := xml.Unmarshal(lshwData, &root); err != nil {
+			return fmt.Errorf("failed to parse lshw: %w", err)
+		}
+
+		root.walk(func(node lshwNode) {
+			if node.Class != "memory" || !strings.HasPrefix(node.ID, "bank") || node.Size == 0 {
+				return
+			}
+
+			h.Memory.DIMMs = append(h.Memory.DIMMs, dimm{
+				Slot:        node.Slot,
+				SizeMB:      node.Size / 1024 / 1024,
+				Description: node.Description,
+				Vendor:      node.Vendor,
+				Product:     node.Product,
+				Serial:      node.Serial,
+				ClockMHz:    node.Clock / 1000 / 1000,
+			})
+		})
+
+		if len(h.GPUs) == 0 {
+			root.walk(func(node lshwNode) {
+				if node.Class == "display" {
+					h.GPUs = append(h.GPUs, pciDevice{
+						Address: strings.TrimPrefix(node.BusInfo, "pci@"),
+						Vendor:  node.Vendor,
+						Product: node.Product,
+					})
+				}
+			})
+		}
+	}
+
+	if lldpData := details["lldp"]; len(lldpData) > 0 {
+		var document lldpDocument
+		if err := xml.Unmarshal(lldpData, &document); err != nil {
+			return fmt.Errorf("failed to parse lldp: %w", err)
+		}
+
+		for _, iface := range document.Interfaces {
+			neighbour := strings.TrimSpace(fmt.Sprintf("%s %s", iface.Chassis.Name, iface.Port.ID))
+			if iface.Port.Descr != "" {
+				neighbour += fmt.Sprintf(" (%s)", iface.Port.Descr)
+			}
+
+			for i := range h.NICs {
+				if h.NICs[i].Name == iface.Name {
+					h.NICs[i].Neighbour = neighbour
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// fromResources adds CPU topology, GPUs and PCI devices from the output of the
+// commissioning resources script.
+func (h *hardwareSummary) fromResources(output string) error {
+	var document struct {
+		Resources struct {
+			CPU struct {
+				Sockets []struct {
+					Name  string `json:"name"`
+					Cores []struct {
+						Threads []any `json:"threads"`
+					} `json:"cores"`
+				} `json:"sockets"`
+			} `json:"cpu"`
+			GPU struct {
+				Cards []struct {
+					Driver     string `json:"driver"`
+					PCIAddress string `json:"pci_address"`
+					Vendor     string `json:"vendor"`
+					Product    string `json:"product"`
+					NUMANode   any    `json:"numa_node"`
+				} `json:"cards"`
+			} `json:"gpu"`
+			PCI struct {
+				Devices []struct {
+					Driver     string `json:"driver"`
+					PCIAddress string `json:"pci_address"`
+					Vendor     string `json:"vendor"`
+					Product    string `json:"product"`
+					NUMANode   any    `json:"numa_node"`
+				} `json:"devices"`
+			} `json:"pci"`
+		} `json:"resources"`
+	}
+
+	if err := json.Unmarshal([]byte(output), &document); err != nil {
+		return fmt.Errorf("failed to parse %s output: %w", commissioningResourcesScript, err)
+	}
+
+	resources := document.Resources
+
+	if len(resources.CPU.Sockets) > 0 {
+		h.CPU.Sockets = len(resources.CPU.Sockets)
+		h.CPU.Cores = 0
+		threads := 0
+
+		for _, socket := range resources.CPU.Sockets {
+			h.CPU.Cores += len(socket.Cores)
+			for _, core := range socket.Cores {
+				threads += len(core.Threads)
+			}
+
+			if h.CPU.Model == "" || h.CPU.Model == "Unknown" {
+				h.CPU.Model = socket.Name
+			}
+		}
+
+		if threads > 0 {
+			h.CPU.Threads = threads
+		}
+	}
+
+	for _, card := range resources.GPU.Cards {
+		h.GPUs = append(h.GPUs, pciDevice{
+			Address: card.PCIAddress,
+			Vendor:  card.Vendor,
+			Product: card.Product,
+			Driver:  card.Driver,
+			NUMA:    card.NUMANode,
+		})
+	}
+
+	for _, device := range resources.PCI.Devices {
+		h.PCIDevices = append(h.PCIDevices, pciDevice{
+			Address: device.PCIAddress,
+			Vendor:  device.Vendor,
+			Product: device.Product,
+			Driver:  device.Driver,
+			NUMA:    device.NUMANode,
+		})
+	}
+
+	return nil
+}
+
+type MachineHardware struct{}
+
+func (MachineHardware) Create() mcp.Tool {
+	return mcp.NewTool(
+		"machine_hardware",
+		mcp.WithString(
+			"system_id",
+			mcp.Required(),
+			mcp.Pattern("^[0-9a-z]{6}$"),
+			mcp.Description("The system ID of the machine."),
+		),
+		mcp.WithToolAnnotation(CreateToolAnnotation("Machine Hardware", true, false, true, true)),
+		mcp.WithDescription("Returns a normalised hardware summary of a commissioned machine: CPU model and topology, NUMA nodes, DIMMs, block devices with model/serial/size/type, NICs with vendor, speed, MAC, link state and LLDP neighbour, and GPUs/PCI devices."),
+	)
+}
+
+func (MachineHardware) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	systemID, err := request.RequireString("system_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[MachineHardware] Required parameter system_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	client := maas_client.MustClient()
+
+	zap.L().Info(fmt.Sprintf("[MachineHardware] Collecting hardware of machine %s...", systemID))
+	machine, err := RetrieveMachine(ctx, client, systemID)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to retrieve the machine with id %s err=%v", systemID, err)
+		zap.L().Error(fmt.Sprintf("[MachineHardware] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	summary := hardwareSummary{SystemID: systemID}
+	summary.fromMachine(machine)
+
+	results, err := queryScriptResults(ctx, client, systemID, "commissioning", commissioningResourcesScript, true)
+	if err == nil && len(results) > 0 {
+		err = summary.fromResources(results[0].Output)
+	}
+	if err != nil {
+		summary.Errors = append(summary.Errors, fmt.Sprintf("commissioning resources: %v", err))
+	}
+
+	detailsData, err := client.Do(ctx, maas_client.RequestTypeGet, fmt.Sprintf("/MAAS/api/2.0/machines/%s/op-details", systemID), nil)
+	if err == nil {
+		var details map[string][]byte
+		details, err = bsonBinaryFields([]byte(detailsData))
+		if err == nil {
+			err = summary.fromDetails(details)
+		}
+	}
+	if err != nil {
+		summary.Errors = append(summary.Errors, fmt.Sprintf("details: %v", err))
+	}
+
+	jsonData, err := json.Marshal(summary)
+	if err != nil {
+		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[MachineHardware] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
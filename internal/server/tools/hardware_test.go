@@ -0,0 +1,94 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+// bsonDocument wraps elements in a BSON document with its length and
+// terminating NUL.
+func bsonDocument(elements ...[]byte) []byte {
+	body := bytes.Join(elements, nil)
+
+	document := binary.LittleEndian.AppendUint32(nil, uint32(4+len(body)+1))
+	document = append(document, body...)
+	return append(document, 0)
+}
+
+func bsonBinary(name string, value []byte) []byte {
+	element := append([]byte{0x05}, name...)
+	element = append(element, 0)
+	element = binary.LittleEndian.AppendUint32(element, uint32(len(value)))
+	element = append(element, 0x00)
+	return append(element, value...)
+}
+
+func bsonString(name, value string) []byte {
+	element := append([]byte{0x02}, name...)
+	element = append(element, 0)
+	element = binary.LittleEndian.AppendUint32(element, uint32(len(value)+1))
+	element = append(element, value...)
+	return append(element, 0)
+}
+
+func bsonNull(name string) []byte {
+	return append(append([]byte{0x0A}, name...), 0)
+}
+
+func TestBSONBinaryFields(t *testing.T) {
+	document := bsonDocument(
+		bsonBinary("lshw", []byte("<list/>")),
+		bsonNull("lldp"),
+		bsonString("note", "hello"),
+		bsonBinary("empty", nil),
+	)
+
+	fields, err := bsonBinaryFields(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"lshw": "<list/>", "note": "hello", "empty": ""}
+	if len(fields) != len(want) {
+		t.Fatalf("fields = %q, want %q", fields, want)
+	}
+	for name, value := range want {
+		if got, ok := fields[name]; !ok || string(got) != value {
+			t.Errorf("field %s = %q, want %q", name, got, value)
+		}
+	}
+}
+
+func TestBSONBinaryFieldsErrors(t *testing.T) {
+	valid := bsonDocument(bsonBinary("lshw", []byte("<list/>")))
+
+	overlong := bsonDocument(bsonBinary("lshw", []byte("<list/>")))
+	binary.LittleEndian.PutUint32(overlong[4+len("lshw")+2:], 1000)
+
+	emptyString := bsonDocument(bsonString("note", ""))
+	binary.LittleEndian.PutUint32(emptyString[4+len("note")+2:], 0)
+
+	tests := []struct {
+		name     string
+		document []byte
+		err      string
+	}{
+		{"too short", []byte{5, 0, 0}, "too short"},
+		{"truncated document", valid[:len(valid)-3], "document truncated"},
+		{"unterminated name", []byte{9, 0, 0, 0, 0x05, 'l', 's', 'h', 'w'}, "not terminated"},
+		{"element longer than the document", overlong, "element lshw truncated"},
+		{"string without its NUL", emptyString, "element note truncated"},
+		{"unsupported type", bsonDocument([]byte{0x10, 'n', 0, 1, 0, 0, 0}), "unsupported bson element type 0x10 for n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := bsonBinaryFields(test.document)
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("bsonBinaryFields = %v, want an error containing %q", err, test.err)
+			}
+		})
+	}
+}
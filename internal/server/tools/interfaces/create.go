@@ -0,0 +1,382 @@
+package interfaces
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/JarcauCristian/ztp-mcp/internal/server/maas_client"
+	"github.com/JarcauCristian/ztp-mcp/internal/server/tools"
+	"github.com/JarcauCristian/ztp-mcp/internal/server/tools/vlans"
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.uber.org/zap"
+)
+
+var (
+	bondModes        = []string{"balance-rr", "active-backup", "balance-xor", "broadcast", "802.3ad", "balance-tlb", "balance-alb"}
+	bondLacpRates    = []string{"fast", "slow"}
+	bondHashPolicies = []string{"layer2", "layer2+3", "layer3+4", "encap2+3", "encap3+4"}
+)
+
+// freeParents resolves the parents of a new bond or bridge and makes sure
+// none of them is already used by another interface.
+func freeParents(interfaces []networkInterface, names []string) ([]*networkInterface, error) {
+	var parents []*networkInterface
+
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		parent := findInterface(interfaces, name)
+		if parent == nil {
+			return nil, fmt.Errorf("interface %s not found", name)
+		}
+
+		if len(parent.Children) > 0 {
+			return nil, fmt.Errorf("interface %s is already used by %s", parent.Name, strings.Join(parent.Children, ", "))
+		}
+
+		parents = append(parents, parent)
+	}
+
+	if len(parents) == 0 {
+		return nil, fmt.Errorf("at least one parent interface is required")
+	}
+
+	return parents, nil
+}
+
+func addParents(form url.Values, parents []*networkInterface) {
+	for _, parent := range parents {
+		form.Add("parents", strconv.Itoa(parent.ID))
+	}
+}
+
+// resolveVlan returns the MAAS ID of the VLAN with the given VID on the fabric
+// of the parent interface, or on fabricID when it is set.
+func resolveVlan(ctx context.Context, client *maas_client.MAASClient, parent *networkInterface, fabricID, vid string) (int, error) {
+	if parent.VLAN == nil {
+		return 0, fmt.Errorf("interface %s is not connected to a fabric", parent.Name)
+	}
+
+	parentFabric := strconv.Itoa(parent.VLAN.FabricID)
+	if fabricID == "" {
+		fabricID = parentFabric
+	}
+
+	if fabricID != parentFabric {
+		return 0, fmt.Errorf("interface %s is on fabric %s, not %s", parent.Name, parentFabric, fabricID)
+	}
+
+	if vid == strconv.Itoa(parent.VLAN.VID) {
+		return 0, fmt.Errorf("VLAN %s is the untagged VLAN of %s", vid, parent.Name)
+	}
+
+	vlan, err := vlans.LookupVlan(ctx, client, fabricID, vid)
+	if err != nil {
+		return 0, fmt.Errorf("VLAN %s not found on fabric %s: %w", vid, fabricID, err)
+	}
+
+	id, _ := vlan["id"].(float64)
+	return int(id), nil
+}
+
+type CreateBond struct{}
+
+func (CreateBond) Create() mcp.Tool {
+	return mcp.NewTool(
+		"create_bond",
+		withSystemID(),
+		mcp.WithString(
+			"name",
+			mcp.Required(),
+			mcp.Description("Name of the bond, e.g. bond0."),
+		),
+		mcp.WithString(
+			"parents",
+			mcp.Required(),
+			mcp.Description("Comma separated IDs or names of the physical interfaces to bond."),
+		),
+		mcp.WithString(
+			"bond_mode",
+			mcp.Enum(bondModes...),
+			mcp.Description("The bonding mode. Defaults to balance-rr; use 802.3ad for LACP."),
+		),
+		mcp.WithString(
+			"bond_lacp_rate",
+			mcp.Enum(bondLacpRates...),
+			mcp.Description("802.3ad: how often LACPDUs are sent."),
+		),
+		mcp.WithString(
+			"bond_xmit_hash_policy",
+			mcp.Enum(bondHashPolicies...),
+			mcp.Description("The transmit hash policy for balance-xor and 802.3ad."),
+		),
+		mcp.WithString(
+			"bond_miimon",
+			mcp.Pattern("^[0-9]*$"),
+			mcp.Description("MII link monitoring frequency in milliseconds."),
+		),
+		mcp.WithString(
+			"mac_address",
+			mcp.Description("MAC address of the bond. Defaults to the MAC of the first parent."),
+		),
+		mcp.WithString(
+			"mtu",
+			mcp.Pattern("^[0-9]*$"),
+			mcp.Description("Maximum transmission unit."),
+		),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Create Bond", false, false, false, true)),
+		mcp.WithDescription("Creates a bond interface on a machine from free physical interfaces."),
+	)
+}
+
+func (CreateBond) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	systemID, err := request.RequireString("system_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[CreateBond] Required parameter system_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	name, err := request.RequireString("name")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[CreateBond] Required parameter name not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	parentNames, err := request.RequireString("parents")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[CreateBond] Required parameter parents not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	client := maas_client.MustClient()
+
+	interfaces, err := loadInterfaces(ctx, client, systemID)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to list the interfaces of machine %s err=%v", systemID, err)
+		zap.L().Error(fmt.Sprintf("[CreateBond] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	parents, err := freeParents(interfaces, strings.Split(parentNames, ","))
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[CreateBond] Invalid parents err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	for _, parent := range parents {
+		if parent.Type != "physical" {
+			return mcp.NewToolResultError(fmt.Sprintf("interface %s is a %s interface, only physical interfaces can be bonded", parent.Name, parent.Type)), nil
+		}
+	}
+
+	form := make(url.Values)
+	form.Add("name", name)
+	addParents(form, parents)
+
+	for _, option := range []string{"bond_mode", "bond_lacp_rate", "bond_xmit_hash_policy", "bond_miimon", "mac_address", "mtu"} {
+		if value := request.GetString(option, ""); value != "" {
+			form.Add(option, value)
+		}
+	}
+
+	if form.Get("bond_lacp_rate") != "" && form.Get("bond_mode") != "802.3ad" {
+		return mcp.NewToolResultError("bond_lacp_rate only applies to the 802.3ad bond mode"), nil
+	}
+
+	path := fmt.Sprintf("/MAAS/api/2.0/nodes/%s/interfaces/op-create_bond", systemID)
+
+	return tools.MachineRequest(ctx, "CreateBond", systemID, maas_client.RequestTypePost, path, form, fmt.Sprintf("create bond %s", name))
+}
+
+type CreateBridge struct{}
+
+func (CreateBridge) Create() mcp.Tool {
+	return mcp.NewTool(
+		"create_bridge",
+		withSystemID(),
+		mcp.WithString(
+			"name",
+			mcp.Required(),
+			mcp.Description("Name of the bridge, e.g. br0."),
+		),
+		mcp.WithString(
+			"parent",
+			mcp.Required(),
+			mcp.Description("ID or name of the interface to bridge."),
+		),
+		mcp.WithString(
+			"bridge_type",
+			mcp.Enum("standard", "ovs"),
+			mcp.Description("The type of bridge. Defaults to standard."),
+		),
+		mcp.WithBoolean(
+			"bridge_stp",
+			mcp.Description("Turn spanning tree protocol on or off."),
+		),
+		mcp.WithString(
+			"bridge_fd",
+			mcp.Pattern("^[0-9]*$"),
+			mcp.Description("Forward delay in seconds."),
+		),
+		mcp.WithString(
+			"mtu",
+			mcp.Pattern("^[0-9]*$"),
+			mcp.Description("Maximum transmission unit."),
+		),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Create Bridge", false, false, false, true)),
+		mcp.WithDescription("Creates a bridge interface on a machine on top of a free interface, for example to attach VMs."),
+	)
+}
+
+func (CreateBridge) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	systemID, err := request.RequireString("system_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[CreateBridge] Required parameter system_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	name, err := request.RequireString("name")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[CreateBridge] Required parameter name not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	parentName, err := request.RequireString("parent")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[CreateBridge] Required parameter parent not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	client := maas_client.MustClient()
+
+	interfaces, err := loadInterfaces(ctx, client, systemID)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to list the interfaces of machine %s err=%v", systemID, err)
+		zap.L().Error(fmt.Sprintf("[CreateBridge] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	parents, err := freeParents(interfaces, []string{parentName})
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[CreateBridge] Invalid parent err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	form := make(url.Values)
+	form.Add("name", name)
+	form.Add("parent", strconv.Itoa(parents[0].ID))
+
+	for _, option := range []string{"bridge_type", "bridge_fd", "mtu"} {
+		if value := request.GetString(option, ""); value != "" {
+			form.Add(option, value)
+		}
+	}
+
+	if request.GetBool("bridge_stp", false) {
+		form.Add("bridge_stp", "true")
+	}
+
+	path := fmt.Sprintf("/MAAS/api/2.0/nodes/%s/interfaces/op-create_bridge", systemID)
+
+	return tools.MachineRequest(ctx, "CreateBridge", systemID, maas_client.RequestTypePost, path, form, fmt.Sprintf("create bridge %s", name))
+}
+
+type CreateVlanInterface struct{}
+
+func (CreateVlanInterface) Create() mcp.Tool {
+	return mcp.NewTool(
+		"create_vlan_interface",
+		withSystemID(),
+		mcp.WithString(
+			"parent",
+			mcp.Required(),
+			mcp.Description("ID or name of the interface to tag the VLAN on, e.g. bond0."),
+		),
+		mcp.WithString(
+			"vid",
+			mcp.Required(),
+			mcp.Pattern("^[0-9]+$"),
+			mcp.Description("The VLAN ID (VID) of the tagged VLAN."),
+		),
+		mcp.WithString(
+			"fabric_id",
+			mcp.Pattern("^[0-9]*$"),
+			mcp.Description("The fabric of the VLAN. Defaults to the fabric of the parent, which is the only one allowed."),
+		),
+		mcp.WithString(
+			"mtu",
+			mcp.Pattern("^[0-9]*$"),
+			mcp.Description("Maximum transmission unit."),
+		),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Create VLAN Interface", false, false, false, true)),
+		mcp.WithDescription("Creates a tagged VLAN interface on a machine. The VLAN must exist on the fabric of the parent interface."),
+	)
+}
+
+func (CreateVlanInterface) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	systemID, err := request.RequireString("system_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[CreateVlanInterface] Required parameter system_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	parentName, err := request.RequireString("parent")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[CreateVlanInterface] Required parameter parent not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	vid, err := request.RequireString("vid")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[CreateVlanInterface] Required parameter vid not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	client := maas_client.MustClient()
+
+	interfaces, err := loadInterfaces(ctx, client, systemID)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to list the interfaces of machine %s err=%v", systemID, err)
+		zap.L().Error(fmt.Sprintf("[CreateVlanInterface] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	parent := findInterface(interfaces, parentName)
+	if parent == nil {
+		return mcp.NewToolResultError(fmt.Sprintf("interface %s not found", parentName)), nil
+	}
+
+	if parent.Type == "vlan" || slices.Contains([]string{"alias", "unknown"}, parent.Type) {
+		return mcp.NewToolResultError(fmt.Sprintf("cannot create a VLAN interface on %s interface %s", parent.Type, parent.Name)), nil
+	}
+
+	vlanID, err := resolveVlan(ctx, client, parent, request.GetString("fabric_id", ""), vid)
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[CreateVlanInterface] Invalid VLAN err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	form := make(url.Values)
+	form.Add("parent", strconv.Itoa(parent.ID))
+	form.Add("vlan", strconv.Itoa(vlanID))
+	if mtu := request.GetString("mtu", ""); mtu != "" {
+		form.Add("mtu", mtu)
+	}
+
+	path := fmt.Sprintf("/MAAS/api/2.0/nodes/%s/interfaces/op-create_vlan", systemID)
+
+	return tools.MachineRequest(ctx, "CreateVlanInterface", systemID, maas_client.RequestTypePost, path, form, fmt.Sprintf("create VLAN %s on %s", vid, parent.Name))
+}
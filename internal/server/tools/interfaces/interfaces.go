@@ -0,0 +1,191 @@
+package interfaces
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/JarcauCristian/ztp-mcp/internal/server/maas_client"
+	"github.com/JarcauCristian/ztp-mcp/internal/server/tools"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+)
+
+type Interfaces struct{}
+
+func (Interfaces) Register(mcpServer *server.MCPServer) {
+	mcpTools := []tools.MCPTool{
+		ListInterfaces{}, DeleteInterface{},
+		CreateBond{}, CreateBridge{}, CreateVlanInterface{},
+		LinkSubnet{}, UnlinkSubnet{}, SetDefaultGateway{},
+		ApplyNetworkPlan{},
+	}
+
+	for _, tool := range mcpTools {
+		mcpServer.AddTool(tool.Create(), tool.Handle)
+	}
+}
+
+type vlanRef struct {
+	ID       int    `json:"id"`
+	VID      int    `json:"vid"`
+	Name     string `json:"name,omitempty"`
+	Fabric   string `json:"fabric"`
+	FabricID int    `json:"fabric_id"`
+}
+
+type subnetRef struct {
+	ID   int      `json:"id"`
+	Name string   `json:"name,omitempty"`
+	CIDR string   `json:"cidr"`
+	VLAN *vlanRef `json:"vlan,omitempty"`
+}
+
+type link struct {
+	ID        int        `json:"id"`
+	Mode      string     `json:"mode"`
+	Subnet    *subnetRef `json:"subnet,omitempty"`
+	IPAddress string     `json:"ip_address,omitempty"`
+}
+
+type networkInterface struct {
+	ID            int      `json:"id"`
+	Name          string   `json:"name"`
+	Type          string   `json:"type"`
+	MACAddress    string   `json:"mac_address"`
+	Enabled       bool     `json:"enabled"`
+	LinkConnected bool     `json:"link_connected"`
+	EffectiveMTU  int      `json:"effective_mtu"`
+	VLAN          *vlanRef `json:"vlan"`
+	Parents       []string `json:"parents"`
+	Children      []string `json:"children"`
+	Links         []link   `json:"links"`
+	Params        any      `json:"params,omitempty"`
+}
+
+func withSystemID() mcp.ToolOption {
+	return mcp.WithString(
+		"system_id",
+		mcp.Required(),
+		mcp.Pattern("^[0-9a-z]{6}$"),
+		mcp.Description("The system ID of the machine."),
+	)
+}
+
+func withInterfaceID(description string) mcp.ToolOption {
+	return mcp.WithString(
+		"interface_id",
+		mcp.Required(),
+		mcp.Pattern("^[0-9]+$"),
+		mcp.Description(description),
+	)
+}
+
+// loadInterfaces returns the interfaces of a machine, refusing protected
+// machines.
+func loadInterfaces(ctx context.Context, client *maas_client.MAASClient, systemID string) ([]networkInterface, error) {
+	if _, err := tools.RetrieveMachine(ctx, client, systemID); err != nil {
+		return nil, err
+	}
+
+	return fetchInterfaces(ctx, client, systemID)
+}
+
+func fetchInterfaces(ctx context.Context, client *maas_client.MAASClient, systemID string) ([]networkInterface, error) {
+	resultData, err := client.Do(ctx, maas_client.RequestTypeGet, fmt.Sprintf("/MAAS/api/2.0/nodes/%s/interfaces/", systemID), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var interfaces []networkInterface
+	if err := json.Unmarshal([]byte(resultData), &interfaces); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the interfaces: %w", err)
+	}
+
+	return interfaces, nil
+}
+
+// findInterface returns the interface with the given ID or name.
+func findInterface(interfaces []networkInterface, idOrName string) *networkInterface {
+	id, _ := strconv.Atoi(idOrName)
+
+	for i := range interfaces {
+		if interfaces[i].Name == idOrName || (id != 0 && interfaces[i].ID == id) {
+			return &interfaces[i]
+		}
+	}
+
+	return nil
+}
+
+type ListInterfaces struct{}
+
+func (ListInterfaces) Create() mcp.Tool {
+	return mcp.NewTool(
+		"list_interfaces",
+		withSystemID(),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("List Interfaces", true, false, true, true)),
+		mcp.WithDescription("Lists the network interfaces of a machine with their type, MAC address, VLAN, parents/children, bond or bridge parameters and subnet links."),
+	)
+}
+
+func (ListInterfaces) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	systemID, err := request.RequireString("system_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[ListInterfaces] Required parameter system_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	client := maas_client.MustClient()
+
+	zap.L().Info(fmt.Sprintf("[ListInterfaces] Listing interfaces of machine %s...", systemID))
+	interfaces, err := loadInterfaces(ctx, client, systemID)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to list the interfaces of machine %s err=%v", systemID, err)
+		zap.L().Error(fmt.Sprintf("[ListInterfaces] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	jsonData, err := json.Marshal(interfaces)
+	if err != nil {
+		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[ListInterfaces] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+type DeleteInterface struct{}
+
+func (DeleteInterface) Create() mcp.Tool {
+	return mcp.NewTool(
+		"delete_interface",
+		withSystemID(),
+		withInterfaceID("The ID of the interface to delete."),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Delete Interface", false, true, false, true)),
+		mcp.WithDescription("Deletes a bond, bridge or VLAN interface of a machine. Its parents become free to be used again."),
+	)
+}
+
+func (DeleteInterface) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	systemID, err := request.RequireString("system_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[DeleteInterface] Required parameter system_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	interfaceID, err := request.RequireString("interface_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[DeleteInterface] Required parameter interface_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	path := fmt.Sprintf("/MAAS/api/2.0/nodes/%s/interfaces/%s/", systemID, interfaceID)
+
+	return tools.MachineRequest(ctx, "DeleteInterface", systemID, maas_client.RequestTypeDelete, path, nil, fmt.Sprintf("delete interface %s", interfaceID))
+}
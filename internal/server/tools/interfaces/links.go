@@ -0,0 +1,255 @@
+package interfaces
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/JarcauCristian/ztp-mcp/internal/server/maas_client"
+	"github.com/JarcauCristian/ztp-mcp/internal/server/tools"
+	"github.com/JarcauCristian/ztp-mcp/internal/server/tools/subnets"
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.uber.org/zap"
+)
+
+var linkModes = []string{"AUTO", "DHCP", "STATIC", "LINK_UP"}
+
+// checkLink makes sure a subnet can be linked to an interface with the given
+// mode: the subnet must be on the VLAN of the interface and a static address
+// must be inside the subnet.
+func checkLink(ctx context.Context, client *maas_client.MAASClient, iface *networkInterface, mode, subnetID, ipAddress string) error {
+	if !slices.Contains(linkModes, mode) {
+		return fmt.Errorf("unknown link mode %s, expected one of %s", mode, strings.Join(linkModes, ", "))
+	}
+
+	if subnetID == "" {
+		if mode == "AUTO" || mode == "STATIC" {
+			return fmt.Errorf("mode %s requires a subnet", mode)
+		}
+		return nil
+	}
+
+	if ipAddress != "" && mode != "STATIC" {
+		return fmt.Errorf("ip_address is only allowed with the STATIC mode")
+	}
+
+	subnet, err := subnets.LookupSubnet(ctx, client, subnetID)
+	if err != nil {
+		return fmt.Errorf("subnet %s not found: %w", subnetID, err)
+	}
+
+	cidr, _ := subnet["cidr"].(string)
+
+	vlan, _ := subnet["vlan"].(map[string]any)
+	vlanID, _ := vlan["id"].(float64)
+
+	if iface.VLAN == nil {
+		return fmt.Errorf("interface %s is not connected to a VLAN", iface.Name)
+	}
+
+	if int(vlanID) != iface.VLAN.ID {
+		return fmt.Errorf("subnet %s is on VLAN %v, but interface %s is on VLAN %d (%s)", cidr, vlan["vid"], iface.Name, iface.VLAN.VID, iface.VLAN.Fabric)
+	}
+
+	if ipAddress != "" {
+		ip := net.ParseIP(ipAddress)
+		if ip == nil {
+			return fmt.Errorf("invalid IP address %s", ipAddress)
+		}
+
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("subnet %s has an invalid CIDR %q: %w", subnetID, cidr, err)
+		}
+
+		if !network.Contains(ip) {
+			return fmt.Errorf("IP address %s is not in subnet %s", ipAddress, cidr)
+		}
+	}
+
+	return nil
+}
+
+type LinkSubnet struct{}
+
+func (LinkSubnet) Create() mcp.Tool {
+	return mcp.NewTool(
+		"link_subnet",
+		withSystemID(),
+		withInterfaceID("The ID of the interface to link."),
+		mcp.WithString(
+			"mode",
+			mcp.Required(),
+			mcp.Enum(linkModes...),
+			mcp.Description("AUTO assigns an address from the subnet at deploy time, DHCP uses DHCP on the VLAN, STATIC uses ip_address (or picks a free one) and LINK_UP only brings the link up."),
+		),
+		mcp.WithString(
+			"subnet",
+			mcp.Pattern("^[0-9]*$"),
+			mcp.Description("The ID of the subnet to link. Required for AUTO and STATIC; it must be on the VLAN of the interface."),
+		),
+		mcp.WithString(
+			"ip_address",
+			mcp.Description("STATIC: the IP address to assign. It must be inside the subnet."),
+		),
+		mcp.WithBoolean(
+			"default_gateway",
+			mcp.Description("AUTO and STATIC: use the gateway of the subnet as the default gateway of the machine."),
+		),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Link Subnet", false, false, false, true)),
+		mcp.WithDescription("Links an interface of a machine to a subnet, configuring how it gets its IP address."),
+	)
+}
+
+func (LinkSubnet) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	systemID, err := request.RequireString("system_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[LinkSubnet] Required parameter system_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	interfaceID, err := request.RequireString("interface_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[LinkSubnet] Required parameter interface_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	mode, err := request.RequireString("mode")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[LinkSubnet] Required parameter mode not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	subnetID := request.GetString("subnet", "")
+	ipAddress := request.GetString("ip_address", "")
+	defaultGateway := request.GetBool("default_gateway", false)
+
+	client := maas_client.MustClient()
+
+	interfaces, err := loadInterfaces(ctx, client, systemID)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to list the interfaces of machine %s err=%v", systemID, err)
+		zap.L().Error(fmt.Sprintf("[LinkSubnet] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	iface := findInterface(interfaces, interfaceID)
+	if iface == nil {
+		return mcp.NewToolResultError(fmt.Sprintf("interface %s not found", interfaceID)), nil
+	}
+
+	if err := checkLink(ctx, client, iface, mode, subnetID, ipAddress); err != nil {
+		zap.L().Error(fmt.Sprintf("[LinkSubnet] Invalid link err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if defaultGateway && mode != "AUTO" && mode != "STATIC" {
+		return mcp.NewToolResultError("default_gateway is only allowed with the AUTO and STATIC modes"), nil
+	}
+
+	form := make(url.Values)
+	form.Add("mode", strings.ToLower(mode))
+	if subnetID != "" {
+		form.Add("subnet", subnetID)
+	}
+	if ipAddress != "" {
+		form.Add("ip_address", ipAddress)
+	}
+	if defaultGateway {
+		form.Add("default_gateway", "true")
+	}
+
+	path := fmt.Sprintf("/MAAS/api/2.0/nodes/%s/interfaces/%d/op-link_subnet", systemID, iface.ID)
+
+	return tools.MachineRequest(ctx, "LinkSubnet", systemID, maas_client.RequestTypePost, path, form, fmt.Sprintf("link %s to subnet %s", iface.Name, subnetID))
+}
+
+type UnlinkSubnet struct{}
+
+func (UnlinkSubnet) Create() mcp.Tool {
+	return mcp.NewTool(
+		"unlink_subnet",
+		withSystemID(),
+		withInterfaceID("The ID of the interface to unlink."),
+		mcp.WithString(
+			"link_id",
+			mcp.Required(),
+			mcp.Pattern("^[0-9]+$"),
+			mcp.Description("The ID of the link to remove, as shown by list_interfaces."),
+		),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Unlink Subnet", false, true, false, true)),
+		mcp.WithDescription("Removes a subnet link from an interface of a machine, releasing its IP address."),
+	)
+}
+
+func (UnlinkSubnet) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	systemID, err := request.RequireString("system_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[UnlinkSubnet] Required parameter system_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	interfaceID, err := request.RequireString("interface_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[UnlinkSubnet] Required parameter interface_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	linkID, err := request.RequireString("link_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[UnlinkSubnet] Required parameter link_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	form := make(url.Values)
+	form.Add("id", linkID)
+
+	path := fmt.Sprintf("/MAAS/api/2.0/nodes/%s/interfaces/%s/op-unlink_subnet", systemID, interfaceID)
+
+	return tools.MachineRequest(ctx, "UnlinkSubnet", systemID, maas_client.RequestTypePost, path, form, fmt.Sprintf("unlink link %s from interface %s", linkID, interfaceID))
+}
+
+type SetDefaultGateway struct{}
+
+func (SetDefaultGateway) Create() mcp.Tool {
+	return mcp.NewTool(
+		"set_default_gateway",
+		withSystemID(),
+		withInterfaceID("The ID of the interface whose gateway becomes the default."),
+		mcp.WithString(
+			"link_id",
+			mcp.Pattern("^[0-9]*$"),
+			mcp.Description("The link to take the gateway from, needed when the interface has more than one AUTO or STATIC link."),
+		),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Set Default Gateway", false, false, true, true)),
+		mcp.WithDescription("Sets the default gateway of a machine to the gateway of a subnet linked to one of its interfaces."),
+	)
+}
+
+func (SetDefaultGateway) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	systemID, err := request.RequireString("system_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[SetDefaultGateway] Required parameter system_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	interfaceID, err := request.RequireString("interface_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[SetDefaultGateway] Required parameter interface_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	form := make(url.Values)
+	if linkID := request.GetString("link_id", ""); linkID != "" {
+		form.Add("link_id", linkID)
+	}
+
+	path := fmt.Sprintf("/MAAS/api/2.0/nodes/%s/interfaces/%s/op-set_default_gateway", systemID, interfaceID)
+
+	return tools.MachineRequest(ctx, "SetDefaultGateway", systemID, maas_client.RequestTypePost, path, form, fmt.Sprintf("set default gateway from interface %s", interfaceID))
+}
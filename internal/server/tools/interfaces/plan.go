@@ -0,0 +1,873 @@
+package interfaces
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/JarcauCristian/ztp-mcp/internal/server/maas_client"
+	"github.com/JarcauCristian/ztp-mcp/internal/server/tools"
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.uber.org/zap"
+)
+
+// interfaceOptions are the MAAS parameters a plan may set per interface type.
+var interfaceOptions = map[string][]string{
+	"physical": nil,
+	"bond":     {"bond_mode", "bond_lacp_rate", "bond_xmit_hash_policy", "bond_miimon", "bond_updelay", "bond_downdelay", "mtu"},
+	"bridge":   {"bridge_type", "bridge_stp", "bridge_fd", "mtu"},
+	"vlan":     {"mtu"},
+}
+
+var optionValues = map[string][]string{
+	"bond_mode":             bondModes,
+	"bond_lacp_rate":        bondLacpRates,
+	"bond_xmit_hash_policy": bondHashPolicies,
+	"bridge_type":           {"standard", "ovs"},
+}
+
+type linkPlan struct {
+	Mode           string `json:"mode"`
+	Subnet         string `json:"subnet,omitempty"`
+	IPAddress      string `json:"ip_address,omitempty"`
+	DefaultGateway bool   `json:"default_gateway,omitempty"`
+}
+
+func (l linkPlan) matches(existing link) bool {
+	if !strings.EqualFold(existing.Mode, l.Mode) {
+		return false
+	}
+
+	subnetID := ""
+	if existing.Subnet != nil {
+		subnetID = strconv.Itoa(existing.Subnet.ID)
+	}
+
+	return subnetID == l.Subnet && (l.IPAddress == "" || existing.IPAddress == l.IPAddress)
+}
+
+func (l linkPlan) String() string {
+	if l.Subnet == "" {
+		return l.Mode
+	}
+
+	if l.IPAddress != "" {
+		return fmt.Sprintf("subnet %s (%s %s)", l.Subnet, l.Mode, l.IPAddress)
+	}
+
+	return fmt.Sprintf("subnet %s (%s)", l.Subnet, l.Mode)
+}
+
+func planFromLink(existing link) linkPlan {
+	plan := linkPlan{Mode: strings.ToUpper(existing.Mode)}
+
+	if existing.Subnet != nil {
+		plan.Subnet = strconv.Itoa(existing.Subnet.ID)
+	}
+
+	if plan.Mode == "STATIC" {
+		plan.IPAddress = existing.IPAddress
+	}
+
+	return plan
+}
+
+// placeholder reports whether a link is the subnet-less LINK_UP link MAAS
+// adds to unconfigured interfaces and replaces when a subnet is linked.
+func placeholder(existing link) bool {
+	return existing.Subnet == nil && strings.EqualFold(existing.Mode, "link_up")
+}
+
+type interfacePlan struct {
+	Name    string         `json:"name,omitempty"`
+	Type    string         `json:"type"`
+	Parents []string       `json:"parents,omitempty"`
+	VID     int            `json:"vid,omitempty"`
+	Options map[string]any `json:"options,omitempty"`
+	Links   []linkPlan     `json:"links,omitempty"`
+}
+
+func (spec interfacePlan) matches(existing *networkInterface) bool {
+	if existing.Type != spec.Type {
+		return false
+	}
+
+	if spec.Type == "vlan" && (existing.VLAN == nil || existing.VLAN.VID != spec.VID) {
+		return false
+	}
+
+	if len(existing.Parents) != len(spec.Parents) {
+		return false
+	}
+
+	for _, parent := range spec.Parents {
+		if !slices.Contains(existing.Parents, parent) {
+			return false
+		}
+	}
+
+	params, _ := existing.Params.(map[string]any)
+	for key, value := range spec.Options {
+		if key == "mtu" {
+			if strconv.Itoa(existing.EffectiveMTU) != fmt.Sprint(value) {
+				return false
+			}
+			continue
+		}
+
+		if fmt.Sprint(params[key]) != fmt.Sprint(value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (spec interfacePlan) String() string {
+	if len(spec.Parents) == 0 {
+		return spec.Name
+	}
+
+	return fmt.Sprintf("%s on %s", spec.Name, strings.Join(spec.Parents, ", "))
+}
+
+// specFromInterface describes an existing interface as a plan entry so it can
+// be recreated during a rollback.
+func specFromInterface(iface networkInterface) interfacePlan {
+	spec := interfacePlan{Name: iface.Name, Type: iface.Type, Parents: iface.Parents, Options: make(map[string]any)}
+
+	if iface.Type == "vlan" && iface.VLAN != nil {
+		spec.VID = iface.VLAN.VID
+	}
+
+	params, _ := iface.Params.(map[string]any)
+	for _, key := range interfaceOptions[iface.Type] {
+		if value, ok := params[key]; ok && value != nil && value != "" {
+			spec.Options[key] = value
+		}
+	}
+
+	return spec
+}
+
+// networkPlan is the desired interface configuration of a machine. Interfaces
+// are referenced by name; VLAN interfaces are named <parent>.<vid>.
+type networkPlan struct {
+	Interfaces     []interfacePlan `json:"interfaces"`
+	RemoveUnlisted bool            `json:"remove_unlisted,omitempty"`
+}
+
+func (p *networkPlan) validate() error {
+	names := make(map[string]bool)
+	usedBy := make(map[string]string)
+
+	for i := range p.Interfaces {
+		spec := &p.Interfaces[i]
+
+		options, ok := interfaceOptions[spec.Type]
+		if !ok {
+			return fmt.Errorf("interface %s has unknown type %q, expected physical, bond, bridge or vlan", spec.Name, spec.Type)
+		}
+
+		switch spec.Type {
+		case "physical":
+			if len(spec.Parents) > 0 {
+				return fmt.Errorf("physical interface %s cannot have parents", spec.Name)
+			}
+		case "bond":
+			if len(spec.Parents) == 0 {
+				return fmt.Errorf("bond %s has no parents", spec.Name)
+			}
+		case "bridge":
+			if len(spec.Parents) != 1 {
+				return fmt.Errorf("bridge %s needs exactly one parent", spec.Name)
+			}
+		case "vlan":
+			if len(spec.Parents) != 1 {
+				return fmt.Errorf("VLAN interface %s needs exactly one parent", spec.Name)
+			}
+			if spec.VID < 1 || spec.VID > 4094 {
+				return fmt.Errorf("VLAN interface on %s has invalid vid %d", spec.Parents[0], spec.VID)
+			}
+
+			name := fmt.Sprintf("%s.%d", spec.Parents[0], spec.VID)
+			if spec.Name != "" && spec.Name != name {
+				return fmt.Errorf("VLAN interface %s must be named %s", spec.Name, name)
+			}
+			spec.Name = name
+		}
+
+		if spec.Name == "" {
+			return fmt.Errorf("%s interface without a name", spec.Type)
+		}
+		if names[spec.Name] {
+			return fmt.Errorf("interface %s is defined more than once", spec.Name)
+		}
+		names[spec.Name] = true
+
+		if spec.VID != 0 && spec.Type != "vlan" {
+			return fmt.Errorf("only VLAN interfaces have a vid, %s is a %s", spec.Name, spec.Type)
+		}
+
+		for key, value := range spec.Options {
+			if !slices.Contains(options, key) {
+				return fmt.Errorf("option %s is not valid for %s interface %s", key, spec.Type, spec.Name)
+			}
+			if allowed, ok := optionValues[key]; ok && !slices.Contains(allowed, fmt.Sprint(value)) {
+				return fmt.Errorf("interface %s has unknown %s %v", spec.Name, key, value)
+			}
+		}
+
+		if spec.Type == "bond" || spec.Type == "bridge" {
+			for _, parent := range spec.Parents {
+				if owner, ok := usedBy[parent]; ok {
+					return fmt.Errorf("interface %s is a parent of both %s and %s", parent, owner, spec.Name)
+				}
+				usedBy[parent] = spec.Name
+			}
+		}
+
+		for _, link := range spec.Links {
+			if !slices.Contains(linkModes, link.Mode) {
+				return fmt.Errorf("interface %s has a link with unknown mode %s", spec.Name, link.Mode)
+			}
+			if link.DefaultGateway && link.Mode != "AUTO" && link.Mode != "STATIC" {
+				return fmt.Errorf("interface %s: default_gateway is only allowed with the AUTO and STATIC modes", spec.Name)
+			}
+		}
+	}
+
+	for _, spec := range p.Interfaces {
+		if owner, ok := usedBy[spec.Name]; ok && len(spec.Links) > 0 {
+			return fmt.Errorf("interface %s is a parent of %s and cannot have links of its own", spec.Name, owner)
+		}
+	}
+
+	return nil
+}
+
+type networkStep struct {
+	Action string `json:"action"`
+	Target string `json:"target"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+
+	run func() (undo func() error, err error)
+}
+
+// networkPlanner computes the steps that turn the current interfaces of a
+// machine into the plan and performs them, undoing the performed steps in
+// reverse order when one fails.
+type networkPlanner struct {
+	ctx        context.Context
+	client     *maas_client.MAASClient
+	systemID   string
+	interfaces []networkInterface
+	vlans      map[string]*vlanRef
+	steps      []*networkStep
+	// gatewayLinks holds the IDs of the links the default routes of the
+	// machine go through, so a rollback can restore them.
+	gatewayLinks map[int]bool
+	// rollbackFailures lists the steps that could not be undone.
+	rollbackFailures []string
+}
+
+// defaultGatewayLinks returns the IDs of the links in the default_gateways of
+// a machine.
+func defaultGatewayLinks(machine map[string]any) map[int]bool {
+	links := make(map[int]bool)
+
+	gateways, _ := machine["default_gateways"].(map[string]any)
+	for _, family := range []string{"ipv4", "ipv6"} {
+		gateway, _ := gateways[family].(map[string]any)
+		if linkID, ok := gateway["link_id"].(float64); ok {
+			links[int(linkID)] = true
+		}
+	}
+
+	return links
+}
+
+// restoredLink describes an existing link for a rollback, keeping the default
+// gateway when the link carried it.
+func (p *networkPlanner) restoredLink(existing link) linkPlan {
+	plan := planFromLink(existing)
+	if p.gatewayLinks[existing.ID] && (plan.Mode == "AUTO" || plan.Mode == "STATIC") {
+		plan.DefaultGateway = true
+	}
+
+	return plan
+}
+
+func (p *networkPlanner) add(action, target string, run func() (func() error, error)) {
+	p.steps = append(p.steps, &networkStep{Action: action, Target: target, Status: "planned", run: run})
+}
+
+// diff validates the plan against the current interfaces and records the
+// steps in order: stale links are removed, stale interfaces deleted from the
+// top of the stack down, missing interfaces created from the bottom up and
+// finally the missing links added.
+func (p *networkPlanner) diff(plan networkPlan) error {
+	specs := make(map[string]interfacePlan)
+	for _, spec := range plan.Interfaces {
+		specs[spec.Name] = spec
+	}
+
+	deleted := make(map[string]bool)
+	var remove func(name string)
+	remove = func(name string) {
+		deleted[name] = true
+		if iface := findInterface(p.interfaces, name); iface != nil {
+			for _, child := range iface.Children {
+				remove(child)
+			}
+		}
+	}
+
+	for _, spec := range plan.Interfaces {
+		existing := findInterface(p.interfaces, spec.Name)
+
+		switch {
+		case spec.Type == "physical":
+			if existing == nil || existing.Type != "physical" {
+				return fmt.Errorf("physical interface %s not found", spec.Name)
+			}
+		case existing == nil:
+		case !slices.Contains([]string{"bond", "bridge", "vlan"}, existing.Type):
+			return fmt.Errorf("interface %s is a %s interface, not a %s", spec.Name, existing.Type, spec.Type)
+		case !spec.matches(existing):
+			remove(existing.Name)
+		}
+	}
+
+	if plan.RemoveUnlisted {
+		for _, existing := range p.interfaces {
+			if _, ok := specs[existing.Name]; !ok && slices.Contains([]string{"bond", "bridge", "vlan"}, existing.Type) {
+				remove(existing.Name)
+			}
+		}
+	}
+
+	kept := func(name string) bool {
+		return findInterface(p.interfaces, name) != nil && !deleted[name]
+	}
+
+	parentOfNew := make(map[string]bool)
+
+	for _, spec := range plan.Interfaces {
+		if spec.Type == "physical" || kept(spec.Name) {
+			continue
+		}
+
+		for _, parentName := range spec.Parents {
+			parent := findInterface(p.interfaces, parentName)
+			if parent == nil || deleted[parentName] {
+				if _, ok := specs[parentName]; !ok {
+					return fmt.Errorf("parent %s of %s not found", parentName, spec.Name)
+				}
+				if spec.Type == "bond" {
+					return fmt.Errorf("bond %s: only physical interfaces can be bonded, %s is not", spec.Name, parentName)
+				}
+				continue
+			}
+
+			if spec.Type == "bond" && parent.Type != "physical" {
+				return fmt.Errorf("bond %s: only physical interfaces can be bonded, %s is a %s", spec.Name, parentName, parent.Type)
+			}
+
+			if spec.Type == "vlan" {
+				continue
+			}
+
+			parentOfNew[parentName] = true
+			for _, child := range parent.Children {
+				if !deleted[child] {
+					return fmt.Errorf("interface %s is already used by %s", parentName, child)
+				}
+			}
+		}
+	}
+
+	missing := make(map[string][]linkPlan)
+
+	for _, existing := range p.interfaces {
+		if deleted[existing.Name] {
+			continue
+		}
+
+		spec, planned := specs[existing.Name]
+		if !planned && !parentOfNew[existing.Name] {
+			continue
+		}
+
+		remaining := slices.Clone(spec.Links)
+		for _, current := range existing.Links {
+			if index := slices.IndexFunc(remaining, func(l linkPlan) bool { return l.matches(current) }); index >= 0 {
+				remaining = slices.Delete(remaining, index, index+1)
+				continue
+			}
+
+			if placeholder(current) {
+				continue
+			}
+
+			name := existing.Name
+			p.add("unlink_subnet", fmt.Sprintf("%s from %s", name, planFromLink(current)), func() (func() error, error) {
+				if err := p.unlink(name, current.ID); err != nil {
+					return nil, err
+				}
+				return func() error {
+					_, err := p.link(name, p.restoredLink(current))
+					return err
+				}, nil
+			})
+		}
+
+		missing[existing.Name] = remaining
+	}
+
+	var pending []string
+	for _, existing := range p.interfaces {
+		if deleted[existing.Name] {
+			pending = append(pending, existing.Name)
+		}
+	}
+
+	for len(pending) > 0 {
+		for i := 0; i < len(pending); {
+			existing := *findInterface(p.interfaces, pending[i])
+			if slices.ContainsFunc(existing.Children, func(child string) bool { return slices.Contains(pending, child) }) {
+				i++
+				continue
+			}
+
+			p.add("delete_interface", existing.Name, func() (func() error, error) {
+				return p.delete(existing)
+			})
+			pending = slices.Delete(pending, i, i+1)
+		}
+	}
+
+	p.vlans = make(map[string]*vlanRef)
+	for _, existing := range p.interfaces {
+		if !deleted[existing.Name] {
+			p.vlans[existing.Name] = existing.VLAN
+		}
+	}
+
+	var creates []interfacePlan
+	for _, spec := range plan.Interfaces {
+		if spec.Type != "physical" && !kept(spec.Name) {
+			creates = append(creates, spec)
+		}
+	}
+
+	for len(creates) > 0 {
+		progressed := false
+
+		for i := 0; i < len(creates); {
+			spec := creates[i]
+			ready := !slices.ContainsFunc(spec.Parents, func(parent string) bool {
+				_, known := p.vlans[parent]
+				return !known
+			})
+			if !ready {
+				i++
+				continue
+			}
+
+			if err := p.deriveVlan(spec); err != nil {
+				return err
+			}
+
+			action := "create_" + spec.Type
+			if spec.Type == "vlan" {
+				action = "create_vlan_interface"
+			}
+
+			p.add(action, spec.String(), func() (func() error, error) {
+				return p.create(spec)
+			})
+			missing[spec.Name] = spec.Links
+			creates = slices.Delete(creates, i, i+1)
+			progressed = true
+		}
+
+		if !progressed {
+			var names []string
+			for _, spec := range creates {
+				names = append(names, spec.Name)
+			}
+			return fmt.Errorf("the parents of %s are missing or depend on each other", strings.Join(names, ", "))
+		}
+	}
+
+	for _, spec := range plan.Interfaces {
+		for _, desired := range missing[spec.Name] {
+			iface := &networkInterface{Name: spec.Name, VLAN: p.vlans[spec.Name]}
+			if err := checkLink(p.ctx, p.client, iface, desired.Mode, desired.Subnet, desired.IPAddress); err != nil {
+				return fmt.Errorf("interface %s: %w", spec.Name, err)
+			}
+
+			name := spec.Name
+			p.add("link_subnet", fmt.Sprintf("%s to %s", name, desired), func() (func() error, error) {
+				return p.link(name, desired)
+			})
+		}
+	}
+
+	return nil
+}
+
+// deriveVlan records the VLAN an interface created by the plan will be on:
+// bonds and bridges inherit it from their first parent, VLAN interfaces use
+// the tagged VLAN on the fabric of their parent.
+func (p *networkPlanner) deriveVlan(spec interfacePlan) error {
+	parentVlan := p.vlans[spec.Parents[0]]
+
+	if spec.Type != "vlan" {
+		p.vlans[spec.Name] = parentVlan
+		return nil
+	}
+
+	parent := &networkInterface{Name: spec.Parents[0], VLAN: parentVlan}
+	vlanID, err := resolveVlan(p.ctx, p.client, parent, "", strconv.Itoa(spec.VID))
+	if err != nil {
+		return fmt.Errorf("interface %s: %w", spec.Name, err)
+	}
+
+	p.vlans[spec.Name] = &vlanRef{ID: vlanID, VID: spec.VID, Fabric: parentVlan.Fabric, FabricID: parentVlan.FabricID}
+	return nil
+}
+
+// apply performs the steps in order. When a step fails the steps performed
+// before it are undone in reverse order and the rest are skipped.
+func (p *networkPlanner) apply() error {
+	var undos []func() error
+
+	for i, step := range p.steps {
+		undo, err := step.run()
+		if err != nil {
+			step.Status = "failed"
+			step.Error = err.Error()
+
+			for _, skipped := range p.steps[i+1:] {
+				skipped.Status = "skipped"
+			}
+
+			p.rollback(undos)
+			return fmt.Errorf("failed to %s %s: %w", step.Action, step.Target, err)
+		}
+
+		step.Status = "done"
+		undos = append(undos, undo)
+	}
+
+	return nil
+}
+
+func (p *networkPlanner) rollback(undos []func() error) {
+	for i := len(undos) - 1; i >= 0; i-- {
+		step := p.steps[i]
+
+		if err := undos[i](); err != nil {
+			zap.L().Error(fmt.Sprintf("[ApplyNetworkPlan] Failed to undo %s %s on machine %s err=%v", step.Action, step.Target, p.systemID, err))
+			step.Status = "rollback_failed"
+			step.Error = err.Error()
+			p.rollbackFailures = append(p.rollbackFailures, fmt.Sprintf("%s %s: %v", step.Action, step.Target, err))
+			continue
+		}
+
+		step.Status = "rolled_back"
+	}
+}
+
+func (p *networkPlanner) path(format string, args ...any) string {
+	return fmt.Sprintf("/MAAS/api/2.0/nodes/%s/interfaces/", p.systemID) + fmt.Sprintf(format, args...)
+}
+
+func (p *networkPlanner) request(requestType maas_client.RequestType, path string, form url.Values) (string, error) {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+
+	return p.client.Do(p.ctx, requestType, path, body)
+}
+
+// lookup reads the current state of an interface. IDs change when
+// interfaces are recreated, so every step resolves them by name.
+func (p *networkPlanner) lookup(name string) (*networkInterface, error) {
+	interfaces, err := fetchInterfaces(p.ctx, p.client, p.systemID)
+	if err != nil {
+		return nil, err
+	}
+
+	iface := findInterface(interfaces, name)
+	if iface == nil {
+		return nil, fmt.Errorf("interface %s not found", name)
+	}
+
+	return iface, nil
+}
+
+func (p *networkPlanner) create(spec interfacePlan) (func() error, error) {
+	var parents []*networkInterface
+	for _, name := range spec.Parents {
+		parent, err := p.lookup(name)
+		if err != nil {
+			return nil, err
+		}
+		parents = append(parents, parent)
+	}
+
+	form := make(url.Values)
+	for key, value := range spec.Options {
+		form.Add(key, fmt.Sprint(value))
+	}
+
+	switch spec.Type {
+	case "bond":
+		form.Add("name", spec.Name)
+		addParents(form, parents)
+	case "bridge":
+		form.Add("name", spec.Name)
+		form.Add("parent", strconv.Itoa(parents[0].ID))
+	case "vlan":
+		vlanID, err := resolveVlan(p.ctx, p.client, parents[0], "", strconv.Itoa(spec.VID))
+		if err != nil {
+			return nil, err
+		}
+		form.Add("parent", strconv.Itoa(parents[0].ID))
+		form.Add("vlan", strconv.Itoa(vlanID))
+	}
+
+	if _, err := p.request(maas_client.RequestTypePost, p.path("op-create_%s", spec.Type), form); err != nil {
+		return nil, err
+	}
+
+	return func() error { return p.remove(spec.Name) }, nil
+}
+
+func (p *networkPlanner) remove(name string) error {
+	iface, err := p.lookup(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = p.request(maas_client.RequestTypeDelete, p.path("%d/", iface.ID), nil)
+	return err
+}
+
+func (p *networkPlanner) delete(existing networkInterface) (func() error, error) {
+	if err := p.remove(existing.Name); err != nil {
+		return nil, err
+	}
+
+	return func() error {
+		if _, err := p.create(specFromInterface(existing)); err != nil {
+			return err
+		}
+
+		for _, current := range existing.Links {
+			if placeholder(current) {
+				continue
+			}
+			if _, err := p.link(existing.Name, p.restoredLink(current)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}, nil
+}
+
+func (p *networkPlanner) link(name string, desired linkPlan) (func() error, error) {
+	iface, err := p.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+
+	form := make(url.Values)
+	form.Add("mode", strings.ToLower(desired.Mode))
+	if desired.Subnet != "" {
+		form.Add("subnet", desired.Subnet)
+	}
+	if desired.IPAddress != "" {
+		form.Add("ip_address", desired.IPAddress)
+	}
+	if desired.DefaultGateway {
+		form.Add("default_gateway", "true")
+	}
+
+	resultData, err := p.request(maas_client.RequestTypePost, p.path("%d/op-link_subnet", iface.ID), form)
+	if err != nil {
+		return nil, err
+	}
+
+	var updated networkInterface
+	if err := json.Unmarshal([]byte(resultData), &updated); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal interface %s: %w", name, err)
+	}
+
+	for _, current := range updated.Links {
+		if !slices.ContainsFunc(iface.Links, func(previous link) bool { return previous.ID == current.ID }) {
+			linkID := current.ID
+			return func() error { return p.unlink(name, linkID) }, nil
+		}
+	}
+
+	return func() error { return nil }, nil
+}
+
+func (p *networkPlanner) unlink(name string, linkID int) error {
+	iface, err := p.lookup(name)
+	if err != nil {
+		return err
+	}
+
+	form := make(url.Values)
+	form.Add("id", strconv.Itoa(linkID))
+
+	_, err = p.request(maas_client.RequestTypePost, p.path("%d/op-unlink_subnet", iface.ID), form)
+	return err
+}
+
+type ApplyNetworkPlan struct{}
+
+func (ApplyNetworkPlan) Create() mcp.Tool {
+	return mcp.NewTool(
+		"apply_network_plan",
+		withSystemID(),
+		mcp.WithString(
+			"plan",
+			mcp.Required(),
+			mcp.Description(`The desired interface configuration as a JSON document. Interfaces are referenced by name and have a type of physical, bond, bridge or vlan; VLAN interfaces are named <parent>.<vid>. options takes the MAAS parameters of the interface (bond_mode, bond_lacp_rate, bond_xmit_hash_policy, bond_miimon, bond_updelay, bond_downdelay, bridge_type, bridge_stp, bridge_fd, mtu). links takes mode (AUTO, DHCP, STATIC, LINK_UP), subnet (ID), ip_address and default_gateway. Example: {"interfaces": [{"name": "bond0", "type": "bond", "parents": ["eth0", "eth1"], "options": {"bond_mode": "802.3ad", "bond_lacp_rate": "fast"}, "links": [{"mode": "DHCP"}]}, {"type": "vlan", "parents": ["bond0"], "vid": 100, "links": [{"mode": "STATIC", "subnet": "5", "ip_address": "10.0.100.10", "default_gateway": true}]}], "remove_unlisted": true}. remove_unlisted also deletes bonds, bridges and VLAN interfaces missing from the plan.`),
+		),
+		mcp.WithBoolean(
+			"dry_run",
+			mcp.Description("Only compute and return the steps without changing the machine."),
+		),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Apply Network Plan", false, true, true, true)),
+		mcp.WithDescription("Makes the interfaces of a Ready or Allocated machine match a declarative document. Computes the unlink, delete, create and link steps from the current configuration and applies them in order; if a step fails the steps already performed are rolled back. Returns the steps with their status and the resulting interfaces."),
+	)
+}
+
+func (ApplyNetworkPlan) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	systemID, err := request.RequireString("system_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[ApplyNetworkPlan] Required parameter system_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	planData, err := request.RequireString("plan")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[ApplyNetworkPlan] Required parameter plan not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	dryRun := request.GetBool("dry_run", false)
+
+	var plan networkPlan
+	decoder := json.NewDecoder(strings.NewReader(planData))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&plan); err != nil {
+		errMsg = fmt.Sprintf("Failed to parse the network plan err=%v", err)
+		zap.L().Error(fmt.Sprintf("[ApplyNetworkPlan] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	if err := plan.validate(); err != nil {
+		errMsg = fmt.Sprintf("Invalid network plan err=%v", err)
+		zap.L().Error(fmt.Sprintf("[ApplyNetworkPlan] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	client := maas_client.MustClient()
+
+	machine, err := tools.RetrieveMachine(ctx, client, systemID)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to retrieve the machine with id %s err=%v", systemID, err)
+		zap.L().Error(fmt.Sprintf("[ApplyNetworkPlan] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	if status, _ := machine["status_name"].(string); status != "Ready" && status != "Allocated" && !dryRun {
+		errMsg = fmt.Sprintf("machine %s is %s, the interfaces can only be changed on a Ready or Allocated machine", systemID, status)
+		zap.L().Error(fmt.Sprintf("[ApplyNetworkPlan] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	interfaces, err := fetchInterfaces(ctx, client, systemID)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to list the interfaces of machine %s err=%v", systemID, err)
+		zap.L().Error(fmt.Sprintf("[ApplyNetworkPlan] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	p := &networkPlanner{
+		ctx:          ctx,
+		client:       client,
+		systemID:     systemID,
+		interfaces:   interfaces,
+		steps:        []*networkStep{},
+		gatewayLinks: defaultGatewayLinks(machine),
+	}
+
+	if err := p.diff(plan); err != nil {
+		errMsg = fmt.Sprintf("Invalid network plan err=%v", err)
+		zap.L().Error(fmt.Sprintf("[ApplyNetworkPlan] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	report := struct {
+		SystemID       string             `json:"system_id"`
+		DryRun         bool               `json:"dry_run"`
+		Steps          []*networkStep     `json:"steps"`
+		Error          string             `json:"error,omitempty"`
+		RolledBack     bool               `json:"rolled_back,omitempty"`
+		RollbackFailed []string           `json:"rollback_failed,omitempty"`
+		Interfaces     []networkInterface `json:"interfaces,omitempty"`
+	}{
+		SystemID: systemID,
+		DryRun:   dryRun,
+		Steps:    p.steps,
+	}
+
+	var applyErr error
+	if !dryRun {
+		zap.L().Info(fmt.Sprintf("[ApplyNetworkPlan] Applying %d steps to machine %s...", len(p.steps), systemID))
+		applyErr = p.apply()
+
+		if applyErr != nil {
+			report.Error = applyErr.Error()
+			report.RolledBack = len(p.rollbackFailures) == 0
+			report.RollbackFailed = p.rollbackFailures
+			zap.L().Error(fmt.Sprintf("[ApplyNetworkPlan] Failed to apply the network plan to machine %s err=%v", systemID, applyErr))
+		}
+
+		if interfaces, err := fetchInterfaces(ctx, client, systemID); err == nil {
+			report.Interfaces = interfaces
+		}
+	}
+
+	jsonData, err := json.Marshal(report)
+	if err != nil {
+		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[ApplyNetworkPlan] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	if applyErr != nil {
+		return mcp.NewToolResultError(string(jsonData)), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
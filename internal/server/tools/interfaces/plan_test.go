@@ -0,0 +1,223 @@
+package interfaces
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRestoredLinkKeepsDefaultGateway(t *testing.T) {
+	var machine map[string]any
+	if err := json.Unmarshal([]byte(`{"default_gateways": {"ipv4": {"gateway_ip": "10.0.0.1", "link_id": 7}, "ipv6": {"gateway_ip": null, "link_id": null}}}`), &machine); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &networkPlanner{gatewayLinks: defaultGatewayLinks(machine)}
+
+	tests := []struct {
+		name     string
+		existing link
+		want     linkPlan
+	}{
+		{
+			name:     "static gateway link",
+			existing: link{ID: 7, Mode: "static", Subnet: &subnetRef{ID: 5}, IPAddress: "10.0.0.10"},
+			want:     linkPlan{Mode: "STATIC", Subnet: "5", IPAddress: "10.0.0.10", DefaultGateway: true},
+		},
+		{
+			name:     "other static link",
+			existing: link{ID: 8, Mode: "static", Subnet: &subnetRef{ID: 6}, IPAddress: "10.0.1.10"},
+			want:     linkPlan{Mode: "STATIC", Subnet: "6", IPAddress: "10.0.1.10"},
+		},
+		{
+			name:     "DHCP gateway link",
+			existing: link{ID: 7, Mode: "dhcp", Subnet: &subnetRef{ID: 5}},
+			want:     linkPlan{Mode: "DHCP", Subnet: "5"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := p.restoredLink(test.existing); got != test.want {
+				t.Errorf("restoredLink = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestNetworkPlanValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		plan string
+		err  string
+	}{
+		{"physical with links", `{"interfaces": [{"name": "eth0", "type": "physical", "links": [{"mode": "DHCP"}]}]}`, ""},
+		{"bond, bridge and VLAN", `{"interfaces": [
+			{"name": "bond0", "type": "bond", "parents": ["eth0", "eth1"], "options": {"bond_mode": "802.3ad", "mtu": 9000}},
+			{"name": "br0", "type": "bridge", "parents": ["bond0"], "links": [{"mode": "STATIC", "subnet": "5", "ip_address": "10.0.0.10", "default_gateway": true}]},
+			{"type": "vlan", "parents": ["bond0"], "vid": 20, "links": [{"mode": "AUTO", "subnet": "6"}]}
+		]}`, ""},
+		{"unknown type", `{"interfaces": [{"name": "x", "type": "team"}]}`, `unknown type "team"`},
+		{"physical with parents", `{"interfaces": [{"name": "eth0", "type": "physical", "parents": ["eth1"]}]}`, "cannot have parents"},
+		{"bond without parents", `{"interfaces": [{"name": "bond0", "type": "bond"}]}`, "bond bond0 has no parents"},
+		{"bridge with two parents", `{"interfaces": [{"name": "br0", "type": "bridge", "parents": ["eth0", "eth1"]}]}`, "exactly one parent"},
+		{"VLAN without parent", `{"interfaces": [{"type": "vlan", "vid": 20}]}`, "exactly one parent"},
+		{"VLAN with invalid vid", `{"interfaces": [{"type": "vlan", "parents": ["eth0"], "vid": 4095}]}`, "invalid vid 4095"},
+		{"VLAN with a different name", `{"interfaces": [{"name": "vlan20", "type": "vlan", "parents": ["eth0"], "vid": 20}]}`, "must be named eth0.20"},
+		{"missing name", `{"interfaces": [{"type": "bond", "parents": ["eth0"]}]}`, "bond interface without a name"},
+		{"duplicate name", `{"interfaces": [{"name": "eth0", "type": "physical"}, {"name": "eth0", "type": "physical"}]}`, "defined more than once"},
+		{"vid on a bond", `{"interfaces": [{"name": "bond0", "type": "bond", "parents": ["eth0"], "vid": 20}]}`, "only VLAN interfaces have a vid"},
+		{"option of another type", `{"interfaces": [{"name": "br0", "type": "bridge", "parents": ["eth0"], "options": {"bond_mode": "802.3ad"}}]}`, "option bond_mode is not valid"},
+		{"unknown option value", `{"interfaces": [{"name": "bond0", "type": "bond", "parents": ["eth0"], "options": {"bond_mode": "fastest"}}]}`, "unknown bond_mode fastest"},
+		{"shared parent", `{"interfaces": [{"name": "bond0", "type": "bond", "parents": ["eth0"]}, {"name": "br0", "type": "bridge", "parents": ["eth0"]}]}`, "parent of both bond0 and br0"},
+		{"unknown link mode", `{"interfaces": [{"name": "eth0", "type": "physical", "links": [{"mode": "STATIC6"}]}]}`, "unknown mode STATIC6"},
+		{"default gateway with DHCP", `{"interfaces": [{"name": "eth0", "type": "physical", "links": [{"mode": "DHCP", "default_gateway": true}]}]}`, "default_gateway is only allowed"},
+		{"links on a bond member", `{"interfaces": [{"name": "eth0", "type": "physical", "links": [{"mode": "DHCP"}]}, {"name": "bond0", "type": "bond", "parents": ["eth0"]}]}`, "parent of bond0 and cannot have links"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var plan networkPlan
+			if err := json.Unmarshal([]byte(test.plan), &plan); err != nil {
+				t.Fatal(err)
+			}
+
+			err := plan.validate()
+			if test.err == "" {
+				if err != nil {
+					t.Fatalf("validate = %v, want nil", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("validate = %v, want an error containing %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestNetworkPlanValidateNamesVLANs(t *testing.T) {
+	plan := networkPlan{Interfaces: []interfacePlan{{Type: "vlan", Parents: []string{"bond0"}, VID: 20}}}
+
+	if err := plan.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if plan.Interfaces[0].Name != "bond0.20" {
+		t.Errorf("name = %q, want bond0.20", plan.Interfaces[0].Name)
+	}
+}
+
+// currentInterfaces is a machine with eth0 and eth1 bonded into bond0, a
+// bridge br0 on the bond and eth2 on DHCP.
+func currentInterfaces() []networkInterface {
+	vlan := &vlanRef{ID: 5001, VID: 0}
+
+	return []networkInterface{
+		{ID: 1, Name: "eth0", Type: "physical", VLAN: vlan, Children: []string{"bond0"}},
+		{ID: 2, Name: "eth1", Type: "physical", VLAN: vlan, Children: []string{"bond0"}},
+		{ID: 3, Name: "eth2", Type: "physical", VLAN: vlan, Links: []link{{ID: 30, Mode: "dhcp", Subnet: &subnetRef{ID: 3}}}},
+		{ID: 4, Name: "bond0", Type: "bond", VLAN: vlan, Parents: []string{"eth0", "eth1"}, Children: []string{"br0"}, Params: map[string]any{"bond_mode": "balance-rr"}},
+		{ID: 5, Name: "br0", Type: "bridge", VLAN: vlan, Parents: []string{"bond0"}, Links: []link{{ID: 50, Mode: "auto", Subnet: &subnetRef{ID: 5}}}},
+	}
+}
+
+func TestNetworkPlannerDiffOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		plan  string
+		steps []string
+	}{
+		{
+			name: "nothing to change",
+			plan: `{"interfaces": [
+				{"name": "eth2", "type": "physical", "links": [{"mode": "DHCP", "subnet": "3"}]},
+				{"name": "bond0", "type": "bond", "parents": ["eth0", "eth1"]},
+				{"name": "br0", "type": "bridge", "parents": ["bond0"], "links": [{"mode": "AUTO", "subnet": "5"}]}
+			]}`,
+		},
+		{
+			name: "unlink, delete top-down, create bottom-up, link",
+			plan: `{"interfaces": [
+				{"name": "eth0", "type": "physical"},
+				{"name": "eth1", "type": "physical"},
+				{"name": "eth2", "type": "physical"},
+				{"name": "br0", "type": "bridge", "parents": ["bond0"], "links": [{"mode": "DHCP"}]},
+				{"name": "bond0", "type": "bond", "parents": ["eth0", "eth1"], "options": {"bond_mode": "active-backup"}}
+			]}`,
+			steps: []string{
+				"unlink_subnet eth2 from subnet 3 (DHCP)",
+				"delete_interface br0",
+				"delete_interface bond0",
+				"create_bond bond0 on eth0, eth1",
+				"create_bridge br0 on bond0",
+				"link_subnet br0 to DHCP",
+			},
+		},
+		{
+			name: "remove unlisted",
+			plan: `{"interfaces": [{"name": "eth0", "type": "physical", "links": [{"mode": "LINK_UP"}]}], "remove_unlisted": true}`,
+			steps: []string{
+				"delete_interface br0",
+				"delete_interface bond0",
+				"link_subnet eth0 to LINK_UP",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var plan networkPlan
+			if err := json.Unmarshal([]byte(test.plan), &plan); err != nil {
+				t.Fatal(err)
+			}
+			if err := plan.validate(); err != nil {
+				t.Fatal(err)
+			}
+
+			p := &networkPlanner{ctx: context.Background(), interfaces: currentInterfaces()}
+			if err := p.diff(plan); err != nil {
+				t.Fatal(err)
+			}
+
+			var steps []string
+			for _, step := range p.steps {
+				steps = append(steps, step.Action+" "+step.Target)
+			}
+
+			if !slices.Equal(steps, test.steps) {
+				t.Errorf("steps =\n%s\nwant\n%s", strings.Join(steps, "\n"), strings.Join(test.steps, "\n"))
+			}
+		})
+	}
+}
+
+func TestNetworkPlannerDiffErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		plan string
+		err  string
+	}{
+		{"unknown physical interface", `{"interfaces": [{"name": "eth9", "type": "physical"}]}`, "physical interface eth9 not found"},
+		{"physical planned as a bond", `{"interfaces": [{"name": "eth2", "type": "bond", "parents": ["eth0"]}]}`, "eth2 is a physical interface, not a bond"},
+		{"missing parent", `{"interfaces": [{"name": "br1", "type": "bridge", "parents": ["eth9"]}]}`, "parent eth9 of br1 not found"},
+		{"bond of a bond", `{"interfaces": [{"name": "bond1", "type": "bond", "parents": ["bond0"]}]}`, "only physical interfaces can be bonded, bond0 is a bond"},
+		{"parent already in use", `{"interfaces": [{"name": "br1", "type": "bridge", "parents": ["eth0"]}]}`, "eth0 is already used by bond0"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var plan networkPlan
+			if err := json.Unmarshal([]byte(test.plan), &plan); err != nil {
+				t.Fatal(err)
+			}
+
+			p := &networkPlanner{ctx: context.Background(), interfaces: currentInterfaces()}
+			if err := p.diff(plan); err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("diff = %v, want an error containing %q", err, test.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/JarcauCristian/ztp-mcp/internal/server/jobs"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+)
+
+type Jobs struct{}
+
+func (Jobs) Register(mcpServer *server.MCPServer) {
+	mcpTools := []MCPTool{JobStatus{}, ListJobs{}, CancelJob{}}
+
+	for _, tool := range mcpTools {
+		mcpServer.AddTool(tool.Create(), tool.Handle)
+	}
+
+	// Loading the manager resumes the jobs left running by the last run.
+	jobs.Default()
+}
+
+func withAsync() mcp.ToolOption {
+	return mcp.WithBoolean(
+		"async",
+		mcp.Description("Run the operation as a background job and return its id right away. The job follows the machines until they finish; track it with job_status."),
+	)
+}
+
+// jobView is a job as returned by the tools, without the form it sends to
+// MAAS since it may hold user data.
+type jobView struct {
+	ID        string         `json:"id"`
+	Tool      string         `json:"tool"`
+	Operation string         `json:"operation"`
+	SendWhen  string         `json:"send_when,omitempty"`
+	State     string         `json:"state"`
+	Error     string         `json:"error,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	Deadline  time.Time      `json:"deadline"`
+	Progress  map[string]int `json:"progress"`
+	Machines  []jobs.Target  `json:"machines,omitempty"`
+	Log       []jobs.Entry   `json:"log,omitempty"`
+}
+
+func newJobView(job jobs.Job, detailed bool) jobView {
+	view := jobView{
+		ID:        job.ID,
+		Tool:      job.Tool,
+		Operation: job.Operation,
+		SendWhen:  job.SendWhen,
+		State:     job.State,
+		Error:     job.Error,
+		CreatedAt: job.CreatedAt,
+		UpdatedAt: job.UpdatedAt,
+		Deadline:  job.Deadline,
+		Progress:  job.Progress(),
+	}
+
+	if detailed {
+		view.Machines = job.Targets
+		view.Log = job.Log
+	}
+
+	return view
+}
+
+func jobTargets(machines []map[string]any) []jobs.Target {
+	targets := make([]jobs.Target, 0, len(machines))
+	for _, machine := range machines {
+		systemID, _ := machine["system_id"].(string)
+		hostname, _ := machine["hostname"].(string)
+		targets = append(targets, jobs.Target{SystemID: systemID, Hostname: hostname})
+	}
+	return targets
+}
+
+// submitJob starts a background job sending op to the machines and returns
+// the job as the tool result.
+func submitJob(toolName, op string, form url.Values, machines []map[string]any) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	job, err := jobs.Default().Submit(toolName, op, form, jobTargets(machines), 0)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to start the %s job err=%v", op, err)
+		zap.L().Error(fmt.Sprintf("[%s] %s", toolName, errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	jsonData, err := json.Marshal(newJobView(job, true))
+	if err != nil {
+		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[%s] %s", toolName, errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+type JobStatus struct{}
+
+func (JobStatus) Create() mcp.Tool {
+	return mcp.NewTool(
+		"job_status",
+		mcp.WithString(
+			"id",
+			mcp.Required(),
+			mcp.Pattern("^[0-9a-f]{16}$"),
+			mcp.Description("The id of the job."),
+		),
+		mcp.WithToolAnnotation(CreateToolAnnotation("Job Status", true, false, true, false)),
+		mcp.WithDescription("Returns the state of a background job with the progress and status of each of its machines and the log of its steps."),
+	)
+}
+
+func (JobStatus) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	id, err := request.RequireString("id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[JobStatus] Required parameter id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	job, err := jobs.Default().Get(id)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to retrieve the job %s err=%v", id, err)
+		zap.L().Error(fmt.Sprintf("[JobStatus] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	jsonData, err := json.Marshal(newJobView(job, true))
+	if err != nil {
+		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[JobStatus] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+type ListJobs struct{}
+
+func (ListJobs) Create() mcp.Tool {
+	return mcp.NewTool(
+		"list_jobs",
+		mcp.WithString(
+			"state",
+			mcp.Enum(jobs.StateRunning, jobs.StateSucceeded, jobs.StateFailed, jobs.StateCancelled),
+			mcp.Description("Only list the jobs in this state."),
+		),
+		mcp.WithToolAnnotation(CreateToolAnnotation("List Jobs", true, false, true, false)),
+		mcp.WithDescription("Lists the background jobs, newest first, with their state and progress. Finished jobs are kept for a week."),
+	)
+}
+
+func (ListJobs) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	all := jobs.Default().List(request.GetString("state", ""))
+
+	views := make([]jobView, 0, len(all))
+	for _, job := range all {
+		views = append(views, newJobView(job, false))
+	}
+
+	jsonData, err := json.Marshal(views)
+	if err != nil {
+		errMsg := fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[ListJobs] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+type CancelJob struct{}
+
+func (CancelJob) Create() mcp.Tool {
+	return mcp.NewTool(
+		"cancel_job",
+		mcp.WithString(
+			"id",
+			mcp.Required(),
+			mcp.Pattern("^[0-9a-f]{16}$"),
+			mcp.Description("The id of the job."),
+		),
+		mcp.WithBoolean(
+			"abort",
+			mcp.Description("Also abort the operation in MAAS on the machines that are still in progress. Defaults to true."),
+		),
+		mcp.WithToolAnnotation(CreateToolAnnotation("Cancel Job", false, true, true, true)),
+		mcp.WithDescription("Cancels a running background job. The machines that did not finish are marked cancelled and, unless abort is false, MAAS is asked to abort their operation. Machines whose operation is being sent stay pending until MAAS answers, then are cancelled and aborted the same way."),
+	)
+}
+
+func (CancelJob) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	id, err := request.RequireString("id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[CancelJob] Required parameter id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	job, err := jobs.Default().Cancel(ctx, id, request.GetBool("abort", true))
+	if errors.Is(err, jobs.ErrNotFound) {
+		return mcp.NewToolResultError(fmt.Sprintf("job %s not found", id)), nil
+	}
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to cancel the job %s err=%v", id, err)
+		zap.L().Error(fmt.Sprintf("[CancelJob] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	jsonData, err := json.Marshal(newJobView(job, true))
+	if err != nil {
+		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[CancelJob] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/url"
 	"strings"
 
@@ -18,7 +19,10 @@ import (
 type Machines struct{}
 
 func (Machines) Register(mcpServer *server.MCPServer) {
-	mcpTools := []MCPTool{ListMachines{}, ListMachine{}, CommissionMachine{}}
+	mcpTools := []MCPTool{
+		ListMachines{}, ListMachine{}, CreateMachine{}, CommissionMachine{}, DeployMachine{}, TestMachine{},
+		EnterRescueMode{}, ExitRescueMode{}, MarkBroken{}, MarkFixed{}, LockMachine{}, UnlockMachine{},
+	}
 
 	for _, tool := range mcpTools {
 		mcpServer.AddTool(tool.Create(), tool.Handle)
@@ -173,6 +177,7 @@ func (CommissionMachine) Create() mcp.Tool {
 			mcp.Pattern("^[0-9a-z]{6}$"),
 			mcp.Description("The id of the machine to commission."),
 		),
+		withAsync(),
 		mcp.WithDescription("Start the commissioning process on a particular machine."),
 	)
 }
@@ -186,29 +191,25 @@ func (CommissionMachine) Handle(ctx context.Context, request mcp.CallToolRequest
 		return mcp.NewToolResultError(err.Error()), nil
 	}
 
-	path := fmt.Sprintf("/MAAS/api/2.0/machines/%s/op-commission", machineID)
-
 	client := maas_client.MustClient()
 
-	form := make(url.Values)
-	form.Add("enable_ssh", "1")
-
-	zap.L().Info(fmt.Sprintf("[CommissionMachine] Commissioning machine with id %s...", machineID))
-	resultData, err := client.Do(ctx, maas_client.RequestTypePost, path, strings.NewReader(form.Encode()))
+	machine, err := RetrieveMachine(ctx, client, machineID)
 	if err != nil {
-		errMsg = fmt.Sprintf("Failed to commission the machine with id %s err=%v", machineID, err)
+		errMsg = fmt.Sprintf("Failed to retrieve the machine with id %s err=%v", machineID, err)
 		zap.L().Error(fmt.Sprintf("[CommissionMachine] %s", errMsg))
 		return mcp.NewToolResultError(errMsg), nil
 	}
 
-	jsonData, err := json.Marshal(resultData)
-	if err != nil {
-		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
-		zap.L().Error(fmt.Sprintf("[CommissionMachine] %s", errMsg))
-		return mcp.NewToolResultError(errMsg), nil
+	form := make(url.Values)
+	form.Add("enable_ssh", "1")
+
+	if request.GetBool("async", false) {
+		return submitJob("CommissionMachine", "commission", form, []map[string]any{machine})
 	}
 
-	return mcp.NewToolResultText(string(jsonData)), nil
+	path := fmt.Sprintf("/MAAS/api/2.0/machines/%s/op-commission", machineID)
+
+	return performRequest(ctx, client, "CommissionMachine", machineID, maas_client.RequestTypePost, path, form, "commission")
 }
 
 type DeployMachine struct{}
@@ -233,7 +234,16 @@ func (DeployMachine) Create() mcp.Tool {
 			mcp.Required(),
 			mcp.Description("The parameters that will be used to replace the values in the templates. They are represented as a JSON valid object. If the template does not require parameters enter an empty JSON map {}."),
 		),
-		mcp.WithDescription("Deploys a machine with the specified id and template."),
+		mcp.WithBoolean(
+			"compress",
+			mcp.Description("If true gzip the user data before encoding it. Defaults to the compress setting of the template."),
+		),
+		mcp.WithString(
+			"distro_series",
+			mcp.Description("The OS release to deploy (e.g. jammy, noble). Defaults to the MAAS default distro series."),
+		),
+		withAsync(),
+		mcp.WithDescription("Deploys a machine with the specified id and template. Warns when the machine does not meet the hardware, architecture or distro series requirements of the template."),
 	)
 }
 
@@ -264,6 +274,8 @@ func (DeployMachine) Handle(ctx context.Context, request mcp.CallToolRequest) (*
 		return mcp.NewToolResultError(err.Error()), nil
 	}
 
+	templateExecutor.Compress = request.GetBool("compress", templateExecutor.Compress)
+
 	userData, err := templateExecutor.Execute()
 	if err != nil {
 		errMsg = "Failed to execute the template to retrieve the userData."
@@ -271,29 +283,68 @@ func (DeployMachine) Handle(ctx context.Context, request mcp.CallToolRequest) (*
 		return mcp.NewToolResultError(errMsg), nil
 	}
 
+	description, err := templates.Template(templateId)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to retrieve description for template with id %s: %v", templateId, err)
+		zap.L().Error(fmt.Sprintf("[DeployMachine] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
 	client := maas_client.MustClient()
 
+	machine, err := RetrieveMachine(ctx, client, machineId)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to retrieve the machine with id %s err=%v", machineId, err)
+		zap.L().Error(fmt.Sprintf("[DeployMachine] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	distroSeries := request.GetString("distro_series", "")
+
+	warnings := description.CheckMachine(machine, distroSeries)
+	for _, warning := range warnings {
+		zap.L().Warn(fmt.Sprintf("[DeployMachine] %s", warning))
+	}
+
 	path := fmt.Sprintf("/MAAS/api/2.0/machines/%s/op-deploy", machineId)
 
 	form := make(url.Values)
 	form.Add("user_data", userData)
+	if distroSeries != "" {
+		form.Add("distro_series", distroSeries)
+	}
 
-	zap.L().Info(fmt.Sprintf("[DeployMachine] Deploying machine with id %s and template %s...", machineId, templateId))
-	resultData, err := client.Do(ctx, maas_client.RequestTypePost, path, strings.NewReader(form.Encode()))
-	if err != nil {
-		errMsg = fmt.Sprintf("Failed to deploy the machine with id %s err=%v", machineId, err)
-		zap.L().Error(fmt.Sprintf("[DeployMachine] %s", errMsg))
-		return mcp.NewToolResultError(errMsg), nil
+	var result *mcp.CallToolResult
+
+	if request.GetBool("async", false) {
+		result, err = submitJob("DeployMachine", "deploy", form, []map[string]any{machine})
+		if err != nil || result.IsError {
+			return result, err
+		}
+	} else {
+		zap.L().Info(fmt.Sprintf("[DeployMachine] Deploying machine with id %s and template %s...", machineId, templateId))
+		resultData, err := client.Do(ctx, maas_client.RequestTypePost, path, strings.NewReader(form.Encode()))
+		if err != nil {
+			errMsg = fmt.Sprintf("Failed to deploy the machine with id %s err=%v", machineId, err)
+			zap.L().Error(fmt.Sprintf("[DeployMachine] %s", errMsg))
+			return mcp.NewToolResultError(errMsg), nil
+		}
+
+		jsonData, err := json.Marshal(resultData)
+		if err != nil {
+			errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+			zap.L().Error(fmt.Sprintf("[DeployMachine] %s", errMsg))
+			return mcp.NewToolResultError(errMsg), nil
+		}
+
+		result = mcp.NewToolResultText(string(jsonData))
 	}
 
-	jsonData, err := json.Marshal(resultData)
-	if err != nil {
-		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
-		zap.L().Error(fmt.Sprintf("[DeployMachine] %s", errMsg))
-		return mcp.NewToolResultError(errMsg), nil
+	for _, warning := range warnings {
+		result.Content = append(result.Content, mcp.NewTextContent(fmt.Sprintf("Warning: %s", warning)))
 	}
 
-	return mcp.NewToolResultText(string(jsonData)), nil
+	return result, nil
 }
 
 type TestMachine struct{}
@@ -326,8 +377,6 @@ func (TestMachine) Create() mcp.Tool {
 }
 
 func (TestMachine) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	var errMsg string
-
 	systemID, err := request.RequireString("system_id")
 	if err != nil {
 		zap.L().Error(fmt.Sprintf("[TestMachine] Required parameter system_id not present err=%v", err))
@@ -353,20 +402,93 @@ func (TestMachine) Handle(ctx context.Context, request mcp.CallToolRequest) (*mc
 		form.Add("testing_scripts", testingScripts)
 	}
 
+	return MachineRequest(ctx, "TestMachine", systemID, maas_client.RequestTypePost, path, form, "test the machine")
+}
+
+// RetrieveMachine returns the MAAS machine object for the given system ID and
+// fails for machines carrying the protected tag.
+func RetrieveMachine(ctx context.Context, client *maas_client.MAASClient, systemID string) (map[string]any, error) {
+	resultData, err := client.Do(ctx, maas_client.RequestTypeGet, fmt.Sprintf("/MAAS/api/2.0/machines/%s/", systemID), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var machine map[string]any
+	if err := json.Unmarshal([]byte(resultData), &machine); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the machine: %w", err)
+	}
+
+	if parser.CheckForProtectedTag(machine) {
+		return nil, fmt.Errorf("machine %s is protected", systemID)
+	}
+
+	return machine, nil
+}
+
+// RetrieveMachineByHostname resolves a hostname to its machine, with the same
+// protected check as RetrieveMachine.
+func RetrieveMachineByHostname(ctx context.Context, client *maas_client.MAASClient, hostname string) (map[string]any, error) {
+	resultData, err := client.Do(ctx, maas_client.RequestTypeGet, "/MAAS/api/2.0/machines/?"+url.Values{"hostname": {hostname}}.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var machines []map[string]any
+	if err := json.Unmarshal([]byte(resultData), &machines); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the machines: %w", err)
+	}
+
+	switch len(machines) {
+	case 0:
+		return nil, fmt.Errorf("no machine with hostname %s", hostname)
+	case 1:
+	default:
+		return nil, fmt.Errorf("%d machines have hostname %s, use system_id", len(machines), hostname)
+	}
+
+	if parser.CheckForProtectedTag(machines[0]) {
+		return nil, fmt.Errorf("machine %s is protected", hostname)
+	}
+
+	return machines[0], nil
+}
+
+// MachineRequest performs a request against a machine after making sure the
+// machine is not protected, and returns the MAAS response as the tool result.
+func MachineRequest(ctx context.Context, toolName, systemID string, requestType maas_client.RequestType, path string, form url.Values, action string) (*mcp.CallToolResult, error) {
 	client := maas_client.MustClient()
 
-	zap.L().Info(fmt.Sprintf("[TestMachine] Testing machine with system ID %s...", systemID))
-	resultData, err := client.Do(ctx, maas_client.RequestTypePost, path, strings.NewReader(form.Encode()))
+	if _, err := RetrieveMachine(ctx, client, systemID); err != nil {
+		errMsg := fmt.Sprintf("Failed to retrieve the machine with id %s err=%v", systemID, err)
+		zap.L().Error(fmt.Sprintf("[%s] %s", toolName, errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	return performRequest(ctx, client, toolName, systemID, requestType, path, form, action)
+}
+
+// performRequest sends a request for a machine that was already checked and
+// returns the MAAS response as the tool result.
+func performRequest(ctx context.Context, client *maas_client.MAASClient, toolName, systemID string, requestType maas_client.RequestType, path string, form url.Values, action string) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+
+	zap.L().Info(fmt.Sprintf("[%s] Trying to %s on machine %s...", toolName, action, systemID))
+	resultData, err := client.Do(ctx, requestType, path, body)
 	if err != nil {
-		errMsg = fmt.Sprintf("Failed to test machine with system ID %s err=%v", systemID, err)
-		zap.L().Error(fmt.Sprintf("[TestMachine] %s", errMsg))
+		errMsg = fmt.Sprintf("Failed to %s on machine %s err=%v", action, systemID, err)
+		zap.L().Error(fmt.Sprintf("[%s] %s", toolName, errMsg))
 		return mcp.NewToolResultError(errMsg), nil
 	}
 
 	jsonData, err := json.Marshal(resultData)
 	if err != nil {
 		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
-		zap.L().Error(fmt.Sprintf("[TestMachine] %s", errMsg))
+		zap.L().Error(fmt.Sprintf("[%s] %s", toolName, errMsg))
 		return mcp.NewToolResultError(errMsg), nil
 	}
 
@@ -0,0 +1,33 @@
+package tools
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// hostLabelPattern matches one label of a host or domain name.
+var hostLabelPattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?$`)
+
+// ValidName checks a DNS name, with or without a trailing dot, whose labels
+// must all match label. allowed describes the characters label accepts and is
+// used in the error message.
+func ValidName(name string, label *regexp.Regexp, allowed string) error {
+	name = strings.TrimSuffix(name, ".")
+	if name == "" || len(name) > 253 {
+		return fmt.Errorf("invalid name %q, it must be 1 to 253 characters", name)
+	}
+
+	for _, part := range strings.Split(name, ".") {
+		if !label.MatchString(part) {
+			return fmt.Errorf("invalid name %q, label %q must be 1 to 63 %s", name, part, allowed)
+		}
+	}
+
+	return nil
+}
+
+// ValidHostname checks a host or domain name, with or without a trailing dot.
+func ValidHostname(name string) error {
+	return ValidName(name, hostLabelPattern, "letters, digits or dashes")
+}
@@ -0,0 +1,47 @@
+package tools
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestValidHostname(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"web-1", true},
+		{"node01.maas", true},
+		{"node01.maas.", true},
+		{"Web", true},
+		{"", false},
+		{"-web", false},
+		{"web-", false},
+		{"web_1", false},
+		{"node..maas", false},
+		{strings.Repeat("a", 63), true},
+		{strings.Repeat("a", 64), false},
+		{strings.Repeat("a.", 127) + "a", false},
+	}
+
+	for _, test := range tests {
+		if err := ValidHostname(test.name); (err == nil) != test.valid {
+			t.Errorf("ValidHostname(%q) = %v, want valid %t", test.name, err, test.valid)
+		}
+	}
+}
+
+func TestValidNameMessage(t *testing.T) {
+	label := regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+	allowed := "letters, digits or underscores"
+
+	if err := ValidName("_sip._tcp", label, allowed); err != nil {
+		t.Fatalf("ValidName(_sip._tcp) = %v, want nil", err)
+	}
+
+	err := ValidName("sip-1", label, allowed)
+	if err == nil || !strings.Contains(err.Error(), allowed) {
+		t.Errorf("ValidName(sip-1) = %v, want an error naming %q", err, allowed)
+	}
+}
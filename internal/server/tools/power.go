@@ -4,6 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/JarcauCristian/ztp-mcp/internal/server/maas_client"
 	"github.com/mark3labs/mcp-go/mcp"
@@ -11,16 +16,227 @@ import (
 	"go.uber.org/zap"
 )
 
+const powerPollInterval = 5 * time.Second
+
 type Power struct{}
 
 func (Power) Register(mcpServer *server.MCPServer) {
-	mcpTools := []MCPTool{PowerState{}, ChangePowerState{}}
+	mcpTools := []MCPTool{PowerState{}, ChangePowerState{}, PowerCycle{}, PowerParameters{}, UpdatePowerParameters{}}
 
 	for _, tool := range mcpTools {
 		mcpServer.AddTool(tool.Create(), tool.Handle)
 	}
 }
 
+// powerDriver lists the power parameters MAAS accepts for a power type.
+type powerDriver struct {
+	required []string
+	optional []string
+}
+
+var powerDrivers = map[string]powerDriver{
+	"ipmi": {
+		required: []string{"power_address", "power_user", "power_pass"},
+		optional: []string{"power_driver", "power_boot_type", "mac_address", "cipher_suite_id", "privilege_level", "k_g", "workaround_flags"},
+	},
+	"redfish": {
+		required: []string{"power_address", "power_user", "power_pass"},
+		optional: []string{"node_id"},
+	},
+	"virsh": {
+		required: []string{"power_address", "power_id"},
+		optional: []string{"power_pass"},
+	},
+	"lxd": {
+		required: []string{"power_address", "instance_name"},
+		optional: []string{"project", "password", "certificate", "key"},
+	},
+	"amt": {
+		required: []string{"power_address", "power_pass"},
+	},
+	"manual": {},
+}
+
+// secretPowerParameters are masked when power parameters are read.
+var secretPowerParameters = []string{"power_pass", "password", "k_g", "key"}
+
+// maskSecrets hides the passwords and keys among power parameters.
+func maskSecrets(parameters map[string]any) {
+	for _, key := range secretPowerParameters {
+		if value, ok := parameters[key]; ok && value != "" {
+			parameters[key] = "********"
+		}
+	}
+}
+
+func powerTypes() []string {
+	types := make([]string, 0, len(powerDrivers))
+	for powerType := range powerDrivers {
+		types = append(types, powerType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// parsePowerParameters reads a JSON object of power parameters. Values of any
+// JSON type are accepted and sent to MAAS as strings.
+func parsePowerParameters(data string) (map[string]string, error) {
+	parameters := make(map[string]string)
+	if strings.TrimSpace(data) == "" {
+		return parameters, nil
+	}
+
+	var raw map[string]any
+	if err := json.Unmarshal([]byte(data), &raw); err != nil {
+		return nil, fmt.Errorf("power_parameters must be a JSON object: %w", err)
+	}
+
+	for key, value := range raw {
+		parameters[key] = fmt.Sprint(value)
+	}
+
+	return parameters, nil
+}
+
+// validatePowerParameters makes sure parameters only holds parameters of the
+// power type and contains all of its required ones.
+func validatePowerParameters(powerType string, parameters map[string]string) error {
+	driver, ok := powerDrivers[powerType]
+	if !ok {
+		return fmt.Errorf("unknown power type %s, expected one of %s", powerType, strings.Join(powerTypes(), ", "))
+	}
+
+	for key := range parameters {
+		if !slices.Contains(driver.required, key) && !slices.Contains(driver.optional, key) {
+			return fmt.Errorf("%s is not a %s power parameter", key, powerType)
+		}
+	}
+
+	var missing []string
+	for _, key := range driver.required {
+		if parameters[key] == "" {
+			missing = append(missing, key)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("the %s power type requires %s", powerType, strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+func addPowerParameters(form url.Values, parameters map[string]string) {
+	for key, value := range parameters {
+		form.Add("power_parameters_"+key, value)
+	}
+}
+
+func queryPowerState(ctx context.Context, client *maas_client.MAASClient, systemID string) (string, error) {
+	resultData, err := client.Do(ctx, maas_client.RequestTypeGet, fmt.Sprintf("/MAAS/api/2.0/machines/%s/op-query_power_state", systemID), nil)
+	if err != nil {
+		return "", err
+	}
+
+	var result struct {
+		State string `json:"state"`
+	}
+	if err := json.Unmarshal([]byte(resultData), &result); err != nil {
+		return "", fmt.Errorf("failed to unmarshal the power state: %w", err)
+	}
+
+	return result.State, nil
+}
+
+// waitForPowerState polls the BMC until the machine reaches want or timeout
+// expires, and returns the last state seen. The BMC is always queried at
+// least once, so a zero timeout returns the current state.
+func waitForPowerState(ctx context.Context, client *maas_client.MAASClient, systemID, want string, timeout time.Duration) (string, error) {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		state, err := queryPowerState(ctx, client, systemID)
+		if err != nil {
+			return "", err
+		}
+
+		if state == want || !time.Now().Before(deadline) {
+			return state, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return state, ctx.Err()
+		case <-time.After(powerPollInterval):
+		}
+	}
+}
+
+func setPower(ctx context.Context, client *maas_client.MAASClient, systemID string, on bool, form url.Values) error {
+	op := "power_off"
+	if on {
+		op = "power_on"
+	}
+
+	_, err := client.Do(ctx, maas_client.RequestTypePost, fmt.Sprintf("/MAAS/api/2.0/machines/%s/op-%s", systemID, op), strings.NewReader(form.Encode()))
+	return err
+}
+
+type powerResult struct {
+	SystemID   string `json:"system_id"`
+	Action     string `json:"action"`
+	PowerState string `json:"power_state"`
+	Reached    bool   `json:"reached"`
+	Message    string `json:"message,omitempty"`
+}
+
+// changePower switches a machine on or off and waits for the BMC to report
+// the new state. Machines with manual power are only told to change.
+func changePower(ctx context.Context, client *maas_client.MAASClient, machine map[string]any, on bool, form url.Values, wait time.Duration) (powerResult, error) {
+	systemID, _ := machine["system_id"].(string)
+
+	want := "off"
+	if on {
+		want = "on"
+	}
+
+	result := powerResult{SystemID: systemID, Action: "power_" + want}
+
+	if err := setPower(ctx, client, systemID, on, form); err != nil {
+		return result, err
+	}
+
+	if powerType, _ := machine["power_type"].(string); powerType == "manual" {
+		result.PowerState = "unknown"
+		result.Message = fmt.Sprintf("the machine has manual power control, power it %s by hand", want)
+		return result, nil
+	}
+
+	state, err := waitForPowerState(ctx, client, systemID, want, wait)
+	if err != nil {
+		return result, err
+	}
+
+	result.PowerState = state
+	result.Reached = state == want
+	if !result.Reached {
+		result.Message = fmt.Sprintf("the machine did not reach power state %s within %s", want, wait)
+	}
+
+	return result, nil
+}
+
+func marshalPowerResult(toolName string, result any) (*mcp.CallToolResult, error) {
+	jsonData, err := json.Marshal(result)
+	if err != nil {
+		errMsg := fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[%s] %s", toolName, errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
 type PowerState struct{}
 
 func (PowerState) Create() mcp.Tool {
@@ -83,7 +299,22 @@ func (ChangePowerState) Create() mcp.Tool {
 			mcp.Required(),
 			mcp.Description("If true power on the machine else power off."),
 		),
-		mcp.WithDescription("Change the power state of a machine specified by id."),
+		mcp.WithString(
+			"stop_mode",
+			mcp.Enum("soft", "hard"),
+			mcp.Description("Power off only: soft asks the OS to shut down, hard cuts the power. Defaults to hard."),
+		),
+		mcp.WithString(
+			"comment",
+			mcp.Description("Reason for the power change, recorded in the machine events."),
+		),
+		mcp.WithNumber(
+			"wait",
+			mcp.Min(0),
+			mcp.Description("Seconds to wait for the BMC to report the new power state. Defaults to 60, 0 queries the BMC once without waiting."),
+		),
+		mcp.WithToolAnnotation(CreateToolAnnotation("Change Power State", false, true, true, true)),
+		mcp.WithDescription("Powers a machine on or off and returns the resulting power state reported by its BMC."),
 	)
 }
 
@@ -102,16 +333,25 @@ func (ChangePowerState) Handle(ctx context.Context, request mcp.CallToolRequest)
 		return mcp.NewToolResultError(err.Error()), nil
 	}
 
-	var path string
-
-	if state {
-		path = fmt.Sprintf("/MAAS/api/2.0/machines/%s/op-power_on", machineID)
-	} else {
-		path = fmt.Sprintf("/MAAS/api/2.0/machines/%s/op-power_off", machineID)
+	form := make(url.Values)
+	if comment := request.GetString("comment", ""); comment != "" {
+		form.Add("comment", comment)
 	}
+	if stopMode := request.GetString("stop_mode", ""); stopMode != "" && !state {
+		form.Add("stop_mode", stopMode)
+	}
+
+	wait := time.Duration(request.GetInt("wait", 60)) * time.Second
 
 	client := maas_client.MustClient()
 
+	machine, err := RetrieveMachine(ctx, client, machineID)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to retrieve the machine with id %s err=%v", machineID, err)
+		zap.L().Error(fmt.Sprintf("[ChangePowerState] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
 	powerName := "on"
 
 	if !state {
@@ -119,19 +359,262 @@ func (ChangePowerState) Handle(ctx context.Context, request mcp.CallToolRequest)
 	}
 
 	zap.L().Info(fmt.Sprintf("[ChangePowerState] Power machine with id %s %s...", machineID, powerName))
-	resultData, err := client.Do(ctx, maas_client.RequestTypeGet, path, nil)
+	result, err := changePower(ctx, client, machine, state, form, wait)
 	if err != nil {
 		errMsg = fmt.Sprintf("Failed to power %s machine with id %s err=%v", powerName, machineID, err)
 		zap.L().Error(fmt.Sprintf("[ChangePowerState] %s", errMsg))
 		return mcp.NewToolResultError(errMsg), nil
 	}
 
-	jsonData, err := json.Marshal(resultData)
+	return marshalPowerResult("ChangePowerState", result)
+}
+
+type PowerCycle struct{}
+
+func (PowerCycle) Create() mcp.Tool {
+	return mcp.NewTool(
+		"power_cycle",
+		mcp.WithString(
+			"id",
+			mcp.Required(),
+			mcp.Pattern("^[0-9a-z]{6}$"),
+			mcp.Description("The id of the machine to power cycle."),
+		),
+		mcp.WithString(
+			"stop_mode",
+			mcp.Enum("soft", "hard"),
+			mcp.Description("soft asks the OS to shut down, hard cuts the power. Defaults to hard."),
+		),
+		mcp.WithString(
+			"comment",
+			mcp.Description("Reason for the power cycle, recorded in the machine events."),
+		),
+		mcp.WithNumber(
+			"wait",
+			mcp.Description("Seconds to wait for each of the off and on transitions. Defaults to 120."),
+		),
+		mcp.WithToolAnnotation(CreateToolAnnotation("Power Cycle", false, true, true, true)),
+		mcp.WithDescription("Powers a machine off, waits until its BMC reports it off, then powers it back on and returns the resulting power state."),
+	)
+}
+
+func (PowerCycle) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	machineID, err := request.RequireString("id")
 	if err != nil {
-		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
-		zap.L().Error(fmt.Sprintf("[ChangePowerState] %s", errMsg))
+		zap.L().Error(fmt.Sprintf("[PowerCycle] Required parameter id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	wait := time.Duration(request.GetInt("wait", 120)) * time.Second
+
+	client := maas_client.MustClient()
+
+	machine, err := RetrieveMachine(ctx, client, machineID)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to retrieve the machine with id %s err=%v", machineID, err)
+		zap.L().Error(fmt.Sprintf("[PowerCycle] %s", errMsg))
 		return mcp.NewToolResultError(errMsg), nil
 	}
 
-	return mcp.NewToolResultText(string(jsonData)), nil
+	if powerType, _ := machine["power_type"].(string); powerType == "manual" {
+		errMsg = fmt.Sprintf("machine %s has manual power control and cannot be power cycled", machineID)
+		zap.L().Error(fmt.Sprintf("[PowerCycle] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	offForm := make(url.Values)
+	onForm := make(url.Values)
+	if comment := request.GetString("comment", ""); comment != "" {
+		offForm.Add("comment", comment)
+		onForm.Add("comment", comment)
+	}
+	if stopMode := request.GetString("stop_mode", ""); stopMode != "" {
+		offForm.Add("stop_mode", stopMode)
+	}
+
+	zap.L().Info(fmt.Sprintf("[PowerCycle] Power cycling machine with id %s...", machineID))
+	off, err := changePower(ctx, client, machine, false, offForm, wait)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to power off machine with id %s err=%v", machineID, err)
+		zap.L().Error(fmt.Sprintf("[PowerCycle] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	if !off.Reached {
+		off.Action = "power_cycle"
+		zap.L().Error(fmt.Sprintf("[PowerCycle] Machine %s did not power off, not powering it on", machineID))
+		return marshalPowerResult("PowerCycle", off)
+	}
+
+	on, err := changePower(ctx, client, machine, true, onForm, wait)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to power on machine with id %s err=%v", machineID, err)
+		zap.L().Error(fmt.Sprintf("[PowerCycle] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	on.Action = "power_cycle"
+	return marshalPowerResult("PowerCycle", on)
+}
+
+type PowerParameters struct{}
+
+func (PowerParameters) Create() mcp.Tool {
+	return mcp.NewTool(
+		"power_parameters",
+		mcp.WithString(
+			"id",
+			mcp.Required(),
+			mcp.Pattern("^[0-9a-z]{6}$"),
+			mcp.Description("The id of the machine."),
+		),
+		mcp.WithBoolean(
+			"show_secrets",
+			mcp.Description("Return passwords and keys instead of masking them."),
+		),
+		mcp.WithToolAnnotation(CreateToolAnnotation("Power Parameters", true, false, true, true)),
+		mcp.WithDescription("Returns the power type of a machine and the parameters MAAS uses to control its power, such as the BMC address and user."),
+	)
+}
+
+func (PowerParameters) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	machineID, err := request.RequireString("id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[PowerParameters] Required parameter id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	client := maas_client.MustClient()
+
+	machine, err := RetrieveMachine(ctx, client, machineID)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to retrieve the machine with id %s err=%v", machineID, err)
+		zap.L().Error(fmt.Sprintf("[PowerParameters] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	zap.L().Info(fmt.Sprintf("[PowerParameters] Retrieving power parameters for machine with id %s...", machineID))
+	resultData, err := client.Do(ctx, maas_client.RequestTypeGet, fmt.Sprintf("/MAAS/api/2.0/machines/%s/op-power_parameters", machineID), nil)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to retrieve the power parameters of machine %s err=%v", machineID, err)
+		zap.L().Error(fmt.Sprintf("[PowerParameters] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	var parameters map[string]any
+	if err := json.Unmarshal([]byte(resultData), &parameters); err != nil {
+		errMsg = fmt.Sprintf("Failed to unmarshal the result err=%v", err)
+		zap.L().Error(fmt.Sprintf("[PowerParameters] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	if !request.GetBool("show_secrets", false) {
+		maskSecrets(parameters)
+	}
+
+	powerType, _ := machine["power_type"].(string)
+
+	return marshalPowerResult("PowerParameters", map[string]any{
+		"system_id":        machineID,
+		"power_type":       powerType,
+		"power_parameters": parameters,
+	})
+}
+
+type UpdatePowerParameters struct{}
+
+func (UpdatePowerParameters) Create() mcp.Tool {
+	return mcp.NewTool(
+		"update_power_parameters",
+		mcp.WithString(
+			"id",
+			mcp.Required(),
+			mcp.Pattern("^[0-9a-z]{6}$"),
+			mcp.Description("The id of the machine."),
+		),
+		mcp.WithString(
+			"power_type",
+			mcp.Enum(powerTypes()...),
+			mcp.Description("The new power type. Defaults to the current one, in which case only the given parameters change."),
+		),
+		mcp.WithString(
+			"power_parameters",
+			mcp.Description(`JSON object of power parameters. ipmi and redfish: power_address, power_user, power_pass (ipmi also power_driver LAN or LAN_2_0, cipher_suite_id, privilege_level, k_g; redfish node_id). virsh: power_address (e.g. qemu+ssh://user@host/system), power_id, power_pass. lxd: power_address, instance_name, project, password, certificate, key. amt: power_address, power_pass. manual: none. Example: {"power_address": "10.0.0.21", "power_user": "admin", "power_pass": "secret"}`),
+		),
+		mcp.WithToolAnnotation(CreateToolAnnotation("Update Power Parameters", false, false, true, true)),
+		mcp.WithDescription("Changes the power type and/or power parameters of a machine. The parameters are checked against the power type before they are sent."),
+	)
+}
+
+func (UpdatePowerParameters) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	machineID, err := request.RequireString("id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[UpdatePowerParameters] Required parameter id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	parameters, err := parsePowerParameters(request.GetString("power_parameters", ""))
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[UpdatePowerParameters] Invalid power parameters err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	client := maas_client.MustClient()
+
+	machine, err := RetrieveMachine(ctx, client, machineID)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to retrieve the machine with id %s err=%v", machineID, err)
+		zap.L().Error(fmt.Sprintf("[UpdatePowerParameters] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	currentType, _ := machine["power_type"].(string)
+	powerType := request.GetString("power_type", currentType)
+
+	merged := parameters
+	if powerType == currentType {
+		resultData, err := client.Do(ctx, maas_client.RequestTypeGet, fmt.Sprintf("/MAAS/api/2.0/machines/%s/op-power_parameters", machineID), nil)
+		if err != nil {
+			errMsg = fmt.Sprintf("Failed to retrieve the power parameters of machine %s err=%v", machineID, err)
+			zap.L().Error(fmt.Sprintf("[UpdatePowerParameters] %s", errMsg))
+			return mcp.NewToolResultError(errMsg), nil
+		}
+
+		var current map[string]any
+		if err := json.Unmarshal([]byte(resultData), &current); err != nil {
+			errMsg = fmt.Sprintf("Failed to unmarshal the power parameters err=%v", err)
+			zap.L().Error(fmt.Sprintf("[UpdatePowerParameters] %s", errMsg))
+			return mcp.NewToolResultError(errMsg), nil
+		}
+
+		driver := powerDrivers[powerType]
+		merged = make(map[string]string)
+		for key, value := range current {
+			if value != nil && (slices.Contains(driver.required, key) || slices.Contains(driver.optional, key)) {
+				merged[key] = fmt.Sprint(value)
+			}
+		}
+		for key, value := range parameters {
+			merged[key] = value
+		}
+	}
+
+	if err := validatePowerParameters(powerType, merged); err != nil {
+		zap.L().Error(fmt.Sprintf("[UpdatePowerParameters] Invalid power parameters err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	form := make(url.Values)
+	form.Add("power_type", powerType)
+	addPowerParameters(form, parameters)
+
+	path := fmt.Sprintf("/MAAS/api/2.0/machines/%s/", machineID)
+
+	return performRequest(ctx, client, "UpdatePowerParameters", machineID, maas_client.RequestTypePut, path, form, "update the power parameters")
 }
@@ -0,0 +1,265 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/JarcauCristian/ztp-mcp/internal/server/maas_client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.uber.org/zap"
+)
+
+// recoveryRequest performs a machine operation after checking that the
+// machine is not protected and that check accepts its current state.
+func recoveryRequest(ctx context.Context, toolName, systemID, op string, form url.Values, action string, check func(machine map[string]any) error) (*mcp.CallToolResult, error) {
+	client := maas_client.MustClient()
+
+	machine, err := RetrieveMachine(ctx, client, systemID)
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to retrieve the machine with id %s err=%v", systemID, err)
+		zap.L().Error(fmt.Sprintf("[%s] %s", toolName, errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	if err := check(machine); err != nil {
+		errMsg := fmt.Sprintf("Cannot %s on machine %s: %v", action, systemID, err)
+		zap.L().Error(fmt.Sprintf("[%s] %s", toolName, errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	path := fmt.Sprintf("/MAAS/api/2.0/machines/%s/op-%s", systemID, op)
+
+	return performRequest(ctx, client, toolName, systemID, maas_client.RequestTypePost, path, form, action)
+}
+
+// statusIn accepts machines whose status is one of statuses.
+func statusIn(statuses ...string) func(machine map[string]any) error {
+	return func(machine map[string]any) error {
+		status, _ := machine["status_name"].(string)
+		if !slices.Contains(statuses, status) {
+			return fmt.Errorf("the machine is %s, expected %s", status, strings.Join(statuses, " or "))
+		}
+		return nil
+	}
+}
+
+// locked accepts machines whose lock state is want.
+func locked(want bool) func(machine map[string]any) error {
+	return func(machine map[string]any) error {
+		if isLocked, _ := machine["locked"].(bool); isLocked != want {
+			if isLocked {
+				return fmt.Errorf("the machine is already locked")
+			}
+			return fmt.Errorf("the machine is not locked")
+		}
+		return nil
+	}
+}
+
+func withComment(description string) mcp.ToolOption {
+	return mcp.WithString(
+		"comment",
+		mcp.Description(description),
+	)
+}
+
+func commentForm(request mcp.CallToolRequest) url.Values {
+	form := make(url.Values)
+	if comment := request.GetString("comment", ""); comment != "" {
+		form.Add("comment", comment)
+	}
+	return form
+}
+
+type EnterRescueMode struct{}
+
+func (EnterRescueMode) Create() mcp.Tool {
+	return mcp.NewTool(
+		"enter_rescue_mode",
+		mcp.WithString(
+			"system_id",
+			mcp.Required(),
+			mcp.Pattern("^[0-9a-z]{6}$"),
+			mcp.Description("The system ID of the machine."),
+		),
+		mcp.WithToolAnnotation(CreateToolAnnotation("Enter Rescue Mode", false, false, true, true)),
+		mcp.WithDescription("Reboots a Deployed or Broken machine into an ephemeral environment, leaving its disks untouched, so it can be inspected and repaired over SSH."),
+	)
+}
+
+func (EnterRescueMode) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	systemID, err := request.RequireString("system_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[EnterRescueMode] Required parameter system_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	return recoveryRequest(ctx, "EnterRescueMode", systemID, "rescue_mode", nil, "enter rescue mode", statusIn("Deployed", "Broken"))
+}
+
+type ExitRescueMode struct{}
+
+func (ExitRescueMode) Create() mcp.Tool {
+	return mcp.NewTool(
+		"exit_rescue_mode",
+		mcp.WithString(
+			"system_id",
+			mcp.Required(),
+			mcp.Pattern("^[0-9a-z]{6}$"),
+			mcp.Description("The system ID of the machine."),
+		),
+		mcp.WithToolAnnotation(CreateToolAnnotation("Exit Rescue Mode", false, false, true, true)),
+		mcp.WithDescription("Reboots a machine in rescue mode back into its deployed operating system, returning it to the status it had before."),
+	)
+}
+
+func (ExitRescueMode) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	systemID, err := request.RequireString("system_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[ExitRescueMode] Required parameter system_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	check := statusIn("Rescue mode", "Failed to enter rescue mode", "Failed to exit rescue mode")
+
+	return recoveryRequest(ctx, "ExitRescueMode", systemID, "exit_rescue_mode", nil, "exit rescue mode", check)
+}
+
+type MarkBroken struct{}
+
+func (MarkBroken) Create() mcp.Tool {
+	return mcp.NewTool(
+		"mark_broken",
+		mcp.WithString(
+			"system_id",
+			mcp.Required(),
+			mcp.Pattern("^[0-9a-z]{6}$"),
+			mcp.Description("The system ID of the machine."),
+		),
+		mcp.WithString(
+			"comment",
+			mcp.Required(),
+			mcp.Description("The reason the machine is broken. It is recorded in the machine events."),
+		),
+		mcp.WithToolAnnotation(CreateToolAnnotation("Mark Broken", false, false, true, true)),
+		mcp.WithDescription("Marks a machine as Broken so it is not allocated or deployed until it is repaired and marked fixed."),
+	)
+}
+
+func (MarkBroken) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	systemID, err := request.RequireString("system_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[MarkBroken] Required parameter system_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	comment, err := request.RequireString("comment")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[MarkBroken] Required parameter comment not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	form := make(url.Values)
+	form.Add("comment", comment)
+
+	check := func(machine map[string]any) error {
+		if status, _ := machine["status_name"].(string); status == "Broken" {
+			return fmt.Errorf("the machine is already Broken")
+		}
+		return nil
+	}
+
+	return recoveryRequest(ctx, "MarkBroken", systemID, "mark_broken", form, "mark the machine broken", check)
+}
+
+type MarkFixed struct{}
+
+func (MarkFixed) Create() mcp.Tool {
+	return mcp.NewTool(
+		"mark_fixed",
+		mcp.WithString(
+			"system_id",
+			mcp.Required(),
+			mcp.Pattern("^[0-9a-z]{6}$"),
+			mcp.Description("The system ID of the machine."),
+		),
+		withComment("What was done to fix the machine."),
+		mcp.WithToolAnnotation(CreateToolAnnotation("Mark Fixed", false, false, true, true)),
+		mcp.WithDescription("Marks a Broken machine as fixed. It returns to Ready, or to Deployed if it was deployed when it broke."),
+	)
+}
+
+func (MarkFixed) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	systemID, err := request.RequireString("system_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[MarkFixed] Required parameter system_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	return recoveryRequest(ctx, "MarkFixed", systemID, "mark_fixed", commentForm(request), "mark the machine fixed", statusIn("Broken"))
+}
+
+type LockMachine struct{}
+
+func (LockMachine) Create() mcp.Tool {
+	return mcp.NewTool(
+		"lock_machine",
+		mcp.WithString(
+			"system_id",
+			mcp.Required(),
+			mcp.Pattern("^[0-9a-z]{6}$"),
+			mcp.Description("The system ID of the machine."),
+		),
+		withComment("Why the machine is locked."),
+		mcp.WithToolAnnotation(CreateToolAnnotation("Lock Machine", false, false, true, true)),
+		mcp.WithDescription("Locks a Deployed machine so it cannot be released, redeployed or have its power changed until it is unlocked."),
+	)
+}
+
+func (LockMachine) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	systemID, err := request.RequireString("system_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[LockMachine] Required parameter system_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	deployed := statusIn("Deployed")
+	check := func(machine map[string]any) error {
+		if err := deployed(machine); err != nil {
+			return err
+		}
+		return locked(false)(machine)
+	}
+
+	return recoveryRequest(ctx, "LockMachine", systemID, "lock", commentForm(request), "lock the machine", check)
+}
+
+type UnlockMachine struct{}
+
+func (UnlockMachine) Create() mcp.Tool {
+	return mcp.NewTool(
+		"unlock_machine",
+		mcp.WithString(
+			"system_id",
+			mcp.Required(),
+			mcp.Pattern("^[0-9a-z]{6}$"),
+			mcp.Description("The system ID of the machine."),
+		),
+		withComment("Why the machine is unlocked."),
+		mcp.WithToolAnnotation(CreateToolAnnotation("Unlock Machine", false, false, true, true)),
+		mcp.WithDescription("Unlocks a locked machine so it can be changed again."),
+	)
+}
+
+func (UnlockMachine) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	systemID, err := request.RequireString("system_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[UnlockMachine] Required parameter system_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	return recoveryRequest(ctx, "UnlockMachine", systemID, "unlock", commentForm(request), "unlock the machine", locked(true))
+}
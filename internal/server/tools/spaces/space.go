@@ -0,0 +1,298 @@
+package spaces
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/JarcauCristian/ztp-mcp/internal/server/maas_client"
+	"github.com/JarcauCristian/ztp-mcp/internal/server/tools"
+	"github.com/JarcauCristian/ztp-mcp/internal/server/tools/subnets"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+)
+
+type Space struct{}
+
+func (Space) Register(mcpServer *server.MCPServer) {
+	mcpTools := []tools.MCPTool{ReadSpace{}, UpdateSpace{}, DeleteSpace{}, AssignSpace{}}
+
+	for _, tool := range mcpTools {
+		mcpServer.AddTool(tool.Create(), tool.Handle)
+	}
+}
+
+type ReadSpace struct{}
+
+func (ReadSpace) Create() mcp.Tool {
+	return mcp.NewTool(
+		"read_space",
+		mcp.WithString(
+			"id",
+			mcp.Required(),
+			mcp.Pattern("^[0-9]+$"),
+			mcp.Description("The ID of the space to retrieve."),
+		),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Read Space", true, false, false, true)),
+		mcp.WithDescription("Read a space with the given ID, including its VLANs and subnets."),
+	)
+}
+
+func (ReadSpace) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	spaceID, err := request.RequireString("id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[ReadSpace] Required parameter id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	client := maas_client.MustClient()
+
+	zap.L().Info(fmt.Sprintf("[ReadSpace] Retrieving space with ID: %s", spaceID))
+	resultData, err := readSpace(ctx, client, spaceID)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to read space %s err=%v", spaceID, err)
+		zap.L().Error(fmt.Sprintf("[ReadSpace] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	jsonData, err := json.Marshal(resultData)
+	if err != nil {
+		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[ReadSpace] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+func readSpace(ctx context.Context, client *maas_client.MAASClient, spaceID string) (string, error) {
+	return client.Do(ctx, maas_client.RequestTypeGet, fmt.Sprintf("/MAAS/api/2.0/spaces/%s/", spaceID), nil)
+}
+
+type UpdateSpace struct{}
+
+func (UpdateSpace) Create() mcp.Tool {
+	return mcp.NewTool(
+		"update_space",
+		mcp.WithString(
+			"id",
+			mcp.Required(),
+			mcp.Pattern("^[0-9]+$"),
+			mcp.Description("The ID of the space to update."),
+		),
+		mcp.WithString(
+			"name",
+			mcp.Description("Name of the space."),
+		),
+		mcp.WithString(
+			"description",
+			mcp.Description("Description of the space."),
+		),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Update Space", false, false, true, true)),
+		mcp.WithDescription("Update a space with the given ID."),
+	)
+}
+
+func (UpdateSpace) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	spaceID, err := request.RequireString("id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[UpdateSpace] Required parameter id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	form := make(url.Values)
+
+	if name := request.GetString("name", ""); name != "" {
+		if name == undefinedSpace {
+			return mcp.NewToolResultError(fmt.Sprintf("%s is reserved by MAAS for VLANs without a space", undefinedSpace)), nil
+		}
+		form.Add("name", name)
+	}
+	if description := request.GetString("description", ""); description != "" {
+		form.Add("description", description)
+	}
+
+	if len(form) == 0 {
+		return mcp.NewToolResultError("nothing to update, set name or description"), nil
+	}
+
+	path := fmt.Sprintf("/MAAS/api/2.0/spaces/%s/", spaceID)
+
+	client := maas_client.MustClient()
+
+	zap.L().Info(fmt.Sprintf("[UpdateSpace] Updating space with ID: %s", spaceID))
+	resultData, err := client.Do(ctx, maas_client.RequestTypePut, path, strings.NewReader(form.Encode()))
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to update space %s err=%v", spaceID, err)
+		zap.L().Error(fmt.Sprintf("[UpdateSpace] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	jsonData, err := json.Marshal(resultData)
+	if err != nil {
+		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[UpdateSpace] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+type DeleteSpace struct{}
+
+func (DeleteSpace) Create() mcp.Tool {
+	return mcp.NewTool(
+		"delete_space",
+		mcp.WithString(
+			"id",
+			mcp.Required(),
+			mcp.Pattern("^[0-9]+$"),
+			mcp.Description("The ID of the space to delete."),
+		),
+		mcp.WithBoolean(
+			"force",
+			mcp.Description("Delete the space even when VLANs are still in it. They move to the undefined space."),
+		),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Delete Space", false, true, false, true)),
+		mcp.WithDescription("Delete a space with the given ID. Spaces that still hold VLANs are only deleted with force."),
+	)
+}
+
+func (DeleteSpace) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	spaceID, err := request.RequireString("id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[DeleteSpace] Required parameter id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	client := maas_client.MustClient()
+
+	if !request.GetBool("force", false) {
+		resultData, err := readSpace(ctx, client, spaceID)
+		if err != nil {
+			errMsg = fmt.Sprintf("Failed to read space %s err=%v", spaceID, err)
+			zap.L().Error(fmt.Sprintf("[DeleteSpace] %s", errMsg))
+			return mcp.NewToolResultError(errMsg), nil
+		}
+
+		var space struct {
+			Name  string             `json:"name"`
+			VLANs []spaceVLANSummary `json:"vlans"`
+		}
+		if err := json.Unmarshal([]byte(resultData), &space); err != nil {
+			errMsg = fmt.Sprintf("Failed to unmarshal space %s err=%v", spaceID, err)
+			zap.L().Error(fmt.Sprintf("[DeleteSpace] %s", errMsg))
+			return mcp.NewToolResultError(errMsg), nil
+		}
+
+		if len(space.VLANs) > 0 {
+			names := make([]string, 0, len(space.VLANs))
+			for _, vlan := range space.VLANs {
+				names = append(names, fmt.Sprintf("%s/%d", vlan.Fabric, vlan.VID))
+			}
+			errMsg = fmt.Sprintf("space %s still holds VLANs %s, move them first or set force", space.Name, strings.Join(names, ", "))
+			return mcp.NewToolResultError(errMsg), nil
+		}
+	}
+
+	path := fmt.Sprintf("/MAAS/api/2.0/spaces/%s/", spaceID)
+
+	zap.L().Info(fmt.Sprintf("[DeleteSpace] Deleting space with ID: %s", spaceID))
+	resultData, err := client.Do(ctx, maas_client.RequestTypeDelete, path, nil)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to delete space %s err=%v", spaceID, err)
+		zap.L().Error(fmt.Sprintf("[DeleteSpace] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	jsonData, err := json.Marshal(resultData)
+	if err != nil {
+		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[DeleteSpace] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+type AssignSpace struct{}
+
+func (AssignSpace) Create() mcp.Tool {
+	return mcp.NewTool(
+		"assign_space",
+		mcp.WithString(
+			"subnet",
+			mcp.Required(),
+			mcp.Pattern("^[0-9]+$"),
+			mcp.Description("The ID of the subnet to move."),
+		),
+		mcp.WithString(
+			"space",
+			mcp.Required(),
+			mcp.Description("The name of the space, or undefined to take the subnet out of its space."),
+		),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Assign Space", false, false, true, true)),
+		mcp.WithDescription("Moves a subnet into a space. MAAS derives the space of a subnet from its VLAN, so the VLAN of the subnet is moved, together with every other subnet on it."),
+	)
+}
+
+func (AssignSpace) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	subnetID, err := request.RequireString("subnet")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[AssignSpace] Required parameter subnet not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	space, err := request.RequireString("space")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[AssignSpace] Required parameter space not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	client := maas_client.MustClient()
+
+	subnet, err := subnets.LookupSubnet(ctx, client, subnetID)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to read subnet %s err=%v", subnetID, err)
+		zap.L().Error(fmt.Sprintf("[AssignSpace] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	vlan, _ := subnet["vlan"].(map[string]any)
+	if vlan == nil {
+		return mcp.NewToolResultError(fmt.Sprintf("subnet %s is not on a VLAN", subnetID)), nil
+	}
+
+	form := make(url.Values)
+	form.Add("space", space)
+
+	path := fmt.Sprintf("/MAAS/api/2.0/fabrics/%v/vlans/%v/", vlan["fabric_id"], vlan["vid"])
+
+	zap.L().Info(fmt.Sprintf("[AssignSpace] Moving VLAN %v of fabric %v with subnet %v to space %s", vlan["vid"], vlan["fabric"], subnet["cidr"], space))
+	resultData, err := client.Do(ctx, maas_client.RequestTypePut, path, strings.NewReader(form.Encode()))
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to move subnet %s to space %s err=%v", subnetID, space, err)
+		zap.L().Error(fmt.Sprintf("[AssignSpace] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	jsonData, err := json.Marshal(resultData)
+	if err != nil {
+		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[AssignSpace] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
@@ -0,0 +1,307 @@
+package spaces
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/JarcauCristian/ztp-mcp/internal/server/maas_client"
+	"github.com/JarcauCristian/ztp-mcp/internal/server/tools"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+)
+
+// undefinedSpace is where MAAS puts VLANs that are in no space.
+const undefinedSpace = "undefined"
+
+type Spaces struct{}
+
+func (Spaces) Register(mcpServer *server.MCPServer) {
+	mcpTools := []tools.MCPTool{ListSpaces{}, CreateSpace{}, SpaceTopology{}}
+
+	for _, tool := range mcpTools {
+		mcpServer.AddTool(tool.Create(), tool.Handle)
+	}
+}
+
+type ListSpaces struct{}
+
+func (ListSpaces) Create() mcp.Tool {
+	return mcp.NewTool(
+		"list_spaces",
+		mcp.WithInputSchema[struct{}](),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("List Spaces", true, false, false, true)),
+		mcp.WithDescription("Returns all spaces that are currently defined on the running instance of MAAS, with their VLANs and subnets."),
+	)
+}
+
+func (ListSpaces) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+	path := "/MAAS/api/2.0/spaces/"
+
+	client := maas_client.MustClient()
+
+	zap.L().Info("[ListSpaces] Retrieving all spaces...")
+	resultData, err := client.Do(ctx, maas_client.RequestTypeGet, path, nil)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to retrieve all the spaces: %v", err)
+		zap.L().Error(fmt.Sprintf("[ListSpaces] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	jsonData, err := json.Marshal(resultData)
+	if err != nil {
+		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[ListSpaces] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+type CreateSpace struct{}
+
+func (CreateSpace) Create() mcp.Tool {
+	return mcp.NewTool(
+		"create_space",
+		mcp.WithString(
+			"name",
+			mcp.Required(),
+			mcp.Description("Name of the space."),
+		),
+		mcp.WithString(
+			"description",
+			mcp.Description("Description of the space."),
+		),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Create Space", false, false, false, true)),
+		mcp.WithDescription("Create a new space on the running instance of MAAS. Move VLANs into it with assign_space or update_vlan."),
+	)
+}
+
+func (CreateSpace) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+	path := "/MAAS/api/2.0/spaces/"
+
+	name, err := request.RequireString("name")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[CreateSpace] Required parameter name not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if name == undefinedSpace {
+		return mcp.NewToolResultError(fmt.Sprintf("%s is reserved by MAAS for VLANs without a space", undefinedSpace)), nil
+	}
+
+	form := make(url.Values)
+	form.Add("name", name)
+
+	if description := request.GetString("description", ""); description != "" {
+		form.Add("description", description)
+	}
+
+	client := maas_client.MustClient()
+
+	zap.L().Info(fmt.Sprintf("[CreateSpace] Creating space %s", name))
+	resultData, err := client.Do(ctx, maas_client.RequestTypePost, path, strings.NewReader(form.Encode()))
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to create space err=%v", err)
+		zap.L().Error(fmt.Sprintf("[CreateSpace] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	jsonData, err := json.Marshal(resultData)
+	if err != nil {
+		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[CreateSpace] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+type spaceVLANSummary struct {
+	ID     int    `json:"id"`
+	Fabric string `json:"fabric"`
+	VID    int    `json:"vid"`
+	Name   string `json:"name"`
+	Space  string `json:"space"`
+}
+
+type spaceSummary struct {
+	ID    int                `json:"id"`
+	Name  string             `json:"name"`
+	VLANs []spaceVLANSummary `json:"vlans"`
+}
+
+type subnetSummary struct {
+	CIDR  string           `json:"cidr"`
+	Space string           `json:"space"`
+	VLAN  spaceVLANSummary `json:"vlan"`
+}
+
+type topologyVLAN struct {
+	ID      int      `json:"id"`
+	Fabric  string   `json:"fabric"`
+	VID     int      `json:"vid"`
+	Name    string   `json:"name,omitempty"`
+	Subnets []string `json:"subnets"`
+}
+
+type topologySpace struct {
+	ID         *int           `json:"id,omitempty"`
+	Name       string         `json:"name"`
+	VLANs      []topologyVLAN `json:"vlans"`
+	Constraint string         `json:"constraint,omitempty"`
+}
+
+// groupTopology groups the VLANs of the spaces and of the subnets by space.
+// VLANs and subnets in no space go to the undefined space, and the VLANs of
+// every space are sorted by fabric and VID.
+func groupTopology(spaces []spaceSummary, subnets []subnetSummary) []topologySpace {
+	topology := make([]topologySpace, 0, len(spaces)+1)
+	index := make(map[string]int, len(spaces)+1)
+
+	// vlans maps a VLAN ID to its space and position in that space.
+	vlans := make(map[int][2]int)
+
+	addVLAN := func(spaceName string, vlan spaceVLANSummary) *topologyVLAN {
+		i, ok := index[spaceName]
+		if !ok {
+			i = len(topology)
+			index[spaceName] = i
+			topology = append(topology, topologySpace{Name: spaceName, VLANs: []topologyVLAN{}})
+		}
+
+		position, ok := vlans[vlan.ID]
+		if !ok {
+			position = [2]int{i, len(topology[i].VLANs)}
+			vlans[vlan.ID] = position
+			topology[i].VLANs = append(topology[i].VLANs, topologyVLAN{
+				ID:      vlan.ID,
+				Fabric:  vlan.Fabric,
+				VID:     vlan.VID,
+				Name:    vlan.Name,
+				Subnets: []string{},
+			})
+		}
+
+		return &topology[position[0]].VLANs[position[1]]
+	}
+
+	for _, space := range spaces {
+		index[space.Name] = len(topology)
+		entry := topologySpace{Name: space.Name, VLANs: []topologyVLAN{}}
+		if space.Name != undefinedSpace {
+			id := space.ID
+			entry.ID = &id
+			entry.Constraint = "spaces=" + space.Name
+		}
+		topology = append(topology, entry)
+
+		for _, vlan := range space.VLANs {
+			addVLAN(space.Name, vlan)
+		}
+	}
+
+	for _, subnet := range subnets {
+		spaceName := subnet.Space
+		if spaceName == "" {
+			spaceName = subnet.VLAN.Space
+		}
+		if spaceName == "" {
+			spaceName = undefinedSpace
+		}
+
+		vlan := addVLAN(spaceName, subnet.VLAN)
+		vlan.Subnets = append(vlan.Subnets, subnet.CIDR)
+	}
+
+	for _, space := range topology {
+		sort.Slice(space.VLANs, func(a, b int) bool {
+			if space.VLANs[a].Fabric != space.VLANs[b].Fabric {
+				return space.VLANs[a].Fabric < space.VLANs[b].Fabric
+			}
+			return space.VLANs[a].VID < space.VLANs[b].VID
+		})
+	}
+
+	return topology
+}
+
+type SpaceTopology struct{}
+
+func (SpaceTopology) Create() mcp.Tool {
+	return mcp.NewTool(
+		"space_topology",
+		mcp.WithInputSchema[struct{}](),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Space Topology", true, false, false, true)),
+		mcp.WithDescription("Groups the VLANs and subnets of MAAS by space, including the VLANs in no space. Each space comes with the constraint to use when allocating machines or composing VMs in it, for example spaces=internal."),
+	)
+}
+
+func (SpaceTopology) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	client := maas_client.MustClient()
+
+	resultData, err := client.Do(ctx, maas_client.RequestTypeGet, "/MAAS/api/2.0/spaces/", nil)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to retrieve all the spaces: %v", err)
+		zap.L().Error(fmt.Sprintf("[SpaceTopology] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	var spaces []spaceSummary
+	if err := json.Unmarshal([]byte(resultData), &spaces); err != nil {
+		errMsg = fmt.Sprintf("Failed to unmarshal the spaces err=%v", err)
+		zap.L().Error(fmt.Sprintf("[SpaceTopology] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	resultData, err = client.Do(ctx, maas_client.RequestTypeGet, "/MAAS/api/2.0/subnets/", nil)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to retrieve all the subnets: %v", err)
+		zap.L().Error(fmt.Sprintf("[SpaceTopology] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	var subnets []subnetSummary
+	if err := json.Unmarshal([]byte(resultData), &subnets); err != nil {
+		errMsg = fmt.Sprintf("Failed to unmarshal the subnets err=%v", err)
+		zap.L().Error(fmt.Sprintf("[SpaceTopology] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	topology := groupTopology(spaces, subnets)
+
+	jsonData, err := json.Marshal(topology)
+	if err != nil {
+		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[SpaceTopology] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	var table strings.Builder
+	writer := tabwriter.NewWriter(&table, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "SPACE\tFABRIC\tVID\tSUBNETS")
+	for _, space := range topology {
+		if len(space.VLANs) == 0 {
+			fmt.Fprintf(writer, "%s\t-\t-\t-\n", space.Name)
+			continue
+		}
+		for _, vlan := range space.VLANs {
+			fmt.Fprintf(writer, "%s\t%s\t%d\t%s\n", space.Name, vlan.Fabric, vlan.VID, strings.Join(vlan.Subnets, ", "))
+		}
+	}
+	writer.Flush()
+
+	result := mcp.NewToolResultText(string(jsonData))
+	result.Content = append(result.Content, mcp.NewTextContent(table.String()))
+
+	return result, nil
+}
@@ -0,0 +1,111 @@
+package spaces
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// describeTopology renders the topology as one line per space, for example
+// "internal#1 spaces=internal [fabric-0/10: 10.0.10.0/24]".
+func describeTopology(topology []topologySpace) []string {
+	var lines []string
+	for _, space := range topology {
+		name := space.Name
+		if space.ID != nil {
+			name = fmt.Sprintf("%s#%d", name, *space.ID)
+		}
+
+		var vlans []string
+		for _, vlan := range space.VLANs {
+			vlans = append(vlans, fmt.Sprintf("%s/%d: %s", vlan.Fabric, vlan.VID, strings.Join(vlan.Subnets, ",")))
+		}
+
+		if space.Constraint != "" {
+			name += " " + space.Constraint
+		}
+
+		lines = append(lines, fmt.Sprintf("%s [%s]", name, strings.Join(vlans, "; ")))
+	}
+	return lines
+}
+
+func TestGroupTopology(t *testing.T) {
+	internal10 := spaceVLANSummary{ID: 10, Fabric: "fabric-1", VID: 10, Space: "internal"}
+	internal20 := spaceVLANSummary{ID: 20, Fabric: "fabric-0", VID: 20, Space: "internal"}
+	internal5 := spaceVLANSummary{ID: 5, Fabric: "fabric-1", VID: 5, Space: "internal"}
+	storage30 := spaceVLANSummary{ID: 30, Fabric: "fabric-0", VID: 30, Space: "storage"}
+	untagged := spaceVLANSummary{ID: 1, Fabric: "fabric-0", VID: 0}
+
+	tests := []struct {
+		name    string
+		spaces  []spaceSummary
+		subnets []subnetSummary
+		want    []string
+	}{
+		{
+			name: "VLANs from the spaces are sorted by fabric and VID",
+			spaces: []spaceSummary{
+				{ID: 1, Name: "internal", VLANs: []spaceVLANSummary{internal10, internal20, internal5}},
+				{ID: 2, Name: "empty"},
+			},
+			subnets: []subnetSummary{
+				{CIDR: "10.0.10.0/24", Space: "internal", VLAN: internal10},
+				{CIDR: "10.0.11.0/24", Space: "internal", VLAN: internal10},
+			},
+			want: []string{
+				"internal#1 spaces=internal [fabric-0/20: ; fabric-1/5: ; fabric-1/10: 10.0.10.0/24,10.0.11.0/24]",
+				"empty#2 spaces=empty []",
+			},
+		},
+		{
+			name:   "VLANs only known from their subnets",
+			spaces: []spaceSummary{{ID: 1, Name: "internal"}},
+			subnets: []subnetSummary{
+				{CIDR: "10.0.20.0/24", Space: "internal", VLAN: internal20},
+				{CIDR: "10.0.30.0/24", VLAN: storage30},
+			},
+			want: []string{
+				"internal#1 spaces=internal [fabric-0/20: 10.0.20.0/24]",
+				"storage [fabric-0/30: 10.0.30.0/24]",
+			},
+		},
+		{
+			name:   "subnets in no space go to the undefined space",
+			spaces: []spaceSummary{{ID: 1, Name: "internal", VLANs: []spaceVLANSummary{internal10}}},
+			subnets: []subnetSummary{
+				{CIDR: "192.168.0.0/24", VLAN: untagged},
+				{CIDR: "10.0.10.0/24", Space: "internal", VLAN: internal10},
+			},
+			want: []string{
+				"internal#1 spaces=internal [fabric-1/10: 10.0.10.0/24]",
+				"undefined [fabric-0/0: 192.168.0.0/24]",
+			},
+		},
+		{
+			name: "the undefined space from MAAS has no constraint",
+			spaces: []spaceSummary{
+				{ID: -1, Name: undefinedSpace, VLANs: []spaceVLANSummary{untagged}},
+				{ID: 1, Name: "internal"},
+			},
+			subnets: []subnetSummary{
+				{CIDR: "192.168.0.0/24", Space: undefinedSpace, VLAN: untagged},
+				{CIDR: "192.168.1.0/24", VLAN: untagged},
+			},
+			want: []string{
+				"undefined [fabric-0/0: 192.168.0.0/24,192.168.1.0/24]",
+				"internal#1 spaces=internal []",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := describeTopology(groupTopology(test.spaces, test.subnets))
+
+			if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+				t.Errorf("topology =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(test.want, "\n"))
+			}
+		})
+	}
+}
@@ -0,0 +1,300 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/JarcauCristian/ztp-mcp/internal/server/maas_client"
+	"github.com/JarcauCristian/ztp-mcp/internal/server/tools"
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.uber.org/zap"
+)
+
+// devicePath returns the API path of a block device, or of one of its
+// partitions when partitionID is not empty.
+func devicePath(systemID, blockDeviceID, partitionID string) string {
+	if partitionID != "" {
+		return fmt.Sprintf("/MAAS/api/2.0/nodes/%s/blockdevices/%s/partition/%s", systemID, blockDeviceID, partitionID)
+	}
+
+	return fmt.Sprintf("/MAAS/api/2.0/nodes/%s/blockdevices/%s/", systemID, blockDeviceID)
+}
+
+func deviceName(blockDeviceID, partitionID string) string {
+	if partitionID != "" {
+		return fmt.Sprintf("partition %s of block device %s", partitionID, blockDeviceID)
+	}
+
+	return fmt.Sprintf("block device %s", blockDeviceID)
+}
+
+// deviceTarget reads the system_id, block_device_id and optional partition_id
+// shared by the filesystem tools.
+func deviceTarget(request mcp.CallToolRequest, toolName string) (string, string, string, error) {
+	systemID, err := request.RequireString("system_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[%s] Required parameter system_id not present err=%v", toolName, err))
+		return "", "", "", err
+	}
+
+	blockDeviceID, err := request.RequireString("block_device_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[%s] Required parameter block_device_id not present err=%v", toolName, err))
+		return "", "", "", err
+	}
+
+	return systemID, blockDeviceID, request.GetString("partition_id", ""), nil
+}
+
+func withDeviceTarget() []mcp.ToolOption {
+	return []mcp.ToolOption{
+		withSystemID(),
+		withID("block_device_id", "The ID of the block device. Logical volumes, RAIDs and bcaches are block devices too."),
+		mcp.WithString(
+			"partition_id",
+			mcp.Pattern("^[0-9]*$"),
+			mcp.Description("The ID of a partition on the block device. When set the partition is targeted instead of the whole device."),
+		),
+	}
+}
+
+type CreatePartition struct{}
+
+func (CreatePartition) Create() mcp.Tool {
+	return mcp.NewTool(
+		"create_partition",
+		withSystemID(),
+		withID("block_device_id", "The ID of the block device to partition."),
+		mcp.WithString(
+			"size",
+			mcp.Description("The size of the partition, e.g. 10G. Uses all available space when not set."),
+		),
+		mcp.WithBoolean(
+			"bootable",
+			mcp.Description("Whether the partition is bootable."),
+		),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Create Partition", false, false, false, true)),
+		mcp.WithDescription("Creates a partition on a block device of a machine."),
+	)
+}
+
+func (CreatePartition) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	systemID, err := request.RequireString("system_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[CreatePartition] Required parameter system_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	blockDeviceID, err := request.RequireString("block_device_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[CreatePartition] Required parameter block_device_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	form := make(url.Values)
+	if size := request.GetString("size", ""); size != "" {
+		form.Add("size", size)
+	}
+	if request.GetBool("bootable", false) {
+		form.Add("bootable", "true")
+	}
+
+	path := fmt.Sprintf("/MAAS/api/2.0/nodes/%s/blockdevices/%s/partitions/", systemID, blockDeviceID)
+
+	return tools.MachineRequest(ctx, "CreatePartition", systemID, maas_client.RequestTypePost, path, form, fmt.Sprintf("create a partition on block device %s", blockDeviceID))
+}
+
+type DeletePartition struct{}
+
+func (DeletePartition) Create() mcp.Tool {
+	return mcp.NewTool(
+		"delete_partition",
+		withSystemID(),
+		withID("block_device_id", "The ID of the block device holding the partition."),
+		withID("partition_id", "The ID of the partition to delete."),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Delete Partition", false, true, false, true)),
+		mcp.WithDescription("Deletes a partition of a machine."),
+	)
+}
+
+func (DeletePartition) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	systemID, blockDeviceID, partitionID, err := deviceTarget(request, "DeletePartition")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if partitionID == "" {
+		zap.L().Error("[DeletePartition] Required parameter partition_id not present")
+		return mcp.NewToolResultError("required argument \"partition_id\" not found"), nil
+	}
+
+	path := devicePath(systemID, blockDeviceID, partitionID)
+
+	return tools.MachineRequest(ctx, "DeletePartition", systemID, maas_client.RequestTypeDelete, path, nil, fmt.Sprintf("delete %s", deviceName(blockDeviceID, partitionID)))
+}
+
+type FormatStorage struct{}
+
+func (FormatStorage) Create() mcp.Tool {
+	options := append(withDeviceTarget(),
+		mcp.WithString(
+			"fstype",
+			mcp.Required(),
+			mcp.Enum("ext2", "ext4", "xfs", "btrfs", "fat32", "vfat", "swap", "zfsroot", "ramfs", "tmpfs"),
+			mcp.Description("The filesystem type."),
+		),
+		mcp.WithString(
+			"label",
+			mcp.Description("The label of the filesystem."),
+		),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Format Storage", false, true, true, true)),
+		mcp.WithDescription("Formats a block device or partition of a machine with a filesystem."),
+	)
+
+	return mcp.NewTool("format_storage", options...)
+}
+
+func (FormatStorage) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	systemID, blockDeviceID, partitionID, err := deviceTarget(request, "FormatStorage")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	fstype, err := request.RequireString("fstype")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[FormatStorage] Required parameter fstype not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	form := make(url.Values)
+	form.Add("fstype", fstype)
+	if label := request.GetString("label", ""); label != "" {
+		form.Add("label", label)
+	}
+
+	path := operationPath(systemID, blockDeviceID, partitionID, "format")
+
+	return tools.MachineRequest(ctx, "FormatStorage", systemID, maas_client.RequestTypePost, path, form, fmt.Sprintf("format %s as %s", deviceName(blockDeviceID, partitionID), fstype))
+}
+
+type UnformatStorage struct{}
+
+func (UnformatStorage) Create() mcp.Tool {
+	options := append(withDeviceTarget(),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Unformat Storage", false, true, true, true)),
+		mcp.WithDescription("Removes the filesystem of a block device or partition of a machine."),
+	)
+
+	return mcp.NewTool("unformat_storage", options...)
+}
+
+func (UnformatStorage) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	systemID, blockDeviceID, partitionID, err := deviceTarget(request, "UnformatStorage")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	path := operationPath(systemID, blockDeviceID, partitionID, "unformat")
+
+	return tools.MachineRequest(ctx, "UnformatStorage", systemID, maas_client.RequestTypePost, path, nil, fmt.Sprintf("unformat %s", deviceName(blockDeviceID, partitionID)))
+}
+
+type MountStorage struct{}
+
+func (MountStorage) Create() mcp.Tool {
+	options := append(withDeviceTarget(),
+		mcp.WithString(
+			"mount_point",
+			mcp.Required(),
+			mcp.Description("Path on the filesystem to mount at, e.g. /var/lib/data. Use 'none' for swap."),
+		),
+		mcp.WithString(
+			"mount_options",
+			mcp.Description("Options to pass to mount(8)."),
+		),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Mount Storage", false, false, true, true)),
+		mcp.WithDescription("Mounts the filesystem of a formatted block device or partition of a machine."),
+	)
+
+	return mcp.NewTool("mount_storage", options...)
+}
+
+func (MountStorage) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	systemID, blockDeviceID, partitionID, err := deviceTarget(request, "MountStorage")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	mountPoint, err := request.RequireString("mount_point")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[MountStorage] Required parameter mount_point not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	form := make(url.Values)
+	form.Add("mount_point", mountPoint)
+	if mountOptions := request.GetString("mount_options", ""); mountOptions != "" {
+		form.Add("mount_options", mountOptions)
+	}
+
+	path := operationPath(systemID, blockDeviceID, partitionID, "mount")
+
+	return tools.MachineRequest(ctx, "MountStorage", systemID, maas_client.RequestTypePost, path, form, fmt.Sprintf("mount %s at %s", deviceName(blockDeviceID, partitionID), mountPoint))
+}
+
+type UnmountStorage struct{}
+
+func (UnmountStorage) Create() mcp.Tool {
+	options := append(withDeviceTarget(),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Unmount Storage", false, false, true, true)),
+		mcp.WithDescription("Unmounts the filesystem of a block device or partition of a machine."),
+	)
+
+	return mcp.NewTool("unmount_storage", options...)
+}
+
+func (UnmountStorage) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	systemID, blockDeviceID, partitionID, err := deviceTarget(request, "UnmountStorage")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	path := operationPath(systemID, blockDeviceID, partitionID, "unmount")
+
+	return tools.MachineRequest(ctx, "UnmountStorage", systemID, maas_client.RequestTypePost, path, nil, fmt.Sprintf("unmount %s", deviceName(blockDeviceID, partitionID)))
+}
+
+type SetBootDisk struct{}
+
+func (SetBootDisk) Create() mcp.Tool {
+	return mcp.NewTool(
+		"set_boot_disk",
+		withSystemID(),
+		withID("block_device_id", "The ID of the physical block device to boot from."),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Set Boot Disk", false, false, true, true)),
+		mcp.WithDescription("Sets the physical block device a machine boots from."),
+	)
+}
+
+func (SetBootDisk) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	systemID, blockDeviceID, _, err := deviceTarget(request, "SetBootDisk")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	path := operationPath(systemID, blockDeviceID, "", "set_boot_disk")
+
+	return tools.MachineRequest(ctx, "SetBootDisk", systemID, maas_client.RequestTypePost, path, nil, fmt.Sprintf("set block device %s as boot disk", blockDeviceID))
+}
+
+// operationPath returns the path of a named operation on a block device or
+// partition. Partition URLs have no trailing slash, so the operation is passed
+// as a query parameter instead of an op- path suffix.
+func operationPath(systemID, blockDeviceID, partitionID, operation string) string {
+	if partitionID != "" {
+		return fmt.Sprintf("%s?op=%s", devicePath(systemID, blockDeviceID, partitionID), operation)
+	}
+
+	return fmt.Sprintf("%sop-%s", devicePath(systemID, blockDeviceID, ""), operation)
+}
@@ -0,0 +1,882 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/JarcauCristian/ztp-mcp/internal/server/maas_client"
+	"github.com/JarcauCristian/ztp-mcp/internal/server/tools"
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.uber.org/zap"
+)
+
+// sizeTolerance is how far an existing partition or logical volume may be
+// from the requested size and still match, since MAAS aligns sizes.
+const sizeTolerance = 16 * 1024 * 1024
+
+type filesystemPlan struct {
+	FSType       string `json:"fstype,omitempty"`
+	MountPoint   string `json:"mount_point,omitempty"`
+	MountOptions string `json:"mount_options,omitempty"`
+	Label        string `json:"label,omitempty"`
+}
+
+type partitionPlan struct {
+	Size     string `json:"size,omitempty"`
+	Bootable bool   `json:"bootable,omitempty"`
+	filesystemPlan
+}
+
+type diskPlan struct {
+	Name       string          `json:"name"`
+	Partitions []partitionPlan `json:"partitions,omitempty"`
+	filesystemPlan
+}
+
+type logicalVolumePlan struct {
+	Name string `json:"name"`
+	Size string `json:"size,omitempty"`
+	filesystemPlan
+}
+
+type volumeGroupPlan struct {
+	Name           string              `json:"name"`
+	Devices        []string            `json:"devices"`
+	LogicalVolumes []logicalVolumePlan `json:"logical_volumes,omitempty"`
+}
+
+type raidPlan struct {
+	Name         string   `json:"name"`
+	Level        string   `json:"level"`
+	Devices      []string `json:"devices"`
+	SpareDevices []string `json:"spare_devices,omitempty"`
+	filesystemPlan
+}
+
+type bcachePlan struct {
+	Name          string `json:"name"`
+	BackingDevice string `json:"backing_device"`
+	CacheDevice   string `json:"cache_device"`
+	CacheMode     string `json:"cache_mode"`
+	filesystemPlan
+}
+
+// storagePlan is the desired storage configuration of a machine. Devices are
+// referenced by their MAAS names; partitions created by the plan are named
+// <disk>-part<N>, logical volumes <vg>-<lv>.
+type storagePlan struct {
+	Layout         string            `json:"layout,omitempty"`
+	LayoutOptions  map[string]string `json:"layout_options,omitempty"`
+	BootDisk       string            `json:"boot_disk,omitempty"`
+	Disks          []diskPlan        `json:"disks,omitempty"`
+	Raids          []raidPlan        `json:"raids,omitempty"`
+	Bcaches        []bcachePlan      `json:"bcaches,omitempty"`
+	VolumeGroups   []volumeGroupPlan `json:"volume_groups,omitempty"`
+	RemoveUnlisted bool              `json:"remove_unlisted,omitempty"`
+}
+
+func (p storagePlan) validate() error {
+	if p.Layout != "" && !slices.Contains([]string{"flat", "lvm", "bcache", "vmfs6", "vmfs7", "custom", "blank"}, p.Layout) {
+		return fmt.Errorf("unknown layout %s", p.Layout)
+	}
+
+	for option := range p.LayoutOptions {
+		if !slices.Contains(layoutOptions, option) && option != "cache_no_part" {
+			return fmt.Errorf("unknown layout option %s", option)
+		}
+	}
+
+	names := make(map[string]bool)
+	unique := func(kind, name string) error {
+		if name == "" {
+			return fmt.Errorf("%s without a name", kind)
+		}
+		if names[name] {
+			return fmt.Errorf("%s is defined more than once", name)
+		}
+		names[name] = true
+		return nil
+	}
+
+	for _, disk := range p.Disks {
+		if err := unique("disk", disk.Name); err != nil {
+			return err
+		}
+		if len(disk.Partitions) > 0 && disk.FSType != "" {
+			return fmt.Errorf("disk %s cannot have both partitions and a filesystem", disk.Name)
+		}
+		for i, partition := range disk.Partitions {
+			if _, err := parseSize(partition.Size); err != nil {
+				return fmt.Errorf("partition %d of %s: %w", i+1, disk.Name, err)
+			}
+			if partition.Size == "" && i != len(disk.Partitions)-1 {
+				return fmt.Errorf("only the last partition of %s may omit its size", disk.Name)
+			}
+		}
+	}
+
+	for _, raid := range p.Raids {
+		if err := unique("raid", raid.Name); err != nil {
+			return err
+		}
+		if !slices.Contains([]string{"raid-0", "raid-1", "raid-5", "raid-6", "raid-10"}, raid.Level) {
+			return fmt.Errorf("raid %s has unknown level %s", raid.Name, raid.Level)
+		}
+		if len(raid.Devices) == 0 {
+			return fmt.Errorf("raid %s has no devices", raid.Name)
+		}
+	}
+
+	for _, bcache := range p.Bcaches {
+		if err := unique("bcache", bcache.Name); err != nil {
+			return err
+		}
+		if bcache.BackingDevice == "" || bcache.CacheDevice == "" {
+			return fmt.Errorf("bcache %s needs a backing_device and a cache_device", bcache.Name)
+		}
+		if !slices.Contains([]string{"writeback", "writethrough", "writearound"}, bcache.CacheMode) {
+			return fmt.Errorf("bcache %s has unknown cache_mode %s", bcache.Name, bcache.CacheMode)
+		}
+	}
+
+	for _, volumeGroup := range p.VolumeGroups {
+		if err := unique("volume group", volumeGroup.Name); err != nil {
+			return err
+		}
+		if len(volumeGroup.Devices) == 0 {
+			return fmt.Errorf("volume group %s has no devices", volumeGroup.Name)
+		}
+		for i, logicalVolume := range volumeGroup.LogicalVolumes {
+			if err := unique("logical volume", volumeGroup.Name+"-"+logicalVolume.Name); err != nil {
+				return err
+			}
+			if _, err := parseSize(logicalVolume.Size); err != nil {
+				return fmt.Errorf("logical volume %s: %w", logicalVolume.Name, err)
+			}
+			if logicalVolume.Size == "" && i != len(volumeGroup.LogicalVolumes)-1 {
+				return fmt.Errorf("only the last logical volume of %s may omit its size", volumeGroup.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// parseSize converts a size such as 512M or 1.5T to bytes using the SI units
+// MAAS uses. An empty size is 0.
+func parseSize(size string) (int64, error) {
+	size = strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(size)), "B")
+	if size == "" {
+		return 0, nil
+	}
+
+	multiplier := 1.0
+	units := map[byte]float64{'K': 1e3, 'M': 1e6, 'G': 1e9, 'T': 1e12, 'P': 1e15}
+	if unit, ok := units[size[len(size)-1]]; ok {
+		multiplier = unit
+		size = size[:len(size)-1]
+	}
+
+	value, err := strconv.ParseFloat(size, 64)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+
+	return int64(value * multiplier), nil
+}
+
+func sizeMatches(actual int64, requested string) bool {
+	size, _ := parseSize(requested)
+	if size == 0 {
+		return true
+	}
+
+	difference := actual - size
+	if difference < 0 {
+		difference = -difference
+	}
+
+	return difference <= max(size/100, sizeTolerance)
+}
+
+func sameNames(members []member, names []string) bool {
+	if len(members) != len(names) {
+		return false
+	}
+
+	for _, member := range members {
+		if !slices.Contains(names, member.name()) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func partitionsMatch(partitions []partition, plans []partitionPlan) bool {
+	if len(partitions) != len(plans) {
+		return false
+	}
+
+	for i, plan := range plans {
+		if !sizeMatches(partitions[i].Size, plan.Size) {
+			return false
+		}
+	}
+
+	return true
+}
+
+type planStep struct {
+	Action string `json:"action"`
+	Target string `json:"target"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	Note   string `json:"note,omitempty"`
+}
+
+// planner computes and performs the steps that turn the current storage of a
+// machine into the plan. In dry run mode the steps are only recorded.
+type planner struct {
+	ctx      context.Context
+	client   *maas_client.MAASClient
+	systemID string
+	dryRun   bool
+	state    *storageState
+	removed  map[string]bool
+	steps    []planStep
+}
+
+func (p *planner) do(action, target string, requestType maas_client.RequestType, path string, form url.Values) error {
+	step := planStep{Action: action, Target: target, Status: "planned"}
+
+	if !p.dryRun {
+		var body io.Reader
+		if form != nil {
+			body = strings.NewReader(form.Encode())
+		}
+
+		if _, err := p.client.Do(p.ctx, requestType, path, body); err != nil {
+			step.Status = "failed"
+			step.Error = err.Error()
+			p.steps = append(p.steps, step)
+			return fmt.Errorf("failed to %s %s: %w", action, target, err)
+		}
+
+		step.Status = "done"
+	}
+
+	p.steps = append(p.steps, step)
+	return nil
+}
+
+func (p *planner) reload() error {
+	if p.dryRun {
+		return nil
+	}
+
+	state, err := loadStorageState(p.ctx, p.client, p.systemID)
+	if err != nil {
+		return err
+	}
+
+	p.state = state
+	return nil
+}
+
+func (p *planner) nodePath(format string, args ...any) string {
+	return fmt.Sprintf("/MAAS/api/2.0/nodes/%s/", p.systemID) + fmt.Sprintf(format, args...)
+}
+
+// exists reports whether an object is present and was not removed earlier in
+// the plan, which matters in dry run mode where the state is never reloaded.
+func (p *planner) exists(kind, name string, present bool) bool {
+	return present && !p.removed[kind+":"+name]
+}
+
+// addDevices adds the named block devices and partitions to the form. In dry
+// run mode devices the plan has yet to create are skipped.
+func (p *planner) addDevices(form url.Values, deviceKey, partitionKey string, names []string) error {
+	for _, name := range names {
+		blockDeviceID, partitionID, _, ok := p.state.resolve(name)
+		if !ok {
+			if p.dryRun {
+				continue
+			}
+			return fmt.Errorf("device %s not found", name)
+		}
+
+		if partitionID != 0 {
+			form.Add(partitionKey, strconv.Itoa(partitionID))
+		} else {
+			form.Add(deviceKey, strconv.Itoa(blockDeviceID))
+		}
+	}
+
+	return nil
+}
+
+func (p *planner) apply(plan storagePlan, machine map[string]any) error {
+	if plan.Layout != "" {
+		form := make(url.Values)
+		form.Add("storage_layout", plan.Layout)
+		for option, value := range plan.LayoutOptions {
+			form.Add(option, value)
+		}
+
+		if err := p.do("set_storage_layout", plan.Layout, maas_client.RequestTypePost, fmt.Sprintf("/MAAS/api/2.0/machines/%s/op-set_storage_layout", p.systemID), form); err != nil {
+			return err
+		}
+
+		if err := p.reload(); err != nil {
+			return err
+		}
+
+		// A dry run cannot reload the storage the layout produces. The blank
+		// layout is simulated; for the others the following steps would be
+		// computed against the storage the layout replaces.
+		if p.dryRun {
+			if plan.Layout != "blank" {
+				p.steps = append(p.steps, planStep{
+					Action: "apply_plan",
+					Target: p.systemID,
+					Status: "unknown",
+					Note:   fmt.Sprintf("which devices are removed or created depends on the storage the %s layout produces; run the plan without layout after applying it to see the remaining steps", plan.Layout),
+				})
+				return nil
+			}
+			p.state = p.state.blank()
+		}
+	}
+
+	if err := p.removeStale(plan); err != nil {
+		return err
+	}
+
+	if err := p.reload(); err != nil {
+		return err
+	}
+
+	if err := p.createMissing(plan, machine); err != nil {
+		return err
+	}
+
+	return p.applyFilesystems(plan)
+}
+
+// removeStale deletes the objects that differ from the plan, from the top of
+// the device stack down, so their members can be reused.
+func (p *planner) removeStale(plan storagePlan) error {
+	for _, volumeGroup := range p.state.VolumeGroups {
+		index := slices.IndexFunc(plan.VolumeGroups, func(v volumeGroupPlan) bool { return v.Name == volumeGroup.Name })
+
+		if index < 0 && !plan.RemoveUnlisted {
+			continue
+		}
+
+		if index < 0 || !sameNames(volumeGroup.Devices, plan.VolumeGroups[index].Devices) {
+			p.removed["vg:"+volumeGroup.Name] = true
+			if err := p.do("delete_volume_group", volumeGroup.Name, maas_client.RequestTypeDelete, p.nodePath("volume-group/%d/", volumeGroup.ID), nil); err != nil {
+				return err
+			}
+			continue
+		}
+
+		for _, logicalVolume := range volumeGroup.LogicalVolumes {
+			name := strings.TrimPrefix(logicalVolume.name(), volumeGroup.Name+"-")
+			lvIndex := slices.IndexFunc(plan.VolumeGroups[index].LogicalVolumes, func(l logicalVolumePlan) bool { return l.Name == name })
+
+			if lvIndex >= 0 && sizeMatches(logicalVolume.Size, plan.VolumeGroups[index].LogicalVolumes[lvIndex].Size) {
+				continue
+			}
+
+			p.removed["lv:"+volumeGroup.Name+"-"+name] = true
+
+			form := make(url.Values)
+			form.Add("id", strconv.Itoa(logicalVolume.ID))
+
+			if err := p.do("delete_logical_volume", logicalVolume.name(), maas_client.RequestTypePost, p.nodePath("volume-group/%d/op-delete_logical_volume", volumeGroup.ID), form); err != nil {
+				return err
+			}
+		}
+	}
+
+	keptCacheSets := make(map[int]bool)
+
+	for _, existing := range p.state.Bcaches {
+		index := slices.IndexFunc(plan.Bcaches, func(b bcachePlan) bool { return b.Name == existing.Name })
+
+		if index < 0 && !plan.RemoveUnlisted {
+			keptCacheSets[existing.CacheSet.ID] = true
+			continue
+		}
+
+		if index >= 0 {
+			desired := plan.Bcaches[index]
+			if existing.BackingDevice.name() == desired.BackingDevice && existing.CacheSet.CacheDevice.name() == desired.CacheDevice && existing.CacheMode == desired.CacheMode {
+				keptCacheSets[existing.CacheSet.ID] = true
+				continue
+			}
+		}
+
+		p.removed["bcache:"+existing.Name] = true
+		if err := p.do("delete_bcache", existing.Name, maas_client.RequestTypeDelete, p.nodePath("bcache/%d/", existing.ID), nil); err != nil {
+			return err
+		}
+	}
+
+	if plan.RemoveUnlisted {
+		for _, existing := range p.state.CacheSets {
+			inPlan := slices.ContainsFunc(plan.Bcaches, func(b bcachePlan) bool { return b.CacheDevice == existing.CacheDevice.name() })
+			if keptCacheSets[existing.ID] || inPlan {
+				continue
+			}
+
+			if err := p.do("delete_bcache_cache_set", existing.Name, maas_client.RequestTypeDelete, p.nodePath("bcache-cache-set/%d/", existing.ID), nil); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, existing := range p.state.Raids {
+		index := slices.IndexFunc(plan.Raids, func(r raidPlan) bool { return r.Name == existing.Name })
+
+		if index < 0 && !plan.RemoveUnlisted {
+			continue
+		}
+
+		if index >= 0 {
+			desired := plan.Raids[index]
+			if existing.Level == desired.Level && sameNames(existing.Devices, desired.Devices) && sameNames(existing.SpareDevices, desired.SpareDevices) {
+				continue
+			}
+		}
+
+		p.removed["raid:"+existing.Name] = true
+		if err := p.do("delete_raid", existing.Name, maas_client.RequestTypeDelete, p.nodePath("raid/%d/", existing.ID), nil); err != nil {
+			return err
+		}
+	}
+
+	for _, disk := range plan.Disks {
+		device := p.state.blockDevice(disk.Name)
+		if device == nil {
+			return fmt.Errorf("disk %s not found", disk.Name)
+		}
+
+		if len(disk.Partitions) == 0 && disk.FSType == "" {
+			continue
+		}
+
+		if len(disk.Partitions) > 0 && partitionsMatch(device.Partitions, disk.Partitions) {
+			continue
+		}
+
+		for _, partition := range device.Partitions {
+			p.removed["partition:"+partition.Name] = true
+			if err := p.do("delete_partition", partition.Name, maas_client.RequestTypeDelete, devicePath(p.systemID, strconv.Itoa(device.ID), strconv.Itoa(partition.ID)), nil); err != nil {
+				return err
+			}
+		}
+
+		if len(disk.Partitions) > 0 && device.Filesystem != nil && device.Filesystem.FSType != "" {
+			p.removed["filesystem:"+device.Name] = true
+			if err := p.do("unformat", device.Name, maas_client.RequestTypePost, operationPath(p.systemID, strconv.Itoa(device.ID), "", "unformat"), nil); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (p *planner) createMissing(plan storagePlan, machine map[string]any) error {
+	if plan.BootDisk != "" {
+		bootDisk, _ := machine["boot_disk"].(map[string]any)
+		if current, _ := bootDisk["name"].(string); current != plan.BootDisk {
+			device := p.state.blockDevice(plan.BootDisk)
+			if device == nil {
+				return fmt.Errorf("boot disk %s not found", plan.BootDisk)
+			}
+
+			if err := p.do("set_boot_disk", plan.BootDisk, maas_client.RequestTypePost, operationPath(p.systemID, strconv.Itoa(device.ID), "", "set_boot_disk"), nil); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, disk := range plan.Disks {
+		device := p.state.blockDevice(disk.Name)
+		if device == nil {
+			return fmt.Errorf("disk %s not found", disk.Name)
+		}
+
+		existing := slices.DeleteFunc(slices.Clone(device.Partitions), func(partition partition) bool {
+			return p.removed["partition:"+partition.Name]
+		})
+
+		if len(disk.Partitions) == 0 || len(existing) > 0 {
+			continue
+		}
+
+		for i, partition := range disk.Partitions {
+			form := make(url.Values)
+			if partition.Size != "" {
+				form.Add("size", partition.Size)
+			}
+			if partition.Bootable {
+				form.Add("bootable", "true")
+			}
+
+			target := fmt.Sprintf("%s-part%d", disk.Name, i+1)
+			if err := p.do("create_partition", target, maas_client.RequestTypePost, p.nodePath("blockdevices/%d/partitions/", device.ID), form); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := p.reload(); err != nil {
+		return err
+	}
+
+	for _, desired := range plan.Raids {
+		if p.exists("raid", desired.Name, p.state.raid(desired.Name) != nil) {
+			continue
+		}
+
+		form := make(url.Values)
+		form.Add("name", desired.Name)
+		form.Add("level", desired.Level)
+		if err := p.addDevices(form, "block_devices", "partitions", desired.Devices); err != nil {
+			return err
+		}
+		if err := p.addDevices(form, "spare_devices", "spare_partitions", desired.SpareDevices); err != nil {
+			return err
+		}
+
+		if err := p.do("create_raid", desired.Name, maas_client.RequestTypePost, p.nodePath("raids/"), form); err != nil {
+			return err
+		}
+	}
+
+	if err := p.reload(); err != nil {
+		return err
+	}
+
+	for _, desired := range plan.Bcaches {
+		if p.exists("bcache", desired.Name, p.state.bcache(desired.Name) != nil) {
+			continue
+		}
+
+		if p.state.cacheSetFor(desired.CacheDevice) == nil {
+			form := make(url.Values)
+			if err := p.addDevices(form, "cache_device", "cache_partition", []string{desired.CacheDevice}); err != nil {
+				return err
+			}
+
+			if err := p.do("create_bcache_cache_set", desired.CacheDevice, maas_client.RequestTypePost, p.nodePath("bcache-cache-sets/"), form); err != nil {
+				return err
+			}
+
+			if err := p.reload(); err != nil {
+				return err
+			}
+		}
+
+		form := make(url.Values)
+		form.Add("name", desired.Name)
+		form.Add("cache_mode", desired.CacheMode)
+		if cacheSet := p.state.cacheSetFor(desired.CacheDevice); cacheSet != nil {
+			form.Add("cache_set", strconv.Itoa(cacheSet.ID))
+		}
+		if err := p.addDevices(form, "backing_device", "backing_partition", []string{desired.BackingDevice}); err != nil {
+			return err
+		}
+
+		if err := p.do("create_bcache", desired.Name, maas_client.RequestTypePost, p.nodePath("bcaches/"), form); err != nil {
+			return err
+		}
+	}
+
+	if err := p.reload(); err != nil {
+		return err
+	}
+
+	for _, desired := range plan.VolumeGroups {
+		if !p.exists("vg", desired.Name, p.state.volumeGroup(desired.Name) != nil) {
+			form := make(url.Values)
+			form.Add("name", desired.Name)
+			if err := p.addDevices(form, "block_devices", "partitions", desired.Devices); err != nil {
+				return err
+			}
+
+			if err := p.do("create_volume_group", desired.Name, maas_client.RequestTypePost, p.nodePath("volume-groups/"), form); err != nil {
+				return err
+			}
+
+			if err := p.reload(); err != nil {
+				return err
+			}
+		}
+
+		volumeGroup := p.state.volumeGroup(desired.Name)
+
+		for _, logicalVolume := range desired.LogicalVolumes {
+			name := desired.Name + "-" + logicalVolume.Name
+
+			present := volumeGroup != nil && slices.ContainsFunc(volumeGroup.LogicalVolumes, func(m member) bool { return m.name() == name || m.name() == logicalVolume.Name })
+			if p.exists("lv", name, present) && !p.removed["vg:"+desired.Name] {
+				continue
+			}
+
+			form := make(url.Values)
+			form.Add("name", logicalVolume.Name)
+			if logicalVolume.Size != "" {
+				form.Add("size", logicalVolume.Size)
+			}
+
+			var path string
+			if volumeGroup != nil {
+				path = p.nodePath("volume-group/%d/op-create_logical_volume", volumeGroup.ID)
+			} else if !p.dryRun {
+				return fmt.Errorf("volume group %s not found", desired.Name)
+			}
+
+			if err := p.do("create_logical_volume", name, maas_client.RequestTypePost, path, form); err != nil {
+				return err
+			}
+		}
+	}
+
+	return p.reload()
+}
+
+// applyFilesystems formats and mounts every device of the plan that has a
+// filesystem, leaving matching filesystems untouched.
+func (p *planner) applyFilesystems(plan storagePlan) error {
+	targets := make(map[string]filesystemPlan)
+	var order []string
+
+	add := func(name string, fs filesystemPlan) {
+		if fs.FSType != "" {
+			targets[name] = fs
+			order = append(order, name)
+		}
+	}
+
+	for _, disk := range plan.Disks {
+		add(disk.Name, disk.filesystemPlan)
+		for i, partition := range disk.Partitions {
+			add(fmt.Sprintf("%s-part%d", disk.Name, i+1), partition.filesystemPlan)
+		}
+	}
+	for _, raid := range plan.Raids {
+		add(raid.Name, raid.filesystemPlan)
+	}
+	for _, bcache := range plan.Bcaches {
+		add(bcache.Name, bcache.filesystemPlan)
+	}
+	for _, volumeGroup := range plan.VolumeGroups {
+		for _, logicalVolume := range volumeGroup.LogicalVolumes {
+			add(volumeGroup.Name+"-"+logicalVolume.Name, logicalVolume.filesystemPlan)
+		}
+	}
+
+	for _, name := range order {
+		desired := targets[name]
+
+		blockDeviceID, partitionID, current, ok := p.state.resolve(name)
+		if !ok && !p.dryRun {
+			return fmt.Errorf("device %s not found", name)
+		}
+
+		if !ok || p.removed["filesystem:"+name] || p.removed["partition:"+name] {
+			current = nil
+		}
+
+		blockID := strconv.Itoa(blockDeviceID)
+		partID := ""
+		if partitionID != 0 {
+			partID = strconv.Itoa(partitionID)
+		}
+
+		formatted := current != nil && current.FSType == desired.FSType && (desired.Label == "" || current.Label == desired.Label)
+
+		if !formatted {
+			if current != nil && current.FSType != "" {
+				if err := p.do("unformat", name, maas_client.RequestTypePost, operationPath(p.systemID, blockID, partID, "unformat"), nil); err != nil {
+					return err
+				}
+			}
+
+			form := make(url.Values)
+			form.Add("fstype", desired.FSType)
+			if desired.Label != "" {
+				form.Add("label", desired.Label)
+			}
+
+			if err := p.do("format", fmt.Sprintf("%s as %s", name, desired.FSType), maas_client.RequestTypePost, operationPath(p.systemID, blockID, partID, "format"), form); err != nil {
+				return err
+			}
+
+			current = nil
+		}
+
+		mountPoint := ""
+		mountOptions := ""
+		if current != nil {
+			mountPoint = current.MountPoint
+			mountOptions = current.MountOptions
+		}
+
+		if mountPoint == desired.MountPoint && (desired.MountOptions == "" || mountOptions == desired.MountOptions) {
+			continue
+		}
+
+		if mountPoint != "" {
+			if err := p.do("unmount", name, maas_client.RequestTypePost, operationPath(p.systemID, blockID, partID, "unmount"), nil); err != nil {
+				return err
+			}
+		}
+
+		if desired.MountPoint != "" {
+			form := make(url.Values)
+			form.Add("mount_point", desired.MountPoint)
+			if desired.MountOptions != "" {
+				form.Add("mount_options", desired.MountOptions)
+			}
+
+			if err := p.do("mount", fmt.Sprintf("%s at %s", name, desired.MountPoint), maas_client.RequestTypePost, operationPath(p.systemID, blockID, partID, "mount"), form); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+type ApplyStoragePlan struct{}
+
+func (ApplyStoragePlan) Create() mcp.Tool {
+	return mcp.NewTool(
+		"apply_storage_plan",
+		withSystemID(),
+		mcp.WithString(
+			"plan",
+			mcp.Required(),
+			mcp.Description(`The desired storage layout as a JSON document. Devices are referenced by name; partitions created by the plan are named <disk>-part<N> and logical volumes <vg>-<lv>. Every device entry may carry fstype, mount_point, mount_options and label. Example: {"layout": "blank", "boot_disk": "sda", "disks": [{"name": "sda", "partitions": [{"size": "1G", "fstype": "fat32", "mount_point": "/boot/efi"}, {"fstype": "ext4", "mount_point": "/"}]}], "raids": [{"name": "md0", "level": "raid-1", "devices": ["sdb", "sdc"]}], "bcaches": [{"name": "bcache0", "backing_device": "md0", "cache_device": "nvme0n1", "cache_mode": "writeback"}], "volume_groups": [{"name": "data", "devices": ["bcache0"], "logical_volumes": [{"name": "lv0", "size": "100G", "fstype": "xfs", "mount_point": "/srv"}]}], "remove_unlisted": true}. layout (flat, lvm, bcache, vmfs6, vmfs7, custom, blank) and layout_options reset the storage first. remove_unlisted also deletes volume groups, RAIDs and bcaches missing from the plan.`),
+		),
+		mcp.WithBoolean(
+			"dry_run",
+			mcp.Description("Only compute and return the steps without changing the machine. With a layout other than blank, the steps after the layout depend on the storage it produces and are not listed."),
+		),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Apply Storage Plan", false, true, true, true)),
+		mcp.WithDescription("Makes the storage of a Ready machine match a declarative layout document by deleting the partitions, volume groups, RAIDs and bcaches that differ from it and creating, formatting and mounting the missing ones. Returns the performed steps and the resulting storage."),
+	)
+}
+
+func (ApplyStoragePlan) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	systemID, err := request.RequireString("system_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[ApplyStoragePlan] Required parameter system_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	planData, err := request.RequireString("plan")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[ApplyStoragePlan] Required parameter plan not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	dryRun := request.GetBool("dry_run", false)
+
+	var plan storagePlan
+	decoder := json.NewDecoder(strings.NewReader(planData))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&plan); err != nil {
+		errMsg = fmt.Sprintf("Failed to parse the storage plan err=%v", err)
+		zap.L().Error(fmt.Sprintf("[ApplyStoragePlan] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	if err := plan.validate(); err != nil {
+		errMsg = fmt.Sprintf("Invalid storage plan err=%v", err)
+		zap.L().Error(fmt.Sprintf("[ApplyStoragePlan] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	client := maas_client.MustClient()
+
+	machine, err := tools.RetrieveMachine(ctx, client, systemID)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to retrieve the machine with id %s err=%v", systemID, err)
+		zap.L().Error(fmt.Sprintf("[ApplyStoragePlan] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	if status, _ := machine["status_name"].(string); status != "Ready" && !dryRun {
+		errMsg = fmt.Sprintf("machine %s is %s, the storage can only be changed on a Ready machine", systemID, status)
+		zap.L().Error(fmt.Sprintf("[ApplyStoragePlan] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	state, err := loadStorageState(ctx, client, systemID)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to read the storage of machine %s err=%v", systemID, err)
+		zap.L().Error(fmt.Sprintf("[ApplyStoragePlan] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	p := &planner{
+		ctx:      ctx,
+		client:   client,
+		systemID: systemID,
+		dryRun:   dryRun,
+		state:    state,
+		removed:  make(map[string]bool),
+		steps:    []planStep{},
+	}
+
+	zap.L().Info(fmt.Sprintf("[ApplyStoragePlan] Applying storage plan to machine %s (dry_run=%t)...", systemID, dryRun))
+	applyErr := p.apply(plan, machine)
+
+	report := struct {
+		SystemID string        `json:"system_id"`
+		DryRun   bool          `json:"dry_run"`
+		Steps    []planStep    `json:"steps"`
+		Error    string        `json:"error,omitempty"`
+		Storage  *storageState `json:"storage,omitempty"`
+	}{
+		SystemID: systemID,
+		DryRun:   dryRun,
+		Steps:    p.steps,
+	}
+
+	if applyErr != nil {
+		report.Error = applyErr.Error()
+		zap.L().Error(fmt.Sprintf("[ApplyStoragePlan] Failed to apply the storage plan to machine %s err=%v", systemID, applyErr))
+	} else if !dryRun {
+		report.Storage = p.state
+	}
+
+	jsonData, err := json.Marshal(report)
+	if err != nil {
+		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[ApplyStoragePlan] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	if applyErr != nil {
+		return mcp.NewToolResultError(string(jsonData)), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func testState() *storageState {
+	return &storageState{
+		BlockDevices: []blockDevice{
+			{
+				ID: 1, Name: "sda", Type: "physical", Size: 100 << 30,
+				Partitions: []partition{
+					{ID: 10, Name: "sda-part1", Size: 1 << 30, Filesystem: &filesystem{FSType: "fat32", MountPoint: "/boot/efi"}},
+					{ID: 11, Name: "sda-part2", Size: 99 << 30, UsedFor: "LVM volume for vgroot"},
+				},
+			},
+			{ID: 2, Name: "sdb", Type: "physical", Size: 100 << 30},
+			{ID: 3, Name: "vgroot-lvroot", Type: "virtual", Size: 99 << 30, Filesystem: &filesystem{FSType: "ext4", MountPoint: "/"}},
+		},
+		VolumeGroups: []volumeGroup{
+			{ID: 5, Name: "vgroot", Devices: []member{{ID: 11, Path: "/dev/disk/by-dname/sda-part2"}}, LogicalVolumes: []member{{ID: 3, Name: "vgroot-lvroot"}}},
+		},
+	}
+}
+
+func dryRun(t *testing.T, planJSON string) []planStep {
+	t.Helper()
+
+	var plan storagePlan
+	if err := json.Unmarshal([]byte(planJSON), &plan); err != nil {
+		t.Fatal(err)
+	}
+	if err := plan.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &planner{
+		ctx:      context.Background(),
+		systemID: "abc123",
+		dryRun:   true,
+		state:    testState(),
+		removed:  make(map[string]bool),
+	}
+
+	if err := p.apply(plan, map[string]any{"boot_disk": map[string]any{"name": "sda"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	return p.steps
+}
+
+func actions(steps []planStep) []string {
+	var actions []string
+	for _, step := range steps {
+		actions = append(actions, step.Action+" "+step.Target)
+	}
+	return actions
+}
+
+func TestDryRunLayout(t *testing.T) {
+	plan := `{"disks": [{"name": "sdb", "partitions": [{"size": "1G", "fstype": "fat32", "mount_point": "/boot/efi"}, {"fstype": "ext4", "mount_point": "/"}]}], "remove_unlisted": true}`
+
+	t.Run("without layout", func(t *testing.T) {
+		got := actions(dryRun(t, plan))
+		if !slices.Contains(got, "delete_volume_group vgroot") {
+			t.Errorf("steps %v do not remove vgroot", got)
+		}
+	})
+
+	t.Run("blank layout", func(t *testing.T) {
+		withLayout := `{"layout": "blank", ` + plan[1:]
+		got := actions(dryRun(t, withLayout))
+
+		want := []string{"set_storage_layout blank", "create_partition sdb-part1", "create_partition sdb-part2"}
+		if len(got) < len(want) || !slices.Equal(got[:len(want)], want) {
+			t.Errorf("steps = %v, want them to start with %v", got, want)
+		}
+		for _, step := range got {
+			if step == "delete_volume_group vgroot" || step == "delete_partition sda-part1" {
+				t.Errorf("blank layout already removed %s", step)
+			}
+		}
+	})
+
+	t.Run("other layout", func(t *testing.T) {
+		withLayout := `{"layout": "flat", ` + plan[1:]
+		steps := dryRun(t, withLayout)
+
+		if len(steps) != 2 || steps[0].Action != "set_storage_layout" || steps[1].Status != "unknown" || steps[1].Note == "" {
+			t.Errorf("steps = %+v, want the layout followed by a step depending on its result", steps)
+		}
+	})
+}
+
+func TestSizeMatches(t *testing.T) {
+	tests := []struct {
+		actual    int64
+		requested string
+		want      bool
+	}{
+		{1e9, "1G", true},
+		{1e9 - 4<<20, "1G", true},
+		{1 << 30, "1G", false},
+		{2e9, "1G", false},
+		{500e9, "", true},
+	}
+
+	for _, test := range tests {
+		if got := sizeMatches(test.actual, test.requested); got != test.want {
+			t.Errorf("sizeMatches(%d, %q) = %t, want %t", test.actual, test.requested, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+
+	"github.com/JarcauCristian/ztp-mcp/internal/server/maas_client"
+)
+
+type filesystem struct {
+	FSType       string `json:"fstype"`
+	MountPoint   string `json:"mount_point"`
+	MountOptions string `json:"mount_options,omitempty"`
+	Label        string `json:"label,omitempty"`
+}
+
+type partition struct {
+	ID         int         `json:"id"`
+	Name       string      `json:"name"`
+	Path       string      `json:"path"`
+	Size       int64       `json:"size"`
+	Bootable   bool        `json:"bootable"`
+	UsedFor    string      `json:"used_for"`
+	Filesystem *filesystem `json:"filesystem"`
+}
+
+type blockDevice struct {
+	ID         int         `json:"id"`
+	Name       string      `json:"name"`
+	Type       string      `json:"type"`
+	Path       string      `json:"path"`
+	Model      string      `json:"model,omitempty"`
+	Serial     string      `json:"serial,omitempty"`
+	Size       int64       `json:"size"`
+	UsedFor    string      `json:"used_for"`
+	Filesystem *filesystem `json:"filesystem"`
+	Partitions []partition `json:"partitions"`
+}
+
+// member is a block device or partition referenced by a volume group, RAID,
+// cache set or bcache. Partitions only carry their path.
+type member struct {
+	ID   int    `json:"id"`
+	Name string `json:"name,omitempty"`
+	Path string `json:"path,omitempty"`
+	Size int64  `json:"size,omitempty"`
+}
+
+func (m member) name() string {
+	if m.Name != "" {
+		return m.Name
+	}
+
+	return path.Base(m.Path)
+}
+
+type volumeGroup struct {
+	ID             int      `json:"id"`
+	Name           string   `json:"name"`
+	Size           int64    `json:"size"`
+	Devices        []member `json:"devices"`
+	LogicalVolumes []member `json:"logical_volumes"`
+}
+
+type raid struct {
+	ID            int      `json:"id"`
+	Name          string   `json:"name"`
+	Level         string   `json:"level"`
+	Devices       []member `json:"devices"`
+	SpareDevices  []member `json:"spare_devices"`
+	VirtualDevice member   `json:"virtual_device"`
+}
+
+type cacheSet struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	CacheDevice member `json:"cache_device"`
+}
+
+type bcache struct {
+	ID            int      `json:"id"`
+	Name          string   `json:"name"`
+	CacheMode     string   `json:"cache_mode"`
+	BackingDevice member   `json:"backing_device"`
+	CacheSet      cacheSet `json:"cache_set"`
+	VirtualDevice member   `json:"virtual_device"`
+}
+
+// storageState is the storage configuration of a machine as reported by MAAS.
+type storageState struct {
+	BlockDevices []blockDevice `json:"block_devices"`
+	VolumeGroups []volumeGroup `json:"volume_groups"`
+	Raids        []raid        `json:"raids"`
+	CacheSets    []cacheSet    `json:"bcache_cache_sets"`
+	Bcaches      []bcache      `json:"bcaches"`
+}
+
+func loadStorageState(ctx context.Context, client *maas_client.MAASClient, systemID string) (*storageState, error) {
+	state := &storageState{}
+
+	endpoints := []struct {
+		path   string
+		target any
+	}{
+		{"blockdevices/", &state.BlockDevices},
+		{"volume-groups/", &state.VolumeGroups},
+		{"raids/", &state.Raids},
+		{"bcache-cache-sets/", &state.CacheSets},
+		{"bcaches/", &state.Bcaches},
+	}
+
+	for _, endpoint := range endpoints {
+		resultData, err := client.Do(ctx, maas_client.RequestTypeGet, fmt.Sprintf("/MAAS/api/2.0/nodes/%s/%s", systemID, endpoint.path), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", endpoint.path, err)
+		}
+
+		if err := json.Unmarshal([]byte(resultData), endpoint.target); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal %s: %w", endpoint.path, err)
+		}
+	}
+
+	for i := range state.BlockDevices {
+		for j := range state.BlockDevices[i].Partitions {
+			partition := &state.BlockDevices[i].Partitions[j]
+			if partition.Name == "" {
+				partition.Name = path.Base(partition.Path)
+			}
+		}
+	}
+
+	return state, nil
+}
+
+// blank returns the storage the blank layout leaves behind: the physical
+// disks without partitions, filesystems or any device built on top of them.
+func (s *storageState) blank() *storageState {
+	state := &storageState{}
+
+	for _, device := range s.BlockDevices {
+		if device.Type != "physical" {
+			continue
+		}
+
+		device.UsedFor = ""
+		device.Filesystem = nil
+		device.Partitions = nil
+		state.BlockDevices = append(state.BlockDevices, device)
+	}
+
+	return state
+}
+
+func (s *storageState) blockDevice(name string) *blockDevice {
+	for i := range s.BlockDevices {
+		if s.BlockDevices[i].Name == name {
+			return &s.BlockDevices[i]
+		}
+	}
+
+	return nil
+}
+
+// resolve finds a block device or partition by name and returns the IDs
+// used in API paths together with its filesystem. partitionID is 0 for
+// block devices.
+func (s *storageState) resolve(name string) (blockDeviceID, partitionID int, fs *filesystem, ok bool) {
+	for _, device := range s.BlockDevices {
+		if device.Name == name {
+			return device.ID, 0, device.Filesystem, true
+		}
+
+		for _, partition := range device.Partitions {
+			if partition.Name == name {
+				return device.ID, partition.ID, partition.Filesystem, true
+			}
+		}
+	}
+
+	return 0, 0, nil, false
+}
+
+func (s *storageState) volumeGroup(name string) *volumeGroup {
+	for i := range s.VolumeGroups {
+		if s.VolumeGroups[i].Name == name {
+			return &s.VolumeGroups[i]
+		}
+	}
+
+	return nil
+}
+
+func (s *storageState) raid(name string) *raid {
+	for i := range s.Raids {
+		if s.Raids[i].Name == name {
+			return &s.Raids[i]
+		}
+	}
+
+	return nil
+}
+
+func (s *storageState) bcache(name string) *bcache {
+	for i := range s.Bcaches {
+		if s.Bcaches[i].Name == name {
+			return &s.Bcaches[i]
+		}
+	}
+
+	return nil
+}
+
+func (s *storageState) cacheSetFor(deviceName string) *cacheSet {
+	for i := range s.CacheSets {
+		if s.CacheSets[i].CacheDevice.name() == deviceName {
+			return &s.CacheSets[i]
+		}
+	}
+
+	return nil
+}
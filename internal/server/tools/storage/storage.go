@@ -0,0 +1,228 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/JarcauCristian/ztp-mcp/internal/server/maas_client"
+	"github.com/JarcauCristian/ztp-mcp/internal/server/tools"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+)
+
+type Storage struct{}
+
+func (Storage) Register(mcpServer *server.MCPServer) {
+	mcpTools := []tools.MCPTool{
+		ListStorage{}, SetStorageLayout{},
+		CreatePartition{}, DeletePartition{},
+		FormatStorage{}, UnformatStorage{}, MountStorage{}, UnmountStorage{}, SetBootDisk{},
+		CreateVolumeGroup{}, DeleteVolumeGroup{}, CreateLogicalVolume{}, DeleteLogicalVolume{},
+		CreateRaid{}, DeleteRaid{},
+		CreateBcacheCacheSet{}, DeleteBcacheCacheSet{}, CreateBcache{}, DeleteBcache{},
+		ApplyStoragePlan{},
+	}
+
+	for _, tool := range mcpTools {
+		mcpServer.AddTool(tool.Create(), tool.Handle)
+	}
+}
+
+func withSystemID() mcp.ToolOption {
+	return mcp.WithString(
+		"system_id",
+		mcp.Required(),
+		mcp.Pattern("^[0-9a-z]{6}$"),
+		mcp.Description("The system ID of the machine."),
+	)
+}
+
+func withID(name, description string) mcp.ToolOption {
+	return mcp.WithString(
+		name,
+		mcp.Required(),
+		mcp.Pattern("^[0-9]+$"),
+		mcp.Description(description),
+	)
+}
+
+// addIDs adds every comma separated ID in value to the form under key.
+func addIDs(form url.Values, key, value string) {
+	for _, id := range strings.Split(value, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			form.Add(key, id)
+		}
+	}
+}
+
+type ListStorage struct{}
+
+func (ListStorage) Create() mcp.Tool {
+	return mcp.NewTool(
+		"list_storage",
+		withSystemID(),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("List Storage", true, false, true, true)),
+		mcp.WithDescription("Lists the block devices (with their partitions and filesystems), volume groups, RAIDs, bcache cache sets and bcaches of a machine."),
+	)
+}
+
+func (ListStorage) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	systemID, err := request.RequireString("system_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[ListStorage] Required parameter system_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	client := maas_client.MustClient()
+
+	if _, err := tools.RetrieveMachine(ctx, client, systemID); err != nil {
+		errMsg = fmt.Sprintf("Failed to retrieve the machine with id %s err=%v", systemID, err)
+		zap.L().Error(fmt.Sprintf("[ListStorage] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	zap.L().Info(fmt.Sprintf("[ListStorage] Listing storage of machine %s...", systemID))
+	state, err := loadStorageState(ctx, client, systemID)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to list the storage of machine %s err=%v", systemID, err)
+		zap.L().Error(fmt.Sprintf("[ListStorage] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	jsonData, err := json.Marshal(state)
+	if err != nil {
+		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[ListStorage] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+type SetStorageLayout struct{}
+
+func (SetStorageLayout) Create() mcp.Tool {
+	return mcp.NewTool(
+		"set_storage_layout",
+		withSystemID(),
+		mcp.WithString(
+			"storage_layout",
+			mcp.Required(),
+			mcp.Enum("flat", "lvm", "bcache", "vmfs6", "vmfs7", "custom", "blank"),
+			mcp.Description("The storage layout to apply. This replaces the current storage configuration of the machine."),
+		),
+		mcp.WithString(
+			"boot_size",
+			mcp.Description("Size of the boot partition, e.g. 512M."),
+		),
+		mcp.WithString(
+			"root_device",
+			mcp.Description("The block device to place the root partition on, defaults to the boot disk."),
+		),
+		mcp.WithString(
+			"root_size",
+			mcp.Description("Size of the root partition, e.g. 50G. Defaults to the rest of the disk."),
+		),
+		mcp.WithString(
+			"vg_name",
+			mcp.Description("lvm: name of the volume group."),
+		),
+		mcp.WithString(
+			"lv_name",
+			mcp.Description("lvm: name of the logical volume."),
+		),
+		mcp.WithString(
+			"lv_size",
+			mcp.Description("lvm: size of the logical volume."),
+		),
+		mcp.WithString(
+			"cache_device",
+			mcp.Description("bcache: the block device to use as the cache."),
+		),
+		mcp.WithString(
+			"cache_mode",
+			mcp.Enum("writeback", "writethrough", "writearound"),
+			mcp.Description("bcache: the cache mode."),
+		),
+		mcp.WithString(
+			"cache_size",
+			mcp.Description("bcache: size of the cache partition."),
+		),
+		mcp.WithBoolean(
+			"cache_no_part",
+			mcp.Description("bcache: use the whole cache device instead of a partition."),
+		),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Set Storage Layout", false, true, true, true)),
+		mcp.WithDescription("Applies a named storage layout to a Ready machine, replacing its current storage configuration."),
+	)
+}
+
+func (SetStorageLayout) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	systemID, err := request.RequireString("system_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[SetStorageLayout] Required parameter system_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	layout, err := request.RequireString("storage_layout")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[SetStorageLayout] Required parameter storage_layout not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	form := make(url.Values)
+	form.Add("storage_layout", layout)
+
+	for _, option := range layoutOptions {
+		if value := request.GetString(option, ""); value != "" {
+			form.Add(option, value)
+		}
+	}
+
+	if request.GetBool("cache_no_part", false) {
+		form.Add("cache_no_part", "true")
+	}
+
+	path := fmt.Sprintf("/MAAS/api/2.0/machines/%s/op-set_storage_layout", systemID)
+
+	client := maas_client.MustClient()
+
+	machine, err := tools.RetrieveMachine(ctx, client, systemID)
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to retrieve the machine with id %s err=%v", systemID, err)
+		zap.L().Error(fmt.Sprintf("[SetStorageLayout] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	if status, _ := machine["status_name"].(string); status != "Ready" {
+		errMsg := fmt.Sprintf("machine %s is %s, the storage layout can only be set on a Ready machine", systemID, status)
+		zap.L().Error(fmt.Sprintf("[SetStorageLayout] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	zap.L().Info(fmt.Sprintf("[SetStorageLayout] Setting storage layout %s on machine %s...", layout, systemID))
+	resultData, err := client.Do(ctx, maas_client.RequestTypePost, path, strings.NewReader(form.Encode()))
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to set storage layout %s on machine %s err=%v", layout, systemID, err)
+		zap.L().Error(fmt.Sprintf("[SetStorageLayout] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	jsonData, err := json.Marshal(resultData)
+	if err != nil {
+		errMsg := fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[SetStorageLayout] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+// layoutOptions are the string options accepted by op-set_storage_layout.
+var layoutOptions = []string{"boot_size", "root_device", "root_size", "vg_name", "lv_name", "lv_size", "cache_device", "cache_mode", "cache_size"}
@@ -0,0 +1,450 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/JarcauCristian/ztp-mcp/internal/server/maas_client"
+	"github.com/JarcauCristian/ztp-mcp/internal/server/tools"
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.uber.org/zap"
+)
+
+func withIDList(name, description string) mcp.ToolOption {
+	return mcp.WithString(
+		name,
+		mcp.Pattern("^[0-9, ]*$"),
+		mcp.Description(description),
+	)
+}
+
+type CreateVolumeGroup struct{}
+
+func (CreateVolumeGroup) Create() mcp.Tool {
+	return mcp.NewTool(
+		"create_volume_group",
+		withSystemID(),
+		mcp.WithString(
+			"name",
+			mcp.Required(),
+			mcp.Description("Name of the volume group."),
+		),
+		withIDList("block_devices", "Comma separated IDs of the block devices to add to the volume group."),
+		withIDList("partitions", "Comma separated IDs of the partitions to add to the volume group."),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Create Volume Group", false, false, false, true)),
+		mcp.WithDescription("Creates an LVM volume group on a machine from block devices and/or partitions."),
+	)
+}
+
+func (CreateVolumeGroup) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	systemID, err := request.RequireString("system_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[CreateVolumeGroup] Required parameter system_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	name, err := request.RequireString("name")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[CreateVolumeGroup] Required parameter name not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	form := make(url.Values)
+	form.Add("name", name)
+	addIDs(form, "block_devices", request.GetString("block_devices", ""))
+	addIDs(form, "partitions", request.GetString("partitions", ""))
+
+	if len(form["block_devices"]) == 0 && len(form["partitions"]) == 0 {
+		return mcp.NewToolResultError("at least one block device or partition is required"), nil
+	}
+
+	path := fmt.Sprintf("/MAAS/api/2.0/nodes/%s/volume-groups/", systemID)
+
+	return tools.MachineRequest(ctx, "CreateVolumeGroup", systemID, maas_client.RequestTypePost, path, form, fmt.Sprintf("create volume group %s", name))
+}
+
+type DeleteVolumeGroup struct{}
+
+func (DeleteVolumeGroup) Create() mcp.Tool {
+	return mcp.NewTool(
+		"delete_volume_group",
+		withSystemID(),
+		withID("volume_group_id", "The ID of the volume group to delete."),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Delete Volume Group", false, true, false, true)),
+		mcp.WithDescription("Deletes a volume group and its logical volumes from a machine."),
+	)
+}
+
+func (DeleteVolumeGroup) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	systemID, err := request.RequireString("system_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[DeleteVolumeGroup] Required parameter system_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	volumeGroupID, err := request.RequireString("volume_group_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[DeleteVolumeGroup] Required parameter volume_group_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	path := fmt.Sprintf("/MAAS/api/2.0/nodes/%s/volume-group/%s/", systemID, volumeGroupID)
+
+	return tools.MachineRequest(ctx, "DeleteVolumeGroup", systemID, maas_client.RequestTypeDelete, path, nil, fmt.Sprintf("delete volume group %s", volumeGroupID))
+}
+
+type CreateLogicalVolume struct{}
+
+func (CreateLogicalVolume) Create() mcp.Tool {
+	return mcp.NewTool(
+		"create_logical_volume",
+		withSystemID(),
+		withID("volume_group_id", "The ID of the volume group to create the logical volume in."),
+		mcp.WithString(
+			"name",
+			mcp.Required(),
+			mcp.Description("Name of the logical volume."),
+		),
+		mcp.WithString(
+			"size",
+			mcp.Description("Size of the logical volume, e.g. 20G. Uses the remaining space of the volume group when not set."),
+		),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Create Logical Volume", false, false, false, true)),
+		mcp.WithDescription("Creates a logical volume in a volume group of a machine. The logical volume is a block device that can then be formatted and mounted."),
+	)
+}
+
+func (CreateLogicalVolume) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	systemID, err := request.RequireString("system_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[CreateLogicalVolume] Required parameter system_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	volumeGroupID, err := request.RequireString("volume_group_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[CreateLogicalVolume] Required parameter volume_group_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	name, err := request.RequireString("name")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[CreateLogicalVolume] Required parameter name not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	form := make(url.Values)
+	form.Add("name", name)
+	if size := request.GetString("size", ""); size != "" {
+		form.Add("size", size)
+	}
+
+	path := fmt.Sprintf("/MAAS/api/2.0/nodes/%s/volume-group/%s/op-create_logical_volume", systemID, volumeGroupID)
+
+	return tools.MachineRequest(ctx, "CreateLogicalVolume", systemID, maas_client.RequestTypePost, path, form, fmt.Sprintf("create logical volume %s", name))
+}
+
+type DeleteLogicalVolume struct{}
+
+func (DeleteLogicalVolume) Create() mcp.Tool {
+	return mcp.NewTool(
+		"delete_logical_volume",
+		withSystemID(),
+		withID("volume_group_id", "The ID of the volume group holding the logical volume."),
+		withID("logical_volume_id", "The ID of the logical volume to delete."),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Delete Logical Volume", false, true, false, true)),
+		mcp.WithDescription("Deletes a logical volume from a volume group of a machine."),
+	)
+}
+
+func (DeleteLogicalVolume) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	systemID, err := request.RequireString("system_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[DeleteLogicalVolume] Required parameter system_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	volumeGroupID, err := request.RequireString("volume_group_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[DeleteLogicalVolume] Required parameter volume_group_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	logicalVolumeID, err := request.RequireString("logical_volume_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[DeleteLogicalVolume] Required parameter logical_volume_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	form := make(url.Values)
+	form.Add("id", logicalVolumeID)
+
+	path := fmt.Sprintf("/MAAS/api/2.0/nodes/%s/volume-group/%s/op-delete_logical_volume", systemID, volumeGroupID)
+
+	return tools.MachineRequest(ctx, "DeleteLogicalVolume", systemID, maas_client.RequestTypePost, path, form, fmt.Sprintf("delete logical volume %s", logicalVolumeID))
+}
+
+type CreateRaid struct{}
+
+func (CreateRaid) Create() mcp.Tool {
+	return mcp.NewTool(
+		"create_raid",
+		withSystemID(),
+		mcp.WithString(
+			"name",
+			mcp.Description("Name of the RAID device, e.g. md0."),
+		),
+		mcp.WithString(
+			"level",
+			mcp.Required(),
+			mcp.Enum("raid-0", "raid-1", "raid-5", "raid-6", "raid-10"),
+			mcp.Description("The RAID level."),
+		),
+		withIDList("block_devices", "Comma separated IDs of the block devices that are active members of the RAID."),
+		withIDList("partitions", "Comma separated IDs of the partitions that are active members of the RAID."),
+		withIDList("spare_devices", "Comma separated IDs of the block devices used as spares."),
+		withIDList("spare_partitions", "Comma separated IDs of the partitions used as spares."),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Create RAID", false, false, false, true)),
+		mcp.WithDescription("Creates a software RAID on a machine. The RAID is a block device that can then be formatted, mounted or used in a volume group."),
+	)
+}
+
+func (CreateRaid) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	systemID, err := request.RequireString("system_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[CreateRaid] Required parameter system_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	level, err := request.RequireString("level")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[CreateRaid] Required parameter level not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	form := make(url.Values)
+	form.Add("level", level)
+	if name := request.GetString("name", ""); name != "" {
+		form.Add("name", name)
+	}
+	for _, key := range []string{"block_devices", "partitions", "spare_devices", "spare_partitions"} {
+		addIDs(form, key, request.GetString(key, ""))
+	}
+
+	path := fmt.Sprintf("/MAAS/api/2.0/nodes/%s/raids/", systemID)
+
+	return tools.MachineRequest(ctx, "CreateRaid", systemID, maas_client.RequestTypePost, path, form, fmt.Sprintf("create %s", level))
+}
+
+type DeleteRaid struct{}
+
+func (DeleteRaid) Create() mcp.Tool {
+	return mcp.NewTool(
+		"delete_raid",
+		withSystemID(),
+		withID("raid_id", "The ID of the RAID to delete."),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Delete RAID", false, true, false, true)),
+		mcp.WithDescription("Deletes a software RAID from a machine."),
+	)
+}
+
+func (DeleteRaid) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	systemID, err := request.RequireString("system_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[DeleteRaid] Required parameter system_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	raidID, err := request.RequireString("raid_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[DeleteRaid] Required parameter raid_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	path := fmt.Sprintf("/MAAS/api/2.0/nodes/%s/raid/%s/", systemID, raidID)
+
+	return tools.MachineRequest(ctx, "DeleteRaid", systemID, maas_client.RequestTypeDelete, path, nil, fmt.Sprintf("delete RAID %s", raidID))
+}
+
+type CreateBcacheCacheSet struct{}
+
+func (CreateBcacheCacheSet) Create() mcp.Tool {
+	return mcp.NewTool(
+		"create_bcache_cache_set",
+		withSystemID(),
+		mcp.WithString(
+			"cache_device",
+			mcp.Pattern("^[0-9]*$"),
+			mcp.Description("The ID of the block device to use as the cache."),
+		),
+		mcp.WithString(
+			"cache_partition",
+			mcp.Pattern("^[0-9]*$"),
+			mcp.Description("The ID of the partition to use as the cache."),
+		),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Create Bcache Cache Set", false, false, false, true)),
+		mcp.WithDescription("Creates a bcache cache set on a machine from a fast block device or partition. Exactly one of cache_device and cache_partition is required."),
+	)
+}
+
+func (CreateBcacheCacheSet) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	systemID, err := request.RequireString("system_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[CreateBcacheCacheSet] Required parameter system_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	cacheDevice := request.GetString("cache_device", "")
+	cachePartition := request.GetString("cache_partition", "")
+
+	if (cacheDevice == "") == (cachePartition == "") {
+		return mcp.NewToolResultError("exactly one of cache_device and cache_partition is required"), nil
+	}
+
+	form := make(url.Values)
+	if cacheDevice != "" {
+		form.Add("cache_device", cacheDevice)
+	} else {
+		form.Add("cache_partition", cachePartition)
+	}
+
+	path := fmt.Sprintf("/MAAS/api/2.0/nodes/%s/bcache-cache-sets/", systemID)
+
+	return tools.MachineRequest(ctx, "CreateBcacheCacheSet", systemID, maas_client.RequestTypePost, path, form, "create a bcache cache set")
+}
+
+type DeleteBcacheCacheSet struct{}
+
+func (DeleteBcacheCacheSet) Create() mcp.Tool {
+	return mcp.NewTool(
+		"delete_bcache_cache_set",
+		withSystemID(),
+		withID("cache_set_id", "The ID of the cache set to delete."),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Delete Bcache Cache Set", false, true, false, true)),
+		mcp.WithDescription("Deletes an unused bcache cache set from a machine."),
+	)
+}
+
+func (DeleteBcacheCacheSet) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	systemID, err := request.RequireString("system_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[DeleteBcacheCacheSet] Required parameter system_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	cacheSetID, err := request.RequireString("cache_set_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[DeleteBcacheCacheSet] Required parameter cache_set_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	path := fmt.Sprintf("/MAAS/api/2.0/nodes/%s/bcache-cache-set/%s/", systemID, cacheSetID)
+
+	return tools.MachineRequest(ctx, "DeleteBcacheCacheSet", systemID, maas_client.RequestTypeDelete, path, nil, fmt.Sprintf("delete bcache cache set %s", cacheSetID))
+}
+
+type CreateBcache struct{}
+
+func (CreateBcache) Create() mcp.Tool {
+	return mcp.NewTool(
+		"create_bcache",
+		withSystemID(),
+		mcp.WithString(
+			"name",
+			mcp.Description("Name of the bcache device, e.g. bcache0."),
+		),
+		withID("cache_set", "The ID of the cache set to use."),
+		mcp.WithString(
+			"backing_device",
+			mcp.Pattern("^[0-9]*$"),
+			mcp.Description("The ID of the block device to cache."),
+		),
+		mcp.WithString(
+			"backing_partition",
+			mcp.Pattern("^[0-9]*$"),
+			mcp.Description("The ID of the partition to cache."),
+		),
+		mcp.WithString(
+			"cache_mode",
+			mcp.Required(),
+			mcp.Enum("writeback", "writethrough", "writearound"),
+			mcp.Description("The cache mode."),
+		),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Create Bcache", false, false, false, true)),
+		mcp.WithDescription("Creates a bcache device on a machine that caches a backing block device or partition with a cache set. Exactly one of backing_device and backing_partition is required."),
+	)
+}
+
+func (CreateBcache) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	systemID, err := request.RequireString("system_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[CreateBcache] Required parameter system_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	cacheSet, err := request.RequireString("cache_set")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[CreateBcache] Required parameter cache_set not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	cacheMode, err := request.RequireString("cache_mode")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[CreateBcache] Required parameter cache_mode not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	backingDevice := request.GetString("backing_device", "")
+	backingPartition := request.GetString("backing_partition", "")
+
+	if (backingDevice == "") == (backingPartition == "") {
+		return mcp.NewToolResultError("exactly one of backing_device and backing_partition is required"), nil
+	}
+
+	form := make(url.Values)
+	form.Add("cache_set", cacheSet)
+	form.Add("cache_mode", cacheMode)
+	if name := request.GetString("name", ""); name != "" {
+		form.Add("name", name)
+	}
+	if backingDevice != "" {
+		form.Add("backing_device", backingDevice)
+	} else {
+		form.Add("backing_partition", backingPartition)
+	}
+
+	path := fmt.Sprintf("/MAAS/api/2.0/nodes/%s/bcaches/", systemID)
+
+	return tools.MachineRequest(ctx, "CreateBcache", systemID, maas_client.RequestTypePost, path, form, "create a bcache device")
+}
+
+type DeleteBcache struct{}
+
+func (DeleteBcache) Create() mcp.Tool {
+	return mcp.NewTool(
+		"delete_bcache",
+		withSystemID(),
+		withID("bcache_id", "The ID of the bcache device to delete."),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Delete Bcache", false, true, false, true)),
+		mcp.WithDescription("Deletes a bcache device from a machine."),
+	)
+}
+
+func (DeleteBcache) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	systemID, err := request.RequireString("system_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[DeleteBcache] Required parameter system_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	bcacheID, err := request.RequireString("bcache_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[DeleteBcache] Required parameter bcache_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	path := fmt.Sprintf("/MAAS/api/2.0/nodes/%s/bcache/%s/", systemID, bcacheID)
+
+	return tools.MachineRequest(ctx, "DeleteBcache", systemID, maas_client.RequestTypeDelete, path, nil, fmt.Sprintf("delete bcache %s", bcacheID))
+}
@@ -0,0 +1,447 @@
+package subnets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/JarcauCristian/ztp-mcp/internal/server/maas_client"
+	"github.com/JarcauCristian/ztp-mcp/internal/server/tools"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+)
+
+// userReserved is the allocation type MAAS gives to addresses reserved
+// through the ipaddresses API.
+const userReserved = 4
+
+type IPAddresses struct{}
+
+func (IPAddresses) Register(mcpServer *server.MCPServer) {
+	mcpTools := []tools.MCPTool{ReserveIP{}, ReleaseIP{}, ListReservedIPs{}}
+
+	for _, tool := range mcpTools {
+		mcpServer.AddTool(tool.Create(), tool.Handle)
+	}
+}
+
+// ipOwner is the part of the MAAS user object an address owner is returned as.
+type ipOwner struct {
+	Username string `json:"username"`
+}
+
+type reservedIP struct {
+	IP        string   `json:"ip"`
+	AllocType int      `json:"alloc_type"`
+	Owner     *ipOwner `json:"owner"`
+	Created   string   `json:"created"`
+	Subnet    struct {
+		ID   int    `json:"id"`
+		CIDR string `json:"cidr"`
+	} `json:"subnet"`
+}
+
+func loadReservedIPs(ctx context.Context, client *maas_client.MAASClient) ([]reservedIP, error) {
+	resultData, err := client.Do(ctx, maas_client.RequestTypeGet, "/MAAS/api/2.0/ipaddresses/?all=true", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var addresses []reservedIP
+	if err := json.Unmarshal([]byte(resultData), &addresses); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal IP addresses: %w", err)
+	}
+
+	reserved := addresses[:0]
+	for _, address := range addresses {
+		if address.AllocType == userReserved {
+			reserved = append(reserved, address)
+		}
+	}
+
+	return reserved, nil
+}
+
+// subnetOf returns the subnet whose CIDR contains the address.
+func subnetOf(ctx context.Context, client *maas_client.MAASClient, addr netip.Addr) (map[string]any, error) {
+	subnets, err := loadSubnets(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	var best map[string]any
+	bestBits := -1
+	for _, subnet := range subnets {
+		cidr, _ := subnet["cidr"].(string)
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil || !prefix.Contains(addr) {
+			continue
+		}
+
+		if prefix.Bits() > bestBits {
+			best, bestBits = subnet, prefix.Bits()
+		}
+	}
+
+	if best == nil {
+		return nil, fmt.Errorf("%s is not in any subnet known to MAAS", addr)
+	}
+
+	return best, nil
+}
+
+type unreservedRange struct {
+	Start        string `json:"start"`
+	End          string `json:"end"`
+	NumAddresses int    `json:"num_addresses"`
+}
+
+// nextFree returns the first address of the subnet that is outside every IP
+// range and not allocated, together with the unreserved range it came from.
+func nextFree(ctx context.Context, client *maas_client.MAASClient, subnet map[string]any) (netip.Addr, unreservedRange, error) {
+	subnetID := fmt.Sprint(subnet["id"])
+
+	resultData, err := client.Do(ctx, maas_client.RequestTypeGet, "/MAAS/api/2.0/subnets/"+subnetID+"/op-unreserved_ip_ranges", nil)
+	if err != nil {
+		return netip.Addr{}, unreservedRange{}, fmt.Errorf("failed to list the unreserved ranges: %w", err)
+	}
+
+	var ranges []unreservedRange
+	if err := json.Unmarshal([]byte(resultData), &ranges); err != nil {
+		return netip.Addr{}, unreservedRange{}, fmt.Errorf("failed to unmarshal the unreserved ranges: %w", err)
+	}
+
+	addresses, err := loadSubnetAddresses(ctx, client, subnetID)
+	if err != nil {
+		return netip.Addr{}, unreservedRange{}, fmt.Errorf("failed to list the addresses of the subnet: %w", err)
+	}
+
+	used := make(map[netip.Addr]bool)
+	for _, address := range addresses {
+		if addr, err := netip.ParseAddr(address.IP); err == nil {
+			used[addr] = true
+		}
+	}
+	if gateway, err := netip.ParseAddr(fmt.Sprint(subnet["gateway_ip"])); err == nil {
+		used[gateway] = true
+	}
+
+	for _, free := range ranges {
+		start, end, err := parseBounds(free.Start, free.End)
+		if err != nil {
+			continue
+		}
+
+		for addr := start; addr.IsValid() && addr.Compare(end) <= 0; addr = addr.Next() {
+			if !used[addr] {
+				return addr, free, nil
+			}
+		}
+	}
+
+	return netip.Addr{}, unreservedRange{}, fmt.Errorf("subnet %v has no free address left", subnet["cidr"])
+}
+
+type ReserveIP struct{}
+
+func (ReserveIP) Create() mcp.Tool {
+	return mcp.NewTool(
+		"reserve_ip",
+		mcp.WithString(
+			"ip",
+			mcp.Description("The address to reserve. Leave empty to take the next free address of subnet."),
+		),
+		mcp.WithString(
+			"subnet",
+			mcp.Pattern("^[0-9]*$"),
+			mcp.Description("The ID of the subnet. Required when ip is empty, otherwise the subnet containing ip is used."),
+		),
+		mcp.WithString(
+			"mac",
+			mcp.Description("MAC address of the device that will use the address."),
+		),
+		mcp.WithString(
+			"hostname",
+			mcp.Description("Hostname to register in DNS for the address."),
+		),
+		mcp.WithString(
+			"domain",
+			mcp.Description("DNS domain of hostname. Defaults to the default domain."),
+		),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Reserve IP", false, false, false, true)),
+		mcp.WithDescription("Reserves an address in a managed subnet for a VIP or a device MAAS does not manage, so MAAS never hands it out. Without ip, the next free address is taken and the unreserved range it came from is shown."),
+	)
+}
+
+func (ReserveIP) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	ip := request.GetString("ip", "")
+	subnetID := request.GetString("subnet", "")
+	mac := request.GetString("mac", "")
+	hostname := request.GetString("hostname", "")
+	domain := request.GetString("domain", "")
+
+	if ip == "" && subnetID == "" {
+		return mcp.NewToolResultError("set ip, or subnet to take its next free address"), nil
+	}
+
+	if mac != "" {
+		if _, err := net.ParseMAC(mac); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("invalid MAC address %s", mac)), nil
+		}
+	}
+
+	if hostname != "" {
+		if err := tools.ValidHostname(hostname); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if strings.Contains(hostname, ".") {
+			return mcp.NewToolResultError(fmt.Sprintf("invalid hostname %s, set the domain with domain", hostname)), nil
+		}
+	}
+
+	if domain != "" {
+		if err := tools.ValidHostname(domain); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+	}
+
+	if domain != "" && hostname == "" {
+		return mcp.NewToolResultError("domain is only used with hostname"), nil
+	}
+
+	client := maas_client.MustClient()
+
+	var subnet map[string]any
+	var addr netip.Addr
+	var err error
+
+	if ip != "" {
+		addr, err = netip.ParseAddr(ip)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("invalid IP address %s", ip)), nil
+		}
+	}
+
+	if subnetID != "" {
+		subnet, err = LookupSubnet(ctx, client, subnetID)
+	} else {
+		subnet, err = subnetOf(ctx, client, addr)
+	}
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to find the subnet err=%v", err)
+		zap.L().Error(fmt.Sprintf("[ReserveIP] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	cidr, _ := subnet["cidr"].(string)
+
+	if managed, _ := subnet["managed"].(bool); !managed {
+		return mcp.NewToolResultError(fmt.Sprintf("subnet %s is not managed by MAAS", cidr)), nil
+	}
+
+	var from *unreservedRange
+	if ip != "" {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil || !prefix.Contains(addr) {
+			return mcp.NewToolResultError(fmt.Sprintf("IP address %s is not in subnet %s", ip, cidr)), nil
+		}
+	} else {
+		free, source, err := nextFree(ctx, client, subnet)
+		if err != nil {
+			errMsg = fmt.Sprintf("Failed to find a free address err=%v", err)
+			zap.L().Error(fmt.Sprintf("[ReserveIP] %s", errMsg))
+			return mcp.NewToolResultError(errMsg), nil
+		}
+		addr, from = free, &source
+	}
+
+	form := make(url.Values)
+	form.Add("subnet", cidr)
+	form.Add("ip", addr.String())
+	if mac != "" {
+		form.Add("mac", mac)
+	}
+	if hostname != "" {
+		form.Add("hostname", hostname)
+	}
+	if domain != "" {
+		form.Add("domain", domain)
+	}
+
+	zap.L().Info(fmt.Sprintf("[ReserveIP] Reserving %s in subnet %s", addr, cidr))
+	resultData, err := client.Do(ctx, maas_client.RequestTypePost, "/MAAS/api/2.0/ipaddresses/op-reserve", strings.NewReader(form.Encode()))
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to reserve %s err=%v", addr, err)
+		zap.L().Error(fmt.Sprintf("[ReserveIP] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	var reserved any
+	if err := json.Unmarshal([]byte(resultData), &reserved); err != nil {
+		reserved = resultData
+	}
+
+	result := map[string]any{"reserved": reserved}
+	if from != nil {
+		result["from_range"] = from
+	}
+
+	jsonData, err := json.Marshal(result)
+	if err != nil {
+		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[ReserveIP] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+type ReleaseIP struct{}
+
+func (ReleaseIP) Create() mcp.Tool {
+	return mcp.NewTool(
+		"release_ip",
+		mcp.WithString(
+			"ip",
+			mcp.Required(),
+			mcp.Description("The reserved address to release."),
+		),
+		mcp.WithBoolean(
+			"force",
+			mcp.Description("Release the address even when another user reserved it."),
+		),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Release IP", false, true, true, true)),
+		mcp.WithDescription("Releases an address reserved with reserve_ip, making it available to MAAS again."),
+	)
+}
+
+func (ReleaseIP) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	ip, err := request.RequireString("ip")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[ReleaseIP] Required parameter ip not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("invalid IP address %s", ip)), nil
+	}
+
+	client := maas_client.MustClient()
+
+	reserved, err := loadReservedIPs(ctx, client)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to list the reserved addresses err=%v", err)
+		zap.L().Error(fmt.Sprintf("[ReleaseIP] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	found := false
+	for _, address := range reserved {
+		if other, err := netip.ParseAddr(address.IP); err == nil && other == addr {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return mcp.NewToolResultError(fmt.Sprintf("%s is not a reserved address", ip)), nil
+	}
+
+	form := make(url.Values)
+	form.Add("ip", addr.String())
+	if request.GetBool("force", false) {
+		form.Add("force", "true")
+	}
+
+	zap.L().Info(fmt.Sprintf("[ReleaseIP] Releasing %s", addr))
+	resultData, err := client.Do(ctx, maas_client.RequestTypePost, "/MAAS/api/2.0/ipaddresses/op-release", strings.NewReader(form.Encode()))
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to release %s err=%v", addr, err)
+		zap.L().Error(fmt.Sprintf("[ReleaseIP] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	jsonData, err := json.Marshal(resultData)
+	if err != nil {
+		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[ReleaseIP] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+type ListReservedIPs struct{}
+
+func (ListReservedIPs) Create() mcp.Tool {
+	return mcp.NewTool(
+		"list_reserved_ips",
+		mcp.WithString(
+			"subnet",
+			mcp.Pattern("^[0-9]*$"),
+			mcp.Description("Only list the addresses of the subnet with this ID."),
+		),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("List Reserved IPs", true, false, false, true)),
+		mcp.WithDescription("Lists the addresses reserved with reserve_ip by all users, with their subnet and owner."),
+	)
+}
+
+func (ListReservedIPs) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	subnetID := request.GetString("subnet", "")
+
+	client := maas_client.MustClient()
+
+	reserved, err := loadReservedIPs(ctx, client)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to list the reserved addresses err=%v", err)
+		zap.L().Error(fmt.Sprintf("[ListReservedIPs] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	filtered := make([]reservedIP, 0, len(reserved))
+	for _, address := range reserved {
+		if subnetID == "" || fmt.Sprint(address.Subnet.ID) == subnetID {
+			filtered = append(filtered, address)
+		}
+	}
+
+	jsonData, err := json.Marshal(filtered)
+	if err != nil {
+		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[ListReservedIPs] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	if len(filtered) == 0 {
+		return mcp.NewToolResultText(string(jsonData)), nil
+	}
+
+	var table strings.Builder
+	writer := tabwriter.NewWriter(&table, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "IP\tSUBNET\tOWNER\tCREATED")
+	for _, address := range filtered {
+		owner := "-"
+		if address.Owner != nil {
+			owner = address.Owner.Username
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", address.IP, address.Subnet.CIDR, owner, address.Created)
+	}
+	writer.Flush()
+
+	result := mcp.NewToolResultText(string(jsonData))
+	result.Content = append(result.Content, mcp.NewTextContent(table.String()))
+
+	return result, nil
+}
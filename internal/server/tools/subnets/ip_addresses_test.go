@@ -0,0 +1,80 @@
+package subnets
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/JarcauCristian/ztp-mcp/internal/server/maas_client"
+)
+
+func TestLoadReservedIPs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/MAAS/api/2.0/ipaddresses/" || r.URL.Query().Get("all") != "true" {
+			http.NotFound(w, r)
+			return
+		}
+
+		fmt.Fprint(w, `[
+			{
+				"alloc_type": 4,
+				"alloc_type_name": "User reserved",
+				"created": "2026-10-01T09:12:44.118",
+				"ip": "10.0.0.50",
+				"owner": {"is_superuser": true, "username": "admin", "email": "admin@example.com", "is_local": true, "resource_uri": "/MAAS/api/2.0/users/admin/"},
+				"subnet": {"id": 1, "cidr": "10.0.0.0/24", "name": "10.0.0.0/24", "vlan": {"id": 5001, "vid": 0}},
+				"interface_set": [],
+				"resource_uri": "/MAAS/api/2.0/ipaddresses/"
+			},
+			{
+				"alloc_type": 1,
+				"alloc_type_name": "Auto",
+				"created": "2026-10-02T10:00:00.000",
+				"ip": "10.0.0.51",
+				"owner": null,
+				"subnet": {"id": 1, "cidr": "10.0.0.0/24"},
+				"interface_set": [{"id": 7, "name": "eth0"}],
+				"resource_uri": "/MAAS/api/2.0/ipaddresses/"
+			},
+			{
+				"alloc_type": 4,
+				"alloc_type_name": "User reserved",
+				"created": "2026-10-03T11:30:00.000",
+				"ip": "2001:db8::10",
+				"owner": {"is_superuser": false, "username": "ops", "email": "", "is_local": true, "resource_uri": "/MAAS/api/2.0/users/ops/"},
+				"subnet": {"id": 2, "cidr": "2001:db8::/64"},
+				"interface_set": [],
+				"resource_uri": "/MAAS/api/2.0/ipaddresses/"
+			}
+		]`)
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv("MAAS_BASE_URL", server.URL)
+	t.Setenv("MAAS_API_KEY", "consumer:token:secret")
+
+	client, err := maas_client.NewMAASClientFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reserved, err := loadReservedIPs(context.Background(), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, address := range reserved {
+		if address.Owner == nil {
+			t.Fatalf("%s has no owner", address.IP)
+		}
+		got = append(got, fmt.Sprintf("%s %d %s %s", address.IP, address.Subnet.ID, address.Subnet.CIDR, address.Owner.Username))
+	}
+
+	want := []string{"10.0.0.50 1 10.0.0.0/24 admin", "2001:db8::10 2 2001:db8::/64 ops"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("reserved = %q, want %q", got, want)
+	}
+}
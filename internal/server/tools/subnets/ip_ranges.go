@@ -0,0 +1,493 @@
+package subnets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/JarcauCristian/ztp-mcp/internal/server/maas_client"
+	"github.com/JarcauCristian/ztp-mcp/internal/server/tools"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+)
+
+var ipRangeTypes = []string{"reserved", "dynamic"}
+
+// dynamicAllocations are the allocation types of addresses handed out by DHCP
+// or observed on the network, as opposed to static assignments.
+var dynamicAllocations = []string{"DHCP", "Discovered"}
+
+type IPRanges struct{}
+
+func (IPRanges) Register(mcpServer *server.MCPServer) {
+	mcpTools := []tools.MCPTool{CreateIPRange{}, UpdateIPRange{}, DeleteIPRange{}}
+
+	for _, tool := range mcpTools {
+		mcpServer.AddTool(tool.Create(), tool.Handle)
+	}
+}
+
+type ipRange struct {
+	ID      int    `json:"id"`
+	Type    string `json:"type"`
+	StartIP string `json:"start_ip"`
+	EndIP   string `json:"end_ip"`
+	Comment string `json:"comment"`
+	Subnet  struct {
+		ID   int    `json:"id"`
+		CIDR string `json:"cidr"`
+	} `json:"subnet"`
+}
+
+func (r ipRange) String() string {
+	return fmt.Sprintf("%s range %d (%s-%s)", r.Type, r.ID, r.StartIP, r.EndIP)
+}
+
+// bounds returns the first and last address of the range.
+func (r ipRange) bounds() (netip.Addr, netip.Addr, error) {
+	return parseBounds(r.StartIP, r.EndIP)
+}
+
+func parseBounds(startIP, endIP string) (netip.Addr, netip.Addr, error) {
+	start, err := netip.ParseAddr(startIP)
+	if err != nil {
+		return netip.Addr{}, netip.Addr{}, fmt.Errorf("invalid start IP %s", startIP)
+	}
+
+	end, err := netip.ParseAddr(endIP)
+	if err != nil {
+		return netip.Addr{}, netip.Addr{}, fmt.Errorf("invalid end IP %s", endIP)
+	}
+
+	if start.Is4() != end.Is4() {
+		return netip.Addr{}, netip.Addr{}, fmt.Errorf("%s and %s are not of the same address family", startIP, endIP)
+	}
+
+	if end.Less(start) {
+		return netip.Addr{}, netip.Addr{}, fmt.Errorf("end IP %s is before start IP %s", endIP, startIP)
+	}
+
+	return start, end, nil
+}
+
+func between(addr, start, end netip.Addr) bool {
+	return start.Compare(addr) <= 0 && addr.Compare(end) <= 0
+}
+
+func loadIPRanges(ctx context.Context, client *maas_client.MAASClient) ([]ipRange, error) {
+	resultData, err := client.Do(ctx, maas_client.RequestTypeGet, "/MAAS/api/2.0/ipranges/", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var ranges []ipRange
+	if err := json.Unmarshal([]byte(resultData), &ranges); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal IP ranges: %w", err)
+	}
+
+	return ranges, nil
+}
+
+type subnetAddress struct {
+	IP        string `json:"ip"`
+	AllocType string `json:"alloc_type_name"`
+	User      string `json:"user,omitempty"`
+}
+
+func loadSubnetAddresses(ctx context.Context, client *maas_client.MAASClient, subnetID string) ([]subnetAddress, error) {
+	path := fmt.Sprintf("/MAAS/api/2.0/subnets/%s/op-ip_addresses?with_username=1&with_summary=0", subnetID)
+
+	resultData, err := client.Do(ctx, maas_client.RequestTypeGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var addresses []subnetAddress
+	if err := json.Unmarshal([]byte(resultData), &addresses); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the addresses of subnet %s: %w", subnetID, err)
+	}
+
+	return addresses, nil
+}
+
+// rangeInSubnet parses the bounds of a range and makes sure the range is
+// inside the CIDR of the subnet, without its gateway nor, for IPv4, its
+// network and broadcast addresses.
+func rangeInSubnet(subnet map[string]any, startIP, endIP string) (netip.Addr, netip.Addr, error) {
+	start, end, err := parseBounds(startIP, endIP)
+	if err != nil {
+		return netip.Addr{}, netip.Addr{}, err
+	}
+
+	cidr, _ := subnet["cidr"].(string)
+
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return netip.Addr{}, netip.Addr{}, fmt.Errorf("subnet %v has an invalid CIDR %q: %w", subnet["id"], cidr, err)
+	}
+	prefix = prefix.Masked()
+
+	if !prefix.Contains(start) || !prefix.Contains(end) {
+		return netip.Addr{}, netip.Addr{}, fmt.Errorf("range %s-%s is not inside subnet %s", startIP, endIP, cidr)
+	}
+
+	// /31 and /32 networks have no network nor broadcast address.
+	if prefix.Addr().Is4() && prefix.Bits() < 31 {
+		if start == prefix.Addr() {
+			return netip.Addr{}, netip.Addr{}, fmt.Errorf("range %s-%s contains the network address of subnet %s", startIP, endIP, cidr)
+		}
+		if end == broadcast(prefix) {
+			return netip.Addr{}, netip.Addr{}, fmt.Errorf("range %s-%s contains the broadcast address of subnet %s", startIP, endIP, cidr)
+		}
+	}
+
+	if gatewayIP, _ := subnet["gateway_ip"].(string); gatewayIP != "" {
+		if gateway, err := netip.ParseAddr(gatewayIP); err == nil && between(gateway, start, end) {
+			return netip.Addr{}, netip.Addr{}, fmt.Errorf("range %s-%s contains the gateway %s of subnet %s", startIP, endIP, gatewayIP, cidr)
+		}
+	}
+
+	return start, end, nil
+}
+
+// broadcast returns the last address of an IPv4 prefix.
+func broadcast(prefix netip.Prefix) netip.Addr {
+	addr := prefix.Masked().Addr().As4()
+	for i := prefix.Bits(); i < 32; i++ {
+		addr[i/8] |= 1 << (7 - i%8)
+	}
+	return netip.AddrFrom4(addr)
+}
+
+// checkIPRange makes sure a range from startIP to endIP fits in the subnet: it
+// must pass rangeInSubnet, must not overlap the other ranges of the subnet and
+// must not contain statically allocated addresses. The range with the ID skip
+// is left out, so a range can be checked against its old self.
+func checkIPRange(ctx context.Context, client *maas_client.MAASClient, subnet map[string]any, startIP, endIP string, skip int) error {
+	start, end, err := rangeInSubnet(subnet, startIP, endIP)
+	if err != nil {
+		return err
+	}
+
+	subnetID := fmt.Sprint(subnet["id"])
+	cidr, _ := subnet["cidr"].(string)
+
+	ranges, err := loadIPRanges(ctx, client)
+	if err != nil {
+		return fmt.Errorf("failed to list the IP ranges: %w", err)
+	}
+
+	for _, other := range ranges {
+		if other.ID == skip || fmt.Sprint(other.Subnet.ID) != subnetID {
+			continue
+		}
+
+		otherStart, otherEnd, err := other.bounds()
+		if err != nil {
+			continue
+		}
+
+		if start.Compare(otherEnd) <= 0 && otherStart.Compare(end) <= 0 {
+			return fmt.Errorf("range %s-%s overlaps the %s", startIP, endIP, other)
+		}
+	}
+
+	addresses, err := loadSubnetAddresses(ctx, client, subnetID)
+	if err != nil {
+		return fmt.Errorf("failed to list the addresses of subnet %s: %w", cidr, err)
+	}
+
+	var static []string
+	for _, address := range addresses {
+		if slices.Contains(dynamicAllocations, address.AllocType) {
+			continue
+		}
+
+		if addr, err := netip.ParseAddr(address.IP); err == nil && between(addr, start, end) {
+			static = append(static, fmt.Sprintf("%s (%s)", address.IP, address.AllocType))
+		}
+	}
+
+	if len(static) > 0 {
+		return fmt.Errorf("range %s-%s contains allocated static addresses: %s", startIP, endIP, strings.Join(static, ", "))
+	}
+
+	return nil
+}
+
+type CreateIPRange struct{}
+
+func (CreateIPRange) Create() mcp.Tool {
+	return mcp.NewTool(
+		"create_ip_range",
+		mcp.WithString(
+			"subnet",
+			mcp.Required(),
+			mcp.Pattern("^[0-9]+$"),
+			mcp.Description("The ID of the subnet the range belongs to."),
+		),
+		mcp.WithString(
+			"type",
+			mcp.Required(),
+			mcp.Enum(ipRangeTypes...),
+			mcp.Description("reserved keeps the addresses away from MAAS, dynamic hands them out with DHCP."),
+		),
+		mcp.WithString(
+			"start_ip",
+			mcp.Required(),
+			mcp.Description("The first address of the range."),
+		),
+		mcp.WithString(
+			"end_ip",
+			mcp.Required(),
+			mcp.Description("The last address of the range."),
+		),
+		mcp.WithString(
+			"comment",
+			mcp.Description("What the range is used for."),
+		),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Create IP Range", false, false, false, true)),
+		mcp.WithDescription("Creates a reserved or dynamic IP range in a subnet. The range must be inside the subnet CIDR and must not overlap other ranges, the gateway or statically allocated addresses."),
+	)
+}
+
+func (CreateIPRange) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	subnetID, err := request.RequireString("subnet")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[CreateIPRange] Required parameter subnet not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	rangeType, err := request.RequireString("type")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[CreateIPRange] Required parameter type not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if !slices.Contains(ipRangeTypes, rangeType) {
+		return mcp.NewToolResultError(fmt.Sprintf("unknown range type %s, expected one of %s", rangeType, strings.Join(ipRangeTypes, ", "))), nil
+	}
+
+	startIP, err := request.RequireString("start_ip")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[CreateIPRange] Required parameter start_ip not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	endIP, err := request.RequireString("end_ip")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[CreateIPRange] Required parameter end_ip not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	client := maas_client.MustClient()
+
+	subnet, err := LookupSubnet(ctx, client, subnetID)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to read subnet %s err=%v", subnetID, err)
+		zap.L().Error(fmt.Sprintf("[CreateIPRange] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	if err := checkIPRange(ctx, client, subnet, startIP, endIP, 0); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Cannot create the range: %v", err)), nil
+	}
+
+	form := make(url.Values)
+	form.Add("subnet", subnetID)
+	form.Add("type", rangeType)
+	form.Add("start_ip", startIP)
+	form.Add("end_ip", endIP)
+	if comment := request.GetString("comment", ""); comment != "" {
+		form.Add("comment", comment)
+	}
+
+	zap.L().Info(fmt.Sprintf("[CreateIPRange] Creating %s range %s-%s in subnet %s", rangeType, startIP, endIP, subnetID))
+	resultData, err := client.Do(ctx, maas_client.RequestTypePost, "/MAAS/api/2.0/ipranges/", strings.NewReader(form.Encode()))
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to create the IP range err=%v", err)
+		zap.L().Error(fmt.Sprintf("[CreateIPRange] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	jsonData, err := json.Marshal(resultData)
+	if err != nil {
+		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[CreateIPRange] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+type UpdateIPRange struct{}
+
+func (UpdateIPRange) Create() mcp.Tool {
+	return mcp.NewTool(
+		"update_ip_range",
+		mcp.WithString(
+			"id",
+			mcp.Required(),
+			mcp.Pattern("^[0-9]+$"),
+			mcp.Description("The ID of the IP range to update."),
+		),
+		mcp.WithString(
+			"type",
+			mcp.Enum(ipRangeTypes...),
+			mcp.Description("The new type of the range."),
+		),
+		mcp.WithString(
+			"start_ip",
+			mcp.Description("The new first address of the range."),
+		),
+		mcp.WithString(
+			"end_ip",
+			mcp.Description("The new last address of the range."),
+		),
+		mcp.WithString(
+			"comment",
+			mcp.Description("The new comment of the range."),
+		),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Update IP Range", false, false, true, true)),
+		mcp.WithDescription("Changes the type, bounds or comment of an IP range. The range is checked with its resulting bounds like in create_ip_range, ignoring the range itself."),
+	)
+}
+
+func (UpdateIPRange) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	rangeID, err := request.RequireString("id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[UpdateIPRange] Required parameter id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	client := maas_client.MustClient()
+
+	ranges, err := loadIPRanges(ctx, client)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to list the IP ranges err=%v", err)
+		zap.L().Error(fmt.Sprintf("[UpdateIPRange] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	index := slices.IndexFunc(ranges, func(r ipRange) bool { return fmt.Sprint(r.ID) == rangeID })
+	if index < 0 {
+		return mcp.NewToolResultError(fmt.Sprintf("IP range %s not found", rangeID)), nil
+	}
+	current := ranges[index]
+
+	form := make(url.Values)
+
+	if rangeType := request.GetString("type", ""); rangeType != "" {
+		if !slices.Contains(ipRangeTypes, rangeType) {
+			return mcp.NewToolResultError(fmt.Sprintf("unknown range type %s, expected one of %s", rangeType, strings.Join(ipRangeTypes, ", "))), nil
+		}
+		form.Add("type", rangeType)
+	}
+
+	startIP := request.GetString("start_ip", "")
+	endIP := request.GetString("end_ip", "")
+
+	if startIP != "" {
+		form.Add("start_ip", startIP)
+	} else {
+		startIP = current.StartIP
+	}
+	if endIP != "" {
+		form.Add("end_ip", endIP)
+	} else {
+		endIP = current.EndIP
+	}
+
+	if comment := request.GetString("comment", ""); comment != "" {
+		form.Add("comment", comment)
+	}
+
+	if len(form) == 0 {
+		return mcp.NewToolResultError("nothing to update, set type, start_ip, end_ip or comment"), nil
+	}
+
+	// The resulting range is checked whatever changes, the type included.
+	subnetID := fmt.Sprint(current.Subnet.ID)
+
+	subnet, err := LookupSubnet(ctx, client, subnetID)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to read subnet %s err=%v", subnetID, err)
+		zap.L().Error(fmt.Sprintf("[UpdateIPRange] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	if err := checkIPRange(ctx, client, subnet, startIP, endIP, current.ID); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Cannot update the %s: %v", current, err)), nil
+	}
+
+	zap.L().Info(fmt.Sprintf("[UpdateIPRange] Updating IP range %s", rangeID))
+	resultData, err := client.Do(ctx, maas_client.RequestTypePut, "/MAAS/api/2.0/ipranges/"+rangeID+"/", strings.NewReader(form.Encode()))
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to update IP range %s err=%v", rangeID, err)
+		zap.L().Error(fmt.Sprintf("[UpdateIPRange] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	jsonData, err := json.Marshal(resultData)
+	if err != nil {
+		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[UpdateIPRange] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+type DeleteIPRange struct{}
+
+func (DeleteIPRange) Create() mcp.Tool {
+	return mcp.NewTool(
+		"delete_ip_range",
+		mcp.WithString(
+			"id",
+			mcp.Required(),
+			mcp.Pattern("^[0-9]+$"),
+			mcp.Description("The ID of the IP range to delete."),
+		),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Delete IP Range", false, true, false, true)),
+		mcp.WithDescription("Deletes an IP range, returning its addresses to the subnet."),
+	)
+}
+
+func (DeleteIPRange) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	rangeID, err := request.RequireString("id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[DeleteIPRange] Required parameter id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	client := maas_client.MustClient()
+
+	zap.L().Info(fmt.Sprintf("[DeleteIPRange] Deleting IP range %s", rangeID))
+	resultData, err := client.Do(ctx, maas_client.RequestTypeDelete, "/MAAS/api/2.0/ipranges/"+rangeID+"/", nil)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to delete IP range %s err=%v", rangeID, err)
+		zap.L().Error(fmt.Sprintf("[DeleteIPRange] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	jsonData, err := json.Marshal(resultData)
+	if err != nil {
+		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[DeleteIPRange] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
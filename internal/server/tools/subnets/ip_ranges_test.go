@@ -0,0 +1,56 @@
+package subnets
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestRangeInSubnet(t *testing.T) {
+	subnet := map[string]any{"id": 1, "cidr": "10.0.0.0/24", "gateway_ip": "10.0.0.1"}
+
+	tests := []struct {
+		name   string
+		subnet map[string]any
+		start  string
+		end    string
+		err    string
+	}{
+		{name: "inside", subnet: subnet, start: "10.0.0.10", end: "10.0.0.254"},
+		{name: "network address", subnet: subnet, start: "10.0.0.0", end: "10.0.0.0", err: "network address"},
+		{name: "broadcast address", subnet: subnet, start: "10.0.0.200", end: "10.0.0.255", err: "broadcast address"},
+		{name: "gateway", subnet: subnet, start: "10.0.0.1", end: "10.0.0.20", err: "gateway"},
+		{name: "outside", subnet: subnet, start: "10.0.0.200", end: "10.0.1.10", err: "not inside"},
+		{name: "reversed", subnet: subnet, start: "10.0.0.20", end: "10.0.0.10", err: "before"},
+		{name: "mixed families", subnet: subnet, start: "10.0.0.10", end: "fd00::1", err: "family"},
+		{name: "point to point", subnet: map[string]any{"id": 2, "cidr": "10.0.1.0/31"}, start: "10.0.1.0", end: "10.0.1.1"},
+		{name: "IPv6 last address", subnet: map[string]any{"id": 3, "cidr": "fd00::/120"}, start: "fd00::", end: "fd00::ff"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, err := rangeInSubnet(test.subnet, test.start, test.end)
+			switch {
+			case test.err == "" && err != nil:
+				t.Errorf("rangeInSubnet = %v, want nil", err)
+			case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+				t.Errorf("rangeInSubnet = %v, want an error about %s", err, test.err)
+			}
+		})
+	}
+}
+
+func TestBroadcast(t *testing.T) {
+	tests := map[string]string{
+		"10.0.0.0/24":    "10.0.0.255",
+		"10.0.0.0/8":     "10.255.255.255",
+		"192.168.4.0/22": "192.168.7.255",
+		"172.16.5.64/26": "172.16.5.127",
+	}
+
+	for cidr, want := range tests {
+		if got := broadcast(netip.MustParsePrefix(cidr)); got.String() != want {
+			t.Errorf("broadcast(%s) = %s, want %s", cidr, got, want)
+		}
+	}
+}
@@ -0,0 +1,511 @@
+package subnets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"math/bits"
+	"net/netip"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/JarcauCristian/ztp-mcp/internal/server/maas_client"
+	"github.com/JarcauCristian/ztp-mcp/internal/server/tools"
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.uber.org/zap"
+)
+
+const (
+	maxProposals = 16
+	// maxReserved caps the reserved range at the start of a proposed subnet.
+	maxReserved = 254
+)
+
+// addrInt and intAddr convert between addresses and integers, so blocks of
+// both families can be walked with the same arithmetic.
+func addrInt(addr netip.Addr) *big.Int {
+	return new(big.Int).SetBytes(addr.AsSlice())
+}
+
+func intAddr(n *big.Int, is4 bool) (netip.Addr, bool) {
+	size := 16
+	if is4 {
+		size = 4
+	}
+
+	if n.Sign() < 0 || n.BitLen() > size*8 {
+		return netip.Addr{}, false
+	}
+
+	return netip.AddrFromSlice(n.FillBytes(make([]byte, size)))
+}
+
+// blockSize returns the number of addresses in the prefix.
+func blockSize(prefix netip.Prefix) *big.Int {
+	return new(big.Int).Lsh(big.NewInt(1), uint(prefix.Addr().BitLen()-prefix.Bits()))
+}
+
+// lastAddr returns the last address of the prefix as an integer.
+func lastAddr(prefix netip.Prefix) *big.Int {
+	last := addrInt(prefix.Masked().Addr())
+	last.Add(last, blockSize(prefix))
+	return last.Sub(last, big.NewInt(1))
+}
+
+// offsetAddr returns the address n addresses after the start of the prefix.
+func offsetAddr(prefix netip.Prefix, n *big.Int) string {
+	addr, _ := intAddr(new(big.Int).Add(addrInt(prefix.Masked().Addr()), n), prefix.Addr().Is4())
+	return addr.String()
+}
+
+// prefixForHosts returns the longest prefix of the family with room for the
+// hosts, their gateway and the network address, plus the broadcast address
+// for IPv4. IPv6 prefixes are never longer than /64.
+func prefixForHosts(hosts int, is4 bool) int {
+	if is4 {
+		return 32 - bits.Len(uint(hosts+2))
+	}
+	return min(128-bits.Len(uint(hosts+1)), 64)
+}
+
+type addressRange struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+type subnetProposal struct {
+	CIDR         string            `json:"cidr"`
+	Addresses    string            `json:"addresses"`
+	Gateway      string            `json:"gateway,omitempty"`
+	Reserved     *addressRange     `json:"reserved,omitempty"`
+	Dynamic      *addressRange     `json:"dynamic,omitempty"`
+	CreateSubnet map[string]string `json:"create_subnet"`
+}
+
+// layout proposes the gateway and ranges of a new subnet: the gateway is the
+// first address, followed by a reserved range of a sixteenth of the subnet
+// for infrastructure and VIPs, and the last quarter is the dynamic range.
+func layout(prefix netip.Prefix) subnetProposal {
+	size := blockSize(prefix)
+	is4 := prefix.Addr().Is4()
+
+	proposal := subnetProposal{
+		CIDR:      prefix.String(),
+		Addresses: size.String(),
+	}
+
+	if size.Cmp(big.NewInt(4)) < 0 {
+		return proposal
+	}
+
+	proposal.Gateway = offsetAddr(prefix, big.NewInt(1))
+
+	if size.Cmp(big.NewInt(8)) < 0 {
+		return proposal
+	}
+
+	reserved := new(big.Int).Rsh(size, 4)
+	if reserved.Sign() == 0 {
+		reserved.SetInt64(1)
+	}
+	if reserved.Cmp(big.NewInt(maxReserved)) > 0 {
+		reserved.SetInt64(maxReserved)
+	}
+
+	proposal.Reserved = &addressRange{
+		Start: offsetAddr(prefix, big.NewInt(2)),
+		End:   offsetAddr(prefix, reserved.Add(reserved, big.NewInt(1))),
+	}
+
+	// The broadcast address of IPv4 subnets can not be handed out.
+	end := new(big.Int).Sub(size, big.NewInt(1))
+	if is4 {
+		end.Sub(end, big.NewInt(1))
+	}
+
+	proposal.Dynamic = &addressRange{
+		Start: offsetAddr(prefix, new(big.Int).Sub(size, new(big.Int).Rsh(size, 2))),
+		End:   offsetAddr(prefix, end),
+	}
+
+	return proposal
+}
+
+type knownSubnet struct {
+	ID       string
+	CIDR     string
+	Fabric   string
+	FabricID string
+	VID      string
+	Prefix   netip.Prefix
+}
+
+func knownSubnets(subnets []map[string]any) []knownSubnet {
+	known := make([]knownSubnet, 0, len(subnets))
+	for _, subnet := range subnets {
+		cidr, _ := subnet["cidr"].(string)
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			continue
+		}
+
+		entry := knownSubnet{
+			ID:     fmt.Sprint(subnet["id"]),
+			CIDR:   cidr,
+			Prefix: prefix.Masked(),
+		}
+
+		if vlan, ok := subnet["vlan"].(map[string]any); ok {
+			entry.Fabric, _ = vlan["fabric"].(string)
+			entry.FabricID = fmt.Sprint(vlan["fabric_id"])
+			entry.VID = fmt.Sprint(vlan["vid"])
+		}
+
+		known = append(known, entry)
+	}
+	return known
+}
+
+// planSubnets walks the supernet in blocks of the given prefix length and
+// returns up to count blocks that overlap none of the known subnets. Blocks
+// overlapping a subnet are skipped up to the end of that subnet.
+func planSubnets(supernet netip.Prefix, prefixLen, count int, known []knownSubnet) []netip.Prefix {
+	is4 := supernet.Addr().Is4()
+	size := new(big.Int).Lsh(big.NewInt(1), uint(supernet.Addr().BitLen()-prefixLen))
+	end := lastAddr(supernet)
+
+	var planned []netip.Prefix
+	cursor := addrInt(supernet.Masked().Addr())
+
+	for len(planned) < count {
+		blockEnd := new(big.Int).Add(cursor, size)
+		blockEnd.Sub(blockEnd, big.NewInt(1))
+		if blockEnd.Cmp(end) > 0 {
+			break
+		}
+
+		start, _ := intAddr(cursor, is4)
+		block := netip.PrefixFrom(start, prefixLen)
+
+		var conflictEnd *big.Int
+		for _, subnet := range known {
+			if !subnet.Prefix.Overlaps(block) {
+				continue
+			}
+			if last := lastAddr(subnet.Prefix); conflictEnd == nil || last.Cmp(conflictEnd) > 0 {
+				conflictEnd = last
+			}
+		}
+
+		if conflictEnd == nil {
+			planned = append(planned, block)
+			cursor = blockEnd.Add(blockEnd, big.NewInt(1))
+			continue
+		}
+
+		// Continue at the first block after the conflicting subnet.
+		next := conflictEnd.Add(conflictEnd, big.NewInt(1))
+		next.Add(next, new(big.Int).Sub(size, big.NewInt(1)))
+		next.Div(next, size)
+		cursor = next.Mul(next, size)
+	}
+
+	return planned
+}
+
+type ipamFinding struct {
+	Kind   string   `json:"kind"`
+	Items  []string `json:"items"`
+	Detail string   `json:"detail"`
+}
+
+type subnetStatistics struct {
+	TotalAddresses   json.Number `json:"total_addresses"`
+	NumAvailable     json.Number `json:"num_available"`
+	NumUnavailable   json.Number `json:"num_unavailable"`
+	LargestAvailable json.Number `json:"largest_available"`
+	Usage            float64     `json:"usage"`
+	UsageString      string      `json:"usage_string"`
+}
+
+func loadStatistics(ctx context.Context, client *maas_client.MAASClient, subnetID string) (subnetStatistics, error) {
+	resultData, err := client.Do(ctx, maas_client.RequestTypeGet, "/MAAS/api/2.0/subnets/"+subnetID+"/op-statistics", nil)
+	if err != nil {
+		return subnetStatistics{}, err
+	}
+
+	var statistics subnetStatistics
+	if err := json.Unmarshal([]byte(resultData), &statistics); err != nil {
+		return subnetStatistics{}, fmt.Errorf("failed to unmarshal the statistics of subnet %s: %w", subnetID, err)
+	}
+
+	return statistics, nil
+}
+
+// auditIPAM reports overlapping subnets, overlapping IP ranges and subnets
+// whose usage reached the threshold, in percent.
+func auditIPAM(ctx context.Context, client *maas_client.MAASClient, known []knownSubnet, threshold float64) ([]ipamFinding, error) {
+	var findings []ipamFinding
+
+	for i, subnet := range known {
+		for _, other := range known[i+1:] {
+			if subnet.Prefix.Overlaps(other.Prefix) {
+				findings = append(findings, ipamFinding{
+					Kind:   "overlapping_subnets",
+					Items:  []string{subnet.CIDR, other.CIDR},
+					Detail: fmt.Sprintf("subnet %s (id %s) overlaps subnet %s (id %s)", subnet.CIDR, subnet.ID, other.CIDR, other.ID),
+				})
+			}
+		}
+	}
+
+	ranges, err := loadIPRanges(ctx, client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the IP ranges: %w", err)
+	}
+
+	for i, r := range ranges {
+		start, end, err := r.bounds()
+		if err != nil {
+			continue
+		}
+
+		for _, other := range ranges[i+1:] {
+			otherStart, otherEnd, err := other.bounds()
+			if err != nil || start.Is4() != otherStart.Is4() {
+				continue
+			}
+
+			if start.Compare(otherEnd) <= 0 && otherStart.Compare(end) <= 0 {
+				findings = append(findings, ipamFinding{
+					Kind:   "overlapping_ranges",
+					Items:  []string{fmt.Sprint(r.ID), fmt.Sprint(other.ID)},
+					Detail: fmt.Sprintf("%s of subnet %s overlaps %s of subnet %s", r, r.Subnet.CIDR, other, other.Subnet.CIDR),
+				})
+			}
+		}
+	}
+
+	for _, subnet := range known {
+		statistics, err := loadStatistics(ctx, client, subnet.ID)
+		if err != nil {
+			findings = append(findings, ipamFinding{
+				Kind:   "statistics_failed",
+				Items:  []string{subnet.CIDR},
+				Detail: err.Error(),
+			})
+			continue
+		}
+
+		if statistics.Usage*100 >= threshold {
+			findings = append(findings, ipamFinding{
+				Kind:   "exhausted_subnet",
+				Items:  []string{subnet.CIDR},
+				Detail: fmt.Sprintf("subnet %s (id %s) is %s used, %s of %s addresses available", subnet.CIDR, subnet.ID, statistics.UsageString, statistics.NumAvailable, statistics.TotalAddresses),
+			})
+		}
+	}
+
+	return findings, nil
+}
+
+type IPAMPlan struct{}
+
+func (IPAMPlan) Create() mcp.Tool {
+	return mcp.NewTool(
+		"ipam_plan",
+		mcp.WithString(
+			"mode",
+			mcp.Enum("plan", "audit"),
+			mcp.DefaultString("plan"),
+			mcp.Description("plan proposes new subnets inside supernet, audit checks the existing subnets and ranges of the whole MAAS."),
+		),
+		mcp.WithString(
+			"supernet",
+			mcp.Description("plan: the CIDR to carve the new subnets from, for example 10.20.0.0/16."),
+		),
+		mcp.WithNumber(
+			"prefix_length",
+			mcp.Min(0),
+			mcp.Max(128),
+			mcp.Description("plan: the prefix length of the new subnets."),
+		),
+		mcp.WithNumber(
+			"hosts",
+			mcp.Min(1),
+			mcp.Description("plan: the number of hosts the new subnets must hold, used instead of prefix_length."),
+		),
+		mcp.WithNumber(
+			"count",
+			mcp.Min(1),
+			mcp.Max(maxProposals),
+			mcp.Description("plan: how many subnets to propose. Defaults to 1."),
+		),
+		mcp.WithString(
+			"fabric",
+			mcp.Description("plan: the fabric the subnets will be created on."),
+		),
+		mcp.WithString(
+			"vid",
+			mcp.Pattern("^[0-9]*$"),
+			mcp.Description("plan: the VLAN ID the subnets will be created in."),
+		),
+		mcp.WithNumber(
+			"exhausted_threshold",
+			mcp.Min(0),
+			mcp.Max(100),
+			mcp.Description("audit: usage in percent from which a subnet is reported as exhausted. Defaults to 95."),
+		),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("IPAM Plan", true, false, false, true)),
+		mcp.WithDescription("Helps choose the CIDR of a new subnet before create_subnet. In plan mode it proposes CIDRs inside a supernet that overlap no existing subnet, each with a gateway, reserved and dynamic range layout and the create_subnet arguments. In audit mode it reports overlapping subnets, IP ranges that overlap each other and exhausted subnets across the whole MAAS."),
+	)
+}
+
+func (IPAMPlan) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	client := maas_client.MustClient()
+
+	subnets, err := loadSubnets(ctx, client)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to retrieve all the subnets: %v", err)
+		zap.L().Error(fmt.Sprintf("[IPAMPlan] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	known := knownSubnets(subnets)
+
+	if request.GetString("mode", "plan") == "audit" {
+		findings, err := auditIPAM(ctx, client, known, request.GetFloat("exhausted_threshold", 95))
+		if err != nil {
+			errMsg = fmt.Sprintf("Failed to audit the subnets: %v", err)
+			zap.L().Error(fmt.Sprintf("[IPAMPlan] %s", errMsg))
+			return mcp.NewToolResultError(errMsg), nil
+		}
+
+		jsonData, err := json.Marshal(map[string]any{"subnets": len(known), "findings": findings})
+		if err != nil {
+			errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+			zap.L().Error(fmt.Sprintf("[IPAMPlan] %s", errMsg))
+			return mcp.NewToolResultError(errMsg), nil
+		}
+
+		result := mcp.NewToolResultText(string(jsonData))
+		if len(findings) == 0 {
+			result.Content = append(result.Content, mcp.NewTextContent("No overlapping or exhausted subnets found."))
+			return result, nil
+		}
+
+		var table strings.Builder
+		writer := tabwriter.NewWriter(&table, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "KIND\tDETAIL")
+		for _, finding := range findings {
+			fmt.Fprintf(writer, "%s\t%s\n", finding.Kind, finding.Detail)
+		}
+		writer.Flush()
+
+		result.Content = append(result.Content, mcp.NewTextContent(table.String()))
+		return result, nil
+	}
+
+	supernetCIDR := request.GetString("supernet", "")
+	if supernetCIDR == "" {
+		return mcp.NewToolResultError("supernet is required in plan mode"), nil
+	}
+
+	supernet, err := netip.ParsePrefix(supernetCIDR)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("invalid supernet %s", supernetCIDR)), nil
+	}
+	supernet = supernet.Masked()
+
+	prefixLen := request.GetInt("prefix_length", 0)
+	if hosts := request.GetInt("hosts", 0); hosts > 0 {
+		prefixLen = prefixForHosts(hosts, supernet.Addr().Is4())
+	}
+
+	switch {
+	case prefixLen == 0:
+		return mcp.NewToolResultError("set prefix_length or hosts"), nil
+	case prefixLen < supernet.Bits():
+		return mcp.NewToolResultError(fmt.Sprintf("a /%d does not fit in supernet %s", prefixLen, supernet)), nil
+	case prefixLen > supernet.Addr().BitLen():
+		return mcp.NewToolResultError(fmt.Sprintf("prefix length %d is too long for supernet %s", prefixLen, supernet)), nil
+	}
+
+	count := min(max(request.GetInt("count", 1), 1), maxProposals)
+	fabric := request.GetString("fabric", "")
+	vid := request.GetString("vid", "")
+
+	var notes []string
+	for _, subnet := range known {
+		if supernet.Overlaps(subnet.Prefix) && subnet.Prefix.Bits() <= supernet.Bits() {
+			notes = append(notes, fmt.Sprintf("supernet %s is inside existing subnet %s", supernet, subnet.CIDR))
+		}
+		if fabric != "" && vid != "" && (subnet.Fabric == fabric || subnet.FabricID == fabric) && subnet.VID == vid && subnet.Prefix.Addr().Is4() == supernet.Addr().Is4() {
+			notes = append(notes, fmt.Sprintf("VLAN %s on fabric %s already carries subnet %s", vid, fabric, subnet.CIDR))
+		}
+	}
+
+	planned := planSubnets(supernet, prefixLen, count, known)
+	switch {
+	case len(planned) == 0:
+		notes = append(notes, fmt.Sprintf("no free /%d block left in %s", prefixLen, supernet))
+	case len(planned) < count:
+		notes = append(notes, fmt.Sprintf("only %d free /%d blocks left in %s", len(planned), prefixLen, supernet))
+	}
+
+	proposals := make([]subnetProposal, 0, len(planned))
+	for _, prefix := range planned {
+		proposal := layout(prefix)
+
+		proposal.CreateSubnet = map[string]string{"cidr": proposal.CIDR}
+		if proposal.Gateway != "" {
+			proposal.CreateSubnet["gateway_ip"] = proposal.Gateway
+		}
+		if fabric != "" {
+			proposal.CreateSubnet["fabric"] = fabric
+		}
+		if vid != "" {
+			proposal.CreateSubnet["vid"] = vid
+		}
+
+		proposals = append(proposals, proposal)
+	}
+
+	jsonData, err := json.Marshal(map[string]any{
+		"supernet":      supernet.String(),
+		"prefix_length": prefixLen,
+		"proposals":     proposals,
+		"notes":         notes,
+	})
+	if err != nil {
+		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[IPAMPlan] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	var table strings.Builder
+	writer := tabwriter.NewWriter(&table, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "CIDR\tGATEWAY\tRESERVED\tDYNAMIC")
+	for _, proposal := range proposals {
+		reserved, dynamic := "-", "-"
+		if proposal.Reserved != nil {
+			reserved = proposal.Reserved.Start + "-" + proposal.Reserved.End
+		}
+		if proposal.Dynamic != nil {
+			dynamic = proposal.Dynamic.Start + "-" + proposal.Dynamic.End
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", proposal.CIDR, proposal.Gateway, reserved, dynamic)
+	}
+	for _, note := range notes {
+		fmt.Fprintf(writer, "note: %s\n", note)
+	}
+	writer.Flush()
+
+	result := mcp.NewToolResultText(string(jsonData))
+	result.Content = append(result.Content, mcp.NewTextContent(table.String()))
+
+	return result, nil
+}
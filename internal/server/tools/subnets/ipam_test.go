@@ -0,0 +1,172 @@
+package subnets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/JarcauCristian/ztp-mcp/internal/server/maas_client"
+)
+
+func TestPrefixForHosts(t *testing.T) {
+	tests := []struct {
+		hosts int
+		is4   bool
+		want  int
+	}{
+		{1, true, 30},
+		{2, true, 29},
+		{5, true, 29},
+		{6, true, 28},
+		{253, true, 24},
+		{254, true, 23},
+		{1000, true, 22},
+		{10, false, 64},
+		{1 << 20, false, 64},
+	}
+
+	for _, test := range tests {
+		if got := prefixForHosts(test.hosts, test.is4); got != test.want {
+			t.Errorf("prefixForHosts(%d, %t) = %d, want %d", test.hosts, test.is4, got, test.want)
+		}
+	}
+}
+
+func testKnown(cidrs ...string) []knownSubnet {
+	known := make([]knownSubnet, 0, len(cidrs))
+	for i, cidr := range cidrs {
+		known = append(known, knownSubnet{ID: fmt.Sprint(i + 1), CIDR: cidr, Prefix: netip.MustParsePrefix(cidr).Masked()})
+	}
+	return known
+}
+
+func TestPlanSubnets(t *testing.T) {
+	tests := []struct {
+		name      string
+		supernet  string
+		prefixLen int
+		count     int
+		known     []knownSubnet
+		want      string
+	}{
+		{
+			name:     "empty supernet",
+			supernet: "10.0.0.0/16", prefixLen: 24, count: 3,
+			want: "[10.0.0.0/24 10.0.1.0/24 10.0.2.0/24]",
+		},
+		{
+			name:     "skips used blocks",
+			supernet: "10.0.0.0/16", prefixLen: 24, count: 2,
+			known: testKnown("10.0.0.0/24", "10.0.1.128/25"),
+			want:  "[10.0.2.0/24 10.0.3.0/24]",
+		},
+		{
+			name:     "skips past a larger subnet",
+			supernet: "10.0.0.0/16", prefixLen: 26, count: 2,
+			known: testKnown("10.0.0.0/23"),
+			want:  "[10.0.2.0/26 10.0.2.64/26]",
+		},
+		{
+			name:     "subnet containing the supernet",
+			supernet: "10.0.0.0/16", prefixLen: 24, count: 2,
+			known: testKnown("10.0.0.0/8"),
+			want:  "[]",
+		},
+		{
+			name:     "stops at the end of the supernet",
+			supernet: "192.168.0.0/24", prefixLen: 26, count: 10,
+			known: testKnown("192.168.0.64/26"),
+			want:  "[192.168.0.0/26 192.168.0.128/26 192.168.0.192/26]",
+		},
+		{
+			name:     "IPv6",
+			supernet: "fd00:10::/56", prefixLen: 64, count: 2,
+			known: testKnown("fd00:10::/64"),
+			want:  "[fd00:10:0:1::/64 fd00:10:0:2::/64]",
+		},
+		{
+			name:     "end of the address space",
+			supernet: "255.255.255.0/24", prefixLen: 25, count: 4,
+			want: "[255.255.255.0/25 255.255.255.128/25]",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := planSubnets(netip.MustParsePrefix(test.supernet), test.prefixLen, test.count, test.known)
+			if fmt.Sprint(got) != test.want {
+				t.Errorf("planSubnets = %v, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestLayout(t *testing.T) {
+	proposal := layout(netip.MustParsePrefix("10.0.0.0/24"))
+
+	if proposal.Gateway != "10.0.0.1" {
+		t.Errorf("gateway = %s, want 10.0.0.1", proposal.Gateway)
+	}
+	if proposal.Reserved == nil || *proposal.Reserved != (addressRange{Start: "10.0.0.2", End: "10.0.0.17"}) {
+		t.Errorf("reserved = %+v, want 10.0.0.2-10.0.0.17", proposal.Reserved)
+	}
+	if proposal.Dynamic == nil || *proposal.Dynamic != (addressRange{Start: "10.0.0.192", End: "10.0.0.254"}) {
+		t.Errorf("dynamic = %+v, want 10.0.0.192-10.0.0.254", proposal.Dynamic)
+	}
+
+	if tiny := layout(netip.MustParsePrefix("10.0.0.0/31")); tiny.Gateway != "" || tiny.Reserved != nil {
+		t.Errorf("a /31 got a layout %+v", tiny)
+	}
+}
+
+func TestAuditIPAM(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/MAAS/api/2.0/ipranges/":
+			json.NewEncoder(w).Encode([]map[string]any{
+				{"id": 1, "type": "dynamic", "start_ip": "10.0.0.100", "end_ip": "10.0.0.150", "subnet": map[string]any{"id": 1, "cidr": "10.0.0.0/24"}},
+				{"id": 2, "type": "reserved", "start_ip": "10.0.0.150", "end_ip": "10.0.0.160", "subnet": map[string]any{"id": 1, "cidr": "10.0.0.0/24"}},
+				{"id": 3, "type": "reserved", "start_ip": "10.0.0.200", "end_ip": "10.0.0.210", "subnet": map[string]any{"id": 1, "cidr": "10.0.0.0/24"}},
+			})
+		case "/MAAS/api/2.0/subnets/1/op-statistics":
+			fmt.Fprint(w, `{"total_addresses": 254, "num_available": 4, "usage": 0.98, "usage_string": "98%"}`)
+		case "/MAAS/api/2.0/subnets/2/op-statistics":
+			fmt.Fprint(w, `{"total_addresses": 126, "num_available": 100, "usage": 0.2, "usage_string": "20%"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv("MAAS_BASE_URL", server.URL)
+	t.Setenv("MAAS_API_KEY", "consumer:token:secret")
+
+	client, err := maas_client.NewMAASClientFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	findings, err := auditIPAM(context.Background(), client, testKnown("10.0.0.0/24", "10.0.0.128/25", "10.1.0.0/24"), 90)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, finding := range findings {
+		got = append(got, fmt.Sprintf("%s %v", finding.Kind, finding.Items))
+	}
+
+	want := []string{
+		"overlapping_subnets [10.0.0.0/24 10.0.0.128/25]",
+		"overlapping_ranges [1 2]",
+		"exhausted_subnet [10.0.0.0/24]",
+		"statistics_failed [10.1.0.0/24]",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("findings = %q, want %q", got, want)
+	}
+}
@@ -57,12 +57,10 @@ func (ReadSubnet) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp
 		return mcp.NewToolResultError(err.Error()), nil
 	}
 
-	path := "/MAAS/api/2.0/subnets/" + subnetID + "/"
-
 	client := maas_client.MustClient()
 
 	zap.L().Info(fmt.Sprintf("[ReadSubnet] Retrieving subnet with ID: %s", subnetID))
-	resultData, err := client.Do(ctx, maas_client.RequestTypeGet, path, nil)
+	resultData, err := readSubnet(ctx, client, subnetID)
 	if err != nil {
 		errMsg = fmt.Sprintf("Failed to read subnet %s err=%v", subnetID, err)
 		zap.L().Error(fmt.Sprintf("[ReadSubnet] %s", errMsg))
@@ -79,6 +77,26 @@ func (ReadSubnet) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp
 	return mcp.NewToolResultText(string(jsonData)), nil
 }
 
+func readSubnet(ctx context.Context, client *maas_client.MAASClient, subnetID string) (string, error) {
+	return client.Do(ctx, maas_client.RequestTypeGet, "/MAAS/api/2.0/subnets/"+subnetID+"/", nil)
+}
+
+// LookupSubnet returns the subnet with the given ID, including its CIDR and
+// the VLAN it belongs to.
+func LookupSubnet(ctx context.Context, client *maas_client.MAASClient, subnetID string) (map[string]any, error) {
+	resultData, err := readSubnet(ctx, client, subnetID)
+	if err != nil {
+		return nil, err
+	}
+
+	var subnet map[string]any
+	if err := json.Unmarshal([]byte(resultData), &subnet); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal subnet %s: %w", subnetID, err)
+	}
+
+	return subnet, nil
+}
+
 type UpdateSubnet struct{}
 
 func (UpdateSubnet) Create() mcp.Tool {
@@ -17,7 +17,7 @@ import (
 type Subnets struct{}
 
 func (Subnets) Register(mcpServer *server.MCPServer) {
-	mcpTools := []tools.MCPTool{ListSubnets{}, CreateSubnet{}}
+	mcpTools := []tools.MCPTool{ListSubnets{}, CreateSubnet{}, IPAMPlan{}, SubnetUtilisationReport{}}
 
 	for _, tool := range mcpTools {
 		mcpServer.AddTool(tool.Create(), tool.Handle)
@@ -59,6 +59,20 @@ func (ListSubnets) Handle(ctx context.Context, request mcp.CallToolRequest) (*mc
 	return mcp.NewToolResultText(string(jsonData)), nil
 }
 
+func loadSubnets(ctx context.Context, client *maas_client.MAASClient) ([]map[string]any, error) {
+	resultData, err := client.Do(ctx, maas_client.RequestTypeGet, "/MAAS/api/2.0/subnets/", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the subnets: %w", err)
+	}
+
+	var subnets []map[string]any
+	if err := json.Unmarshal([]byte(resultData), &subnets); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the subnets: %w", err)
+	}
+
+	return subnets, nil
+}
+
 type CreateSubnet struct{}
 
 func (CreateSubnet) Create() mcp.Tool {
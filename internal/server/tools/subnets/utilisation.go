@@ -0,0 +1,305 @@
+package subnets
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"net/netip"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/JarcauCristian/ztp-mcp/internal/server/maas_client"
+	"github.com/JarcauCristian/ztp-mcp/internal/server/tools"
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.uber.org/zap"
+)
+
+type dynamicRanges struct {
+	Addresses string  `json:"addresses"`
+	Used      int     `json:"used"`
+	Usage     float64 `json:"usage_percent"`
+}
+
+type subnetUtilisation struct {
+	ID          string         `json:"id"`
+	CIDR        string         `json:"cidr"`
+	Fabric      string         `json:"fabric"`
+	VID         string         `json:"vid"`
+	Space       string         `json:"space,omitempty"`
+	Total       string         `json:"total_addresses"`
+	Free        string         `json:"free_addresses"`
+	LargestFree string         `json:"largest_free_block"`
+	Usage       float64        `json:"usage_percent"`
+	Dynamic     *dynamicRanges `json:"dynamic,omitempty"`
+	Alerts      []string       `json:"alerts,omitempty"`
+	Error       string         `json:"error,omitempty"`
+}
+
+func percent(value float64) float64 {
+	return math.Round(value*1000) / 10
+}
+
+// dynamicFill counts the addresses of the dynamic ranges of the subnet and how
+// many of them are in use.
+func dynamicFill(ranges []ipRange, addresses []subnetAddress, subnetID string) (*big.Int, int) {
+	size := new(big.Int)
+	used := 0
+
+	for _, r := range ranges {
+		if r.Type != "dynamic" || fmt.Sprint(r.Subnet.ID) != subnetID {
+			continue
+		}
+
+		start, end, err := r.bounds()
+		if err != nil {
+			continue
+		}
+
+		size.Add(size, new(big.Int).Sub(addrInt(end), addrInt(start)))
+		size.Add(size, big.NewInt(1))
+
+		for _, address := range addresses {
+			if addr, err := netip.ParseAddr(address.IP); err == nil && between(addr, start, end) {
+				used++
+			}
+		}
+	}
+
+	return size, used
+}
+
+func utilisation(ctx context.Context, client *maas_client.MAASClient, subnet knownSubnet, space string, ranges []ipRange) subnetUtilisation {
+	row := subnetUtilisation{
+		ID:     subnet.ID,
+		CIDR:   subnet.CIDR,
+		Fabric: subnet.Fabric,
+		VID:    subnet.VID,
+		Space:  space,
+	}
+
+	statistics, err := loadStatistics(ctx, client, subnet.ID)
+	if err != nil {
+		row.Error = err.Error()
+		return row
+	}
+
+	row.Total = statistics.TotalAddresses.String()
+	row.Free = statistics.NumAvailable.String()
+	row.LargestFree = statistics.LargestAvailable.String()
+	row.Usage = percent(statistics.Usage)
+
+	addresses, err := loadSubnetAddresses(ctx, client, subnet.ID)
+	if err != nil {
+		row.Error = err.Error()
+		return row
+	}
+
+	size, used := dynamicFill(ranges, addresses, subnet.ID)
+	if size.Sign() > 0 {
+		usage, _ := new(big.Float).Quo(new(big.Float).SetInt64(int64(used)), new(big.Float).SetInt(size)).Float64()
+
+		row.Dynamic = &dynamicRanges{
+			Addresses: size.String(),
+			Used:      used,
+			Usage:     percent(usage),
+		}
+	}
+
+	return row
+}
+
+// flag adds an alert to the row for every threshold, in percent, it reached.
+func (row *subnetUtilisation) flag(usageThreshold, dynamicThreshold float64) {
+	if row.Error != "" {
+		return
+	}
+
+	if row.Free == "0" {
+		row.Alerts = append(row.Alerts, "no free addresses")
+	}
+
+	if row.Usage >= usageThreshold {
+		row.Alerts = append(row.Alerts, fmt.Sprintf("usage %.1f%% >= %.0f%%", row.Usage, usageThreshold))
+	}
+
+	if row.Dynamic != nil && row.Dynamic.Usage >= dynamicThreshold {
+		row.Alerts = append(row.Alerts, fmt.Sprintf("dynamic range %.1f%% >= %.0f%%", row.Dynamic.Usage, dynamicThreshold))
+	}
+}
+
+func (row subnetUtilisation) fields() []string {
+	dynamic := ""
+	if row.Dynamic != nil {
+		dynamic = fmt.Sprintf("%.1f%% (%d/%s)", row.Dynamic.Usage, row.Dynamic.Used, row.Dynamic.Addresses)
+	}
+
+	alerts := strings.Join(row.Alerts, "; ")
+	if row.Error != "" {
+		alerts = "error: " + row.Error
+	}
+
+	return []string{
+		row.CIDR, row.Fabric, row.VID, row.Space,
+		fmt.Sprintf("%.1f%%", row.Usage), row.Free, row.LargestFree, dynamic, alerts,
+	}
+}
+
+// markdownRow formats the fields as a Markdown table row, escaping the pipes
+// inside them.
+func markdownRow(fields []string) string {
+	escaped := make([]string, len(fields))
+	for i, field := range fields {
+		escaped[i] = strings.ReplaceAll(field, "|", "\\|")
+	}
+
+	return fmt.Sprintf("| %s |\n", strings.Join(escaped, " | "))
+}
+
+var utilisationHeader = []string{"CIDR", "FABRIC", "VID", "SPACE", "USAGE", "FREE", "LARGEST FREE", "DYNAMIC", "ALERTS"}
+
+type SubnetUtilisationReport struct{}
+
+func (SubnetUtilisationReport) Create() mcp.Tool {
+	return mcp.NewTool(
+		"subnet_utilisation_report",
+		mcp.WithString(
+			"fabric",
+			mcp.Description("Only report the subnets of this fabric, by name or ID."),
+		),
+		mcp.WithString(
+			"vid",
+			mcp.Pattern("^[0-9]*$"),
+			mcp.Description("Only report the subnets of the VLAN with this VID."),
+		),
+		mcp.WithString(
+			"space",
+			mcp.Description("Only report the subnets of this space."),
+		),
+		mcp.WithNumber(
+			"usage_threshold",
+			mcp.Min(0),
+			mcp.Max(100),
+			mcp.Description("Usage in percent from which a subnet is flagged. Defaults to 80."),
+		),
+		mcp.WithNumber(
+			"dynamic_threshold",
+			mcp.Min(0),
+			mcp.Max(100),
+			mcp.Description("Fill level in percent of the dynamic ranges from which a subnet is flagged. Defaults to 80."),
+		),
+		mcp.WithString(
+			"format",
+			mcp.Enum("json", "csv", "markdown"),
+			mcp.DefaultString("json"),
+			mcp.Description("json returns the rows with a table, csv and markdown return only the exported report."),
+		),
+		mcp.WithToolAnnotation(tools.CreateToolAnnotation("Subnet Utilisation Report", true, false, false, true)),
+		mcp.WithDescription("Aggregates the statistics of all subnets, or those of a fabric, VLAN or space: usage, free addresses, the largest free block and the fill level of the dynamic ranges. Subnets above the thresholds are flagged, and the busiest subnets come first."),
+	)
+}
+
+func (SubnetUtilisationReport) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	fabric := request.GetString("fabric", "")
+	vid := request.GetString("vid", "")
+	space := request.GetString("space", "")
+	usageThreshold := request.GetFloat("usage_threshold", 80)
+	dynamicThreshold := request.GetFloat("dynamic_threshold", 80)
+	format := request.GetString("format", "json")
+
+	client := maas_client.MustClient()
+
+	subnets, err := loadSubnets(ctx, client)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to retrieve all the subnets: %v", err)
+		zap.L().Error(fmt.Sprintf("[SubnetUtilisationReport] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	ranges, err := loadIPRanges(ctx, client)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to list the IP ranges: %v", err)
+		zap.L().Error(fmt.Sprintf("[SubnetUtilisationReport] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	spaces := make(map[string]string, len(subnets))
+	for _, subnet := range subnets {
+		spaces[fmt.Sprint(subnet["id"])], _ = subnet["space"].(string)
+	}
+
+	rows := make([]subnetUtilisation, 0, len(subnets))
+	for _, subnet := range knownSubnets(subnets) {
+		if fabric != "" && subnet.Fabric != fabric && subnet.FabricID != fabric {
+			continue
+		}
+		if vid != "" && subnet.VID != vid {
+			continue
+		}
+		if space != "" && spaces[subnet.ID] != space {
+			continue
+		}
+
+		zap.L().Info(fmt.Sprintf("[SubnetUtilisationReport] Retrieving statistics for subnet %s...", subnet.CIDR))
+		row := utilisation(ctx, client, subnet, spaces[subnet.ID], ranges)
+		row.flag(usageThreshold, dynamicThreshold)
+		rows = append(rows, row)
+	}
+
+	slices.SortStableFunc(rows, func(a, b subnetUtilisation) int {
+		switch {
+		case a.Usage > b.Usage:
+			return -1
+		case a.Usage < b.Usage:
+			return 1
+		}
+		return 0
+	})
+
+	switch format {
+	case "csv":
+		var report strings.Builder
+		writer := csv.NewWriter(&report)
+		writer.Write(utilisationHeader)
+		for _, row := range rows {
+			writer.Write(row.fields())
+		}
+		writer.Flush()
+
+		return mcp.NewToolResultText(report.String()), nil
+	case "markdown":
+		var report strings.Builder
+		report.WriteString(markdownRow(utilisationHeader))
+		fmt.Fprintf(&report, "|%s\n", strings.Repeat("---|", len(utilisationHeader)))
+		for _, row := range rows {
+			report.WriteString(markdownRow(row.fields()))
+		}
+
+		return mcp.NewToolResultText(report.String()), nil
+	}
+
+	jsonData, err := json.Marshal(rows)
+	if err != nil {
+		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[SubnetUtilisationReport] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	var table strings.Builder
+	writer := tabwriter.NewWriter(&table, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, strings.Join(utilisationHeader, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(writer, strings.Join(row.fields(), "\t"))
+	}
+	writer.Flush()
+
+	result := mcp.NewToolResultText(string(jsonData))
+	result.Content = append(result.Content, mcp.NewTextContent(table.String()))
+
+	return result, nil
+}
@@ -0,0 +1,134 @@
+package subnets
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func testRange(id int, kind, start, end string, subnetID int) ipRange {
+	r := ipRange{ID: id, Type: kind, StartIP: start, EndIP: end}
+	r.Subnet.ID = subnetID
+	return r
+}
+
+func TestDynamicFill(t *testing.T) {
+	ranges := []ipRange{
+		testRange(1, "dynamic", "10.0.0.100", "10.0.0.109", 1),
+		testRange(2, "dynamic", "10.0.0.200", "10.0.0.200", 1),
+		testRange(3, "reserved", "10.0.0.1", "10.0.0.50", 1),
+		testRange(4, "dynamic", "10.1.0.100", "10.1.0.199", 2),
+		testRange(5, "dynamic", "10.0.0.250", "bogus", 1),
+		testRange(6, "dynamic", "2001:db8::", "2001:db8::ffff:ffff:ffff:ffff", 3),
+	}
+
+	addresses := []subnetAddress{
+		{IP: "10.0.0.100"},
+		{IP: "10.0.0.109"},
+		{IP: "10.0.0.110"},
+		{IP: "10.0.0.200"},
+		{IP: "10.0.0.10"},
+		{IP: "not an address"},
+		{IP: "2001:db8::1"},
+	}
+
+	tests := []struct {
+		subnetID string
+		size     string
+		used     int
+	}{
+		{"1", "11", 3},
+		{"2", "100", 0},
+		{"3", "18446744073709551616", 1},
+		{"4", "0", 0},
+	}
+
+	for _, test := range tests {
+		size, used := dynamicFill(ranges, addresses, test.subnetID)
+		if size.String() != test.size || used != test.used {
+			t.Errorf("dynamicFill(subnet %s) = %s, %d, want %s, %d", test.subnetID, size, used, test.size, test.used)
+		}
+	}
+}
+
+func TestSubnetUtilisationFlag(t *testing.T) {
+	tests := []struct {
+		name string
+		row  subnetUtilisation
+		want []string
+	}{
+		{"below the thresholds", subnetUtilisation{Free: "100", Usage: 50, Dynamic: &dynamicRanges{Usage: 50}}, nil},
+		{"at the usage threshold", subnetUtilisation{Free: "20", Usage: 80}, []string{"usage 80.0% >= 80%"}},
+		{"full", subnetUtilisation{Free: "0", Usage: 100, Dynamic: &dynamicRanges{Usage: 95.5}}, []string{"no free addresses", "usage 100.0% >= 80%", "dynamic range 95.5% >= 90%"}},
+		{"dynamic range only", subnetUtilisation{Free: "150", Usage: 40, Dynamic: &dynamicRanges{Usage: 90}}, []string{"dynamic range 90.0% >= 90%"}},
+		{"failed row", subnetUtilisation{Free: "0", Usage: 100, Error: "statistics failed"}, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			row := test.row
+			row.flag(80, 90)
+
+			if fmt.Sprint(row.Alerts) != fmt.Sprint(test.want) {
+				t.Errorf("alerts = %q, want %q", row.Alerts, test.want)
+			}
+		})
+	}
+}
+
+func TestSubnetUtilisationFields(t *testing.T) {
+	tests := []struct {
+		name string
+		row  subnetUtilisation
+		want []string
+	}{
+		{
+			name: "with dynamic ranges and alerts",
+			row: subnetUtilisation{
+				CIDR: "10.0.0.0/24", Fabric: "fabric-0", VID: "0", Space: "internal",
+				Free: "0", LargestFree: "0", Usage: 100,
+				Dynamic: &dynamicRanges{Addresses: "11", Used: 11, Usage: 100},
+				Alerts:  []string{"no free addresses", "usage 100.0% >= 80%"},
+			},
+			want: []string{"10.0.0.0/24", "fabric-0", "0", "internal", "100.0%", "0", "0", "100.0% (11/11)", "no free addresses; usage 100.0% >= 80%"},
+		},
+		{
+			name: "without dynamic ranges",
+			row:  subnetUtilisation{CIDR: "10.1.0.0/24", Fabric: "fabric-1", VID: "20", Free: "200", LargestFree: "150", Usage: 21.3},
+			want: []string{"10.1.0.0/24", "fabric-1", "20", "", "21.3%", "200", "150", "", ""},
+		},
+		{
+			name: "error replaces the alerts",
+			row:  subnetUtilisation{CIDR: "10.2.0.0/24", Fabric: "fabric-2", VID: "0", Alerts: []string{"ignored"}, Error: "statistics failed"},
+			want: []string{"10.2.0.0/24", "fabric-2", "0", "", "0.0%", "", "", "", "error: statistics failed"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.row.fields()
+			if len(got) != len(utilisationHeader) {
+				t.Fatalf("got %d fields, want one per header column (%d)", len(got), len(utilisationHeader))
+			}
+			if strings.Join(got, ",") != strings.Join(test.want, ",") {
+				t.Errorf("fields = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestMarkdownRow(t *testing.T) {
+	tests := []struct {
+		fields []string
+		want   string
+	}{
+		{[]string{"10.0.0.0/24", "fabric-0"}, "| 10.0.0.0/24 | fabric-0 |\n"},
+		{[]string{"a|b", "", "error: x | y"}, "| a\\|b |  | error: x \\| y |\n"},
+	}
+
+	for _, test := range tests {
+		if got := markdownRow(test.fields); got != test.want {
+			t.Errorf("markdownRow(%q) = %q, want %q", test.fields, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/JarcauCristian/ztp-mcp/internal/server/resources"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+)
+
+type Subscriptions struct{}
+
+func (Subscriptions) Register(mcpServer *server.MCPServer) {
+	mcpTools := []MCPTool{SubscribeMachine{}, UnsubscribeMachine{}}
+
+	for _, tool := range mcpTools {
+		mcpServer.AddTool(tool.Create(), tool.Handle)
+	}
+}
+
+type SubscribeMachine struct{}
+
+func (SubscribeMachine) Create() mcp.Tool {
+	return mcp.NewTool(
+		"subscribe_machine",
+		mcp.WithString(
+			"system_id",
+			mcp.Required(),
+			mcp.Pattern("^[0-9a-z]{6}$"),
+			mcp.Description("The system ID of the machine to watch."),
+		),
+		mcp.WithToolAnnotation(CreateToolAnnotation("Subscribe Machine", false, false, true, true)),
+		mcp.WithDescription("Subscribe the current session to status changes of a machine. A resources/updated notification for ztp://machines/{system_id} is sent whenever the machine status changes."),
+	)
+}
+
+func (SubscribeMachine) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	systemID, err := request.RequireString("system_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[SubscribeMachine] Required parameter system_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	session := server.ClientSessionFromContext(ctx)
+	if session == nil {
+		errMsg := "Subscriptions require an active client session"
+		zap.L().Error(fmt.Sprintf("[SubscribeMachine] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	zap.L().Info(fmt.Sprintf("[SubscribeMachine] Subscribing session %s to machine %s...", session.SessionID(), systemID))
+	status, err := resources.SubscribeMachine(ctx, session.SessionID(), systemID)
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to subscribe to machine %s err=%v", systemID, err)
+		zap.L().Error(fmt.Sprintf("[SubscribeMachine] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	return mcp.NewToolResultText(fmt.Sprintf("Subscribed to %s, current status is %s", fmt.Sprintf(resources.MachineURI, systemID), status)), nil
+}
+
+type UnsubscribeMachine struct{}
+
+func (UnsubscribeMachine) Create() mcp.Tool {
+	return mcp.NewTool(
+		"unsubscribe_machine",
+		mcp.WithString(
+			"system_id",
+			mcp.Required(),
+			mcp.Pattern("^[0-9a-z]{6}$"),
+			mcp.Description("The system ID of the machine to stop watching."),
+		),
+		mcp.WithToolAnnotation(CreateToolAnnotation("Unsubscribe Machine", false, false, true, false)),
+		mcp.WithDescription("Stop sending status change notifications for a machine to the current session."),
+	)
+}
+
+func (UnsubscribeMachine) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	systemID, err := request.RequireString("system_id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[UnsubscribeMachine] Required parameter system_id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	session := server.ClientSessionFromContext(ctx)
+	if session == nil {
+		errMsg := "Subscriptions require an active client session"
+		zap.L().Error(fmt.Sprintf("[UnsubscribeMachine] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	if !resources.UnsubscribeMachine(session.SessionID(), systemID) {
+		return mcp.NewToolResultError(fmt.Sprintf("The session is not subscribed to machine %s", systemID)), nil
+	}
+
+	return mcp.NewToolResultText(fmt.Sprintf("Unsubscribed from %s", fmt.Sprintf(resources.MachineURI, systemID))), nil
+}
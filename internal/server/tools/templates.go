@@ -2,9 +2,12 @@ package tools
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"strings"
 
+	"github.com/JarcauCristian/ztp-mcp/internal/server/resources"
 	"github.com/JarcauCristian/ztp-mcp/internal/server/templates"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
@@ -14,7 +17,7 @@ import (
 type Templates struct{}
 
 func (Templates) Register(mcpServer *server.MCPServer) {
-	mcpTools := []MCPTool{RetrieveTemplates{}, RetrieveTemplateContents{}, RetrieveTemplateById{}, CreateTemplate{}, DeleteTemplate{}}
+	mcpTools := []MCPTool{RetrieveTemplates{}, RetrieveTemplateContents{}, RetrieveTemplateById{}, CreateTemplate{}, DeleteTemplate{}, ExportTemplate{}, ImportTemplate{}, SearchTemplates{}}
 
 	for _, tool := range mcpTools {
 		mcpServer.AddTool(tool.Create(), tool.Handle)
@@ -40,7 +43,7 @@ func (RetrieveTemplates) Handle(ctx context.Context, request mcp.CallToolRequest
 	var errMsg string
 	onlyIDs := request.GetBool("only_ids", false)
 
-	if onlyIDs {
+	if !onlyIDs {
 		zap.L().Info("[RetrieveTemplates] Retrieving all template descriptions...")
 		descriptions, err := templates.Templates()
 		if err != nil {
@@ -186,6 +189,8 @@ func (CreateTemplate) Handle(ctx context.Context, request mcp.CallToolRequest) (
 		return mcp.NewToolResultError(errMsg), nil
 	}
 
+	resources.NotifyTemplatesChanged(ctx, genericTemplate.Id)
+
 	return mcp.NewToolResultText(fmt.Sprintf("Successfully created the template with id=%s", genericTemplate.Id)), nil
 }
 
@@ -217,5 +222,171 @@ func (DeleteTemplate) Handle(ctx context.Context, request mcp.CallToolRequest) (
 		return mcp.NewToolResultError(err.Error()), nil
 	}
 
+	resources.NotifyTemplatesChanged(ctx, templateId)
+
 	return mcp.NewToolResultText(fmt.Sprintf("Successfully delete template with id: %s", templateId)), nil
 }
+
+type ExportTemplate struct{}
+
+func (ExportTemplate) Create() mcp.Tool {
+	return mcp.NewTool(
+		"export_template",
+		mcp.WithString(
+			"id",
+			mcp.Required(),
+			mcp.Pattern("^[0-9a-z_-]+$"),
+			mcp.Description("The id of the template to export."),
+		),
+		mcp.WithToolAnnotation(CreateToolAnnotation("Export Template", true, false, true, false)),
+		mcp.WithDescription("Export a template as a base64 encoded tar.gz bundle containing its description, template files, partials and a checksummed manifest. The bundle is signed when ZTP_BUNDLE_KEY is set on the server."),
+	)
+}
+
+func (ExportTemplate) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	templateId, err := request.RequireString("id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[ExportTemplate] Required parameter id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	zap.L().Info(fmt.Sprintf("[ExportTemplate] Exporting template with id %s...", templateId))
+	bundle, err := templates.ExportTemplate(templateId)
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to export template with id %s: %v", templateId, err)
+		zap.L().Error(fmt.Sprintf("[ExportTemplate] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	return mcp.NewToolResultText(base64.StdEncoding.EncodeToString(bundle)), nil
+}
+
+type ImportTemplate struct{}
+
+func (ImportTemplate) Create() mcp.Tool {
+	return mcp.NewTool(
+		"import_template",
+		mcp.WithString(
+			"bundle",
+			mcp.Required(),
+			mcp.Description("The base64 encoded tar.gz bundle produced by export_template."),
+		),
+		mcp.WithBoolean(
+			"force",
+			mcp.DefaultBool(false),
+			mcp.Description("If true overwrite a template that already exists with the same id."),
+		),
+		mcp.WithToolAnnotation(CreateToolAnnotation("Import Template", false, true, false, false)),
+		mcp.WithDescription("Validate a template bundle against its manifest checksums (and signature when ZTP_BUNDLE_KEY is set) and install it. Refuses to overwrite an existing template unless force is true."),
+	)
+}
+
+func (ImportTemplate) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	encodedBundle, err := request.RequireString("bundle")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[ImportTemplate] Required parameter bundle not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	force := request.GetBool("force", false)
+
+	bundle, err := base64.StdEncoding.DecodeString(encodedBundle)
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to decode bundle: %v", err)
+		zap.L().Error(fmt.Sprintf("[ImportTemplate] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	zap.L().Info("[ImportTemplate] Importing template bundle...")
+	description, err := templates.ImportTemplate(bundle, force)
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to import template bundle: %v", err)
+		zap.L().Error(fmt.Sprintf("[ImportTemplate] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	resources.NotifyTemplatesChanged(ctx, description.ID)
+
+	return mcp.NewToolResultText(fmt.Sprintf("Successfully imported the template with id=%s", description.ID)), nil
+}
+
+type SearchTemplates struct{}
+
+func (SearchTemplates) Create() mcp.Tool {
+	return mcp.NewTool(
+		"search_templates",
+		mcp.WithString(
+			"query",
+			mcp.Description("Free text matched against the template id, name and description."),
+		),
+		mcp.WithString(
+			"tags",
+			mcp.Pattern("^[^,]+(?:,[^,]+)*$"),
+			mcp.Description("A comma-separated list of category tags the template must have."),
+		),
+		mcp.WithString(
+			"distro_series",
+			mcp.Description("Only return templates that support this distro series (e.g. jammy, noble)."),
+		),
+		mcp.WithString(
+			"architecture",
+			mcp.Description("Only return templates that support this architecture (e.g. amd64 or amd64/generic)."),
+		),
+		mcp.WithString(
+			"network_access",
+			mcp.Description("Only return templates that declare this required network access (e.g. internet)."),
+		),
+		mcp.WithNumber(
+			"cpu_count",
+			mcp.Min(0),
+			mcp.Description("Only return templates whose minimum CPU count fits within this value."),
+		),
+		mcp.WithNumber(
+			"memory",
+			mcp.Min(0),
+			mcp.Description("Only return templates whose minimum memory fits within this value (in MiB)."),
+		),
+		mcp.WithNumber(
+			"storage",
+			mcp.Min(0),
+			mcp.Description("Only return templates whose minimum storage fits within this value (in GB)."),
+		),
+		mcp.WithToolAnnotation(CreateToolAnnotation("Search Templates", true, false, true, false)),
+		mcp.WithDescription("Search the deployment templates by category tags, supported distro series, architecture, required network access and minimum hardware."),
+	)
+}
+
+func (SearchTemplates) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	filter := templates.SearchFilter{
+		Query:         request.GetString("query", ""),
+		DistroSeries:  request.GetString("distro_series", ""),
+		Architecture:  request.GetString("architecture", ""),
+		NetworkAccess: request.GetString("network_access", ""),
+		CPUCount:      request.GetInt("cpu_count", 0),
+		MemoryMB:      request.GetInt("memory", 0),
+		StorageGB:     request.GetInt("storage", 0),
+	}
+
+	if tags := request.GetString("tags", ""); tags != "" {
+		for _, tag := range strings.Split(tags, ",") {
+			filter.Tags = append(filter.Tags, strings.TrimSpace(tag))
+		}
+	}
+
+	zap.L().Info("[SearchTemplates] Searching templates...")
+	descriptions, err := templates.SearchTemplates(filter)
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to search the templates: %v", err)
+		zap.L().Error(fmt.Sprintf("[SearchTemplates] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	jsonData, err := json.Marshal(descriptions)
+	if err != nil {
+		errMsg := fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[SearchTemplates] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
@@ -120,12 +120,10 @@ func (ReadVlan) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.C
 		return mcp.NewToolResultError(err.Error()), nil
 	}
 
-	path := fmt.Sprintf("/MAAS/api/2.0/fabrics/%s/vlans/%s/", fabricID, vid)
-
 	client := maas_client.MustClient()
 
 	zap.L().Info(fmt.Sprintf("[ReadVlan] Retrieving VLAN %s on fabric %s", vid, fabricID))
-	resultData, err := client.Do(ctx, maas_client.RequestTypeGet, path, nil)
+	resultData, err := readVlan(ctx, client, fabricID, vid)
 	if err != nil {
 		errMsg = fmt.Sprintf("Failed to read VLAN %s on fabric %s err=%v", vid, fabricID, err)
 		zap.L().Error(fmt.Sprintf("[ReadVlan] %s", errMsg))
@@ -142,6 +140,26 @@ func (ReadVlan) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.C
 	return mcp.NewToolResultText(string(jsonData)), nil
 }
 
+func readVlan(ctx context.Context, client *maas_client.MAASClient, fabricID, vid string) (string, error) {
+	return client.Do(ctx, maas_client.RequestTypeGet, fmt.Sprintf("/MAAS/api/2.0/fabrics/%s/vlans/%s/", fabricID, vid), nil)
+}
+
+// LookupVlan returns the VLAN with the given VID on a fabric. Its "id" field
+// is the MAAS wide VLAN ID used by interfaces and subnets.
+func LookupVlan(ctx context.Context, client *maas_client.MAASClient, fabricID, vid string) (map[string]any, error) {
+	resultData, err := readVlan(ctx, client, fabricID, vid)
+	if err != nil {
+		return nil, err
+	}
+
+	var vlan map[string]any
+	if err := json.Unmarshal([]byte(resultData), &vlan); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal VLAN %s on fabric %s: %w", vid, fabricID, err)
+	}
+
+	return vlan, nil
+}
+
 type UpdateVlan struct{}
 
 func (UpdateVlan) Create() mcp.Tool {
@@ -0,0 +1,178 @@
+package tools
+
+import (
+	"fmt"
+	"math"
+	"slices"
+)
+
+// bytesPerGB is the unit of the disk sizes given to compose.
+const bytesPerGB = 1000 * 1000 * 1000
+
+type storagePoolCapacity struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Default   bool   `json:"default"`
+	Total     int64  `json:"total"`
+	Available int64  `json:"available"`
+}
+
+// vmHostCapacity is what a VM host has left to compose VMs with. Cores and
+// memory include the over commit ratios of the host. Memory is in MiB and
+// storage in bytes.
+type vmHostCapacity struct {
+	ID               string                `json:"id"`
+	Name             string                `json:"name"`
+	Type             string                `json:"type"`
+	Zone             string                `json:"zone"`
+	Pool             string                `json:"pool"`
+	Architectures    []string              `json:"architectures"`
+	Cores            int                   `json:"cores"`
+	UsedCores        int                   `json:"used_cores"`
+	AvailableCores   int                   `json:"available_cores"`
+	Memory           int                   `json:"memory"`
+	UsedMemory       int                   `json:"used_memory"`
+	AvailableMemory  int                   `json:"available_memory"`
+	CPUOverCommit    float64               `json:"cpu_over_commit_ratio"`
+	MemoryOverCommit float64               `json:"memory_over_commit_ratio"`
+	StoragePools     []storagePoolCapacity `json:"storage_pools"`
+}
+
+func number(value any) float64 {
+	n, _ := value.(float64)
+	return n
+}
+
+func nestedName(value any) string {
+	object, _ := value.(map[string]any)
+	name, _ := object["name"].(string)
+	return name
+}
+
+func parseVMHostCapacity(vmHost map[string]any) vmHostCapacity {
+	total, _ := vmHost["total"].(map[string]any)
+	used, _ := vmHost["used"].(map[string]any)
+
+	capacity := vmHostCapacity{
+		ID:               fmt.Sprint(vmHost["id"]),
+		Zone:             nestedName(vmHost["zone"]),
+		Pool:             nestedName(vmHost["pool"]),
+		Cores:            int(number(total["cores"])),
+		UsedCores:        int(number(used["cores"])),
+		Memory:           int(number(total["memory"])),
+		UsedMemory:       int(number(used["memory"])),
+		CPUOverCommit:    number(vmHost["cpu_over_commit_ratio"]),
+		MemoryOverCommit: number(vmHost["memory_over_commit_ratio"]),
+	}
+
+	capacity.Name, _ = vmHost["name"].(string)
+	capacity.Type, _ = vmHost["type"].(string)
+
+	if capacity.CPUOverCommit == 0 {
+		capacity.CPUOverCommit = 1
+	}
+	if capacity.MemoryOverCommit == 0 {
+		capacity.MemoryOverCommit = 1
+	}
+
+	capacity.AvailableCores = max(int(math.Floor(float64(capacity.Cores)*capacity.CPUOverCommit))-capacity.UsedCores, 0)
+	capacity.AvailableMemory = max(int(math.Floor(float64(capacity.Memory)*capacity.MemoryOverCommit))-capacity.UsedMemory, 0)
+
+	architectures, _ := vmHost["architectures"].([]any)
+	for _, architecture := range architectures {
+		if name, ok := architecture.(string); ok {
+			capacity.Architectures = append(capacity.Architectures, name)
+		}
+	}
+
+	defaultPool := fmt.Sprint(vmHost["default_storage_pool"])
+
+	storagePools, _ := vmHost["storage_pools"].([]any)
+	for _, entry := range storagePools {
+		pool, _ := entry.(map[string]any)
+
+		storagePool := storagePoolCapacity{
+			ID:    fmt.Sprint(pool["id"]),
+			Total: int64(number(pool["total"])),
+		}
+		storagePool.Name, _ = pool["name"].(string)
+		storagePool.Default, _ = pool["default"].(bool)
+		storagePool.Default = storagePool.Default || storagePool.ID == defaultPool
+
+		if available, ok := pool["available"].(float64); ok {
+			storagePool.Available = int64(available)
+		} else {
+			storagePool.Available = max(storagePool.Total-int64(number(pool["used"])), 0)
+		}
+
+		capacity.StoragePools = append(capacity.StoragePools, storagePool)
+	}
+
+	return capacity
+}
+
+// storagePool returns the pool with the given name, or the default pool when
+// name is empty.
+func (c vmHostCapacity) storagePool(name string) (storagePoolCapacity, bool) {
+	index := slices.IndexFunc(c.StoragePools, func(pool storagePoolCapacity) bool {
+		if name == "" {
+			return pool.Default
+		}
+		return pool.Name == name
+	})
+	if index < 0 {
+		return storagePoolCapacity{}, false
+	}
+	return c.StoragePools[index], true
+}
+
+type vmDisk struct {
+	Size int      `json:"size"`
+	Pool string   `json:"pool,omitempty"`
+	Tags []string `json:"tags,omitempty"`
+}
+
+// vmShape is the resources a VM asks of its host. Memory is in MiB and disk
+// sizes in GB.
+type vmShape struct {
+	Cores        int      `json:"cores"`
+	Memory       int      `json:"memory"`
+	Architecture string   `json:"architecture,omitempty"`
+	Disks        []vmDisk `json:"disks,omitempty"`
+}
+
+// fits returns why the host cannot hold the shape, or nil when it can.
+func (c vmHostCapacity) fits(shape vmShape) error {
+	if shape.Architecture != "" && len(c.Architectures) > 0 && !slices.Contains(c.Architectures, shape.Architecture) {
+		return fmt.Errorf("architecture %s is not supported, the host supports %v", shape.Architecture, c.Architectures)
+	}
+
+	if shape.Cores > c.AvailableCores {
+		return fmt.Errorf("%d cores requested, %d available", shape.Cores, c.AvailableCores)
+	}
+
+	if shape.Memory > c.AvailableMemory {
+		return fmt.Errorf("%d MiB of memory requested, %d MiB available", shape.Memory, c.AvailableMemory)
+	}
+
+	requested := make(map[string]int64)
+	for _, disk := range shape.Disks {
+		pool, ok := c.storagePool(disk.Pool)
+		if !ok {
+			if disk.Pool == "" {
+				return fmt.Errorf("the host has no default storage pool")
+			}
+			return fmt.Errorf("the host has no storage pool %s", disk.Pool)
+		}
+		requested[pool.Name] += int64(disk.Size) * bytesPerGB
+	}
+
+	for name, size := range requested {
+		pool, _ := c.storagePool(name)
+		if size > pool.Available {
+			return fmt.Errorf("%d GB requested from storage pool %s, %d GB available", size/bytesPerGB, name, pool.Available/bytesPerGB)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package tools
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func testVMHost(t *testing.T, data string) vmHostCapacity {
+	t.Helper()
+
+	var vmHost map[string]any
+	if err := json.Unmarshal([]byte(data), &vmHost); err != nil {
+		t.Fatal(err)
+	}
+	return parseVMHostCapacity(vmHost)
+}
+
+func TestParseVMHostCapacity(t *testing.T) {
+	capacity := testVMHost(t, `{
+		"id": 3, "name": "kvm-1", "type": "lxd",
+		"zone": {"name": "az1"}, "pool": {"name": "default"},
+		"architectures": ["amd64/generic"],
+		"total": {"cores": 8, "memory": 16384},
+		"used": {"cores": 10, "memory": 20000},
+		"cpu_over_commit_ratio": 2, "memory_over_commit_ratio": 1.5,
+		"default_storage_pool": "p2",
+		"storage_pools": [
+			{"id": "p1", "name": "fast", "total": 100000000000, "used": 40000000000},
+			{"id": "p2", "name": "bulk", "total": 500000000000, "available": 450000000000}
+		]
+	}`)
+
+	if capacity.ID != "3" || capacity.Name != "kvm-1" || capacity.Zone != "az1" || capacity.Pool != "default" {
+		t.Errorf("identity = %+v", capacity)
+	}
+	if capacity.AvailableCores != 6 || capacity.AvailableMemory != 4576 {
+		t.Errorf("available = %d cores and %d MiB, want 6 and 4576", capacity.AvailableCores, capacity.AvailableMemory)
+	}
+
+	fast, _ := capacity.storagePool("fast")
+	if fast.Available != 60*bytesPerGB || fast.Default {
+		t.Errorf("fast pool = %+v, want 60 GB available and not default", fast)
+	}
+
+	bulk, ok := capacity.storagePool("")
+	if !ok || bulk.Name != "bulk" || bulk.Available != 450*bytesPerGB {
+		t.Errorf("default pool = %+v, want bulk with 450 GB available", bulk)
+	}
+}
+
+func TestParseVMHostCapacityDefaults(t *testing.T) {
+	capacity := testVMHost(t, `{"id": 1, "total": {"cores": 4, "memory": 8192}, "used": {"cores": 6, "memory": 1024}}`)
+
+	if capacity.CPUOverCommit != 1 || capacity.MemoryOverCommit != 1 {
+		t.Errorf("over commit = %g and %g, want 1", capacity.CPUOverCommit, capacity.MemoryOverCommit)
+	}
+	if capacity.AvailableCores != 0 || capacity.AvailableMemory != 7168 {
+		t.Errorf("available = %d cores and %d MiB, want 0 and 7168", capacity.AvailableCores, capacity.AvailableMemory)
+	}
+}
+
+func TestFits(t *testing.T) {
+	host := vmHostCapacity{
+		Architectures:   []string{"amd64/generic"},
+		AvailableCores:  4,
+		AvailableMemory: 8192,
+		StoragePools: []storagePoolCapacity{
+			{ID: "1", Name: "fast", Available: 50 * bytesPerGB},
+			{ID: "2", Name: "bulk", Default: true, Available: 200 * bytesPerGB},
+		},
+	}
+
+	tests := []struct {
+		name  string
+		shape vmShape
+		err   string
+	}{
+		{name: "fits", shape: vmShape{Cores: 4, Memory: 8192, Disks: []vmDisk{{Size: 200}, {Size: 50, Pool: "fast"}}}},
+		{name: "architecture", shape: vmShape{Cores: 1, Memory: 1, Architecture: "arm64/generic"}, err: "architecture"},
+		{name: "cores", shape: vmShape{Cores: 5, Memory: 1}, err: "cores"},
+		{name: "memory", shape: vmShape{Cores: 1, Memory: 8193}, err: "memory"},
+		{name: "unknown pool", shape: vmShape{Cores: 1, Memory: 1, Disks: []vmDisk{{Size: 1, Pool: "ssd"}}}, err: "no storage pool ssd"},
+		{name: "disks add up", shape: vmShape{Cores: 1, Memory: 1, Disks: []vmDisk{{Size: 30, Pool: "fast"}, {Size: 30, Pool: "fast"}}}, err: "60 GB requested from storage pool fast"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := host.fits(test.shape)
+			switch {
+			case test.err == "" && err != nil:
+				t.Errorf("fits = %v, want nil", err)
+			case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+				t.Errorf("fits = %v, want an error about %s", err, test.err)
+			}
+		})
+	}
+
+	if err := (vmHostCapacity{AvailableCores: 1, AvailableMemory: 1}).fits(vmShape{Cores: 1, Memory: 1, Disks: []vmDisk{{Size: 1}}}); err == nil {
+		t.Error("a disk fits on a host without a default storage pool")
+	}
+}
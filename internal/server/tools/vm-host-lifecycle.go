@@ -0,0 +1,554 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/JarcauCristian/ztp-mcp/internal/server/maas_client"
+	"github.com/JarcauCristian/ztp-mcp/internal/server/parser"
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.uber.org/zap"
+)
+
+// maxOverCommitRatio is the highest CPU and memory over commit ratio MAAS
+// accepts for a VM host.
+const maxOverCommitRatio = 10
+
+// exclusiveMin sets a lower bound a number property must be above.
+func exclusiveMin(min float64) mcp.PropertyOption {
+	return func(schema map[string]any) {
+		schema["exclusiveMinimum"] = min
+	}
+}
+
+func retrieveVMHost(ctx context.Context, client *maas_client.MAASClient, vmHostID string) (map[string]any, error) {
+	resultData, err := client.Do(ctx, maas_client.RequestTypeGet, fmt.Sprintf("/MAAS/api/2.0/vm-hosts/%s/", vmHostID), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var vmHost map[string]any
+	if err := json.Unmarshal([]byte(resultData), &vmHost); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the VM host: %w", err)
+	}
+
+	return vmHost, nil
+}
+
+// vmHostMachines returns the machines composed on the VM host.
+func vmHostMachines(ctx context.Context, client *maas_client.MAASClient, vmHost map[string]any) ([]map[string]any, error) {
+	name, _ := vmHost["name"].(string)
+
+	query := make(url.Values)
+	query.Add("pod", name)
+
+	resultData, err := client.Do(ctx, maas_client.RequestTypeGet, "/MAAS/api/2.0/machines/?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var machines []map[string]any
+	if err := json.Unmarshal([]byte(resultData), &machines); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the machines: %w", err)
+	}
+
+	return machines, nil
+}
+
+type CreateVMHost struct{}
+
+func (CreateVMHost) Create() mcp.Tool {
+	return mcp.NewTool(
+		"create_vm_host",
+		mcp.WithString(
+			"type",
+			mcp.Required(),
+			mcp.Enum("lxd", "virsh"),
+			mcp.Description("The kind of VM host."),
+		),
+		mcp.WithString(
+			"system_id",
+			mcp.Pattern("^[0-9a-z]{6}$"),
+			mcp.Description("Deploy this Ready or Allocated machine as the VM host instead of registering an existing one. The connection parameters are not used then."),
+		),
+		mcp.WithString(
+			"power_address",
+			mcp.Description("How MAAS reaches an existing host. lxd: the address of the LXD server, e.g. 10.0.0.5:8443. virsh: a libvirt URI, e.g. qemu+ssh://ubuntu@10.0.0.5/system. Required unless system_id is given."),
+		),
+		mcp.WithString(
+			"power_user",
+			mcp.Description("virsh only, the user to connect with when it is not part of the URI."),
+		),
+		mcp.WithString(
+			"power_pass",
+			mcp.Description("virsh only, the password of the user when SSH keys are not used."),
+		),
+		mcp.WithString(
+			"password",
+			mcp.Description("lxd only, the trust password of the LXD server, used once so MAAS can add its certificate."),
+		),
+		mcp.WithString(
+			"certificate",
+			mcp.Description("lxd only, a PEM client certificate already trusted by the LXD server."),
+		),
+		mcp.WithString(
+			"key",
+			mcp.Description("lxd only, the PEM key of the certificate."),
+		),
+		mcp.WithString(
+			"project",
+			mcp.Description("lxd only, the LXD project to create the VMs in. Defaults to default."),
+		),
+		mcp.WithString(
+			"name",
+			mcp.Pattern("^[0-9a-z-]*$"),
+			mcp.Description("The name of the VM host. MAAS picks one when empty."),
+		),
+		mcp.WithString(
+			"zone",
+			mcp.Description("The availability zone of the VM host."),
+		),
+		mcp.WithString(
+			"pool",
+			mcp.Description("The resource pool the composed VMs are placed in."),
+		),
+		mcp.WithString(
+			"tags",
+			mcp.Description("Comma separated tags to add to the VM host."),
+		),
+		mcp.WithString(
+			"distro_series",
+			mcp.Description("With system_id, the OS release to deploy on the machine. Defaults to the MAAS default distro series."),
+		),
+		withAsync(),
+		mcp.WithToolAnnotation(CreateToolAnnotation("Create VM Host", false, false, false, true)),
+		mcp.WithDescription("Registers an existing LXD or virsh host with MAAS, or deploys a machine managed by MAAS as a new LXD or virsh VM host."),
+	)
+}
+
+func (CreateVMHost) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	hostType, err := request.RequireString("type")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[CreateVMHost] Required parameter type not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	client := maas_client.MustClient()
+
+	if systemID := request.GetString("system_id", ""); systemID != "" {
+		machine, err := RetrieveMachine(ctx, client, systemID)
+		if err != nil {
+			errMsg = fmt.Sprintf("Failed to retrieve the machine with id %s err=%v", systemID, err)
+			zap.L().Error(fmt.Sprintf("[CreateVMHost] %s", errMsg))
+			return mcp.NewToolResultError(errMsg), nil
+		}
+
+		if err := statusIn("Ready", "Allocated")(machine); err != nil {
+			errMsg = fmt.Sprintf("Cannot deploy machine %s as a VM host: %v", systemID, err)
+			zap.L().Error(fmt.Sprintf("[CreateVMHost] %s", errMsg))
+			return mcp.NewToolResultError(errMsg), nil
+		}
+
+		form := make(url.Values)
+		if hostType == "lxd" {
+			form.Add("register_vmhost", "true")
+		} else {
+			form.Add("install_kvm", "true")
+		}
+		if distroSeries := request.GetString("distro_series", ""); distroSeries != "" {
+			form.Add("distro_series", distroSeries)
+		}
+
+		if request.GetBool("async", false) {
+			return submitJob("CreateVMHost", "deploy", form, []map[string]any{machine})
+		}
+
+		path := fmt.Sprintf("/MAAS/api/2.0/machines/%s/op-deploy", systemID)
+
+		return performRequest(ctx, client, "CreateVMHost", systemID, maas_client.RequestTypePost, path, form, fmt.Sprintf("deploy the machine as a %s VM host", hostType))
+	}
+
+	powerAddress := request.GetString("power_address", "")
+	if powerAddress == "" {
+		return mcp.NewToolResultError("power_address is required unless system_id is given"), nil
+	}
+
+	isURI := strings.HasPrefix(powerAddress, "qemu")
+	if hostType == "virsh" && !isURI {
+		return mcp.NewToolResultError("the power_address of a virsh host must be a libvirt URI such as qemu+ssh://ubuntu@10.0.0.5/system"), nil
+	}
+	if hostType == "lxd" && isURI {
+		return mcp.NewToolResultError("the power_address of an lxd host must be the address of the LXD server, not a libvirt URI"), nil
+	}
+
+	options := map[string][]string{
+		"lxd":   {"password", "certificate", "key", "project"},
+		"virsh": {"power_user", "power_pass"},
+	}
+
+	form := make(url.Values)
+	form.Add("type", hostType)
+	form.Add("power_address", powerAddress)
+
+	for kind, names := range options {
+		for _, name := range names {
+			value := request.GetString(name, "")
+			if value == "" {
+				continue
+			}
+			if kind != hostType {
+				errMsg = fmt.Sprintf("%s is not used by %s VM hosts", name, hostType)
+				return mcp.NewToolResultError(errMsg), nil
+			}
+			form.Add(name, value)
+		}
+	}
+
+	if (form.Get("certificate") == "") != (form.Get("key") == "") {
+		return mcp.NewToolResultError("certificate and key must be given together"), nil
+	}
+
+	for _, option := range []string{"name", "zone", "pool", "tags"} {
+		if value := request.GetString(option, ""); value != "" {
+			form.Add(option, value)
+		}
+	}
+
+	zap.L().Info(fmt.Sprintf("[CreateVMHost] Registering %s VM host at %s...", hostType, powerAddress))
+	resultData, err := client.Do(ctx, maas_client.RequestTypePost, "/MAAS/api/2.0/vm-hosts/", strings.NewReader(form.Encode()))
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to create the VM host err=%v", err)
+		zap.L().Error(fmt.Sprintf("[CreateVMHost] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	jsonData, err := json.Marshal(resultData)
+	if err != nil {
+		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[CreateVMHost] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+type UpdateVMHost struct{}
+
+func (UpdateVMHost) Create() mcp.Tool {
+	return mcp.NewTool(
+		"update_vm_host",
+		mcp.WithString(
+			"id",
+			mcp.Required(),
+			mcp.Pattern(NUMBER_PATTERN),
+			mcp.Description("The ID of the VM host."),
+		),
+		mcp.WithNumber(
+			"cpu_over_commit_ratio",
+			exclusiveMin(0),
+			mcp.Max(maxOverCommitRatio),
+			mcp.Description("How many virtual cores may be composed per physical core, e.g. 4 allows four times the host cores."),
+		),
+		mcp.WithNumber(
+			"memory_over_commit_ratio",
+			exclusiveMin(0),
+			mcp.Max(maxOverCommitRatio),
+			mcp.Description("How much VM memory may be composed per unit of host memory, e.g. 1.5."),
+		),
+		mcp.WithString(
+			"default_storage_pool",
+			mcp.Description("The name or id of the host storage pool VMs use when compose does not name one."),
+		),
+		mcp.WithString(
+			"pool",
+			mcp.Description("The resource pool the composed VMs are placed in."),
+		),
+		mcp.WithString(
+			"zone",
+			mcp.Description("The availability zone of the VM host."),
+		),
+		mcp.WithString(
+			"name",
+			mcp.Pattern("^[0-9a-z-]*$"),
+			mcp.Description("A new name for the VM host."),
+		),
+		mcp.WithToolAnnotation(CreateToolAnnotation("Update VM Host", false, false, true, true)),
+		mcp.WithDescription("Changes the over commit ratios, default storage pool, resource pool, zone or name of a VM host. Only the given settings change."),
+	)
+}
+
+func (UpdateVMHost) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	vmHostID, err := request.RequireString("id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[UpdateVMHost] Required parameter id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	form := make(url.Values)
+
+	arguments := request.GetArguments()
+	for _, ratio := range []string{"cpu_over_commit_ratio", "memory_over_commit_ratio"} {
+		if _, ok := arguments[ratio]; !ok {
+			continue
+		}
+
+		value := request.GetFloat(ratio, 0)
+		if value <= 0 || value > maxOverCommitRatio {
+			errMsg = fmt.Sprintf("%s must be above 0 and at most %d", ratio, maxOverCommitRatio)
+			return mcp.NewToolResultError(errMsg), nil
+		}
+
+		form.Add(ratio, strconv.FormatFloat(value, 'f', -1, 64))
+	}
+
+	for _, option := range []string{"pool", "zone", "name"} {
+		if value := request.GetString(option, ""); value != "" {
+			form.Add(option, value)
+		}
+	}
+
+	client := maas_client.MustClient()
+
+	if storagePool := request.GetString("default_storage_pool", ""); storagePool != "" {
+		vmHost, err := retrieveVMHost(ctx, client, vmHostID)
+		if err != nil {
+			errMsg = fmt.Sprintf("Failed to retrieve VM host with ID %s err=%v", vmHostID, err)
+			zap.L().Error(fmt.Sprintf("[UpdateVMHost] %s", errMsg))
+			return mcp.NewToolResultError(errMsg), nil
+		}
+
+		storagePools, _ := vmHost["storage_pools"].([]any)
+
+		var names []string
+		for _, entry := range storagePools {
+			pool, _ := entry.(map[string]any)
+			name, _ := pool["name"].(string)
+			names = append(names, name)
+
+			if name == storagePool || fmt.Sprint(pool["id"]) == storagePool {
+				form.Add("default_storage_pool_id", fmt.Sprint(pool["id"]))
+			}
+		}
+
+		if !form.Has("default_storage_pool_id") {
+			errMsg = fmt.Sprintf("VM host %s has no storage pool %s, available: %s", vmHostID, storagePool, strings.Join(names, ", "))
+			return mcp.NewToolResultError(errMsg), nil
+		}
+	}
+
+	if len(form) == 0 {
+		return mcp.NewToolResultError("nothing to update, give at least one setting"), nil
+	}
+
+	zap.L().Info(fmt.Sprintf("[UpdateVMHost] Updating VM host with ID %s...", vmHostID))
+	resultData, err := client.Do(ctx, maas_client.RequestTypePut, fmt.Sprintf("/MAAS/api/2.0/vm-hosts/%s/", vmHostID), strings.NewReader(form.Encode()))
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to update VM host with ID %s err=%v", vmHostID, err)
+		zap.L().Error(fmt.Sprintf("[UpdateVMHost] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	jsonData, err := json.Marshal(resultData)
+	if err != nil {
+		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[UpdateVMHost] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+type RefreshVMHost struct{}
+
+func (RefreshVMHost) Create() mcp.Tool {
+	return mcp.NewTool(
+		"refresh_vm_host",
+		mcp.WithString(
+			"id",
+			mcp.Required(),
+			mcp.Pattern(NUMBER_PATTERN),
+			mcp.Description("The ID of the VM host."),
+		),
+		mcp.WithToolAnnotation(CreateToolAnnotation("Refresh VM Host", false, false, true, true)),
+		mcp.WithDescription("Asks MAAS to query a VM host again for its resources, storage pools and VMs, picking up changes made outside MAAS."),
+	)
+}
+
+func (RefreshVMHost) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	vmHostID, err := request.RequireString("id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[RefreshVMHost] Required parameter id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	client := maas_client.MustClient()
+
+	zap.L().Info(fmt.Sprintf("[RefreshVMHost] Refreshing VM host with ID %s...", vmHostID))
+	resultData, err := client.Do(ctx, maas_client.RequestTypePost, fmt.Sprintf("/MAAS/api/2.0/vm-hosts/%s/op-refresh", vmHostID), nil)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to refresh VM host with ID %s err=%v", vmHostID, err)
+		zap.L().Error(fmt.Sprintf("[RefreshVMHost] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	jsonData, err := json.Marshal(resultData)
+	if err != nil {
+		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[RefreshVMHost] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+type DeleteVMHost struct{}
+
+func (DeleteVMHost) Create() mcp.Tool {
+	return mcp.NewTool(
+		"delete_vm_host",
+		mcp.WithString(
+			"id",
+			mcp.Required(),
+			mcp.Pattern(NUMBER_PATTERN),
+			mcp.Description("The ID of the VM host."),
+		),
+		mcp.WithBoolean(
+			"decompose",
+			mcp.Description("Also destroy the VMs composed on the host. Without it the host is refused while it still has VMs."),
+		),
+		mcp.WithToolAnnotation(CreateToolAnnotation("Delete VM Host", false, true, false, true)),
+		mcp.WithDescription("Removes a VM host from MAAS. The host machine itself is left as it is. A host with composed VMs is only removed with decompose, and never while one of its VMs is protected."),
+	)
+}
+
+func (DeleteVMHost) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	vmHostID, err := request.RequireString("id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[DeleteVMHost] Required parameter id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	decompose := request.GetBool("decompose", false)
+
+	client := maas_client.MustClient()
+
+	vmHost, err := retrieveVMHost(ctx, client, vmHostID)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to retrieve VM host with ID %s err=%v", vmHostID, err)
+		zap.L().Error(fmt.Sprintf("[DeleteVMHost] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	machines, err := vmHostMachines(ctx, client, vmHost)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to retrieve the VMs of VM host %s err=%v", vmHostID, err)
+		zap.L().Error(fmt.Sprintf("[DeleteVMHost] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	if len(machines) > 0 && !decompose {
+		errMsg = fmt.Sprintf("VM host %s still has %d VMs, set decompose to destroy them with the host", vmHostID, len(machines))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	if index := slices.IndexFunc(machines, parser.CheckForProtectedTag); index >= 0 {
+		errMsg = fmt.Sprintf("VM host %s cannot be deleted, its VM %v is protected", vmHostID, machines[index]["system_id"])
+		zap.L().Error(fmt.Sprintf("[DeleteVMHost] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	path := fmt.Sprintf("/MAAS/api/2.0/vm-hosts/%s/", vmHostID)
+	if decompose {
+		path += "?" + url.Values{"decompose": {"true"}}.Encode()
+	}
+
+	zap.L().Info(fmt.Sprintf("[DeleteVMHost] Deleting VM host with ID %s and its %d VMs...", vmHostID, len(machines)))
+	resultData, err := client.Do(ctx, maas_client.RequestTypeDelete, path, nil)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to delete VM host with ID %s err=%v", vmHostID, err)
+		zap.L().Error(fmt.Sprintf("[DeleteVMHost] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	jsonData, err := json.Marshal(resultData)
+	if err != nil {
+		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[DeleteVMHost] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
+
+type VMHostParameters struct{}
+
+func (VMHostParameters) Create() mcp.Tool {
+	return mcp.NewTool(
+		"vm_host_parameters",
+		mcp.WithString(
+			"id",
+			mcp.Required(),
+			mcp.Pattern(NUMBER_PATTERN),
+			mcp.Description("The ID of the VM host."),
+		),
+		mcp.WithBoolean(
+			"show_secrets",
+			mcp.Description("Return passwords and keys instead of masking them."),
+		),
+		mcp.WithToolAnnotation(CreateToolAnnotation("VM Host Parameters", true, false, true, true)),
+		mcp.WithDescription("Returns the parameters MAAS uses to connect to a VM host, such as its address, project and credentials."),
+	)
+}
+
+func (VMHostParameters) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	vmHostID, err := request.RequireString("id")
+	if err != nil {
+		zap.L().Error(fmt.Sprintf("[VMHostParameters] Required parameter id not present err=%v", err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	client := maas_client.MustClient()
+
+	zap.L().Info(fmt.Sprintf("[VMHostParameters] Retrieving the parameters of VM host with ID %s...", vmHostID))
+	resultData, err := client.Do(ctx, maas_client.RequestTypeGet, fmt.Sprintf("/MAAS/api/2.0/vm-hosts/%s/op-parameters", vmHostID), nil)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to retrieve the parameters of VM host with ID %s err=%v", vmHostID, err)
+		zap.L().Error(fmt.Sprintf("[VMHostParameters] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	var parameters map[string]any
+	if err := json.Unmarshal([]byte(resultData), &parameters); err != nil {
+		errMsg = fmt.Sprintf("Failed to unmarshal the result err=%v", err)
+		zap.L().Error(fmt.Sprintf("[VMHostParameters] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	if !request.GetBool("show_secrets", false) {
+		maskSecrets(parameters)
+	}
+
+	jsonData, err := json.Marshal(parameters)
+	if err != nil {
+		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[VMHostParameters] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	return mcp.NewToolResultText(string(jsonData)), nil
+}
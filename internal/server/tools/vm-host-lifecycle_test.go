@@ -0,0 +1,32 @@
+package tools
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestUpdateVMHostOverCommitRatios(t *testing.T) {
+	tool := UpdateVMHost{}.Create()
+
+	for _, ratio := range []string{"cpu_over_commit_ratio", "memory_over_commit_ratio"} {
+		schema, _ := tool.InputSchema.Properties[ratio].(map[string]any)
+		if _, ok := schema["minimum"]; ok || schema["exclusiveMinimum"] != 0.0 || schema["maximum"] != float64(maxOverCommitRatio) {
+			t.Errorf("%s schema = %v, want it above 0 and at most %d", ratio, schema, maxOverCommitRatio)
+		}
+
+		for _, value := range []float64{0, -1, maxOverCommitRatio + 0.5} {
+			var request mcp.CallToolRequest
+			request.Params.Arguments = map[string]any{"id": "1", ratio: value}
+
+			result, err := UpdateVMHost{}.Handle(context.Background(), request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !result.IsError {
+				t.Errorf("%s = %v was accepted", ratio, value)
+			}
+		}
+	}
+}
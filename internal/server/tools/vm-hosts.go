@@ -4,10 +4,16 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 
+	"github.com/JarcauCristian/ztp-mcp/internal/server/jobs"
 	"github.com/JarcauCristian/ztp-mcp/internal/server/maas_client"
+	"github.com/JarcauCristian/ztp-mcp/internal/server/templates"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"go.uber.org/zap"
@@ -18,7 +24,11 @@ const NUMBER_PATTERN = "^[0-9]+$"
 type VMHosts struct{}
 
 func (VMHosts) Register(mcpServer *server.MCPServer) {
-	mcpTools := []MCPTool{ListVMHosts{}, ListVMHost{}, ComposeVM{}}
+	mcpTools := []MCPTool{
+		ListVMHosts{}, ListVMHost{}, ComposeVM{}, PlanVMPlacement{},
+		CreateVMHost{}, UpdateVMHost{}, RefreshVMHost{}, DeleteVMHost{}, VMHostParameters{},
+		DeleteComposedVM{}, FindOrphanedVMs{},
+	}
 
 	for _, tool := range mcpTools {
 		mcpServer.AddTool(tool.Create(), tool.Handle)
@@ -106,6 +116,134 @@ func (ListVMHost) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp
 	return mcp.NewToolResultText(string(jsonData)), nil
 }
 
+// vmInterface binds a network interface of a composed VM to a subnet, space,
+// VLAN or fabric of the host.
+type vmInterface struct {
+	Name   string `json:"name"`
+	Subnet string `json:"subnet,omitempty"`
+	Space  string `json:"space,omitempty"`
+	VLAN   string `json:"vlan,omitempty"`
+	Fabric string `json:"fabric,omitempty"`
+	IP     string `json:"ip,omitempty"`
+}
+
+var interfaceNamePattern = regexp.MustCompile("^[a-z0-9]+$")
+
+// decodeStrict unmarshals a JSON parameter, rejecting unknown fields.
+func decodeStrict(name, data string, value any) error {
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(value); err != nil {
+		return fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return nil
+}
+
+// storageConstraint formats disks as the compose storage parameter, e.g.
+// disk0:20(ssd,fast),disk1:100. A tag naming a storage pool of the host
+// places the disk in that pool.
+func storageConstraint(disks []vmDisk) string {
+	parts := make([]string, 0, len(disks))
+	for i, disk := range disks {
+		tags := disk.Tags
+		if disk.Pool != "" {
+			tags = append([]string{disk.Pool}, tags...)
+		}
+
+		part := fmt.Sprintf("disk%d:%d", i, disk.Size)
+		if len(tags) > 0 {
+			part += "(" + strings.Join(tags, ",") + ")"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ",")
+}
+
+// interfacesConstraint formats interfaces as the compose interfaces
+// parameter, e.g. eth0:space=internal;eth1:subnet=10.0.0.0/24,ip=10.0.0.5.
+func interfacesConstraint(interfaces []vmInterface) (string, error) {
+	parts := make([]string, 0, len(interfaces))
+	seen := make(map[string]bool)
+
+	for _, iface := range interfaces {
+		if !interfaceNamePattern.MatchString(iface.Name) {
+			return "", fmt.Errorf("invalid interface name %q", iface.Name)
+		}
+		if seen[iface.Name] {
+			return "", fmt.Errorf("interface %s is given twice", iface.Name)
+		}
+		seen[iface.Name] = true
+
+		if iface.IP != "" && net.ParseIP(iface.IP) == nil {
+			return "", fmt.Errorf("invalid IP address %s for interface %s", iface.IP, iface.Name)
+		}
+
+		var constraints []string
+		for _, constraint := range [][2]string{
+			{"subnet", iface.Subnet}, {"space", iface.Space}, {"vlan", iface.VLAN}, {"fabric", iface.Fabric}, {"ip", iface.IP},
+		} {
+			if constraint[1] != "" {
+				constraints = append(constraints, constraint[0]+"="+constraint[1])
+			}
+		}
+
+		if len(constraints) == 0 {
+			return "", fmt.Errorf("interface %s needs a subnet, space, vlan, fabric or ip", iface.Name)
+		}
+
+		parts = append(parts, iface.Name+":"+strings.Join(constraints, ","))
+	}
+
+	return strings.Join(parts, ";"), nil
+}
+
+// parsePinnedCores parses a comma separated list of host core indexes.
+func parsePinnedCores(data string, hostCores int) ([]int, error) {
+	var cores []int
+
+	for _, field := range strings.Split(data, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		core, err := strconv.Atoi(field)
+		if err != nil || core < 0 {
+			return nil, fmt.Errorf("invalid core index %q", field)
+		}
+		if core >= hostCores {
+			return nil, fmt.Errorf("core %d does not exist, the host has %d cores", core, hostCores)
+		}
+		if slices.Contains(cores, core) {
+			return nil, fmt.Errorf("core %d is given twice", core)
+		}
+
+		cores = append(cores, core)
+	}
+
+	return cores, nil
+}
+
+// composeForm returns the compose parameters for the resources of shape.
+// MAAS picks a hostname when it is empty.
+func composeForm(shape vmShape, hostname string) url.Values {
+	form := make(url.Values)
+	form.Set("cores", strconv.Itoa(shape.Cores))
+	form.Set("memory", strconv.Itoa(shape.Memory))
+
+	if hostname != "" {
+		form.Set("hostname", hostname)
+	}
+	if len(shape.Disks) > 0 {
+		form.Set("storage", storageConstraint(shape.Disks))
+	}
+	if shape.Architecture != "" {
+		form.Set("architecture", shape.Architecture)
+	}
+
+	return form
+}
+
 type ComposeVM struct{}
 
 func (ComposeVM) Create() mcp.Tool {
@@ -117,23 +255,43 @@ func (ComposeVM) Create() mcp.Tool {
 			mcp.Description("ID of the VM host to compose the machine on."),
 			mcp.Pattern(NUMBER_PATTERN),
 		),
-		mcp.WithString(
+		mcp.WithNumber(
 			"cores",
 			mcp.Required(),
-			mcp.Description("The number of cores the composed VM should have."),
-			mcp.Pattern(NUMBER_PATTERN),
+			mcp.Min(1),
+			mcp.Description("The number of cores the composed VM should have. Ignored when pinned_cores is given."),
 		),
-		mcp.WithString(
+		mcp.WithNumber(
 			"memory",
 			mcp.Required(),
+			mcp.Min(1),
 			mcp.Description("How much RAM the composed VM should have (Should be in MiB)."),
-			mcp.Pattern(NUMBER_PATTERN),
 		),
-		mcp.WithString(
+		mcp.WithNumber(
 			"storage",
-			mcp.Required(),
-			mcp.Description("How much storage the composed VM should have (Should be in GB)."),
-			mcp.Pattern(NUMBER_PATTERN),
+			mcp.Min(1),
+			mcp.Description("The size in GB of a single disk in the default storage pool. Use disks for more than one disk."),
+		),
+		mcp.WithString(
+			"disks",
+			mcp.Description(`JSON array of disks, the first one being the root disk. size is in GB, pool is a storage pool of the host (the default one when empty) and tags are added to the disk. Example: [{"size": 20, "pool": "ssd"}, {"size": 500, "pool": "hdd", "tags": ["data"]}]`),
+		),
+		mcp.WithString(
+			"interfaces",
+			mcp.Description(`JSON array of network interfaces, each bound to a subnet (id or CIDR), space, vlan or fabric, optionally with a static ip. Example: [{"name": "eth0", "space": "internal"}, {"name": "eth1", "subnet": "10.0.5.0/24", "ip": "10.0.5.20"}]`),
+		),
+		mcp.WithString(
+			"architecture",
+			mcp.Description("The architecture of the VM, e.g. amd64/generic. Must be supported by the host."),
+		),
+		mcp.WithString(
+			"pinned_cores",
+			mcp.Pattern("^[0-9]+(,[0-9]+)*$"),
+			mcp.Description("Comma separated host core indexes to pin the VM to. The VM gets one core per index."),
+		),
+		mcp.WithBoolean(
+			"hugepages_backed",
+			mcp.Description("Back the VM memory with hugepages."),
 		),
 		mcp.WithString(
 			"hostname",
@@ -141,7 +299,33 @@ func (ComposeVM) Create() mcp.Tool {
 			mcp.Description("The name of the created VM (Give something random if not provided)."),
 			mcp.Pattern("^[a-zA-Z0-9.-]+$"),
 		),
-		mcp.WithDescription("Compose a VM on a particular VM host specified by ID."),
+		mcp.WithString(
+			"domain",
+			mcp.Description("The DNS domain of the VM."),
+		),
+		mcp.WithString(
+			"zone",
+			mcp.Description("The availability zone of the VM."),
+		),
+		mcp.WithString(
+			"pool",
+			mcp.Description("The resource pool of the VM."),
+		),
+		mcp.WithString(
+			"templateId",
+			mcp.Pattern("^[0-9a-z-_]*$"),
+			mcp.Description("Deploy the VM with this template once it finishes commissioning. The deployment runs as a background job."),
+		),
+		mcp.WithString(
+			"templateParameters",
+			mcp.Description("With templateId, the parameters of the template as a JSON object. Defaults to {}."),
+		),
+		mcp.WithString(
+			"distro_series",
+			mcp.Description("With templateId, the OS release to deploy. Defaults to the MAAS default distro series."),
+		),
+		mcp.WithToolAnnotation(CreateToolAnnotation("Compose VM", false, false, false, true)),
+		mcp.WithDescription("Compose a VM on a particular VM host specified by ID, with its disks, network interfaces and CPU placement. The request is checked against the cores, memory, storage pools and architectures the host has left before it is sent, and the VM can be deployed with a template once it is ready."),
 	)
 }
 
@@ -154,41 +338,120 @@ func (ComposeVM) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.
 		return mcp.NewToolResultError(err.Error()), nil
 	}
 
-	cores, err := request.RequireString("cores")
+	cores, err := request.RequireInt("cores")
 	if err != nil {
 		zap.L().Error(fmt.Sprintf("[ComposeVM] Required parameter cores not present err=%v", err))
 		return mcp.NewToolResultError(err.Error()), nil
 	}
 
-	memory, err := request.RequireString("memory")
+	memory, err := request.RequireInt("memory")
 	if err != nil {
 		zap.L().Error(fmt.Sprintf("[ComposeVM] Required parameter memory not present err=%v", err))
 		return mcp.NewToolResultError(err.Error()), nil
 	}
 
-	storage, err := request.RequireString("storage")
-	if err != nil {
-		zap.L().Error(fmt.Sprintf("[ComposeVM] Required parameter storage not present err=%v", err))
-		return mcp.NewToolResultError(err.Error()), nil
-	}
-
 	hostname, err := request.RequireString("hostname")
 	if err != nil {
 		zap.L().Error(fmt.Sprintf("[ComposeVM] Required parameter hostname not present err=%v", err))
 		return mcp.NewToolResultError(err.Error()), nil
 	}
 
-	form := make(url.Values)
-	form.Set("cores", cores)
-	form.Set("memory", memory)
-	form.Set("storage", storage)
-	form.Set("hostname", hostname)
+	if cores < 1 || memory < 1 {
+		return mcp.NewToolResultError("cores and memory must be at least 1"), nil
+	}
 
-	path := fmt.Sprintf("/MAAS/api/2.0/vm-hosts/%s/op-compose", vmHostID)
+	shape := vmShape{Cores: cores, Memory: memory, Architecture: request.GetString("architecture", "")}
+
+	if disks := request.GetString("disks", ""); disks != "" {
+		if err := decodeStrict("disks", disks, &shape.Disks); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+	} else if storage := request.GetInt("storage", 0); storage > 0 {
+		shape.Disks = []vmDisk{{Size: storage}}
+	}
+
+	for i, disk := range shape.Disks {
+		if disk.Size < 1 {
+			return mcp.NewToolResultError(fmt.Sprintf("disk %d needs a size of at least 1 GB", i)), nil
+		}
+	}
+
+	var interfaces string
+	if data := request.GetString("interfaces", ""); data != "" {
+		var vmInterfaces []vmInterface
+		if err := decodeStrict("interfaces", data, &vmInterfaces); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		interfaces, err = interfacesConstraint(vmInterfaces)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+	}
+
+	var userData string
+	templateId := request.GetString("templateId", "")
+	if templateId != "" {
+		templateExecutor, err := templates.RetrieveExecutor(templateId, request.GetString("templateParameters", "{}"))
+		if err != nil {
+			zap.L().Error(fmt.Sprintf("[ComposeVM] Failed to retrieve the template executor for template %s.", templateId))
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		userData, err = templateExecutor.Execute()
+		if err != nil {
+			errMsg = "Failed to execute the template to retrieve the userData."
+			zap.L().Error(fmt.Sprintf("[ComposeVM] %s", errMsg))
+			return mcp.NewToolResultError(errMsg), nil
+		}
+	}
 
 	client := maas_client.MustClient()
 
-	zap.L().Info(fmt.Sprintf("[ComposeVM] Composing VM on host %s with the following configuration:\nCores: %s\nMemory: %s\nStorage: %s\nHostname: %s", vmHostID, cores, memory, storage, hostname))
+	vmHost, err := retrieveVMHost(ctx, client, vmHostID)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to retrieve VM host with ID %s err=%v", vmHostID, err)
+		zap.L().Error(fmt.Sprintf("[ComposeVM] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	capacity := parseVMHostCapacity(vmHost)
+
+	var pinnedCores []int
+	if data := request.GetString("pinned_cores", ""); data != "" {
+		pinnedCores, err = parsePinnedCores(data, capacity.Cores)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		shape.Cores = len(pinnedCores)
+	}
+
+	if err := capacity.fits(shape); err != nil {
+		errMsg = fmt.Sprintf("VM host %s cannot hold the VM: %v", capacity.Name, err)
+		zap.L().Error(fmt.Sprintf("[ComposeVM] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	form := composeForm(shape, hostname)
+
+	if interfaces != "" {
+		form.Set("interfaces", interfaces)
+	}
+	for _, core := range pinnedCores {
+		form.Add("pinned_cores", strconv.Itoa(core))
+	}
+	if request.GetBool("hugepages_backed", false) {
+		form.Set("hugepages_backed", "true")
+	}
+	for _, option := range []string{"domain", "zone", "pool"} {
+		if value := request.GetString(option, ""); value != "" {
+			form.Set(option, value)
+		}
+	}
+
+	path := fmt.Sprintf("/MAAS/api/2.0/vm-hosts/%s/op-compose", vmHostID)
+
+	zap.L().Info(fmt.Sprintf("[ComposeVM] Composing VM %s on host %s with %d cores, %d MiB of memory and storage %s...", hostname, vmHostID, shape.Cores, shape.Memory, form.Get("storage")))
 	resultData, err := client.Do(ctx, maas_client.RequestTypePost, path, strings.NewReader(form.Encode()))
 	if err != nil {
 		errMsg = fmt.Sprintf("Failed to compose VM err=%v", err)
@@ -196,7 +459,42 @@ func (ComposeVM) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.
 		return mcp.NewToolResultError(errMsg), nil
 	}
 
-	jsonData, err := json.Marshal(resultData)
+	if templateId == "" {
+		jsonData, err := json.Marshal(resultData)
+		if err != nil {
+			errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+			zap.L().Error(fmt.Sprintf("[ComposeVM] %s", errMsg))
+			return mcp.NewToolResultError(errMsg), nil
+		}
+
+		return mcp.NewToolResultText(string(jsonData)), nil
+	}
+
+	var machine map[string]any
+	if err := json.Unmarshal([]byte(resultData), &machine); err != nil || machine["system_id"] == nil {
+		errMsg = fmt.Sprintf("VM %s was composed but its system id could not be read to deploy it: %s", hostname, resultData)
+		zap.L().Error(fmt.Sprintf("[ComposeVM] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	if _, ok := machine["hostname"]; !ok {
+		machine["hostname"] = hostname
+	}
+
+	deployForm := make(url.Values)
+	deployForm.Add("user_data", userData)
+	if distroSeries := request.GetString("distro_series", ""); distroSeries != "" {
+		deployForm.Add("distro_series", distroSeries)
+	}
+
+	job, err := jobs.Default().SubmitWhen("ComposeVM", "deploy", "Ready", deployForm, jobTargets([]map[string]any{machine}), 0)
+	if err != nil {
+		errMsg = fmt.Sprintf("VM %s was composed but its deployment could not be started err=%v", hostname, err)
+		zap.L().Error(fmt.Sprintf("[ComposeVM] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	jsonData, err := json.Marshal(map[string]any{"machine": machine, "job": newJobView(job, false)})
 	if err != nil {
 		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
 		zap.L().Error(fmt.Sprintf("[ComposeVM] %s", errMsg))
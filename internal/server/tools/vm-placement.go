@@ -0,0 +1,373 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/JarcauCristian/ztp-mcp/internal/server/maas_client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.uber.org/zap"
+)
+
+const maxPlannedVMs = 100
+
+var placementStrategies = []string{"bin-pack", "spread", "zone-aware"}
+
+// plannedVM is a VM shape of a placement request. count places that many
+// copies, named hostname-1, hostname-2, ... when a hostname is given.
+type plannedVM struct {
+	vmShape
+	Hostname string `json:"hostname,omitempty"`
+	Count    int    `json:"count,omitempty"`
+}
+
+type placement struct {
+	Hostname string            `json:"hostname,omitempty"`
+	Shape    vmShape           `json:"shape"`
+	HostID   string            `json:"host_id,omitempty"`
+	HostName string            `json:"host_name,omitempty"`
+	Zone     string            `json:"zone,omitempty"`
+	Reason   string            `json:"reason"`
+	Rejected map[string]string `json:"rejected,omitempty"`
+	SystemID string            `json:"system_id,omitempty"`
+	Error    string            `json:"error,omitempty"`
+}
+
+// headroom is the share of cores and memory the host keeps once the shape
+// is placed on it, between 0 and 1.
+func (c vmHostCapacity) headroom(shape vmShape) float64 {
+	cores := math.Floor(float64(c.Cores) * c.CPUOverCommit)
+	memory := math.Floor(float64(c.Memory) * c.MemoryOverCommit)
+	if cores == 0 || memory == 0 {
+		return 0
+	}
+
+	return (float64(c.AvailableCores-shape.Cores)/cores + float64(c.AvailableMemory-shape.Memory)/memory) / 2
+}
+
+// reserve takes the resources of the shape from the host.
+func (c *vmHostCapacity) reserve(shape vmShape) {
+	c.AvailableCores -= shape.Cores
+	c.AvailableMemory -= shape.Memory
+	c.UsedCores += shape.Cores
+	c.UsedMemory += shape.Memory
+
+	for _, disk := range shape.Disks {
+		pool, _ := c.storagePool(disk.Pool)
+		for i := range c.StoragePools {
+			if c.StoragePools[i].ID == pool.ID {
+				c.StoragePools[i].Available -= int64(disk.Size) * bytesPerGB
+			}
+		}
+	}
+}
+
+// placementPlanner places VMs one by one on the hosts, keeping track of what
+// the VMs placed so far use.
+type placementPlanner struct {
+	strategy  string
+	hosts     []vmHostCapacity
+	zoneCount map[string]int
+}
+
+func (p *placementPlanner) place(vm plannedVM) placement {
+	result := placement{Hostname: vm.Hostname, Shape: vm.vmShape, Rejected: make(map[string]string)}
+
+	var candidates []int
+	for i, host := range p.hosts {
+		if err := host.fits(vm.vmShape); err != nil {
+			result.Rejected[host.Name] = err.Error()
+			continue
+		}
+		candidates = append(candidates, i)
+	}
+
+	if len(candidates) == 0 {
+		result.Reason = "no VM host has room for the VM"
+		return result
+	}
+
+	best := slices.MinFunc(candidates, func(a, b int) int {
+		hostA, hostB := p.hosts[a], p.hosts[b]
+
+		if p.strategy == "zone-aware" && p.zoneCount[hostA.Zone] != p.zoneCount[hostB.Zone] {
+			return p.zoneCount[hostA.Zone] - p.zoneCount[hostB.Zone]
+		}
+
+		scoreA, scoreB := hostA.headroom(vm.vmShape), hostB.headroom(vm.vmShape)
+		if p.strategy == "bin-pack" {
+			scoreA, scoreB = -scoreA, -scoreB
+		}
+
+		switch {
+		case scoreA > scoreB:
+			return -1
+		case scoreA < scoreB:
+			return 1
+		}
+		return strings.Compare(hostA.Name, hostB.Name)
+	})
+
+	host := &p.hosts[best]
+	headroom := host.headroom(vm.vmShape)
+
+	switch p.strategy {
+	case "bin-pack":
+		result.Reason = fmt.Sprintf("fullest of %d hosts with room, %.0f%% of its cores and memory left afterwards", len(candidates), headroom*100)
+	case "spread":
+		result.Reason = fmt.Sprintf("emptiest of %d hosts with room, %.0f%% of its cores and memory left afterwards", len(candidates), headroom*100)
+	case "zone-aware":
+		result.Reason = fmt.Sprintf("zone %s had %d VMs of this plan, the fewest among the zones with room, and the host is the emptiest there with %.0f%% left afterwards", host.Zone, p.zoneCount[host.Zone], headroom*100)
+	}
+
+	host.reserve(vm.vmShape)
+	p.zoneCount[host.Zone]++
+
+	result.HostID = host.ID
+	result.HostName = host.Name
+	result.Zone = host.Zone
+
+	return result
+}
+
+// expandVMs checks the requested shapes and returns one VM per copy. The
+// counts are added up first, so a huge count is refused before any copy is
+// made.
+func expandVMs(requested []plannedVM) ([]plannedVM, error) {
+	total := 0
+	for i, vm := range requested {
+		if vm.Cores < 1 || vm.Memory < 1 {
+			return nil, fmt.Errorf("VM %d needs at least 1 core and 1 MiB of memory", i)
+		}
+		for _, disk := range vm.Disks {
+			if disk.Size < 1 {
+				return nil, fmt.Errorf("VM %d has a disk smaller than 1 GB", i)
+			}
+		}
+
+		if vm.Count < 0 || vm.Count > maxPlannedVMs {
+			return nil, fmt.Errorf("VM %d has a count of %d, at most %d VMs can be planned at once", i, vm.Count, maxPlannedVMs)
+		}
+
+		total += max(vm.Count, 1)
+		if total > maxPlannedVMs {
+			return nil, fmt.Errorf("at most %d VMs can be planned at once", maxPlannedVMs)
+		}
+	}
+
+	vms := make([]plannedVM, 0, total)
+	for _, vm := range requested {
+		count := max(vm.Count, 1)
+		for n := 1; n <= count; n++ {
+			instance := vm
+			instance.Count = 0
+			if vm.Hostname != "" && count > 1 {
+				instance.Hostname = fmt.Sprintf("%s-%d", vm.Hostname, n)
+			}
+			vms = append(vms, instance)
+		}
+	}
+
+	return vms, nil
+}
+
+func listVMHostCapacities(ctx context.Context, client *maas_client.MAASClient) ([]vmHostCapacity, error) {
+	resultData, err := client.Do(ctx, maas_client.RequestTypeGet, "/MAAS/api/2.0/vm-hosts/", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var vmHosts []map[string]any
+	if err := json.Unmarshal([]byte(resultData), &vmHosts); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the VM hosts: %w", err)
+	}
+
+	capacities := make([]vmHostCapacity, 0, len(vmHosts))
+	for _, vmHost := range vmHosts {
+		capacities = append(capacities, parseVMHostCapacity(vmHost))
+	}
+
+	return capacities, nil
+}
+
+type PlanVMPlacement struct{}
+
+func (PlanVMPlacement) Create() mcp.Tool {
+	return mcp.NewTool(
+		"plan_vm_placement",
+		mcp.WithNumber(
+			"cores",
+			mcp.Min(1),
+			mcp.Description("Cores of a single VM to place. Use vms for a batch."),
+		),
+		mcp.WithNumber(
+			"memory",
+			mcp.Min(1),
+			mcp.Description("Memory in MiB of a single VM to place."),
+		),
+		mcp.WithNumber(
+			"storage",
+			mcp.Min(1),
+			mcp.Description("Size in GB of the disk of a single VM, taken from the default storage pool."),
+		),
+		mcp.WithString(
+			"vms",
+			mcp.Description(`JSON array of VM shapes, each with cores, memory (MiB) and optionally architecture, disks (as in compose_vm_host), hostname and count. Example: [{"cores": 2, "memory": 4096, "disks": [{"size": 20}], "hostname": "web", "count": 3}]`),
+		),
+		mcp.WithString(
+			"strategy",
+			mcp.Enum(placementStrategies...),
+			mcp.Description("bin-pack fills the fullest hosts first to keep others free, spread puts each VM on the emptiest host, zone-aware spreads the VMs over the availability zones first. Defaults to bin-pack."),
+		),
+		mcp.WithString(
+			"zone",
+			mcp.Description("Only consider the VM hosts in this availability zone."),
+		),
+		mcp.WithBoolean(
+			"compose",
+			mcp.Description("Compose the VMs as planned. Nothing is composed unless every VM has a host."),
+		),
+		mcp.WithToolAnnotation(CreateToolAnnotation("Plan VM Placement", false, false, false, true)),
+		mcp.WithDescription("Recommends on which VM hosts to compose one or more VMs from the cores, memory and storage pools each host has left, including over commit, and explains each choice. The largest VMs are placed first. Optionally composes the VMs as planned."),
+	)
+}
+
+func (PlanVMPlacement) Handle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var errMsg string
+
+	var requested []plannedVM
+	if data := request.GetString("vms", ""); data != "" {
+		if err := decodeStrict("vms", data, &requested); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+	} else {
+		vm := plannedVM{vmShape: vmShape{Cores: request.GetInt("cores", 0), Memory: request.GetInt("memory", 0)}}
+		if storage := request.GetInt("storage", 0); storage > 0 {
+			vm.Disks = []vmDisk{{Size: storage}}
+		}
+		requested = append(requested, vm)
+	}
+
+	vms, err := expandVMs(requested)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	strategy := request.GetString("strategy", "bin-pack")
+	if !slices.Contains(placementStrategies, strategy) {
+		return mcp.NewToolResultError(fmt.Sprintf("unknown strategy %s", strategy)), nil
+	}
+
+	client := maas_client.MustClient()
+
+	hosts, err := listVMHostCapacities(ctx, client)
+	if err != nil {
+		errMsg = fmt.Sprintf("Failed to retrieve the VM hosts err=%v", err)
+		zap.L().Error(fmt.Sprintf("[PlanVMPlacement] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	if zone := request.GetString("zone", ""); zone != "" {
+		hosts = slices.DeleteFunc(hosts, func(host vmHostCapacity) bool { return host.Zone != zone })
+	}
+
+	if len(hosts) == 0 {
+		return mcp.NewToolResultError("there are no VM hosts to place the VMs on"), nil
+	}
+
+	planner := placementPlanner{strategy: strategy, hosts: hosts, zoneCount: make(map[string]int)}
+
+	// The largest VMs are placed first so that they still find room, and the
+	// placements are reported in the order they were requested.
+	order := make([]int, len(vms))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		if vms[a].Memory != vms[b].Memory {
+			return vms[b].Memory - vms[a].Memory
+		}
+		return vms[b].Cores - vms[a].Cores
+	})
+
+	placements := make([]placement, len(vms))
+	unplaced := 0
+	for _, i := range order {
+		placements[i] = planner.place(vms[i])
+		if placements[i].HostID == "" {
+			unplaced++
+		}
+	}
+
+	zap.L().Info(fmt.Sprintf("[PlanVMPlacement] Planned %d VMs on %d hosts with strategy %s, %d without a host", len(vms), len(hosts), strategy, unplaced))
+
+	composed := false
+	if request.GetBool("compose", false) {
+		if unplaced > 0 {
+			errMsg = fmt.Sprintf("%d of the %d VMs have no host, nothing was composed", unplaced, len(vms))
+		} else {
+			composed = true
+			for i := range placements {
+				result := &placements[i]
+
+				path := fmt.Sprintf("/MAAS/api/2.0/vm-hosts/%s/op-compose", result.HostID)
+				zap.L().Info(fmt.Sprintf("[PlanVMPlacement] Composing VM %s on host %s...", result.Hostname, result.HostName))
+				resultData, err := client.Do(ctx, maas_client.RequestTypePost, path, strings.NewReader(composeForm(result.Shape, result.Hostname).Encode()))
+				if err != nil {
+					zap.L().Error(fmt.Sprintf("[PlanVMPlacement] Failed to compose VM %s on host %s err=%v", result.Hostname, result.HostName, err))
+					result.Error = err.Error()
+					continue
+				}
+
+				var machine map[string]any
+				if err := json.Unmarshal([]byte(resultData), &machine); err == nil {
+					result.SystemID, _ = machine["system_id"].(string)
+				}
+			}
+		}
+	}
+
+	output := map[string]any{
+		"strategy":   strategy,
+		"placements": placements,
+		"unplaced":   unplaced,
+		"composed":   composed,
+		"hosts":      planner.hosts,
+	}
+	if errMsg != "" {
+		output["error"] = errMsg
+	}
+
+	jsonData, err := json.Marshal(output)
+	if err != nil {
+		errMsg = fmt.Sprintf("failed to marshal result: %v", err)
+		zap.L().Error(fmt.Sprintf("[PlanVMPlacement] %s", errMsg))
+		return mcp.NewToolResultError(errMsg), nil
+	}
+
+	var table strings.Builder
+	writer := tabwriter.NewWriter(&table, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "VM\tCORES\tMEMORY\tHOST\tZONE\tREASON")
+	for i, result := range placements {
+		name := result.Hostname
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		host := result.HostName
+		if host == "" {
+			host = "-"
+		}
+		fmt.Fprintf(writer, "%s\t%d\t%d\t%s\t%s\t%s\n", name, result.Shape.Cores, result.Shape.Memory, host, result.Zone, result.Reason)
+	}
+	writer.Flush()
+
+	result := mcp.NewToolResultText(string(jsonData))
+	result.Content = append(result.Content, mcp.NewTextContent(table.String()))
+
+	return result, nil
+}
@@ -0,0 +1,121 @@
+package tools
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestExpandVMs(t *testing.T) {
+	shape := vmShape{Cores: 1, Memory: 1024}
+
+	tests := []struct {
+		name      string
+		requested []plannedVM
+		want      []string
+		err       string
+	}{
+		{name: "single", requested: []plannedVM{{vmShape: shape}}, want: []string{""}},
+		{name: "named copies", requested: []plannedVM{{vmShape: shape, Hostname: "web", Count: 3}}, want: []string{"web-1", "web-2", "web-3"}},
+		{name: "named single", requested: []plannedVM{{vmShape: shape, Hostname: "db", Count: 1}}, want: []string{"db"}},
+		{name: "at the limit", requested: []plannedVM{{vmShape: shape, Count: maxPlannedVMs - 1}, {vmShape: shape}}},
+		{name: "total above the limit", requested: []plannedVM{{vmShape: shape, Count: maxPlannedVMs}, {vmShape: shape}}, err: "at most"},
+		{name: "huge count", requested: []plannedVM{{vmShape: shape, Count: math.MaxInt32}}, err: "count"},
+		{name: "negative count", requested: []plannedVM{{vmShape: shape, Count: -1}}, err: "count"},
+		{name: "no memory", requested: []plannedVM{{vmShape: vmShape{Cores: 1}}}, err: "1 MiB"},
+		{name: "empty disk", requested: []plannedVM{{vmShape: vmShape{Cores: 1, Memory: 1, Disks: []vmDisk{{}}}}}, err: "disk"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			vms, err := expandVMs(test.requested)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("err = %v, want an error about %s", err, test.err)
+				}
+				if vms != nil {
+					t.Errorf("expanded %d VMs despite the error", len(vms))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if test.want == nil {
+				if len(vms) != maxPlannedVMs {
+					t.Errorf("expanded %d VMs, want %d", len(vms), maxPlannedVMs)
+				}
+				return
+			}
+
+			var hostnames []string
+			for _, vm := range vms {
+				hostnames = append(hostnames, vm.Hostname)
+				if vm.Count != 0 {
+					t.Errorf("expanded VM %s keeps count %d", vm.Hostname, vm.Count)
+				}
+			}
+			if fmt.Sprint(hostnames) != fmt.Sprint(test.want) {
+				t.Errorf("hostnames = %v, want %v", hostnames, test.want)
+			}
+		})
+	}
+}
+
+func testHosts() []vmHostCapacity {
+	host := func(name, zone string, cores, memory int) vmHostCapacity {
+		return vmHostCapacity{ID: name, Name: name, Zone: zone, Cores: cores, AvailableCores: cores, Memory: memory, AvailableMemory: memory, CPUOverCommit: 1, MemoryOverCommit: 1}
+	}
+
+	return []vmHostCapacity{
+		host("big", "az1", 16, 32768),
+		host("small", "az1", 4, 8192),
+		host("other", "az2", 8, 16384),
+	}
+}
+
+func TestPlace(t *testing.T) {
+	shape := vmShape{Cores: 2, Memory: 4096}
+
+	tests := []struct {
+		strategy string
+		want     []string
+	}{
+		{strategy: "bin-pack", want: []string{"small", "small", "other"}},
+		{strategy: "spread", want: []string{"big", "big", "other"}},
+		{strategy: "zone-aware", want: []string{"big", "other", "big"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.strategy, func(t *testing.T) {
+			planner := placementPlanner{strategy: test.strategy, hosts: testHosts(), zoneCount: make(map[string]int)}
+
+			var got []string
+			for range test.want {
+				result := planner.place(plannedVM{vmShape: shape})
+				if result.Reason == "" {
+					t.Error("placement without a reason")
+				}
+				got = append(got, result.HostName)
+			}
+
+			if fmt.Sprint(got) != fmt.Sprint(test.want) {
+				t.Errorf("hosts = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPlaceWithoutRoom(t *testing.T) {
+	planner := placementPlanner{strategy: "bin-pack", hosts: testHosts(), zoneCount: make(map[string]int)}
+
+	result := planner.place(plannedVM{vmShape: vmShape{Cores: 32, Memory: 1024}})
+	if result.HostID != "" {
+		t.Errorf("placed on %s, want no host", result.HostName)
+	}
+	if len(result.Rejected) != 3 {
+		t.Errorf("rejected = %v, want a reason for every host", result.Rejected)
+	}
+}